@@ -0,0 +1,92 @@
+package bluegopher
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// MissingAltText is an image of a post without alt text
+type MissingAltText struct {
+	URI       string `json:"uri"`
+	URL       string `json:"url"`
+	CreatedAt string `json:"createdAt"`
+	Text      string `json:"text"`
+	// Image is the position of the image in the post, from 1
+	Image    int    `json:"image"`
+	CID      string `json:"cid,omitempty"`
+	Fullsize string `json:"fullsize,omitempty"`
+}
+
+// AltTextAudit <actor> walks the posts of an author with images and outputs each image without alt text, with a
+// link to its post, then logs the share of images that have alt text. alt text of only whitespace counts as missing.
+func (Bs) AltTextAudit(ctx context.Context, actor string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	did, err := c.ResolveDID(ctx, actor)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	progress := StartProgress("bs:altTextAudit", c)
+	defer progress.Stop(&err)
+
+	posts, images, missing := 0, 0, 0
+	err = c.EachAuthorPost(ctx, did, p.LimitOr(100), "posts_with_media", func(post PostView) error {
+		embedded := postImages(&post)
+		if len(embedded) == 0 {
+			return nil
+		}
+		posts++
+		progress.Items(1)
+
+		for i, img := range embedded {
+			images++
+			if strings.TrimSpace(img.Alt) != "" {
+				continue
+			}
+			missing++
+			link, err := BskyURL(post.URI)
+			if err != nil {
+				return err
+			}
+			err = out.Emit(MissingAltText{
+				URI:       post.URI,
+				URL:       link,
+				CreatedAt: post.Record.CreatedAt,
+				Text:      post.Record.Text,
+				Image:     i + 1,
+				CID:       img.CID,
+				Fullsize:  img.URL,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if images == 0 {
+		log.Printf("%s has no posts with images\n", actor)
+		return nil
+	}
+	log.Printf("%d of %d images in %d posts have alt text (%.1f%%), %d are missing it\n", images-missing, images, posts, 100*float64(images-missing)/float64(images), missing)
+	return nil
+}
@@ -0,0 +1,371 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// analyticsTop is the number of hashtags and posts in the top lists of the analysis targets
+const analyticsTop = 10
+
+// hashtagPattern matches #hashtags at the start of the text or after whitespace. tags start with a letter, so
+// numbers such as #1 are not counted.
+var hashtagPattern = regexp.MustCompile(`(?:^|\s)#(\pL[\pL\pN_]*)`)
+
+// hashtags returns the lowercased hashtags of post text
+func hashtags(text string) []string {
+	var tags []string
+	for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tags = append(tags, strings.ToLower(match[1]))
+	}
+	return tags
+}
+
+// TermCount is a term of a frequency table and its number of occurrences
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// topTerms returns the n most frequent terms, ordered by count and then by term. n = 0 returns all terms.
+func topTerms(counts map[string]int, n int) []TermCount {
+	terms := make([]TermCount, 0, len(counts))
+	for term, count := range counts {
+		terms = append(terms, TermCount{Term: term, Count: count})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if n > 0 && len(terms) > n {
+		terms = terms[:n]
+	}
+	return terms
+}
+
+// EachAuthorPost calls fn with each post of an author feed, newest first, skipping reposts of other accounts' posts
+func (c *Client) EachAuthorPost(ctx context.Context, actor string, limit int, filter string, fn func(post PostView) error) error {
+	cursor := ""
+	for {
+		resp, err := c.GetAuthorFeed(ctx, actor, limit, cursor, filter, false)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range resp.Feed {
+			if len(item.Reason) > 0 {
+				continue
+			}
+			if err := fn(item.Post); err != nil {
+				return err
+			}
+		}
+
+		if resp.Cursor == "" || len(resp.Feed) == 0 {
+			return nil
+		}
+		cursor = resp.Cursor
+	}
+}
+
+// postTime returns the creation time of a post, falling back to the time it was indexed
+func postTime(post PostView) (time.Time, bool) {
+	for _, ts := range []string{post.Record.CreatedAt, post.IndexedAt} {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// PostEngagement is the engagement of a single post
+type PostEngagement struct {
+	URI       string `json:"uri"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"createdAt"`
+	Likes     int    `json:"likes"`
+	Reposts   int    `json:"reposts"`
+	Replies   int    `json:"replies"`
+	Quotes    int    `json:"quotes"`
+}
+
+// total returns the engagement count the top posts are ranked by
+func (e PostEngagement) total() int {
+	return e.Likes + e.Reposts + e.Replies + e.Quotes
+}
+
+// AuthorStats is the engagement summary of an author's posts
+type AuthorStats struct {
+	Actor string `json:"actor"`
+	// Posts counts the author's posts, including replies; Replies counts the posts that are replies
+	Posts     int    `json:"posts"`
+	Replies   int    `json:"replies"`
+	FirstPost string `json:"firstPost,omitempty"`
+	LastPost  string `json:"lastPost,omitempty"`
+	// PostsPerDay is the number of posts per day between the first and the last post
+	PostsPerDay float64 `json:"postsPerDay"`
+
+	// Likes, Reposts, RepliesReceived, and Quotes total the engagement of all posts
+	Likes           int     `json:"likes"`
+	Reposts         int     `json:"reposts"`
+	RepliesReceived int     `json:"repliesReceived"`
+	Quotes          int     `json:"quotes"`
+	AvgLikes        float64 `json:"avgLikes"`
+	AvgReposts      float64 `json:"avgReposts"`
+	AvgReplies      float64 `json:"avgReplies"`
+
+	// TimeZone is the time zone of the hour and weekday breakdowns
+	TimeZone  string         `json:"timeZone"`
+	ByHour    [24]int        `json:"postsByHour"`
+	ByWeekday map[string]int `json:"postsByWeekday"`
+
+	TopHashtags []TermCount      `json:"topHashtags"`
+	TopPosts    []PostEngagement `json:"topPosts"`
+
+	first, last time.Time
+	tags        map[string]int
+}
+
+// newAuthorStats returns an empty summary with breakdowns in loc
+func newAuthorStats(actor string, loc *time.Location) *AuthorStats {
+	s := &AuthorStats{
+		Actor:     actor,
+		TimeZone:  loc.String(),
+		ByWeekday: make(map[string]int),
+		tags:      make(map[string]int),
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		s.ByWeekday[day.String()] = 0
+	}
+	return s
+}
+
+// Add counts a post in the summary
+func (s *AuthorStats) Add(post PostView, loc *time.Location) {
+	s.Posts++
+	if post.Record.Reply != nil {
+		s.Replies++
+	}
+	s.Likes += post.LikeCount
+	s.Reposts += post.RepostCount
+	s.RepliesReceived += post.ReplyCount
+	s.Quotes += post.QuoteCount
+
+	if t, ok := postTime(post); ok {
+		if s.first.IsZero() || t.Before(s.first) {
+			s.first = t
+		}
+		if t.After(s.last) {
+			s.last = t
+		}
+		local := t.In(loc)
+		s.ByHour[local.Hour()]++
+		s.ByWeekday[local.Weekday().String()]++
+	}
+
+	for _, tag := range hashtags(post.Record.Text) {
+		s.tags[tag]++
+	}
+
+	s.TopPosts = append(s.TopPosts, PostEngagement{
+		URI:       post.URI,
+		Text:      post.Record.Text,
+		CreatedAt: post.Record.CreatedAt,
+		Likes:     post.LikeCount,
+		Reposts:   post.RepostCount,
+		Replies:   post.ReplyCount,
+		Quotes:    post.QuoteCount,
+	})
+	// keep the candidates bounded rather than holding every post of long histories
+	if len(s.TopPosts) > 4*analyticsTop {
+		s.trimTopPosts()
+	}
+}
+
+// trimTopPosts keeps the most engaging posts
+func (s *AuthorStats) trimTopPosts() {
+	sort.SliceStable(s.TopPosts, func(i, j int) bool {
+		return s.TopPosts[i].total() > s.TopPosts[j].total()
+	})
+	if len(s.TopPosts) > analyticsTop {
+		s.TopPosts = s.TopPosts[:analyticsTop]
+	}
+}
+
+// Finish computes the averages and top lists after the last post
+func (s *AuthorStats) Finish() {
+	if s.Posts > 0 {
+		s.AvgLikes = float64(s.Likes) / float64(s.Posts)
+		s.AvgReposts = float64(s.Reposts) / float64(s.Posts)
+		s.AvgReplies = float64(s.RepliesReceived) / float64(s.Posts)
+	}
+	if !s.first.IsZero() {
+		s.FirstPost = s.first.UTC().Format(time.RFC3339)
+		s.LastPost = s.last.UTC().Format(time.RFC3339)
+		days := s.last.Sub(s.first).Hours() / 24
+		if days < 1 {
+			days = 1
+		}
+		s.PostsPerDay = float64(s.Posts) / days
+	}
+	s.TopHashtags = topTerms(s.tags, analyticsTop)
+	s.trimTopPosts()
+	if s.TopPosts == nil {
+		s.TopPosts = []PostEngagement{}
+	}
+}
+
+// stopWords are common English words left out of word frequencies
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`a about after all also am an and any are as at be because been but by can could
+		did do does for from get got had has have he her here him his how i if in into is it its just like me more my no
+		not now of on one or our out so some than that the their them then there these they this to too up us was we
+		were what when which who will with would you your`) {
+		stopWords[word] = true
+	}
+}
+
+// Frequencies counts the hashtags, words, and mentions of post text
+type Frequencies struct {
+	Posts    int
+	Hashtags map[string]int
+	Words    map[string]int
+	Mentions map[string]int
+}
+
+// newFrequencies returns empty frequency tables
+func newFrequencies() *Frequencies {
+	return &Frequencies{
+		Hashtags: make(map[string]int),
+		Words:    make(map[string]int),
+		Mentions: make(map[string]int),
+	}
+}
+
+// Add counts the terms of a post's text. words shorter than three letters, stop words, links, hashtags, and mentions
+// are left out of the word table.
+func (f *Frequencies) Add(text string) {
+	f.Posts++
+	for _, tag := range hashtags(text) {
+		f.Hashtags[tag]++
+	}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		f.Mentions[strings.ToLower(match[1])]++
+	}
+
+	for _, field := range strings.Fields(text) {
+		if strings.HasPrefix(field, "#") || strings.HasPrefix(field, "@") || strings.Contains(field, "://") {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(strings.ToLower(field), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+		}) {
+			word = strings.Trim(word, "'")
+			if utf8.RuneCountInString(word) < 3 || stopWords[word] {
+				continue
+			}
+			f.Words[word]++
+		}
+	}
+}
+
+// postText returns the text of a JSONL post item: a feed item, a post view, or a post record
+func postText(line []byte) (string, bool, error) {
+	var item struct {
+		Text   *string `json:"text"`
+		Record *struct {
+			Text *string `json:"text"`
+		} `json:"record"`
+		Post *struct {
+			Record struct {
+				Text *string `json:"text"`
+			} `json:"record"`
+		} `json:"post"`
+	}
+	if err := json.Unmarshal(line, &item); err != nil {
+		return "", false, err
+	}
+
+	switch {
+	case item.Post != nil && item.Post.Record.Text != nil:
+		return *item.Post.Record.Text, true, nil
+	case item.Record != nil && item.Record.Text != nil:
+		return *item.Record.Text, true, nil
+	case item.Text != nil:
+		return *item.Text, true, nil
+	}
+	return "", false, nil
+}
+
+// EngagementCell is the engagement of the posts created in one hour of one weekday
+type EngagementCell struct {
+	Weekday string `json:"weekday"`
+	Hour    int    `json:"hour"`
+	Posts   int    `json:"posts"`
+	Likes   int    `json:"likes"`
+	Reposts int    `json:"reposts"`
+	// AvgLikes, AvgReposts, and AvgEngagement are per post; engagement is likes plus reposts
+	AvgLikes      float64 `json:"avgLikes"`
+	AvgReposts    float64 `json:"avgReposts"`
+	AvgEngagement float64 `json:"avgEngagement"`
+}
+
+// EngagementMatrix is the hour by weekday engagement of an author's posts
+type EngagementMatrix [7][24]EngagementCell
+
+// newEngagementMatrix returns an empty matrix, indexed by time.Weekday and hour
+func newEngagementMatrix() *EngagementMatrix {
+	var m EngagementMatrix
+	for day := range m {
+		for hour := range m[day] {
+			m[day][hour] = EngagementCell{Weekday: time.Weekday(day).String(), Hour: hour}
+		}
+	}
+	return &m
+}
+
+// Add counts a post in the cell of its creation time in loc
+func (m *EngagementMatrix) Add(post PostView, loc *time.Location) {
+	t, ok := postTime(post)
+	if !ok {
+		return
+	}
+	t = t.In(loc)
+
+	cell := &m[t.Weekday()][t.Hour()]
+	cell.Posts++
+	cell.Likes += post.LikeCount
+	cell.Reposts += post.RepostCount
+	cell.AvgLikes = float64(cell.Likes) / float64(cell.Posts)
+	cell.AvgReposts = float64(cell.Reposts) / float64(cell.Posts)
+	cell.AvgEngagement = float64(cell.Likes+cell.Reposts) / float64(cell.Posts)
+}
+
+// Best returns the n cells with posts and the highest average engagement
+func (m *EngagementMatrix) Best(n int) []EngagementCell {
+	var cells []EngagementCell
+	for day := range m {
+		for _, cell := range m[day] {
+			if cell.Posts > 0 {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	sort.SliceStable(cells, func(i, j int) bool {
+		return cells[i].AvgEngagement > cells[j].AvgEngagement
+	})
+	if len(cells) > n {
+		cells = cells[:n]
+	}
+	return cells
+}
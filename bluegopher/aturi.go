@@ -0,0 +1,101 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// bskyAppURL is the base URL of the bsky.app web client
+const bskyAppURL = "https://bsky.app"
+
+// urlCollections maps the path segment of bsky.app URLs to the collection of the record they show
+var urlCollections = map[string]string{
+	"post":  "app.bsky.feed.post",
+	"lists": "app.bsky.graph.list",
+	"feed":  "app.bsky.feed.generator",
+}
+
+// ATURI converts a bsky.app URL of a profile, post, list, feed, or starter pack to its AT URI, resolving the handle
+// to a DID. AT URIs are returned unchanged.
+func (c *Client) ATURI(ctx context.Context, rawURL string) (string, error) {
+	return resolveATURI(ctx, rawURL, c.ResolveDID)
+}
+
+// resolveATURI converts a bsky.app URL to its AT URI with the DID of the actor of the URL returned by resolve
+func resolveATURI(ctx context.Context, rawURL string, resolve func(ctx context.Context, actor string) (string, error)) (string, error) {
+	if strings.HasPrefix(rawURL, "at://") {
+		return rawURL, nil
+	}
+
+	// Remove any query parameters
+	parsedURL, err := url.Parse(strings.Split(rawURL, "?")[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	pathComponents := strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	var actor, collection, rkey string
+	switch {
+	case len(pathComponents) == 2 && pathComponents[0] == "profile":
+		actor = pathComponents[1]
+	case len(pathComponents) == 4 && pathComponents[0] == "profile" && urlCollections[pathComponents[2]] != "":
+		actor, collection, rkey = pathComponents[1], urlCollections[pathComponents[2]], pathComponents[3]
+	case len(pathComponents) == 3 && pathComponents[0] == "starter-pack":
+		actor, collection, rkey = pathComponents[1], "app.bsky.graph.starterpack", pathComponents[2]
+	default:
+		return "", fmt.Errorf("unsupported URL %q: expected a bsky.app profile, post, list, feed, or starter pack URL", rawURL)
+	}
+
+	did, err := resolve(ctx, actor)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", actor, err)
+	}
+
+	if collection == "" {
+		return "at://" + did, nil
+	}
+	return fmt.Sprintf("at://%s/%s/%s", did, collection, rkey), nil
+}
+
+// collectionATURI converts a bsky.app URL to an AT URI, checking that it refers to a record of the given collection
+func (c *Client) collectionATURI(ctx context.Context, rawURL, collection string) (string, error) {
+	uri, err := c.ATURI(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	_, uriCollection, _, err := splitATURI(uri)
+	if err != nil || uriCollection != collection {
+		return "", fmt.Errorf("invalid URL %q: not a %s", rawURL, collection)
+	}
+	return uri, nil
+}
+
+// BskyURL converts the AT URI of a profile, post, list, feed, or starter pack to its bsky.app URL
+func BskyURL(uri string) (string, error) {
+	if !strings.HasPrefix(uri, "at://") {
+		return "", fmt.Errorf("invalid AT URI %q", uri)
+	}
+
+	repo := strings.TrimPrefix(uri, "at://")
+	if !strings.Contains(repo, "/") {
+		return fmt.Sprintf("%s/profile/%s", bskyAppURL, repo), nil
+	}
+
+	repo, collection, rkey, err := splitATURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if collection == "app.bsky.graph.starterpack" {
+		return fmt.Sprintf("%s/starter-pack/%s/%s", bskyAppURL, repo, rkey), nil
+	}
+	for segment, c := range urlCollections {
+		if c == collection {
+			return fmt.Sprintf("%s/profile/%s/%s/%s", bskyAppURL, repo, segment, rkey), nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported AT URI %q: no bsky.app URL for %s", uri, collection)
+}
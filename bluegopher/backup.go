@@ -0,0 +1,413 @@
+package bluegopher
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the files of a backup, besides blobs/<cid>
+const (
+	backupManifestFile    = "manifest.json"
+	backupRepoFile        = "repo.car"
+	backupPreferencesFile = "preferences.json"
+)
+
+// BackupManifest describes an account backup: the account it was taken from, and the size and SHA-256 of every
+// other file of the backup
+type BackupManifest struct {
+	DID       string       `json:"did"`
+	Handle    string       `json:"handle"`
+	PDS       string       `json:"pds"`
+	Rev       string       `json:"rev"`
+	CreatedAt time.Time    `json:"createdAt"`
+	Files     []BackupFile `json:"files"`
+}
+
+// BackupFile is the checksum of a file of a backup, by its slash-separated path in the backup
+type BackupFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// GetPreferences retrieves the app.bsky preferences of the authenticated account, such as saved feeds and muted words
+func (c *Client) GetPreferences(ctx context.Context) (json.RawMessage, error) {
+	var response json.RawMessage
+	if err := c.GetJSON(ctx, "app.bsky.actor.getPreferences", nil, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// isTarGz reports whether a backup path names a gzipped tar archive rather than a directory
+func isTarGz(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// Backup <dir> backs up the authenticated account into a directory: the repository as repo.car, every blob under
+// blobs/, the preferences, and a manifest of checksums. blobs already in the directory that match their CIDs are not
+// downloaded again, and a dir ending in .tar.gz or .tgz is written as a gzipped tar archive instead, which can be an
+// s3:// or az:// URL.
+func (Bs) Backup(ctx context.Context, dir string) (err error) {
+	if isRemotePath(dir) && !isTarGz(dir) {
+		return fmt.Errorf("invalid backup URL %s: backups to cloud storage must be .tar.gz archives", dir)
+	}
+
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	target := dir
+	if isTarGz(dir) {
+		tmpDir := filepath.Dir(dir)
+		if isRemotePath(dir) {
+			tmpDir = ""
+		}
+		if target, err = os.MkdirTemp(tmpDir, ".backup-"); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		defer os.RemoveAll(target)
+	}
+	if err := os.MkdirAll(filepath.Join(target, "blobs"), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	progress := StartProgress("bs:backup", c)
+	defer progress.Stop(&err)
+
+	did := c.Session.DID
+	manifest := BackupManifest{DID: did, Handle: c.Session.Handle, PDS: c.BaseURL, CreatedAt: time.Now().UTC()}
+
+	repo, err := c.GetRepo(ctx, did)
+	if err != nil {
+		return fmt.Errorf("failed to export repository: %w", err)
+	}
+	car, err := readCAR(repo)
+	if err != nil {
+		return err
+	}
+	if err := car.Verify(); err != nil {
+		return fmt.Errorf("failed to verify repository: %w", err)
+	}
+	if len(car.Roots) > 0 {
+		if commit, err := car.Record(car.Roots[0]); err == nil {
+			manifest.Rev, _ = commit.(map[string]interface{})["rev"].(string)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(target, backupRepoFile), repo, 0644); err != nil {
+		return fmt.Errorf("failed to write repository: %w", err)
+	}
+	log.Printf("exported repository of %s at rev %s: %d blocks\n", did, manifest.Rev, len(car.Blocks))
+
+	preferences, err := c.GetPreferences(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to export preferences: %w", err)
+	}
+	b, err := json.MarshalIndent(preferences, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(target, backupPreferencesFile), b, 0644); err != nil {
+		return fmt.Errorf("failed to write preferences: %w", err)
+	}
+
+	limit := p.LimitOr(500)
+	cursor := ""
+	downloaded := 0
+	for {
+		blobsResponse, err := c.ListBlobs(ctx, did, "", limit, cursor)
+		if err != nil {
+			return err
+		}
+		progress.Page()
+
+		cids, _ := blobsResponse["cids"].([]interface{})
+		for _, item := range cids {
+			cid, ok := item.(string)
+			if !ok {
+				continue
+			}
+
+			saved, err := saveBlob(ctx, c, did, cid, filepath.Join(target, "blobs", cid))
+			if err != nil {
+				return err
+			}
+			if saved {
+				downloaded++
+				progress.Items(1)
+			}
+		}
+
+		next, _ := blobsResponse["cursor"].(string)
+		if next == "" || next == cursor || len(cids) == 0 {
+			break
+		}
+		cursor = next
+	}
+	log.Printf("downloaded %d blobs\n", downloaded)
+
+	if manifest.Files, err = checksumBackup(target); err != nil {
+		return err
+	}
+	if b, err = json.MarshalIndent(manifest, "", "  "); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(target, backupManifestFile), b, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if target != dir {
+		if err := writeTarGz(ctx, dir, target); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Backup of @%s written to %s successfully: %d files\n", manifest.Handle, dir, len(manifest.Files)+1)
+	return nil
+}
+
+// saveBlob downloads a blob into path unless the file already holds it, and reports whether it was downloaded. an
+// existing file that does not match the CID, such as one truncated by an earlier run, is downloaded again, and the blob
+// is written to a temporary file renamed into place only after it is verified.
+func saveBlob(ctx context.Context, c *Client, did, cid, path string) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && verifyBlob(cid, existing) == nil {
+		return false, nil
+	}
+
+	blob, err := c.GetBlob(ctx, did, cid)
+	if err != nil {
+		return false, fmt.Errorf("failed to download blob %s: %w", cid, err)
+	}
+	if err := verifyBlob(cid, blob); err != nil {
+		return false, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, blob, 0644); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("failed to write blob %s: %w", cid, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("failed to write blob %s: %w", cid, err)
+	}
+	return true, nil
+}
+
+// verifyBlob checks that a blob hashes to its CID
+func verifyBlob(cid string, blob []byte) error {
+	parsed, err := parseCID(cid)
+	if err != nil {
+		return err
+	}
+	return verifyCID(parsed, blob)
+}
+
+// checksumBackup returns the checksums of the files of a backup directory other than the manifest, sorted by path
+func checksumBackup(dir string) ([]BackupFile, error) {
+	var files []BackupFile
+	err := walkBackup(dir, func(name string, r io.Reader) error {
+		if name == backupManifestFile {
+			return nil
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files = append(files, BackupFile{Path: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, err
+}
+
+// walkBackup calls fn with the slash-separated path and content of every file of a backup directory or archive
+func walkBackup(backup string, fn func(name string, r io.Reader) error) error {
+	if !isTarGz(backup) {
+		return filepath.WalkDir(backup, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(backup, p)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return fn(filepath.ToSlash(rel), f)
+		})
+	}
+
+	f, err := os.Open(backup)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", backup, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", backup, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(path.Clean(header.Name), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeTarGz archives the files of a directory into a gzipped tar file, then renames it into place so an interrupted
+// backup does not leave a truncated archive. archives for s3:// and az:// URLs are uploaded as they are written, and
+// the upload is aborted when the archive fails.
+func writeTarGz(ctx context.Context, archive, dir string) (err error) {
+	tmp := archive
+	if !isRemotePath(archive) {
+		tmp = archive + ".tmp"
+		defer os.Remove(tmp)
+	}
+	f, err := createFile(ctx, tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			abortFile(f)
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = walkBackup(dir, func(name string, r io.Reader) error {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: name, Mode: 0644, Size: info.Size(), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, r)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if tmp == archive {
+		return nil
+	}
+	return os.Rename(tmp, archive)
+}
+
+// BackupVerify <path> checks a backup directory or .tar.gz archive written by bs:backup: every file must match its
+// checksum in the manifest, the repository must decode with every block matching its CID, and every blob must match
+// its CID. each problem is logged, and the target fails when any is found.
+func (Bs) BackupVerify(backup string) error {
+	var manifest *BackupManifest
+	found := make(map[string]BackupFile)
+	var problems []string
+
+	err := walkBackup(backup, func(name string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if name == backupManifestFile {
+			manifest = &BackupManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return fmt.Errorf("failed to parse manifest: %w", err)
+			}
+			return nil
+		}
+
+		sum := sha256.Sum256(data)
+		found[name] = BackupFile{Path: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+
+		switch {
+		case name == backupRepoFile:
+			car, err := readCAR(data)
+			if err == nil {
+				err = car.Verify()
+			}
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+		case strings.HasPrefix(name, "blobs/"):
+			if err := verifyBlob(path.Base(name), data); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return fmt.Errorf("%s is not a backup: %s is missing", backup, backupManifestFile)
+	}
+
+	for _, want := range manifest.Files {
+		got, ok := found[want.Path]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s: missing", want.Path))
+		case got.Size != want.Size || got.SHA256 != want.SHA256:
+			problems = append(problems, fmt.Sprintf("%s: checksum mismatch", want.Path))
+		}
+		delete(found, want.Path)
+	}
+	for name := range found {
+		problems = append(problems, fmt.Sprintf("%s: not in the manifest", name))
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		for _, problem := range problems {
+			log.Printf("%s\n", problem)
+		}
+		return fmt.Errorf("backup %s failed verification: %d problems", backup, len(problems))
+	}
+
+	fmt.Printf("Backup of @%s at rev %s verified successfully: %d files\n", manifest.Handle, manifest.Rev, len(manifest.Files)+1)
+	return nil
+}
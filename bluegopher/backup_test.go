@@ -0,0 +1,71 @@
+package bluegopher
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBlobCID returns the raw CIDv1 of a blob
+func testBlobCID(blob string) string {
+	sum := sha256.Sum256([]byte(blob))
+	return CID(append([]byte{0x01, 0x55, 0x12, 0x20}, sum[:]...)).String()
+}
+
+func TestSaveBlob(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+	cid := testBlobCID("png data")
+	path := filepath.Join(t.TempDir(), cid)
+
+	// a blob already saved is not downloaded again
+	os.WriteFile(path, []byte("png data"), 0644)
+	if saved, err := saveBlob(ctx, c, testDID, cid, path); err != nil || saved {
+		t.Fatalf("saveBlob of a saved blob = %t, %v", saved, err)
+	}
+	if n := len(pds.calls("com.atproto.sync.getBlob")); n != 0 {
+		t.Errorf("getBlob called %d times for a saved blob", n)
+	}
+
+	// a truncated blob is downloaded again, and a download that does not match the CID leaves the file as it was
+	os.WriteFile(path, []byte("png"), 0644)
+	pds.queue("com.atproto.sync.getBlob", fakeResponse{Status: 200, Body: "jpg data"})
+	if _, err := saveBlob(ctx, c, testDID, cid, path); err == nil {
+		t.Fatal("saveBlob of a mismatched download succeeded")
+	}
+	if b, _ := os.ReadFile(path); string(b) != "png" {
+		t.Errorf("blob after a mismatched download = %q, want it unchanged", b)
+	}
+
+	pds.queue("com.atproto.sync.getBlob", fakeResponse{Status: 200, Body: "png data"})
+	if saved, err := saveBlob(ctx, c, testDID, cid, path); err != nil || !saved {
+		t.Fatalf("saveBlob of a truncated blob = %t, %v", saved, err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "png data" {
+		t.Errorf("blob = %q, want png data", b)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestBackupBlobsRejectsInvalidCID(t *testing.T) {
+	pds := newFakePDS(t)
+	setTestEnv(t, pds)
+	dir := filepath.Join(t.TempDir(), "blobs")
+
+	// a CID that is not one cannot name a file outside the directory
+	pds.queue("com.atproto.sync.listBlobs", fakeResponse{Status: 200, Body: `{"cids":["../escaped"]}`})
+	if err := (Bs{}).BackupBlobs(context.Background(), testDID, dir); err == nil {
+		t.Fatal("BackupBlobs of an invalid CID succeeded")
+	}
+	if n := len(pds.calls("com.atproto.sync.getBlob")); n != 0 {
+		t.Errorf("getBlob called %d times for an invalid CID", n)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "escaped")); !os.IsNotExist(err) {
+		t.Errorf("blob written outside the directory: %v", err)
+	}
+}
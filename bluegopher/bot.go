@@ -0,0 +1,151 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// BotHandler handles a notification of a registered reason. an error is logged and the notification is not retried,
+// so one bad notification cannot stall the bot.
+type BotHandler func(ctx context.Context, e *BotEvent) error
+
+// Bot dispatches the new notifications of the authenticated account to handlers registered by reason. the runtime
+// polls with a NotificationWatcher, which refreshes the session and handles each notification once across restarts:
+//
+//	bot, err := NewBot(ctx, "mybot")
+//	if err != nil {
+//		return err
+//	}
+//	bot.On("mention", func(ctx context.Context, e *BotEvent) error {
+//		_, err := e.Reply(ctx, "hello @"+e.Author.Handle)
+//		return err
+//	})
+//	return bot.Run(ctx)
+type Bot struct {
+	Client *Client
+	// Name keys the saved state of the bot, so several bots can run for one account
+	Name string
+	// DryRun logs the replies instead of posting them
+	DryRun bool
+
+	params   Params
+	handlers map[string]BotHandler
+}
+
+// BotEvent is a notification passed to a BotHandler
+type BotEvent struct {
+	Notification
+	bot *Bot
+}
+
+// NewBot creates a bot for the account of the configured session. BG_INTERVAL sets the polling interval and
+// BG_DRY_RUN logs replies instead of posting them.
+func NewBot(ctx context.Context, name string) (*Bot, error) {
+	p, err := LoadParams()
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Bot{Client: c, Name: name, DryRun: p.DryRun, params: p, handlers: make(map[string]BotHandler)}, nil
+}
+
+// On registers the handler of a notification reason: mention, reply, quote, follow, like, or repost
+func (b *Bot) On(reason string, handle BotHandler) {
+	b.handlers[reason] = handle
+}
+
+// Run dispatches notifications until ctx is done. the notifications of the bot's own account are ignored, so a bot
+// replying to replies does not answer itself.
+func (b *Bot) Run(ctx context.Context) error {
+	if len(b.handlers) == 0 {
+		return fmt.Errorf("bot %s has no handlers", b.Name)
+	}
+
+	p := b.params
+	p.Reasons = nil
+	for reason := range b.handlers {
+		p.Reasons = append(p.Reasons, reason)
+	}
+	w, err := NewNotificationWatcher(b.Client, "bot:"+b.Name, p)
+	if err != nil {
+		return err
+	}
+	serveMetrics(ctx)
+
+	return w.Run(ctx, func(n Notification) error {
+		if n.Author.DID == b.Client.Session.DID {
+			return nil
+		}
+		log.Printf("%s: %s from @%s %s\n", b.Name, n.Reason, n.Author.Handle, n.URI)
+		if err := b.handlers[n.Reason](ctx, &BotEvent{Notification: n, bot: b}); err != nil {
+			log.Printf("%s: failed to handle %s: %v\n", b.Name, n.URI, err)
+		}
+		return nil
+	})
+}
+
+// Text returns the text of the post of a mention, reply, or quote
+func (e *BotEvent) Text() string {
+	var record PostRecord
+	json.Unmarshal(e.Record, &record)
+	return record.Text
+}
+
+// ParentDID returns the DID of the author of the post a reply notification answers, or "" for a post that is not a
+// reply
+func (e *BotEvent) ParentDID() string {
+	var record PostRecord
+	if err := json.Unmarshal(e.Record, &record); err != nil || record.Reply == nil {
+		return ""
+	}
+	repo, _, _, err := splitATURI(record.Reply.Parent.URI)
+	if err != nil {
+		return ""
+	}
+	return repo
+}
+
+// Reply replies to the post of a mention, reply, or quote, in the same thread
+func (e *BotEvent) Reply(ctx context.Context, text string) (StrongRef, error) {
+	var record PostRecord
+	if err := json.Unmarshal(e.Record, &record); err != nil || record.Type != "app.bsky.feed.post" {
+		return StrongRef{}, fmt.Errorf("cannot reply to a %s notification: it is not a post", e.Reason)
+	}
+
+	parent := StrongRef{URI: e.URI, CID: e.CID}
+	reply := &ReplyRef{Root: parent, Parent: parent}
+	if record.Reply != nil {
+		reply.Root = record.Reply.Root
+	}
+
+	if e.bot.DryRun {
+		log.Printf("%s: dry run, would reply to %s: %s\n", e.bot.Name, e.URI, text)
+		return StrongRef{}, nil
+	}
+	return e.bot.Client.Post(ctx, PostRecord{Text: text, Reply: reply})
+}
+
+// AutoReply <name> <text> runs a bot that replies with a fixed text to the mentions and replies of the account. the
+// name keys the state of the bot, so a restarted bot does not answer twice. replies to the posts of the account itself,
+// such as its own auto-replies, are not answered, so two bots cannot keep replying to each other.
+func (Bs) AutoReply(ctx context.Context, name, text string) error {
+	bot, err := NewBot(ctx, name)
+	if err != nil {
+		return err
+	}
+	reply := func(ctx context.Context, e *BotEvent) error {
+		if e.Reason == "reply" && e.ParentDID() == bot.Client.Session.DID {
+			return nil
+		}
+		_, err := e.Reply(ctx, text)
+		return err
+	}
+	bot.On("mention", reply)
+	bot.On("reply", reply)
+	return bot.Run(ctx)
+}
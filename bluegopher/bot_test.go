@@ -0,0 +1,22 @@
+package bluegopher
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBotEventParentDID(t *testing.T) {
+	for _, tt := range []struct {
+		record string
+		want   string
+	}{
+		{`{"$type":"app.bsky.feed.post","text":"hi"}`, ""},
+		{`{"$type":"app.bsky.feed.post","text":"hi","reply":{"root":{"uri":"at://did:plc:bob/app.bsky.feed.post/1","cid":"c"},"parent":{"uri":"at://did:plc:alice/app.bsky.feed.post/2","cid":"c"}}}`, "did:plc:alice"},
+		{`{"$type":"app.bsky.feed.post","reply":{"parent":{"uri":"not a uri"}}}`, ""},
+	} {
+		e := &BotEvent{Notification: Notification{Record: json.RawMessage(tt.record)}}
+		if got := e.ParentDID(); got != tt.want {
+			t.Errorf("ParentDID of %s = %q, want %q", tt.record, got, tt.want)
+		}
+	}
+}
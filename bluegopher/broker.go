@@ -0,0 +1,201 @@
+package bluegopher
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// brokerSessionRequest asks the session broker for the session of a profile. Expired is the access token a request
+// was rejected with, which the broker refreshes unless it already has.
+type brokerSessionRequest struct {
+	Profile string `json:"profile"`
+	Expired string `json:"expired,omitempty"`
+}
+
+// brokerSession is the session handed out by the broker, without its refresh token
+type brokerSession struct {
+	BaseURL string                `json:"baseURL"`
+	Session CreateSessionResponse `json:"session"`
+}
+
+// sessionBroker owns the sessions of the profiles and hands their access tokens to commands, so only the broker
+// creates and refreshes sessions
+type sessionBroker struct {
+	token   string
+	envOpts ClientOptions
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// client returns the authenticated client of a profile, creating its session on first use. the empty profile, and
+// the profile of the broker environment, use the credentials of the environment.
+func (b *sessionBroker) client(ctx context.Context, profile string) (*Client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if profile == "" {
+		profile = b.envOpts.Profile
+	}
+	if c, ok := b.clients[profile]; ok {
+		return c, nil
+	}
+
+	opts := b.envOpts
+	if profile != b.envOpts.Profile {
+		var err error
+		if opts, err = ClientOptionsForProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	// the broker keeps its own sessions rather than asking itself for them
+	opts.SessionBroker = ""
+	c, err := NewClientWithOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("session broker: authenticated profile %s as %s\n", profile, c.Session.Handle)
+	b.clients[profile] = c
+	return c, nil
+}
+
+// handler serves POST /session behind the bearer token of the broker
+func (b *sessionBroker) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /session", b.session)
+	api := &apiServer{token: b.token}
+	return api.authenticate(mux)
+}
+
+// session returns the session of a profile, refreshing it first when the command holds an expired access token or
+// the token is about to expire
+func (b *sessionBroker) session(w http.ResponseWriter, r *http.Request) {
+	var req brokerSessionRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	c, err := b.client(r.Context(), req.Profile)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+
+	token := c.accessToken()
+	if req.Expired == "" && !tokenValid(token, sessionExpiryMargin) {
+		req.Expired = token
+	}
+	if req.Expired != "" {
+		// refreshExpired refreshes once however many commands report the same expired token
+		if err := c.refreshExpired(r.Context(), req.Expired); err != nil {
+			writeUpstreamError(w, err)
+			return
+		}
+	}
+
+	c.mu.Lock()
+	session := c.Session
+	c.mu.Unlock()
+	session.RefreshJwt = ""
+	writeJSON(w, http.StatusOK, brokerSession{BaseURL: c.BaseURL, Session: session})
+}
+
+// brokerSession gets the session of the client profile from the session broker, reporting the access token that
+// expired when there is one
+func (c *Client) brokerSession(ctx context.Context, expired string) error {
+	b, err := json.Marshal(brokerSessionRequest{Profile: c.Profile, Expired: expired})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(c.broker, "/")+"/session", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create session broker request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.brokerToken)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach session broker: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read session broker response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("session broker failed with status code %d: %s", res.StatusCode, body)
+	}
+
+	var session brokerSession
+	if err := json.Unmarshal(body, &session); err != nil {
+		return fmt.Errorf("failed to unmarshal session broker response: %w", err)
+	}
+	if session.Session.AccessJwt == "" {
+		return fmt.Errorf("session broker returned no access token")
+	}
+
+	c.mu.Lock()
+	if c.BaseURL == "" {
+		c.BaseURL = session.BaseURL
+	}
+	c.mu.Unlock()
+	c.setSession(session.Session)
+	return nil
+}
+
+// SessionBroker <addr> owns the sessions of the account profiles and hands their access tokens to commands run with
+// BLUESKY_SESSION_BROKER set to its URL, until interrupted. only the broker creates and refreshes sessions, so
+// concurrent commands neither create a session each nor race to spend the same refresh token. requests need the
+// bearer token of BLUESKY_SESSION_BROKER_TOKEN, or the token logged at startup. a bare port such as 7788 listens on
+// 127.0.0.1, and non-loopback addresses need BLUESKY_SESSION_BROKER_PUBLIC=true.
+func (Bs) SessionBroker(ctx context.Context, addr string) error {
+	// the broker hands out access tokens to any holder of its bearer token
+	addr, err := loopbackListenAddr(addr, "BLUESKY_SESSION_BROKER_PUBLIC")
+	if err != nil {
+		return err
+	}
+	opts, err := ClientOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+
+	token := os.Getenv("BLUESKY_SESSION_BROKER_TOKEN")
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("failed to generate token: %w", err)
+		}
+		token = hex.EncodeToString(b)
+		log.Printf("BLUESKY_SESSION_BROKER_TOKEN is not set: commands need BLUESKY_SESSION_BROKER_TOKEN=%s\n", token)
+	}
+
+	b := &sessionBroker{token: token, envOpts: opts, clients: make(map[string]*Client)}
+	// the session of the environment is created up front so credential errors show at startup
+	if opts.Identifier != "" {
+		if _, err := b.client(ctx, ""); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("serving sessions on %s\n", addr)
+	if err := serveHTTP(ctx, addr, b.handler()); err != nil {
+		return err
+	}
+	fmt.Printf("session broker stopped successfully\n")
+	return nil
+}
@@ -0,0 +1,2534 @@
+package bluegopher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/magefile/mage/mg"
+)
+
+type Bs mg.Namespace
+
+// GetAuthorFeed <author> retrieves a single page of an author feed
+func (Bs) GetAuthorFeed(ctx context.Context, author string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	includePins := p.IncludePins
+	// posts_with_replies, posts_no_replies, posts_with_media, posts_and_author_threads
+	filter := p.Filter
+
+	resp, err := c.GetAuthorFeed(ctx, author, limit, cursor, filter, includePins)
+	if err != nil {
+		return err
+	}
+
+	return emitStdout(resp)
+}
+
+// GetAuthorFeeds <authors> retrieves the author feed
+func (Bs) GetAuthorFeeds(ctx context.Context, author string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	cp, err := OpenCheckpoint("bs:getAuthorFeeds", author)
+	if err != nil {
+		return err
+	}
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	if cp.State.Cursor != "" {
+		cursor = cp.State.Cursor
+	}
+	includePins := p.IncludePins
+	// posts_with_replies, posts_no_replies, posts_with_media, posts_and_author_threads
+	filter := p.Filter
+
+	for {
+		authorFeedResponse, err := c.GetAuthorFeed(ctx, author, limit, cursor, filter, includePins)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range authorFeedResponse.Feed {
+			if err := out.Emit(item); err != nil {
+				return err
+			}
+		}
+
+		if authorFeedResponse.Cursor == "" {
+			break
+		}
+		cursor = authorFeedResponse.Cursor
+		if err := cp.NextPage(cursor); err != nil {
+			return err
+		}
+	}
+
+	return cp.Done()
+}
+
+// GetProfiles <profiles> retrieves the profiles of multiple actors
+func (Bs) GetProfiles(ctx context.Context, profiles string) (err error) {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	actors := strings.Split(profiles, ",")
+	profilesResponse, err := c.GetProfiles(ctx, actors)
+	if err != nil {
+		return err
+	}
+
+	for _, profile := range profilesResponse.Profiles {
+		if err := out.Emit(profile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetFollowers <actor> retrieves the followers of a specified actor
+func (Bs) GetFollowers(ctx context.Context, actor string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	cp, err := OpenCheckpoint("bs:getFollowers", actor)
+	if err != nil {
+		return err
+	}
+
+	progress := StartProgress("bs:getFollowers", c)
+	defer progress.Stop(&err)
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	if cp.State.Cursor != "" {
+		cursor = cp.State.Cursor
+	}
+	for {
+		accountsResponse, err := c.GetAccounts(ctx, "/xrpc/app.bsky.graph.getFollowers", actor, limit, cursor)
+		if err != nil {
+			return err
+		}
+		progress.Page()
+
+		if val, ok := accountsResponse["followers"]; ok {
+			accounts, ok := val.([]interface{})
+			if !ok {
+				return fmt.Errorf("Cannot type assert followers to []interface{}")
+			}
+			for _, x := range accounts {
+				if err := out.Emit(x); err != nil {
+					return err
+				}
+			}
+			progress.Items(len(accounts))
+		}
+
+		val, ok := accountsResponse["cursor"]
+		if !ok {
+			break
+		}
+		cursor = val.(string)
+		if cursor == "" {
+			break
+		}
+		if err := cp.NextPage(cursor); err != nil {
+			return err
+		}
+	}
+	return cp.Done()
+}
+
+// GetFollows <actor> retrieves the followers of a specified actor
+func (Bs) GetFollows(ctx context.Context, actor string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	cp, err := OpenCheckpoint("bs:getFollows", actor)
+	if err != nil {
+		return err
+	}
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	if cp.State.Cursor != "" {
+		cursor = cp.State.Cursor
+	}
+	for {
+		accountsResponse, err := c.GetAccounts(ctx, "/xrpc/app.bsky.graph.getFollows", actor, limit, cursor)
+		if err != nil {
+			return err
+		}
+
+		if val, ok := accountsResponse["follows"]; ok {
+			accounts, ok := val.([]interface{})
+			if !ok {
+				return fmt.Errorf("Cannot type assert follows to []interface{}")
+			}
+			for _, x := range accounts {
+				if err := out.Emit(x); err != nil {
+					return err
+				}
+			}
+		}
+
+		val, ok := accountsResponse["cursor"]
+		if !ok {
+			break
+		}
+		cursor = val.(string)
+		if cursor == "" {
+			break
+		}
+		if err := cp.NextPage(cursor); err != nil {
+			return err
+		}
+	}
+	return cp.Done()
+}
+
+// CreateSession authenticates to the Bluesky API using the BLUESKY_HANDLE and BLUESKY_PASSWORD env vars
+func (Bs) CreateSession(ctx context.Context) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	createSessionResponse, err := c.CreateSession(ctx)
+	if err != nil {
+		return err
+	}
+	c.saveSession()
+
+	formattedResponse, err := json.MarshalIndent(createSessionResponse, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", formattedResponse)
+	return nil
+}
+
+// CreateRecord <text> creates a new post
+func (Bs) CreateRecord(ctx context.Context, text string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Create the record request
+	request := CreateRecordRequest{
+		Repo:       c.Session.Handle,
+		Collection: "app.bsky.feed.post",
+		Record: map[string]interface{}{
+			"text":      text,
+			"createdAt": time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	resp, err := c.CreateRecord(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	return emitStdout(resp)
+}
+
+// GetAuthorFeedsBulk <pageLimit> retrieves the author feed for a list of authors. page size is 100. pages = 0 for no limit.
+func (Bs) GetAuthorFeedsBulk(ctx context.Context, pageLimit int) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	cp, err := OpenCheckpoint("bs:getAuthorFeedsBulk", fmt.Sprint(pageLimit))
+	if err != nil {
+		return err
+	}
+
+	progress := StartProgress("bs:getAuthorFeedsBulk", c)
+	defer progress.Stop(&err)
+
+	failed := OpenFailureLog("bs:getAuthorFeedsBulk", fmt.Sprint(pageLimit))
+	defer failed.Close()
+
+	// authors are fetched by BG_CONCURRENCY workers, and the pages of each author in order by one worker. authors
+	// take turns writing in input order: the author whose turn it is writes each page as it is fetched and saves the
+	// cursor of the next one, while later authors keep their pages until their turn, so the posts of an author are
+	// never interleaved with those of another, and an interrupted run resumes from the last page written.
+	turns := newLineTurns(cp.State.Line)
+	first, resumeCursor, resumePage := cp.State.Line, cp.State.Cursor, cp.State.Page
+	fetch := func(ctx context.Context, line int, author string) error {
+		turn := turns.turn(line)
+		cursor, page := "", 1
+		if line == first {
+			cursor, page = resumeCursor, resumePage
+		}
+
+		var pending []FeedViewPost
+		write := func(items []FeedViewPost) error {
+			for _, item := range append(pending, items...) {
+				if err := out.Emit(item); err != nil {
+					return err
+				}
+			}
+			pending = nil
+			return nil
+		}
+		emit := func(items []FeedViewPost, next string, nextPage int) error {
+			select {
+			case <-turn:
+			default:
+				pending = append(pending, items...)
+				return nil
+			}
+			if err := write(items); err != nil {
+				return err
+			}
+			if next == "" {
+				return nil
+			}
+			cp.State.Cursor, cp.State.Page = next, nextPage
+			return cp.Save()
+		}
+
+		if err := getAuthorFeedPages(ctx, c, progress, p, author, pageLimit, cursor, page, emit); err != nil {
+			// failed authors are recorded and skipped unless the run was interrupted
+			if ctx.Err() != nil {
+				return err
+			}
+			if err := failed.Record(author, err); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-turn:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err := write(nil); err != nil {
+			return err
+		}
+		if err := cp.NextLine(); err != nil {
+			return err
+		}
+		turns.done(line)
+		return nil
+	}
+	if err := forEachLine(ctx, os.Stdin, cp.State.Line, p.Concurrency, fetch, nil); err != nil {
+		return err
+	}
+
+	return cp.Done()
+}
+
+// getAuthorFeedPages fetches up to pageLimit pages of an author feed from cursor, the page-th page, passing the
+// items of each page to emit with the cursor and number of the next page. pages = 0 for no limit.
+func getAuthorFeedPages(ctx context.Context, c *Client, progress *Progress, p Params, author string, pageLimit int, cursor string, page int, emit func(items []FeedViewPost, next string, nextPage int) error) error {
+	limit := p.LimitOr(100)
+	for {
+		log.Printf("author: %s | page: %d\n", author, page)
+		authorFeedResponse, err := c.GetAuthorFeed(ctx, author, limit, cursor, p.Filter, p.IncludePins)
+		if err != nil {
+			return err
+		}
+		progress.Page()
+		progress.Items(len(authorFeedResponse.Feed))
+
+		cursor = authorFeedResponse.Cursor
+		page++
+		// if pages = 0, skip limit
+		if page > pageLimit && pageLimit != 0 {
+			cursor = ""
+		}
+		if err := emit(authorFeedResponse.Feed, cursor, page); err != nil {
+			return err
+		}
+		if cursor == "" {
+			return nil
+		}
+	}
+}
+
+// GetProfilesBulk retrieves the profiles of multiple actors from standard input
+func (Bs) GetProfilesBulk(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	cp, err := OpenCheckpoint("bs:getProfilesBulk")
+	if err != nil {
+		return err
+	}
+
+	// todo: loop through items vs appending to a single list
+	scanner := bufio.NewScanner(os.Stdin)
+	var actors []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		actors = append(actors, strings.Split(line, ",")...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read from stdin: %w", err)
+	}
+
+	progress := StartProgress("bs:getProfilesBulk", c)
+	defer progress.Stop(&err)
+
+	failed := OpenFailureLog("bs:getProfilesBulk")
+	defer failed.Close()
+
+	// batches are fetched by up to BG_CONCURRENCY goroutines sharing the client rate limiter, and emitted in input order
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type batchResult struct {
+		actors   []string
+		end      int
+		profiles []Profile
+		err      error
+	}
+	batchSize := 25
+	sem := make(chan struct{}, p.Concurrency)
+	results := make(chan chan batchResult, p.Concurrency)
+	go func() {
+		defer close(results)
+		// resume after the actors completed by a previous run
+		for i := cp.State.Line; i < len(actors); i += batchSize {
+			end := i + batchSize
+			if end > len(actors) {
+				end = len(actors)
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			res := make(chan batchResult, 1)
+			select {
+			case results <- res:
+			case <-ctx.Done():
+				return
+			}
+			go func(batch []string) {
+				defer func() { <-sem }()
+				profiles, err := getProfilesBatch(ctx, c, batch)
+				res <- batchResult{actors: batch, end: end, profiles: profiles, err: err}
+			}(actors[i:end])
+		}
+	}()
+
+	for res := range results {
+		batch := <-res
+		if batch.err != nil {
+			// the actors of a failed batch are recorded and skipped unless the run was interrupted
+			if ctx.Err() != nil {
+				return batch.err
+			}
+			for _, actor := range batch.actors {
+				if err := failed.Record(actor, batch.err); err != nil {
+					return err
+				}
+			}
+		} else {
+			progress.Page()
+		}
+
+		for _, item := range batch.profiles {
+			if err := out.Emit(item); err != nil {
+				return err
+			}
+		}
+		progress.Items(len(batch.profiles))
+
+		cp.State.Line = batch.end
+		if err := cp.Save(); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cp.Done()
+}
+
+// getProfilesBatch retrieves the profiles of up to 25 actors
+func getProfilesBatch(ctx context.Context, c *Client, actors []string) ([]Profile, error) {
+	profilesResponse, err := c.GetProfiles(ctx, actors)
+	if err != nil {
+		return nil, err
+	}
+
+	return profilesResponse.Profiles, nil
+}
+
+// SearchPosts <query> searches posts and outputs the first page
+func (Bs) SearchPosts(ctx context.Context, query string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	sort := p.Sort
+	since := p.Since
+	until := p.Until
+	mentions := p.Mentions
+	author := p.Author
+	lang := p.Lang
+	domain := p.Domain
+	url := p.URL
+	tags := p.Tags
+
+	resp, err := c.SearchPosts(ctx, query, limit, cursor, sort, since, until, mentions, author, lang, domain, url, tags)
+	if err != nil {
+		return err
+	}
+
+	return emitStdout(resp)
+}
+
+// SearchPostsBulk <pageLimit> <query> searches posts and outputs multiple pages, filtered with the BG_RULES filter
+// rules
+func (Bs) SearchPostsBulk(ctx context.Context, pageLimit int, query string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	rules, err := LoadRules()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	cp, err := OpenCheckpoint("bs:searchPostsBulk", fmt.Sprint(pageLimit), query)
+	if err != nil {
+		return err
+	}
+
+	progress := StartProgress("bs:searchPostsBulk", c)
+	defer progress.Stop(&err)
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	if cp.State.Cursor != "" {
+		cursor = cp.State.Cursor
+	}
+	page := cp.State.Page
+
+	for {
+		log.Printf("page: %d\n", page)
+		searchResponse, err := c.SearchPosts(
+			ctx,        // ctx
+			query,      // q
+			limit,      // limit
+			cursor,     // cursor
+			p.Sort,     // sort
+			p.Since,    // since
+			p.Until,    // until
+			p.Mentions, // mentions
+			p.Author,   // author
+			p.Lang,     // lang
+			p.Domain,   // domain
+			p.URL,      // postURL
+			p.Tags,     // tags
+		)
+		if err != nil {
+			return err
+		}
+		progress.Page()
+
+		emitted := 0
+		for _, item := range searchResponse.Posts {
+			if !rules.MatchItem(item) {
+				continue
+			}
+			if err := out.Emit(item); err != nil {
+				return err
+			}
+			emitted++
+		}
+		progress.Items(emitted)
+
+		if searchResponse.Cursor == "" {
+			break
+		}
+		cursor = searchResponse.Cursor
+
+		page++
+		if page > pageLimit && pageLimit != 0 {
+			break
+		}
+		if err := cp.NextPage(cursor); err != nil {
+			return err
+		}
+	}
+
+	return cp.Done()
+}
+
+// ListCreate <name> <description> creates a new list
+func (Bs) ListCreate(ctx context.Context, name, description string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	purpose := p.ListPurpose
+	createdAt := time.Now().UTC()
+	resp, err := c.ListCreate(ctx, purpose, name, description, createdAt)
+	if err != nil {
+		return err
+	}
+
+	return emitStdout(resp)
+}
+
+// GetProfile <actor> retrieves the profile for a given actor and prints the profile data
+func (Bs) GetProfile(ctx context.Context, actor string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	profile, err := c.GetProfile(ctx, actor)
+	if err != nil {
+		return err
+	}
+
+	return emitStdout(profile)
+}
+
+// ListItem <listURL> <actor> adds an actor to a list by its URL
+func (Bs) ListItem(ctx context.Context, listURL, actor string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Retrieve the profile data to get the DID
+	profile, err := c.GetProfile(ctx, actor)
+	if err != nil {
+		return err
+	}
+
+	did := profile.DID
+	if did == "" {
+		return fmt.Errorf("failed to get DID from profile")
+	}
+
+	// Convert listURL to AT URI
+	atURI, err := c.ListATURI(ctx, listURL)
+	if err != nil {
+		return err
+	}
+
+	// Add the actor to the list
+	createdAt := time.Now().UTC()
+	resp, err := c.ListItem(ctx, atURI, did, createdAt)
+	if err != nil {
+		return err
+	}
+
+	// Print the response
+	return emitStdout(resp)
+}
+
+// ListItemBulk <listURL> reads accounts from standard input and adds them to the list. lines are JSON objects with a did or handle, or a DID or handle.
+func (Bs) ListItemBulk(ctx context.Context, listURL string) (err error) {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	cp, err := OpenCheckpoint("bs:listItemBulk", listURL)
+	if err != nil {
+		return err
+	}
+
+	failed := OpenFailureLog("bs:listItemBulk", listURL)
+	defer failed.Close()
+
+	// Convert listURL to AT URI
+	atURI, err := c.ListATURI(ctx, listURL)
+	if err != nil {
+		return err
+	}
+
+	// skip the accounts already in the list rather than creating duplicate listitem records
+	members, err := c.listMembers(ctx, atURI)
+	if err != nil {
+		return fmt.Errorf("failed to list current members: %w", err)
+	}
+	log.Printf("list has %d members\n", len(members))
+
+	progress := StartProgress("bs:listItemBulk", c)
+	defer progress.Stop(&err)
+
+	added, skipped := 0, 0
+	scanner := bufio.NewScanner(os.Stdin)
+	lineNum := 0
+	for scanner.Scan() {
+		// stop on Ctrl-C rather than failing every remaining line
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// skip lines completed by a previous run, then record the lines completed so far
+		lineNum++
+		if lineNum <= cp.State.Line {
+			continue
+		}
+		cp.State.Line = lineNum - 1
+		if err := cp.Save(); err != nil {
+			return err
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		did, err := c.resolveActorLine(ctx, line)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			if err := failed.Record(line, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		log.Printf("actor: %s\n", did)
+
+		if _, ok := members[did]; ok {
+			skipped++
+			continue
+		}
+
+		// Add the actor to the list
+		createdAt := time.Now().UTC()
+		resp, err := c.ListItem(ctx, atURI, did, createdAt)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			if err := failed.Record(line, fmt.Errorf("failed to add DID %s to list: %w", did, err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Print the response
+		b, err := json.Marshal(resp)
+		if err != nil {
+			return fmt.Errorf("failed to marshal response for DID %s: %w", did, err)
+		}
+		fmt.Printf("Added DID %s to list: %s\n", did, b)
+		uri, _ := resp["uri"].(string)
+		members[did] = append(members[did], uri)
+		added++
+		progress.Items(1)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading standard input: %w", err)
+	}
+
+	log.Printf("added %d accounts, skipped %d already in the list\n", added, skipped)
+
+	return cp.Done()
+}
+
+// DmList lists the conversations of the authenticated user. requires an app password with DM access.
+func (Bs) DmList(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	for {
+		convosResponse, err := c.ListConvos(ctx, limit, cursor)
+		if err != nil {
+			return err
+		}
+
+		if convos, ok := convosResponse["convos"].([]interface{}); ok {
+			for _, item := range convos {
+				if err := out.Emit(item); err != nil {
+					return err
+				}
+			}
+		}
+
+		if nextCursor, ok := convosResponse["cursor"].(string); ok && nextCursor != "" {
+			cursor = nextCursor
+		} else {
+			break
+		}
+	}
+
+	return nil
+}
+
+// DmHistory <convoId> retrieves every message in a conversation, newest first
+func (Bs) DmHistory(ctx context.Context, convoID string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	for {
+		messagesResponse, err := c.GetMessages(ctx, convoID, limit, cursor)
+		if err != nil {
+			return err
+		}
+
+		if messages, ok := messagesResponse["messages"].([]interface{}); ok {
+			for _, item := range messages {
+				if err := out.Emit(item); err != nil {
+					return err
+				}
+			}
+		}
+
+		if nextCursor, ok := messagesResponse["cursor"].(string); ok && nextCursor != "" {
+			cursor = nextCursor
+		} else {
+			break
+		}
+	}
+
+	return nil
+}
+
+// DmSend <handle> <text> sends a direct message to an actor
+func (Bs) DmSend(ctx context.Context, handle, text string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	profile, err := c.GetProfile(ctx, handle)
+	if err != nil {
+		return err
+	}
+
+	did := profile.DID
+	if did == "" {
+		return fmt.Errorf("failed to get DID from profile")
+	}
+
+	convoResponse, err := c.GetConvoForMembers(ctx, []string{did})
+	if err != nil {
+		return err
+	}
+
+	convo, ok := convoResponse["convo"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("convo not found in response")
+	}
+	convoID, ok := convo["id"].(string)
+	if !ok {
+		return fmt.Errorf("failed to get convo id")
+	}
+
+	resp, err := c.SendMessage(ctx, convoID, text)
+	if err != nil {
+		return err
+	}
+
+	return emitStdout(resp)
+}
+
+// QueryLabels <uriPatterns> retrieves the labels applied to comma-separated subject URIs or DIDs. BLUESKY_LABELERS limits the sources.
+func (Bs) QueryLabels(ctx context.Context, uriPatterns string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	// labeler DIDs may carry parameters, e.g. did:plc:xyz;redact
+	var sources []string
+	for _, labeler := range c.Labelers {
+		sources = append(sources, strings.Split(labeler, ";")[0])
+	}
+	// a single labeler is queried directly through the PDS rather than via the AppView
+	if len(sources) == 1 {
+		ctx = WithProxy(ctx, LabelerProxy(sources[0]))
+	}
+
+	patterns := strings.Split(uriPatterns, ",")
+	limit := p.LimitOr(250)
+	cursor := p.Cursor
+	for {
+		labelsResponse, err := c.QueryLabels(ctx, patterns, sources, limit, cursor)
+		if err != nil {
+			return err
+		}
+
+		if labels, ok := labelsResponse["labels"].([]interface{}); ok {
+			for _, item := range labels {
+				if err := out.Emit(item); err != nil {
+					return err
+				}
+			}
+		}
+
+		if nextCursor, ok := labelsResponse["cursor"].(string); ok && nextCursor != "" {
+			cursor = nextCursor
+		} else {
+			break
+		}
+	}
+
+	return nil
+}
+
+// BackupBlobs <actor> <dir> downloads every blob for an account into dir, one file per CID. blobs are verified against
+// their CIDs, and existing files that already match are skipped.
+func (Bs) BackupBlobs(ctx context.Context, actor, dir string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	did, err := c.ResolveDID(ctx, actor)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	limit := p.LimitOr(500)
+	cursor := p.Cursor
+	page := 1
+	for {
+		log.Printf("did: %s | page: %d\n", did, page)
+		blobsResponse, err := c.ListBlobs(ctx, did, "", limit, cursor)
+		if err != nil {
+			return err
+		}
+
+		cids, _ := blobsResponse["cids"].([]interface{})
+		for _, item := range cids {
+			cid, ok := item.(string)
+			if !ok {
+				continue
+			}
+
+			// the CID names the file, so one that does not parse could point outside dir
+			parsed, err := parseCID(cid)
+			if err != nil {
+				return fmt.Errorf("invalid blob CID from %s: %w", did, err)
+			}
+			path := filepath.Join(dir, parsed.String())
+			saved, err := saveBlob(ctx, c, did, cid, path)
+			if err != nil {
+				return err
+			}
+			if !saved {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+
+			if err := out.Emit(map[string]interface{}{
+				"cid":  cid,
+				"path": path,
+				"size": info.Size(),
+			}); err != nil {
+				return err
+			}
+		}
+
+		if nextCursor, ok := blobsResponse["cursor"].(string); ok && nextCursor != "" && len(cids) > 0 {
+			cursor = nextCursor
+		} else {
+			break
+		}
+		page++
+	}
+
+	return nil
+}
+
+// ListRepos streams every repository (did, head, rev, active) hosted on the PDS
+func (Bs) ListRepos(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	limit := p.LimitOr(1000)
+	cursor := p.Cursor
+	for {
+		reposResponse, err := c.ListRepos(ctx, limit, cursor)
+		if err != nil {
+			return err
+		}
+
+		repos, _ := reposResponse["repos"].([]interface{})
+		for _, item := range repos {
+			if err := out.Emit(item); err != nil {
+				return err
+			}
+		}
+
+		if nextCursor, ok := reposResponse["cursor"].(string); ok && nextCursor != "" && len(repos) > 0 {
+			cursor = nextCursor
+		} else {
+			break
+		}
+	}
+
+	return nil
+}
+
+// UpdateHandle <handle> changes the account handle, waiting for custom-domain DNS or well-known verification to propagate
+func (Bs) UpdateHandle(ctx context.Context, handle string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	attempts := 30
+	delay := 10 * time.Second
+
+	customDomain := !strings.HasSuffix(handle, ".bsky.social")
+	if customDomain {
+		log.Printf("verify %s with a DNS TXT record _atproto.%s \"did=%s\" or https://%s/.well-known/atproto-did\n", handle, handle, c.Session.DID, handle)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = c.UpdateHandle(ctx, handle)
+		if err == nil {
+			break
+		}
+		if !customDomain || attempt >= attempts {
+			return fmt.Errorf("failed to update handle: %w", err)
+		}
+		log.Printf("handle not verified yet (attempt %d/%d): %v\n", attempt, attempts, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		did, err := c.ResolveHandle(ctx, handle)
+		if err == nil && did == c.Session.DID {
+			break
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("handle %s updated but does not resolve to %s yet", handle, c.Session.DID)
+		}
+		log.Printf("waiting for %s to resolve (attempt %d/%d)\n", handle, attempt, attempts)
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	return emitStdout(map[string]string{
+		"did":    c.Session.DID,
+		"handle": handle,
+	})
+}
+
+// GetServiceAuth <aud> <lxm> mints a service auth token for a service DID, such as did:web:video.bsky.app, and a lexicon
+// method. lxm may be empty.
+func (Bs) GetServiceAuth(ctx context.Context, aud, lxm string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the PDS only allows long-lived tokens when they are bound to a method
+	exp := time.Now().Add(30 * time.Minute)
+	if lxm == "" {
+		exp = time.Now().Add(60 * time.Second)
+	}
+	token, err := c.GetServiceAuth(ctx, aud, exp, lxm)
+	if err != nil {
+		return err
+	}
+
+	return emitStdout(map[string]interface{}{
+		"aud":   aud,
+		"lxm":   lxm,
+		"exp":   exp.UTC().Format(time.RFC3339),
+		"token": token,
+	})
+}
+
+// GetPopularFeedGenerators <pageLimit> retrieves popular feed generators. page size is 100. pages = 0 for no limit.
+func (Bs) GetPopularFeedGenerators(ctx context.Context, pageLimit int) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	limit := p.LimitOr(100)
+	cursor := p.Cursor
+	page := 1
+	for {
+		log.Printf("page: %d\n", page)
+		feedsResponse, err := c.GetPopularFeedGenerators(ctx, p.Query, limit, cursor)
+		if err != nil {
+			return err
+		}
+
+		if feeds, ok := feedsResponse["feeds"].([]interface{}); ok {
+			for _, item := range feeds {
+				if err := out.Emit(item); err != nil {
+					return err
+				}
+			}
+		}
+
+		if nextCursor, ok := feedsResponse["cursor"].(string); ok && nextCursor != "" {
+			cursor = nextCursor
+		} else {
+			break
+		}
+
+		page++
+		if page > pageLimit && pageLimit != 0 {
+			break
+		}
+	}
+
+	return nil
+}
+
+// GetTrendingTopics retrieves the current trending topics, then the suggested topics, one per line
+func (Bs) GetTrendingTopics(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	topicsResponse, err := c.GetTrendingTopics(ctx, p.LimitOr(25))
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range []string{"topics", "suggested"} {
+		topics, _ := topicsResponse[kind].([]interface{})
+		for _, item := range topics {
+			topic, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			topic["kind"] = kind
+
+			if err := out.Emit(topic); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetFeedGenerator <feed> prints the did, creator, displayName, likeCount, and online status of a feed generator by AT URI or bsky.app URL
+func (Bs) GetFeedGenerator(ctx context.Context, feed string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	feedURI, err := c.FeedATURI(ctx, feed)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.GetFeedGenerator(ctx, feedURI)
+	if err != nil {
+		return err
+	}
+
+	view, ok := resp["view"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("feed generator view not found in response")
+	}
+
+	info := map[string]interface{}{
+		"uri":         view["uri"],
+		"did":         view["did"],
+		"displayName": view["displayName"],
+		"description": view["description"],
+		"likeCount":   view["likeCount"],
+		"indexedAt":   view["indexedAt"],
+		"isOnline":    resp["isOnline"],
+		"isValid":     resp["isValid"],
+	}
+	if creator, ok := view["creator"].(map[string]interface{}); ok {
+		info["creator"] = creator["handle"]
+	}
+
+	return emitStdout(info)
+}
+
+// GetFeedGenerators <feeds> retrieves the views of comma-separated feed generators by AT URI or bsky.app URL
+func (Bs) GetFeedGenerators(ctx context.Context, feeds string) (err error) {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	var feedURIs []string
+	for _, feed := range strings.Split(feeds, ",") {
+		feedURI, err := c.FeedATURI(ctx, strings.TrimSpace(feed))
+		if err != nil {
+			return err
+		}
+		feedURIs = append(feedURIs, feedURI)
+	}
+
+	resp, err := c.GetFeedGenerators(ctx, feedURIs)
+	if err != nil {
+		return err
+	}
+
+	views, _ := resp["feeds"].([]interface{})
+	for _, item := range views {
+		if err := out.Emit(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Profiles lists the account profiles in the config file. select one with BG_PROFILE.
+func (Bs) Profiles() (err error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	for _, name := range names {
+		profile := cfg.Profiles[name]
+		if err := out.Emit(map[string]interface{}{
+			"profile": name,
+			"handle":  profile.Handle,
+			"pdsHost": profile.PDSHost,
+			"default": name == cfg.DefaultProfile,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RetryFailed <file> re-runs the inputs recorded in a .failed file by a bulk target. inputs that fail again are
+// written back to the file.
+func (Bs) RetryFailed(ctx context.Context, file string) error {
+	failures, err := readFailures(file)
+	if err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		log.Printf("no failed inputs in %s\n", file)
+		return nil
+	}
+
+	// a command that cannot be replayed would fail the retry part way through
+	for _, failure := range failures {
+		if !bulkTargets[failure.Command] {
+			return fmt.Errorf("cannot retry %s: not a bulk target", failure.Command)
+		}
+	}
+
+	// keep the failures until every run has finished, so an interrupted retry loses nothing
+	retrying := file + ".retrying"
+	if err := os.Rename(file, retrying); err != nil {
+		return fmt.Errorf("failed to move %s: %w", file, err)
+	}
+	os.Setenv("BG_FAILED", file)
+	// the retried inputs are a new run, not a continuation of the saved one
+	os.Setenv("BG_RESUME", "false")
+
+	// group the inputs by command and arguments, in file order
+	type run struct {
+		command  string
+		args     []string
+		failures []Failure
+	}
+	var runs []*run
+	byKey := make(map[string]*run)
+	for _, failure := range failures {
+		key := failure.Command + "\x00" + strings.Join(failure.Args, "\x00")
+		r, ok := byKey[key]
+		if !ok {
+			r = &run{command: failure.Command, args: failure.Args}
+			byKey[key] = r
+			runs = append(runs, r)
+		}
+		r.failures = append(r.failures, failure)
+	}
+
+	for i, r := range runs {
+		inputs := make([]string, len(r.failures))
+		for j, failure := range r.failures {
+			inputs[j] = failure.Input
+		}
+		log.Printf("retrying %d inputs of %s %s\n", len(inputs), r.command, strings.Join(r.args, " "))
+		err := withStdin(inputs, func() error {
+			return runBulk(ctx, r.command, r.args)
+		})
+		if err != nil {
+			// put the inputs of this run and the runs not started back, so the retry can be run again
+			var pending []Failure
+			for _, r := range runs[i:] {
+				pending = append(pending, r.failures...)
+			}
+			if err := appendFailures(file, pending); err != nil {
+				log.Printf("%v, the inputs are kept in %s\n", err, retrying)
+			} else {
+				os.Remove(retrying)
+			}
+			return err
+		}
+	}
+
+	return os.Remove(retrying)
+}
+
+// bulkTargets are the commands runBulk can re-run
+var bulkTargets = map[string]bool{
+	"bs:getAuthorFeedsBulk": true,
+	"bs:getProfilesBulk":    true,
+	"bs:followBulk":         true,
+	"bs:blockBulk":          true,
+	"bs:scoreProfiles":      true,
+	"bs:listItemBulk":       true,
+}
+
+// runBulk runs a bulk target by its command name
+func runBulk(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "bs:getAuthorFeedsBulk":
+		pageLimit := 0
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid pageLimit %q: %w", args[0], err)
+			}
+			pageLimit = n
+		}
+		return Bs{}.GetAuthorFeedsBulk(ctx, pageLimit)
+	case "bs:getProfilesBulk":
+		return Bs{}.GetProfilesBulk(ctx)
+	case "bs:followBulk":
+		return Bs{}.FollowBulk(ctx)
+	case "bs:blockBulk":
+		return Bs{}.BlockBulk(ctx)
+	case "bs:scoreProfiles":
+		return Bs{}.ScoreProfiles(ctx)
+	case "bs:listItemBulk":
+		if len(args) != 1 {
+			return fmt.Errorf("%s requires a list URL", command)
+		}
+		return Bs{}.ListItemBulk(ctx, args[0])
+	default:
+		return fmt.Errorf("cannot retry %s: not a bulk target", command)
+	}
+}
+
+// withStdin runs fn with standard input replaced by the given lines
+func withStdin(lines []string, fn func() error) error {
+	f, err := os.CreateTemp("", "bg-stdin-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	return fn()
+}
+
+// FollowBulk follows the accounts read from standard input. lines are JSON objects with a did or handle, such as the
+// output of bs:getFollowers, or a DID or handle. accounts already followed are skipped. BG_DRY_RUN=true only reports
+// the accounts that would be followed.
+func (Bs) FollowBulk(ctx context.Context) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	cp, err := OpenCheckpoint("bs:followBulk")
+	if err != nil {
+		return err
+	}
+
+	failed := OpenFailureLog("bs:followBulk")
+	defer failed.Close()
+
+	return graphBulk(ctx, c, os.Stdin, cp, failed, "app.bsky.graph.follow", "follow", c.Follow)
+}
+
+// BlockBulk blocks the accounts read from standard input, such as a community blocklist exported as JSON lines. lines
+// are JSON objects with a did or handle, or a DID or handle. accounts already blocked are skipped. BG_DRY_RUN=true
+// only reports the accounts that would be blocked.
+func (Bs) BlockBulk(ctx context.Context) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	cp, err := OpenCheckpoint("bs:blockBulk")
+	if err != nil {
+		return err
+	}
+
+	failed := OpenFailureLog("bs:blockBulk")
+	defer failed.Close()
+
+	return graphBulk(ctx, c, os.Stdin, cp, failed, "app.bsky.graph.block", "block", c.Block)
+}
+
+// graphBulk creates a follow or block record for each account read from input, skipping the subjects of the existing
+// records of the collection. dry runs leave the checkpoint alone, so a resumed real run does not skip their lines.
+func graphBulk(ctx context.Context, c *Client, input io.Reader, cp *Checkpoint, failed *FailureLog, collection, verb string, create func(ctx context.Context, did string, createdAt time.Time) (map[string]interface{}, error)) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	existing, err := c.recordSubjects(ctx, collection)
+	if err != nil {
+		return fmt.Errorf("failed to list current %s records: %w", verb, err)
+	}
+	log.Printf("%d existing %s records\n", len(existing), verb)
+
+	progress := StartProgress(cp.State.Command, c)
+	defer progress.Stop(&err)
+
+	created, skipped := 0, 0
+	scanner := bufio.NewScanner(input)
+	lineNum := 0
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// skip lines completed by a previous run, then record the lines completed so far
+		lineNum++
+		if lineNum <= cp.State.Line {
+			continue
+		}
+		cp.State.Line = lineNum - 1
+		if !p.DryRun {
+			if err := cp.Save(); err != nil {
+				return err
+			}
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		did, err := c.resolveActorLine(ctx, line)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			if err := failed.Record(line, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if existing[did] || did == c.Session.DID {
+			skipped++
+			continue
+		}
+
+		if p.DryRun {
+			log.Printf("dry run: would %s %s\n", verb, did)
+			existing[did] = true
+			created++
+			continue
+		}
+
+		resp, err := create(ctx, did, time.Now().UTC())
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			if err := failed.Record(line, fmt.Errorf("failed to %s %s: %w", verb, did, err)); err != nil {
+				return err
+			}
+			continue
+		}
+		existing[did] = true
+		created++
+		progress.Items(1)
+
+		if err := out.Emit(map[string]interface{}{"subject": did, "uri": resp["uri"], "cid": resp["cid"]}); err != nil {
+			return err
+		}
+		if err := sleepContext(ctx, p.WriteDelay); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+
+	log.Printf("%s: created %d, skipped %d existing\n", cp.State.Command, created, skipped)
+	if p.DryRun {
+		return nil
+	}
+	return cp.Done()
+}
+
+// Mutuals <actor> outputs the accounts that follow the actor and that the actor follows
+func (Bs) Mutuals(ctx context.Context, actor string) error {
+	return emitFollowGraph(ctx, actor, true)
+}
+
+// NotFollowingBack <actor> outputs the accounts the actor follows that do not follow the actor back
+func (Bs) NotFollowingBack(ctx context.Context, actor string) error {
+	return emitFollowGraph(ctx, actor, false)
+}
+
+// emitFollowGraph fetches the follows and followers of an actor and emits the follows that are (mutual = true) or
+// are not followers
+func emitFollowGraph(ctx context.Context, actor string, mutual bool) (err error) {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	follows, err := c.AllFollows(ctx, actor)
+	if err != nil {
+		return err
+	}
+	followers, err := c.AllFollowers(ctx, actor)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s follows %d accounts and has %d followers\n", actor, len(follows), len(followers))
+
+	followedBy := make(map[string]bool, len(followers))
+	for _, follower := range followers {
+		followedBy[follower.DID] = true
+	}
+
+	count := 0
+	for _, follow := range follows {
+		if followedBy[follow.DID] != mutual {
+			continue
+		}
+		if err := out.Emit(follow); err != nil {
+			return err
+		}
+		count++
+	}
+
+	log.Printf("%d accounts\n", count)
+	return nil
+}
+
+// FollowerDiff <actor> <previous> compares the current followers of an actor with a previous JSONL export of them and
+// outputs the gained and lost followers. with BG_SNAPSHOT_DIR set, the current followers are also written there to a
+// timestamped file for the next comparison.
+func (Bs) FollowerDiff(ctx context.Context, actor, previous string) (err error) {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	before, err := readAccounts(previous)
+	if err != nil {
+		return err
+	}
+
+	followers, err := c.AllFollowers(ctx, actor)
+	if err != nil {
+		return err
+	}
+
+	if dir := os.Getenv("BG_SNAPSHOT_DIR"); dir != "" {
+		if err := writeSnapshot(dir, "followers-"+actor, followers); err != nil {
+			return err
+		}
+	}
+
+	gained, lost := 0, 0
+	current := make(map[string]bool, len(followers))
+	for _, follower := range followers {
+		current[follower.DID] = true
+		if _, ok := before[follower.DID]; ok {
+			continue
+		}
+		item, err := toMap(follower)
+		if err != nil {
+			return err
+		}
+		item["change"] = "gained"
+		if err := out.Emit(item); err != nil {
+			return err
+		}
+		gained++
+	}
+	dids := make([]string, 0, len(before))
+	for did := range before {
+		dids = append(dids, did)
+	}
+	sort.Strings(dids)
+	for _, did := range dids {
+		if current[did] {
+			continue
+		}
+		item := before[did]
+		item["change"] = "lost"
+		if err := out.Emit(item); err != nil {
+			return err
+		}
+		lost++
+	}
+
+	log.Printf("%s: %d followers, %d gained, %d lost since %s\n", actor, len(followers), gained, lost, previous)
+	return nil
+}
+
+// readAccounts reads a JSONL export of accounts, such as the output of bs:getFollowers, keyed by DID
+func readAccounts(path string) (map[string]map[string]interface{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	accounts := make(map[string]map[string]interface{})
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var account map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &account); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if did, ok := account["did"].(string); ok {
+			accounts[did] = account
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// writeSnapshot writes accounts as JSON lines to <dir>/<name>-<timestamp>.jsonl
+func writeSnapshot(dir, name string, accounts []FollowerView) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.jsonl", name, time.Now().UTC().Format("20060102T150405Z")))
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for _, account := range accounts {
+		b, err := json.Marshal(account)
+		if err != nil {
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	log.Printf("wrote %d accounts to %s\n", len(accounts), path)
+	return nil
+}
+
+// ListSync <listURL> adds and removes list members so the list matches the accounts read from standard input. lines are
+// JSON objects with a did or handle, or a DID or handle. BG_DRY_RUN=true only outputs the planned changes.
+func (Bs) ListSync(ctx context.Context, listURL string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	atURI, err := c.ListATURI(ctx, listURL)
+	if err != nil {
+		return err
+	}
+
+	// read the desired members, keeping input order for the adds
+	var desired []string
+	want := make(map[string]bool)
+	var unresolved []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		did, err := c.resolveActorLine(ctx, line)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Printf("failed: %s: %v\n", line, err)
+			unresolved = append(unresolved, line)
+			continue
+		}
+		if !want[did] {
+			want[did] = true
+			desired = append(desired, did)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading standard input: %w", err)
+	}
+	// removing members because their input lines failed to resolve would empty the list on a bad run
+	if len(unresolved) > 0 {
+		return fmt.Errorf("%d input lines could not be resolved, list not changed: %s", len(unresolved), strings.Join(unresolved, ", "))
+	}
+
+	members, err := c.listMembers(ctx, atURI)
+	if err != nil {
+		return fmt.Errorf("failed to list current members: %w", err)
+	}
+
+	var writes []WriteOp
+	// subjects of the removed listitem records, keyed by rkey
+	removedSubjects := make(map[string]string)
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	for _, did := range desired {
+		if _, ok := members[did]; ok {
+			continue
+		}
+		op := CreateOp("app.bsky.graph.listitem", listItemRecord{
+			Type:      "app.bsky.graph.listitem",
+			Subject:   did,
+			List:      atURI,
+			CreatedAt: createdAt,
+		})
+		writes = append(writes, op)
+	}
+	adds := len(writes)
+
+	// members not wanted are removed, and wanted members listed more than once keep only their first record
+	removed := make([]string, 0)
+	duplicates := 0
+	for did, uris := range members {
+		if !want[did] || len(uris) > 1 {
+			removed = append(removed, did)
+		}
+	}
+	sort.Strings(removed)
+	for _, did := range removed {
+		uris := members[did]
+		if want[did] {
+			uris = uris[1:]
+			duplicates += len(uris)
+		}
+		for _, uri := range uris {
+			_, collection, rkey, err := splitATURI(uri)
+			if err != nil {
+				return err
+			}
+			op := DeleteOp(collection, rkey)
+			removedSubjects[rkey] = did
+			writes = append(writes, op)
+		}
+	}
+
+	log.Printf("list has %d members, %d wanted: %d to add, %d to remove, %d duplicate records to delete\n", len(members), len(desired), adds, len(writes)-adds-duplicates, duplicates)
+
+	emit := func(batch []WriteOp) error {
+		for _, op := range batch {
+			change := map[string]interface{}{"list": atURI}
+			if record, ok := op.Value.(listItemRecord); ok {
+				change["action"] = "add"
+				change["subject"] = record.Subject
+			} else {
+				change["action"] = "remove"
+				change["subject"] = removedSubjects[op.Rkey]
+			}
+			if err := out.Emit(change); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if p.DryRun {
+		return emit(writes)
+	}
+	return c.applyWritesBatched(ctx, writes, p.WriteDelay, emit)
+}
+
+// ListClone <sourceListURL> <newName> creates a list with the purpose and description of any account's list and adds
+// all of its members
+func (Bs) ListClone(ctx context.Context, sourceListURL, newName string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	sourceURI, err := c.ListATURI(ctx, sourceListURL)
+	if err != nil {
+		return err
+	}
+
+	source, members, err := c.AllListMembers(ctx, sourceURI)
+	if err != nil {
+		return err
+	}
+	purpose, _ := source["purpose"].(string)
+	if purpose == "" {
+		purpose = p.ListPurpose
+	}
+	description, _ := source["description"].(string)
+	log.Printf("cloning %s (%d members)\n", sourceURI, len(members))
+
+	if p.DryRun {
+		log.Printf("dry run: would create list %q and add %d members\n", newName, len(members))
+		return nil
+	}
+
+	list, err := c.ListCreate(ctx, purpose, newName, description, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	listURI, ok := list["uri"].(string)
+	if !ok {
+		return fmt.Errorf("failed to get URI of the new list")
+	}
+
+	writes := make([]WriteOp, 0, len(members))
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	for _, did := range members {
+		writes = append(writes, CreateOp("app.bsky.graph.listitem", listItemRecord{
+			Type:      "app.bsky.graph.listitem",
+			Subject:   did,
+			List:      listURI,
+			CreatedAt: createdAt,
+		}))
+	}
+	if err := c.applyWritesBatched(ctx, writes, p.WriteDelay, nil); err != nil {
+		return fmt.Errorf("failed to add members to %s: %w", listURI, err)
+	}
+
+	return emitStdout(list)
+}
+
+// FollowList <url> follows every member of a list or starter pack, given by its bsky.app URL or AT URI. accounts
+// already followed are skipped. BG_DRY_RUN=true only reports the accounts that would be followed.
+func (Bs) FollowList(ctx context.Context, listURL string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	listURI, err := c.ATURI(ctx, listURL)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(listURI, "/app.bsky.graph.starterpack/") {
+		if listURI, err = c.StarterPackListURI(ctx, listURI); err != nil {
+			return err
+		}
+	}
+
+	_, members, err := c.AllListMembers(ctx, listURI)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s has %d members\n", listURI, len(members))
+
+	cp, err := OpenCheckpoint("bs:followList", listURL)
+	if err != nil {
+		return err
+	}
+
+	// failures are retried as a bs:followBulk run of the failed accounts
+	failed := OpenFailureLog("bs:followBulk")
+	defer failed.Close()
+
+	input := strings.NewReader(strings.Join(members, "\n"))
+	return graphBulk(ctx, c, input, cp, failed, "app.bsky.graph.follow", "follow", c.Follow)
+}
+
+// AtUri <url> converts a bsky.app profile, post, list, feed, or starter pack URL to its AT URI. handles are resolved
+// with DNS or their well-known URL, so no session is created.
+func (Bs) AtUri(ctx context.Context, rawURL string) error {
+	opts, err := ClientOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	httpClient, err := newHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+
+	uri, err := resolveATURI(ctx, rawURL, func(ctx context.Context, actor string) (string, error) {
+		if strings.HasPrefix(actor, "did:") {
+			return actor, nil
+		}
+		return resolveHandleToDID(ctx, httpClient, actor)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(uri)
+	return nil
+}
+
+// Url <atUri> converts the AT URI of a profile, post, list, feed, or starter pack to its bsky.app URL
+func (Bs) Url(atURI string) error {
+	bskyURL, err := BskyURL(atURI)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(bskyURL)
+	return nil
+}
+
+// PrunePosts <days> deletes the account's posts created more than days ago. BG_KEEP=pinned,liked keeps the pinned post
+// and posts the account liked itself. BG_DRY_RUN=true only outputs the posts that would be deleted.
+func (Bs) PrunePosts(ctx context.Context, days int) (err error) {
+	if days < 1 {
+		return fmt.Errorf("invalid days %d: must be at least 1", days)
+	}
+
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	keep := make(map[string]bool)
+	for _, k := range p.Keep {
+		switch k {
+		case "pinned":
+			profile, err := c.GetProfile(ctx, c.Session.DID)
+			if err != nil {
+				return err
+			}
+			var pinned StrongRef
+			if len(profile.PinnedPost) > 0 {
+				if err := json.Unmarshal(profile.PinnedPost, &pinned); err != nil {
+					return fmt.Errorf("failed to parse pinned post: %w", err)
+				}
+				keep[pinned.URI] = true
+			}
+		case "liked":
+			err := c.EachRecord(ctx, c.Session.DID, "app.bsky.feed.like", func(record map[string]interface{}) error {
+				value, _ := record["value"].(map[string]interface{})
+				subject, _ := value["subject"].(map[string]interface{})
+				if uri, ok := subject["uri"].(string); ok && strings.HasPrefix(uri, "at://"+c.Session.DID+"/") {
+					keep[uri] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list likes: %w", err)
+			}
+		}
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -days)
+	var writes []WriteOp
+	var pruned []map[string]interface{}
+	total := 0
+	err = c.EachRecord(ctx, c.Session.DID, "app.bsky.feed.post", func(record map[string]interface{}) error {
+		total++
+		uri, _ := record["uri"].(string)
+		value, _ := record["value"].(map[string]interface{})
+		createdAtValue, _ := value["createdAt"].(string)
+		createdAt, err := time.Parse(time.RFC3339, createdAtValue)
+		if err != nil || !createdAt.Before(cutoff) || keep[uri] {
+			return nil
+		}
+
+		_, collection, rkey, err := splitATURI(uri)
+		if err != nil {
+			return err
+		}
+		writes = append(writes, DeleteOp(collection, rkey))
+		pruned = append(pruned, map[string]interface{}{"uri": uri, "createdAt": createdAtValue, "text": value["text"]})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("%d of %d posts created before %s to delete\n", len(writes), total, cutoff.Format(time.RFC3339))
+
+	if p.DryRun {
+		for _, post := range pruned {
+			if err := out.Emit(post); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	deleted := 0
+	return c.applyWritesBatched(ctx, writes, p.WriteDelay, func(batch []WriteOp) error {
+		for range batch {
+			if err := out.Emit(pruned[deleted]); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+}
+
+// PostThread <file> posts the text of a file, or of standard input when file is -, as a numbered thread split at
+// sentence boundaries, and prints the AT URI of each post. BG_DRY_RUN=true only prints the parts.
+func (Bs) PostThread(ctx context.Context, file string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	text, err := readTextInput(file)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("no text to post")
+	}
+
+	parts := splitThread(text, maxPostLength)
+	if p.DryRun {
+		for _, part := range parts {
+			fmt.Printf("%s\n\n", part)
+		}
+		return nil
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	refs, err := c.PostThread(ctx, parts)
+	for _, ref := range refs {
+		fmt.Println(ref.URI)
+	}
+	return err
+}
+
+// readTextInput reads the whole of a file, or of standard input when path is -
+func readTextInput(path string) (string, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return string(b), nil
+}
+
+// CreateRecordFromStdin creates a new post with the text read from standard input, with facets for mentions and links
+func (Bs) CreateRecordFromStdin(ctx context.Context) error {
+	return createPostFromInput(ctx, "-")
+}
+
+// CreateRecordFromFile <file> creates a new post with the text of a file, with facets for mentions and links
+func (Bs) CreateRecordFromFile(ctx context.Context, file string) error {
+	return createPostFromInput(ctx, file)
+}
+
+// createPostFromInput posts the text of a file, or of standard input when path is -, and prints its reference
+func createPostFromInput(ctx context.Context, path string) error {
+	text, err := readTextInput(path)
+	if err != nil {
+		return err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("no text to post")
+	}
+	if n := utf8.RuneCountInString(text); n > maxPostLength {
+		return fmt.Errorf("text is %d characters, over the %d limit: use bs:postThread to post it as a thread", n, maxPostLength)
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	ref, err := c.Post(ctx, PostRecord{Text: text})
+	if err != nil {
+		return err
+	}
+
+	return emitStdout(ref)
+}
+
+// Tui pages through the home timeline, author feeds, and notifications in a full-screen terminal UI, opens threads,
+// and likes, reposts, and replies to posts
+func (Bs) Tui(ctx context.Context) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	// client logs such as retries would draw over the screen
+	logOutput := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(logOutput)
+
+	_, err = tea.NewProgram(newTUIModel(ctx, c, p.LimitOr(20)), tea.WithContext(ctx), tea.WithAltScreen()).Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// <actor> summarizes the engagement, posting times, and hashtags of an author's posts as JSON. the argument comes
+// first since go/doc drops synopses starting with "author".
+func (Bs) AuthorStats(ctx context.Context, actor string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	progress := StartProgress("bs:authorStats", c)
+	defer progress.Stop(&err)
+
+	stats := newAuthorStats(actor, p.Location)
+	err = c.EachAuthorPost(ctx, actor, p.LimitOr(100), p.Filter, func(post PostView) error {
+		stats.Add(post, p.Location)
+		progress.Items(1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	stats.Finish()
+
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\n", b)
+	return nil
+}
+
+// Frequency counts the hashtags, words, and mentions of posts read as JSONL from standard input
+func (Bs) Frequency(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	// accept the output of bs:getAuthorFeeds, bs:searchPosts, and similar exports
+	freq := newFrequencies()
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		text, ok, err := postText(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("failed to parse line %d: %w", line, err)
+		}
+		if !ok {
+			log.Printf("line %d: no post text, skipping\n", line)
+			continue
+		}
+		freq.Add(text)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading standard input: %w", err)
+	}
+	log.Printf("posts: %d | hashtags: %d | words: %d | mentions: %d\n", freq.Posts, len(freq.Hashtags), len(freq.Words), len(freq.Mentions))
+
+	// BG_LIMIT is the number of terms of each table, all terms when unset
+	tables := []struct {
+		kind   string
+		counts map[string]int
+	}{
+		{"hashtag", freq.Hashtags},
+		{"mention", freq.Mentions},
+		{"word", freq.Words},
+	}
+	for _, table := range tables {
+		for _, term := range topTerms(table.counts, p.Limit) {
+			row := map[string]interface{}{"kind": table.kind, "term": term.Term, "count": term.Count}
+			if err := out.Emit(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EngagementByHour <actor> reports the average likes and reposts of an author's posts by weekday and hour of creation
+func (Bs) EngagementByHour(ctx context.Context, actor string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	progress := StartProgress("bs:engagementByHour", c)
+	defer progress.Stop(&err)
+
+	matrix := newEngagementMatrix()
+	err = c.EachAuthorPost(ctx, actor, p.LimitOr(100), p.Filter, func(post PostView) error {
+		matrix.Add(post, p.Location)
+		progress.Items(1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// one row per weekday and hour, Sunday 00:00 first, so the matrix pivots easily from csv
+	for day := range matrix {
+		for _, cell := range matrix[day] {
+			if err := out.Emit(cell); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, cell := range matrix.Best(3) {
+		log.Printf("best: %s %02d:00 %s | posts: %d | avg engagement: %.1f\n", cell.Weekday, cell.Hour, p.Location, cell.Posts, cell.AvgEngagement)
+	}
+	return nil
+}
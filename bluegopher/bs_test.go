@@ -0,0 +1,80 @@
+package bluegopher
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cancelReader cancels a run when its input is read, as an interrupt between two lines does
+type cancelReader struct {
+	cancel context.CancelFunc
+	rest   string
+}
+
+func (r cancelReader) Read(b []byte) (int, error) {
+	r.cancel()
+	return copy(b, r.rest), io.EOF
+}
+
+func TestGraphBulkDryRunKeepsCheckpoint(t *testing.T) {
+	tests := []struct {
+		command    string
+		collection string
+		verb       string
+		create     func(c *Client) func(ctx context.Context, did string, createdAt time.Time) (map[string]interface{}, error)
+	}{
+		{"bs:followBulk", "app.bsky.graph.follow", "follow", func(c *Client) func(context.Context, string, time.Time) (map[string]interface{}, error) {
+			return c.Follow
+		}},
+		{"bs:blockBulk", "app.bsky.graph.block", "block", func(c *Client) func(context.Context, string, time.Time) (map[string]interface{}, error) {
+			return c.Block
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			pds := newFakePDS(t)
+			c := newTestClient(t, pds)
+			t.Setenv("BG_OUTPUT", filepath.Join(t.TempDir(), "out.jsonl"))
+			t.Setenv("BG_WRITE_DELAY", "0")
+			run := func(ctx context.Context, input io.Reader) error {
+				cp, err := OpenCheckpoint(tt.command)
+				if err != nil {
+					t.Fatal(err)
+				}
+				failed := OpenFailureLog(tt.command)
+				defer failed.Close()
+				return graphBulk(ctx, c, input, cp, failed, tt.collection, tt.verb, tt.create(c))
+			}
+
+			// a dry run interrupted after bob.test and carol.test
+			t.Setenv("BG_DRY_RUN", "true")
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			input := io.MultiReader(strings.NewReader("bob.test\ncarol.test\n"), cancelReader{cancel: cancel, rest: "did:plc:dave\n"})
+			if err := run(ctx, input); err == nil {
+				t.Fatal("interrupted dry run succeeded")
+			}
+			if n := len(pds.collection(tt.collection)); n != 0 {
+				t.Fatalf("dry run created %d records", n)
+			}
+
+			// the resumed real run starts from the first line, since the dry run wrote nothing
+			t.Setenv("BG_DRY_RUN", "")
+			t.Setenv("BG_RESUME", "true")
+			if err := run(context.Background(), strings.NewReader("bob.test\ncarol.test\n")); err != nil {
+				t.Fatal(err)
+			}
+			var subjects []string
+			for _, r := range pds.collection(tt.collection) {
+				subjects = append(subjects, r.Value["subject"].(string))
+			}
+			if strings.Join(subjects, ",") != "did:plc:bob,did:plc:carol" {
+				t.Errorf("%s records of %v, want did:plc:bob and did:plc:carol", tt.verb, subjects)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package bluegopher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResponseCache is an on-disk cache of GET responses, so repeated lookups such as resolving the same profile many
+// times during a bulk run are served locally. entries are fresh for the TTL, after which they are revalidated with a
+// conditional request when the server sent an ETag or Last-Modified header, and fetched again otherwise.
+type ResponseCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// cacheEntry is a cached response body with its validators
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	Body         []byte    `json:"body"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	StoredAt     time.Time `json:"storedAt"`
+}
+
+// NewResponseCache creates a cache in the http directory of the cache directory, whose entries are fresh for ttl
+func NewResponseCache(ttl time.Duration) (*ResponseCache, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseCache{dir: filepath.Join(dir, "http"), ttl: ttl}, nil
+}
+
+// cacheableMethods are the XRPC methods whose responses are cached: idempotent profile and identity reads. other
+// responses, such as notifications, direct messages, and repositories, change with every write or must not be
+// stored on disk.
+var cacheableMethods = map[string]bool{
+	"app.bsky.actor.getProfile":          true,
+	"app.bsky.actor.getProfiles":         true,
+	"com.atproto.identity.resolveHandle": true,
+	"com.atproto.server.describeServer":  true,
+}
+
+// cacheable reports whether the response of a GET request may be cached: an allowed method, not sent through the chat
+// proxy
+func cacheable(ctx context.Context, url string) bool {
+	if proxyFromContext(ctx) == ChatProxy {
+		return false
+	}
+	_, method, ok := strings.Cut(url, "/xrpc/")
+	if !ok {
+		return false
+	}
+	method, _, _ = strings.Cut(method, "?")
+	return cacheableMethods[method]
+}
+
+// cacheKey returns the cache key of a GET request. responses such as profiles carry the viewer state of the account and
+// the labels of the accepted labelers, so the key includes everything that changes the response along with the URL.
+func (c *Client) cacheKey(ctx context.Context, url string, headers map[string]string) string {
+	parts := []string{c.Session.DID, url, proxyFromContext(ctx), strings.Join(c.Labelers, ",")}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, strings.ToLower(name)+"="+headers[name])
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// path returns the file of a key, in a subdirectory per first byte to keep directories small
+func (rc *ResponseCache) path(key string) string {
+	return filepath.Join(rc.dir, key[:2], key+".json")
+}
+
+// Get returns the entry of a key, or nil when it is missing or unreadable
+func (rc *ResponseCache) Get(key string) *cacheEntry {
+	b, err := os.ReadFile(rc.path(key))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("failed to read cached response: %v\n", err)
+		}
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		log.Printf("failed to parse cached response %s: %v\n", rc.path(key), err)
+		return nil
+	}
+	return &entry
+}
+
+// Fresh reports whether an entry is within the TTL
+func (rc *ResponseCache) Fresh(entry *cacheEntry) bool {
+	return entry != nil && time.Since(entry.StoredAt) < rc.ttl
+}
+
+// Put stores a response, unless the server asked not to. failures are logged, since the cache is only an
+// optimization.
+func (rc *ResponseCache) Put(key, url string, header http.Header, body []byte) {
+	if strings.Contains(header.Get("Cache-Control"), "no-store") {
+		return
+	}
+	rc.write(key, cacheEntry{
+		URL:          url,
+		Body:         body,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		StoredAt:     time.Now(),
+	})
+}
+
+// Revalidated marks an entry fresh again after the server answered a conditional request with 304 Not Modified
+func (rc *ResponseCache) Revalidated(key string, entry *cacheEntry) {
+	entry.StoredAt = time.Now()
+	rc.write(key, *entry)
+}
+
+// write writes an entry to a temporary file and renames it, so concurrent readers never see a partial entry
+func (rc *ResponseCache) write(key string, entry cacheEntry) {
+	path := rc.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		log.Printf("failed to create response cache directory: %v\n", err)
+		return
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("failed to marshal cached response: %v\n", err)
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		log.Printf("failed to write cached response: %v\n", err)
+		return
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Printf("failed to write cached response: %v\n", err)
+	}
+}
+
+// conditionalHeaders returns the request headers with the validators of a stale entry
+func conditionalHeaders(headers map[string]string, entry *cacheEntry) map[string]string {
+	if entry.ETag == "" && entry.LastModified == "" {
+		return headers
+	}
+	conditional := make(map[string]string, len(headers)+2)
+	for k, v := range headers {
+		conditional[k] = v
+	}
+	if entry.ETag != "" {
+		conditional["If-None-Match"] = entry.ETag
+	}
+	if entry.LastModified != "" {
+		conditional["If-Modified-Since"] = entry.LastModified
+	}
+	return conditional
+}
@@ -0,0 +1,158 @@
+package bluegopher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// CAR is a decoded CAR v1 archive: the blocks of a repository or of a firehose commit, keyed by the binary CID
+type CAR struct {
+	Roots  []CID
+	Blocks map[string][]byte
+}
+
+// Block returns the data of a block
+func (car *CAR) Block(cid CID) ([]byte, bool) {
+	b, ok := car.Blocks[string(cid)]
+	return b, ok
+}
+
+// Record decodes the DAG-CBOR block of a record
+func (car *CAR) Record(cid CID) (interface{}, error) {
+	b, ok := car.Block(cid)
+	if !ok {
+		return nil, fmt.Errorf("block %s is missing from the CAR", cid)
+	}
+	v, _, err := decodeCBOR(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode block %s: %w", cid, err)
+	}
+	return v, nil
+}
+
+// readCAR decodes a CAR v1 archive: a varint-prefixed DAG-CBOR header with the roots, then varint-prefixed sections
+// of a CID followed by its block
+func readCAR(data []byte) (*CAR, error) {
+	header, rest, err := carSection(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CAR header: %w", err)
+	}
+	v, _, err := decodeCBOR(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode CAR header: %w", err)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok || m["version"] != int64(1) {
+		return nil, fmt.Errorf("unsupported CAR header: only version 1 is supported")
+	}
+
+	car := &CAR{Blocks: make(map[string][]byte)}
+	roots, _ := m["roots"].([]interface{})
+	for _, root := range roots {
+		if cid, ok := root.(CID); ok {
+			car.Roots = append(car.Roots, cid)
+		}
+	}
+
+	for len(rest) > 0 {
+		var section []byte
+		if section, rest, err = carSection(rest); err != nil {
+			return nil, fmt.Errorf("failed to read CAR block: %w", err)
+		}
+		n, err := cidLength(section)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CAR block: %w", err)
+		}
+		car.Blocks[string(section[:n])] = section[n:]
+	}
+	return car, nil
+}
+
+// carSection splits a varint length-prefixed section from data
+func carSection(data []byte) (section, rest []byte, err error) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, nil, fmt.Errorf("invalid section length")
+	}
+	if length > uint64(len(data)-n) {
+		return nil, nil, fmt.Errorf("section length %d exceeds the data", length)
+	}
+	end := n + int(length)
+	return data[n:end], data[end:], nil
+}
+
+// cidLength returns the length of the binary CID at the start of data: a CIDv0 sha2-256 multihash, or a CIDv1
+// version, codec, and multihash
+func cidLength(data []byte) (int, error) {
+	if len(data) >= 34 && data[0] == 0x12 && data[1] == 0x20 {
+		return 34, nil
+	}
+
+	pos := 0
+	// version, codec, and multihash code, then the digest length
+	for i := 0; i < 4; i++ {
+		v, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid CID")
+		}
+		if i == 0 && v != 1 {
+			return 0, fmt.Errorf("unsupported CID version %d", v)
+		}
+		pos += n
+		if i == 3 {
+			if v > uint64(len(data)-pos) {
+				return 0, fmt.Errorf("invalid CID digest length %d", v)
+			}
+			pos += int(v)
+		}
+	}
+	return pos, nil
+}
+
+// Verify checks that the roots of the archive are present and that every block hashes to its CID
+func (car *CAR) Verify() error {
+	for _, root := range car.Roots {
+		if _, ok := car.Block(root); !ok {
+			return fmt.Errorf("root %s is missing from the CAR", root)
+		}
+	}
+	for cid, block := range car.Blocks {
+		if err := verifyCID(CID(cid), block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyCID checks that data hashes to the sha2-256 multihash of a CID, the only hash used by atproto
+func verifyCID(cid CID, data []byte) error {
+	n, err := cidLength(cid)
+	if err != nil {
+		return err
+	}
+	if n < 34 || cid[n-34] != 0x12 || cid[n-33] != 0x20 {
+		return fmt.Errorf("unsupported multihash in CID %s: only sha2-256 is supported", cid)
+	}
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], cid[n-32:n]) {
+		return fmt.Errorf("block %s does not match its CID", cid)
+	}
+	return nil
+}
+
+// parseCID decodes the base32 multibase form of a CIDv1
+func parseCID(s string) (CID, error) {
+	if !strings.HasPrefix(s, "b") {
+		return nil, fmt.Errorf("unsupported CID %q: only base32 CIDs are supported", s)
+	}
+	b, err := cidBase32.DecodeString(s[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid CID %q: %w", s, err)
+	}
+	if n, err := cidLength(b); err != nil || n != len(b) {
+		return nil, fmt.Errorf("invalid CID %q", s)
+	}
+	return CID(b), nil
+}
@@ -0,0 +1,330 @@
+package bluegopher
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CID is a content identifier in its binary form, as found in DAG-CBOR links and CAR files
+type CID []byte
+
+// cidBase32 is the multibase base32 encoding of CIDv1 strings such as bafyrei...
+var cidBase32 = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// String returns the base32 multibase form of a CID
+func (c CID) String() string {
+	return "b" + cidBase32.EncodeToString(c)
+}
+
+// cborTagCID is the CBOR tag of a DAG-CBOR link
+const cborTagCID = 42
+
+// cborMaxDepth limits the nesting of decoded values, so a hostile frame cannot exhaust the stack
+const cborMaxDepth = 64
+
+// decodeCBOR decodes the first DAG-CBOR value of data and returns the bytes after it. maps decode to
+// map[string]interface{}, arrays to []interface{}, integers to int64, byte strings to []byte, and links to CID.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, d.data[d.pos:], nil
+}
+
+// cborDecoder reads DAG-CBOR, the deterministic subset of CBOR used by atproto: definite lengths, string map keys,
+// and tag 42 for links only
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// head reads the major type and argument of the next item
+func (d *cborDecoder) head() (major byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, fmt.Errorf("cbor: unexpected end of data")
+	}
+	b := d.data[d.pos]
+	d.pos++
+	major, info := b>>5, b&0x1f
+
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		n := 1 << (info - 24)
+		if d.pos+n > len(d.data) {
+			return 0, 0, fmt.Errorf("cbor: unexpected end of data")
+		}
+		buf := d.data[d.pos : d.pos+n]
+		d.pos += n
+		switch n {
+		case 1:
+			arg = uint64(buf[0])
+		case 2:
+			arg = uint64(binary.BigEndian.Uint16(buf))
+		case 4:
+			arg = uint64(binary.BigEndian.Uint32(buf))
+		default:
+			arg = binary.BigEndian.Uint64(buf)
+		}
+		return major, arg, nil
+	case info == 31:
+		return 0, 0, fmt.Errorf("cbor: indefinite lengths are not allowed in DAG-CBOR")
+	}
+	return 0, 0, fmt.Errorf("cbor: invalid additional info %d", info)
+}
+
+// bytes reads n bytes
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("cbor: length %d exceeds the data", n)
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// value decodes the next item
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > cborMaxDepth {
+		return nil, fmt.Errorf("cbor: nesting deeper than %d", cborMaxDepth)
+	}
+
+	start := d.pos
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("cbor: integer %d overflows int64", arg)
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("cbor: integer -%d overflows int64", arg)
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 3:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4:
+		// every item takes at least one byte, which bounds the allocation of hostile lengths
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("cbor: array length %d exceeds the data", arg)
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("cbor: map length %d exceeds the data", arg)
+		}
+		m := make(map[string]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("cbor: map key %v is not a string", k)
+			}
+			if m[key], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case 6:
+		if arg != cborTagCID {
+			return nil, fmt.Errorf("cbor: unsupported tag %d", arg)
+		}
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.([]byte)
+		// links are byte strings with a leading 0x00, the identity multibase prefix
+		if !ok || len(b) < 2 || b[0] != 0 {
+			return nil, fmt.Errorf("cbor: invalid link")
+		}
+		return CID(b[1:]), nil
+	case 7:
+		return d.simple(start, arg)
+	}
+	return nil, fmt.Errorf("cbor: invalid major type %d", major)
+}
+
+// simple decodes the booleans, null, and floats of major type 7
+func (d *cborDecoder) simple(start int, arg uint64) (interface{}, error) {
+	switch info := d.data[start] & 0x1f; info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return float64(halfFloat(uint16(arg))), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+	}
+}
+
+// halfFloat converts an IEEE 754 half-precision float
+func halfFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+
+	switch exp {
+	case 0:
+		// subnormal half floats are normal float32s
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+}
+
+// cborJSON converts a decoded DAG-CBOR value to its atproto JSON form: links become {"$link": cid} and byte strings
+// {"$bytes": base64}
+func cborJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case CID:
+		return map[string]interface{}{"$link": v.String()}
+	case []byte:
+		return map[string]interface{}{"$bytes": base64.RawStdEncoding.EncodeToString(v)}
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = cborJSON(item)
+		}
+		return items
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = cborJSON(item)
+		}
+		return m
+	}
+	return v
+}
+
+// encodeCBOR encodes a value as DAG-CBOR, the canonical form that atproto signs and hashes: map keys are sorted by
+// length and then bytewise, and integers use their shortest form. it accepts the types decodeCBOR returns, along with
+// int, string slices, and nested map[string]interface{} values.
+func encodeCBOR(v interface{}) ([]byte, error) {
+	return appendCBOR(nil, v)
+}
+
+// appendCBOR appends the DAG-CBOR encoding of a value
+func appendCBOR(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xf6), nil
+	case bool:
+		if v {
+			return append(b, 0xf5), nil
+		}
+		return append(b, 0xf4), nil
+	case int:
+		return appendCBOR(b, int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(b, 1, uint64(-1-v)), nil
+		}
+		return cborHead(b, 0, uint64(v)), nil
+	case string:
+		return append(cborHead(b, 3, uint64(len(v))), v...), nil
+	case []byte:
+		return append(cborHead(b, 2, uint64(len(v))), v...), nil
+	case CID:
+		b = cborHead(b, 6, cborTagCID)
+		b = cborHead(b, 2, uint64(len(v)+1))
+		return append(append(b, 0), v...), nil
+	case []string:
+		b = cborHead(b, 4, uint64(len(v)))
+		for _, item := range v {
+			b = append(cborHead(b, 3, uint64(len(item))), item...)
+		}
+		return b, nil
+	case []interface{}:
+		b = cborHead(b, 4, uint64(len(v)))
+		for _, item := range v {
+			var err error
+			if b, err = appendCBOR(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		b = cborHead(b, 5, uint64(len(v)))
+		for _, k := range keys {
+			b = append(cborHead(b, 3, uint64(len(k))), k...)
+			var err error
+			if b, err = appendCBOR(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported type %T", v)
+}
+
+// cborHead appends the major type and argument of an item in the shortest form
+func cborHead(b []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(b, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(b, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), arg)
+}
@@ -0,0 +1,79 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// sendChatRequest sends a request to the chat service via the PDS and unmarshals the response
+func (c *Client) sendChatRequest(ctx context.Context, method, requestURL string, requestBody interface{}) (map[string]interface{}, error) {
+	body, err := c.SendRequest(WithProxy(ctx, ChatProxy), method, requestURL, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListConvos retrieves a page of the authenticated user's conversations
+func (c *Client) ListConvos(ctx context.Context, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/chat.bsky.convo.listConvos"
+	params := url.Values{}
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	return c.sendChatRequest(ctx, "GET", requestURL, nil)
+}
+
+// GetMessages retrieves a page of messages from a conversation, newest first
+func (c *Client) GetMessages(ctx context.Context, convoID string, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/chat.bsky.convo.getMessages"
+	params := url.Values{}
+	params.Add("convoId", convoID)
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	return c.sendChatRequest(ctx, "GET", requestURL, nil)
+}
+
+// GetConvoForMembers retrieves (or creates) the conversation between the authenticated user and the given DIDs
+func (c *Client) GetConvoForMembers(ctx context.Context, members []string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/chat.bsky.convo.getConvoForMembers"
+	params := url.Values{}
+	for _, member := range members {
+		params.Add("members", member)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	return c.sendChatRequest(ctx, "GET", requestURL, nil)
+}
+
+// SendMessage sends a text message to a conversation
+func (c *Client) SendMessage(ctx context.Context, convoID, text string) (map[string]interface{}, error) {
+	url := c.BaseURL + "/xrpc/chat.bsky.convo.sendMessage"
+	request := map[string]interface{}{
+		"convoId": convoID,
+		"message": map[string]interface{}{
+			"text": text,
+		},
+	}
+
+	return c.sendChatRequest(ctx, "POST", url, request)
+}
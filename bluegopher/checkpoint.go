@@ -0,0 +1,135 @@
+package bluegopher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Checkpoint persists the progress of a paginated or bulk target so an interrupted run can be resumed with
+// BG_RESUME=true. the state file is keyed by the command and its arguments and removed when the run completes.
+type Checkpoint struct {
+	path  string
+	State CheckpointState
+}
+
+// CheckpointState is the progress of a run
+type CheckpointState struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	// Line is the number of stdin lines (or input items) fully processed
+	Line int `json:"line,omitempty"`
+	// Cursor is the cursor of the next page of the current line
+	Cursor string `json:"cursor,omitempty"`
+	// Page is the number of the next page of the current line
+	Page int `json:"page,omitempty"`
+	// Crawl is the frontier of bs:crawlGraph
+	Crawl     *CrawlState `json:"crawl,omitempty"`
+	UpdatedAt string      `json:"updatedAt"`
+}
+
+// cacheDir returns the directory for cached sessions and run state, overridable with BG_CACHE_DIR
+func cacheDir() (string, error) {
+	if dir := os.Getenv("BG_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find cache directory: %w", err)
+	}
+	return filepath.Join(dir, "blue-gopher"), nil
+}
+
+// OpenCheckpoint returns the checkpoint of a command. with BG_RESUME=true the state of the previous interrupted
+// run is loaded, otherwise the run starts from the beginning.
+func OpenCheckpoint(command string, args ...string) (*Checkpoint, error) {
+	resume, err := envBool("BG_RESUME", false)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(command + "\x00" + strings.Join(args, "\x00")))
+	cp := &Checkpoint{
+		path: filepath.Join(dir, "state", hex.EncodeToString(sum[:8])+".json"),
+		State: CheckpointState{
+			Command: command,
+			Args:    args,
+			Page:    1,
+		},
+	}
+
+	if !resume {
+		return cp, nil
+	}
+
+	b, err := os.ReadFile(cp.path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("no saved state for %s, starting from the beginning\n", command)
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(b, &cp.State); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", cp.path, err)
+	}
+	if cp.State.Page < 1 {
+		cp.State.Page = 1
+	}
+
+	log.Printf("resuming %s from line %d, page %d (saved %s)\n", command, cp.State.Line, cp.State.Page, cp.State.UpdatedAt)
+	return cp, nil
+}
+
+// Save writes the current state
+func (cp *Checkpoint) Save() error {
+	cp.State.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	if err := os.MkdirAll(filepath.Dir(cp.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	b, err := json.Marshal(cp.State)
+	if err != nil {
+		return err
+	}
+	// write then rename so an interrupted save does not corrupt the state
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return os.Rename(tmp, cp.path)
+}
+
+// NextPage records the cursor of the next page of the current line
+func (cp *Checkpoint) NextPage(cursor string) error {
+	cp.State.Cursor = cursor
+	cp.State.Page++
+	return cp.Save()
+}
+
+// NextLine records that the current line is complete
+func (cp *Checkpoint) NextLine() error {
+	cp.State.Line++
+	cp.State.Cursor = ""
+	cp.State.Page = 1
+	return cp.Save()
+}
+
+// Done removes the state once the run has completed
+func (cp *Checkpoint) Done() error {
+	if err := os.Remove(cp.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove state: %w", err)
+	}
+	return nil
+}
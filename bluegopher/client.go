@@ -0,0 +1,697 @@
+package bluegopher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Client is a client for the Bluesky API
+type Client struct {
+	BaseURL   string
+	AuthToken string
+	Session   CreateSessionResponse
+	// Labelers are the labeler DIDs sent in the atproto-accept-labelers header so labels are hydrated onto responses
+	Labelers []string
+
+	// Profile names the account whose session is cached between runs
+	Profile string
+	// Identifier is the handle, DID, or email used to authenticate
+	Identifier string
+	// HTTPClient is shared by every request so connections are kept alive
+	HTTPClient *http.Client
+	// Retry controls how transient failures are retried
+	Retry RetryPolicy
+	// Cache serves repeated GET requests from disk when set
+	Cache *ResponseCache
+	// WriteBudget is the write limit shared with other processes writing to the account, consulted before each
+	// repository write when set
+	WriteBudget *WriteBudget
+
+	password string
+	// broker is the URL of the session broker that owns the session, with the bearer token of its API
+	broker      string
+	brokerToken string
+	mu          sync.Mutex
+	rateLimit   RateLimit
+	// refreshMu serializes session refreshes so concurrent requests with an expired token refresh only once
+	refreshMu sync.Mutex
+	// handles caches resolved handle DIDs
+	handles map[string]string
+
+	// requests and failedRequests count the HTTP requests sent and the requests that failed after retries
+	requests       atomic.Int64
+	failedRequests atomic.Int64
+}
+
+// CreateSessionResponse represents the structure of the response from the createSession API
+type CreateSessionResponse struct {
+	DID             string      `json:"did"`
+	DIDDoc          DIDDocument `json:"didDoc"`
+	Handle          string      `json:"handle"`
+	Email           string      `json:"email"`
+	EmailConfirmed  bool        `json:"emailConfirmed"`
+	EmailAuthFactor bool        `json:"emailAuthFactor"`
+	AccessJwt       string      `json:"accessJwt"`
+	RefreshJwt      string      `json:"refreshJwt"`
+	Active          bool        `json:"active"`
+}
+
+// CreateRecordRequest represents the structure of the request to create a record
+type CreateRecordRequest struct {
+	Repo       string      `json:"repo"`
+	Collection string      `json:"collection"`
+	Rkey       string      `json:"rkey,omitempty"`
+	Validate   bool        `json:"validate,omitempty"`
+	Record     interface{} `json:"record"`
+	SwapCommit string      `json:"swapCommit,omitempty"`
+}
+
+// NewClient creates a new Bluesky API client configured from the environment
+func NewClient(ctx context.Context) (*Client, error) {
+	opts, err := ClientOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewClientWithOptions(ctx, opts)
+}
+
+// NewClientWithOptions creates a new Bluesky API client and authenticates it, reusing the cached session of the profile when possible
+func NewClientWithOptions(ctx context.Context, opts ClientOptions) (*Client, error) {
+	httpClient, err := newHTTPClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		BaseURL:     opts.BaseURL,
+		HTTPClient:  httpClient,
+		Retry:       opts.Retry,
+		Labelers:    opts.Labelers,
+		Profile:     opts.Profile,
+		Identifier:  opts.Identifier,
+		password:    opts.Password,
+		broker:      opts.SessionBroker,
+		brokerToken: opts.SessionBrokerToken,
+	}
+	// a recording holds the whole run from createSession, so cached sessions are neither resumed nor overwritten
+	if opts.VCR != "" {
+		client.Profile = ""
+	}
+	if opts.CacheTTL > 0 {
+		client.Cache, err = NewResponseCache(opts.CacheTTL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// with a session broker, its session is used as-is and never cached or refreshed here
+	if client.broker != "" {
+		if err := client.brokerSession(ctx, ""); err != nil {
+			return nil, err
+		}
+		if err := client.openWriteBudget(opts.WriteBudget); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	if client.resumeSession(ctx) {
+		if err := client.openWriteBudget(opts.WriteBudget); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	// discover the PDS from the DID document of the account, falling back to https://bsky.social
+	discover := client.BaseURL == ""
+	if discover {
+		client.BaseURL = "https://bsky.social"
+		// email identifiers cannot be resolved before authenticating
+		if client.Identifier != "" && !strings.Contains(client.Identifier, "@") {
+			pds, err := DiscoverPDS(ctx, httpClient, client.Identifier)
+			if err != nil {
+				log.Printf("failed to discover PDS for %s, using %s: %v\n", client.Identifier, client.BaseURL, err)
+			} else {
+				client.BaseURL = pds
+			}
+		}
+	}
+
+	session, err := client.CreateSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the session DID document names the PDS that hosts the account, e.g. when authenticating via an entryway
+	if discover {
+		if pds := session.DIDDoc.PDSEndpoint(); pds != "" {
+			client.BaseURL = pds
+		}
+	}
+	client.saveSession()
+
+	if err := client.openWriteBudget(opts.WriteBudget); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// CreateSession authenticates to the Bluesky API using the provided credentials and sets the AuthToken on the client
+func (c *Client) CreateSession(ctx context.Context) (*CreateSessionResponse, error) {
+	url := c.BaseURL + "/xrpc/com.atproto.server.createSession"
+	req := map[string]string{
+		"identifier": c.Identifier,
+		"password":   c.password,
+	}
+	body, err := c.SendRequest(ctx, "POST", url, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var createSessionResponse CreateSessionResponse
+	if err := json.Unmarshal(body, &createSessionResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	if createSessionResponse.AccessJwt == "" {
+		return nil, fmt.Errorf("failed to authenticate: missing access token")
+	}
+	c.AuthToken = createSessionResponse.AccessJwt
+	c.Session = createSessionResponse
+	return &createSessionResponse, nil
+}
+
+// SendRequest makes a generic request to a given URL. requestBody is sent as JSON, or as-is when it is a []byte
+func (c *Client) SendRequest(ctx context.Context, method, url string, requestBody interface{}) ([]byte, error) {
+	return c.SendRequestWithHeaders(ctx, method, url, requestBody, nil)
+}
+
+// SendRequestWithHeaders makes a generic request to a given URL with additional request headers.
+// it waits when the rate-limit budget or the shared write budget is nearly exhausted and retries 429 responses once
+// the window resets.
+// each request, with its retries and waits, is traced as a span when OTLP export is configured.
+func (c *Client) SendRequestWithHeaders(ctx context.Context, method, url string, requestBody interface{}, headers map[string]string) ([]byte, error) {
+	ctx, span := startSpan(ctx, method+" "+xrpcMethod(url), spanKindClient)
+	span.SetAttr("http.request.method", method)
+	span.SetAttr("rpc.method", xrpcMethod(url))
+	span.SetAttr("url.full", url)
+
+	body, err := c.sendRequest(ctx, method, url, requestBody, headers)
+	if err != nil {
+		c.failedRequests.Add(1)
+	}
+	span.End(err)
+	return body, err
+}
+
+// sendRequest makes a request, retrying rate-limited, expired-token, and transient failures
+func (c *Client) sendRequest(ctx context.Context, method, url string, requestBody interface{}, headers map[string]string) ([]byte, error) {
+	var b []byte
+	var err error
+	if raw, ok := requestBody.([]byte); ok {
+		// raw bodies such as CAR files and blobs are sent as-is, with the Content-Type given in headers
+		b = raw
+	} else if requestBody != nil {
+		b, err = json.Marshal(requestBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+	}
+
+	// GET responses of the cacheable methods are served from the cache while fresh, and revalidated once stale
+	var cacheKey string
+	var cached *cacheEntry
+	if c.Cache != nil && method == http.MethodGet && cacheable(ctx, url) {
+		cacheKey = c.cacheKey(ctx, url, headers)
+		cached = c.Cache.Get(cacheKey)
+		if c.Cache.Fresh(cached) {
+			metrics.Add(metricCache, 1, "hit")
+			spanFromContext(ctx).SetAttr("bluegopher.cache", "hit")
+			return cached.Body, nil
+		}
+		if cached != nil {
+			headers = conditionalHeaders(headers, cached)
+		}
+	}
+
+	// repository writes spend the budget shared by every process writing to the account. the points of a write the
+	// PDS rejects are refunded, but not those of a write that failed without a response, which may have been applied.
+	var reserved int
+	if c.WriteBudget != nil {
+		if points := requestWritePoints(method, url, b); points > 0 {
+			if err := c.WriteBudget.Reserve(ctx, points); err != nil {
+				return nil, err
+			}
+			reserved = points
+		}
+	}
+	refund := func() {
+		if reserved == 0 {
+			return
+		}
+		if err := c.WriteBudget.Refund(ctx, reserved); err != nil {
+			log.Printf("failed to refund write budget: %v\n", err)
+		}
+	}
+
+	rateLimitRetries := 0
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		if err := c.waitForRateLimit(ctx); err != nil {
+			return nil, err
+		}
+
+		token := c.accessToken()
+		res, body, err := c.doRequest(ctx, method, url, b, headers)
+		if err != nil {
+			if attempt < c.Retry.Attempts && ctx.Err() == nil && retryableError(method, err) {
+				if err := c.backoff(ctx, attempt, err.Error()); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, err
+		}
+		c.updateRateLimit(res.Header)
+
+		if res.StatusCode == http.StatusTooManyRequests && rateLimitRetries < maxRateLimitRetries {
+			rateLimitRetries++
+			wait := rateLimitWait(res.Header)
+			spanFromContext(ctx).AddEvent("rate limited", map[string]interface{}{"wait_ms": wait.Milliseconds()})
+			log.Printf("rate limited: retrying in %s (attempt %d/%d)\n", wait, rateLimitRetries, maxRateLimitRetries)
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+			attempt--
+			continue
+		}
+
+		// access tokens expire after a couple of hours, so long runs refresh the session once and retry
+		if res.StatusCode == http.StatusBadRequest && !refreshed && (c.refreshToken() != "" || c.broker != "") && bytes.Contains(body, []byte("ExpiredToken")) {
+			refreshed = true
+			if err := c.refreshExpired(ctx, token); err != nil {
+				refund()
+				return nil, err
+			}
+			attempt--
+			continue
+		}
+
+		if attempt < c.Retry.Attempts && retryableStatus(method, res.StatusCode) {
+			if err := c.backoff(ctx, attempt, fmt.Sprintf("status code %d", res.StatusCode)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if res.StatusCode == http.StatusNotModified && cached != nil {
+			metrics.Add(metricCache, 1, "revalidated")
+			spanFromContext(ctx).SetAttr("bluegopher.cache", "revalidated")
+			c.Cache.Revalidated(cacheKey, cached)
+			return cached.Body, nil
+		}
+
+		if res.StatusCode != http.StatusOK {
+			refund()
+			return nil, fmt.Errorf("request failed with status code %d: %s", res.StatusCode, body)
+		}
+
+		if cacheKey != "" {
+			metrics.Add(metricCache, 1, "miss")
+			c.Cache.Put(cacheKey, url, res.Header, body)
+		}
+		return body, nil
+	}
+}
+
+// doRequest executes a single HTTP request and reads the full response body
+func (c *Client) doRequest(ctx context.Context, method, url string, b []byte, headers map[string]string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := c.accessToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if len(c.Labelers) > 0 {
+		req.Header.Set("atproto-accept-labelers", strings.Join(c.Labelers, ", "))
+	}
+	if proxy := proxyFromContext(ctx); proxy != "" {
+		req.Header.Set("atproto-proxy", proxy)
+	}
+	span := spanFromContext(ctx)
+	if span != nil {
+		req.Header.Set("traceparent", span.traceparent())
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c.requests.Add(1)
+	res, err := httpClient.Do(req)
+	if err != nil {
+		metrics.Add(metricRequests, 1, xrpcMethod(url), "error")
+		return nil, nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	metrics.Add(metricRequests, 1, xrpcMethod(url), strconv.Itoa(res.StatusCode))
+	span.SetAttr("http.response.status_code", res.StatusCode)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return res, body, nil
+}
+
+// GetAuthorFeed retrieves a page of an author feed
+func (c *Client) GetAuthorFeed(ctx context.Context, actor string, limit int, cursor, filter string, includePins bool) (*AuthorFeedResponse, error) {
+	params := url.Values{}
+	params.Set("actor", actor)
+	params.Set("limit", fmt.Sprintf("%d", limit))
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	if filter != "" {
+		params.Set("filter", filter)
+	}
+	params.Set("includePins", fmt.Sprintf("%t", includePins))
+
+	var response AuthorFeedResponse
+	if err := c.GetJSON(ctx, "app.bsky.feed.getAuthorFeed", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// GetProfile retrieves the profile for a given actor
+func (c *Client) GetProfile(ctx context.Context, actor string) (*Profile, error) {
+	params := url.Values{}
+	params.Set("actor", actor)
+
+	var profile Profile
+	if err := c.GetJSON(ctx, "app.bsky.actor.getProfile", params, &profile); err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// GetProfiles retrieves up to 25 profiles
+func (c *Client) GetProfiles(ctx context.Context, actors []string) (*ProfilesResponse, error) {
+	if len(actors) > 25 {
+		return nil, fmt.Errorf("too many actors: maximum allowed is 25")
+	}
+
+	params := url.Values{}
+	for _, actor := range actors {
+		params.Add("actors", actor)
+	}
+
+	var response ProfilesResponse
+	if err := c.GetJSON(ctx, "app.bsky.actor.getProfiles", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// GetAccounts retrieves the followers of a specified actor from the Bluesky API using the session
+func (c *Client) GetAccounts(ctx context.Context, endpoint, actor string, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + endpoint
+	params := url.Values{}
+	params.Add("actor", actor)
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// CreateRecord creates a record in the Bluesky API
+func (c *Client) CreateRecord(ctx context.Context, request CreateRecordRequest) (map[string]interface{}, error) {
+	url := c.BaseURL + "/xrpc/com.atproto.repo.createRecord"
+
+	res, err := c.SendRequest(ctx, "POST", url, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(res, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// SearchPosts searches posts in the Bluesky API. empty parameters are left out of the query.
+func (c *Client) SearchPosts(ctx context.Context, q string, limit int, cursor, sort, since, until, mentions, author, lang, domain, postURL string, tags []string) (*SearchPostsResponse, error) {
+	params := url.Values{}
+	params.Add("q", q)
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	if sort != "" {
+		params.Add("sort", sort)
+	}
+	if since != "" {
+		params.Add("since", since)
+	}
+	if until != "" {
+		params.Add("until", until)
+	}
+	if mentions != "" {
+		params.Add("mentions", mentions)
+	}
+	if author != "" {
+		params.Add("author", author)
+	}
+	if lang != "" {
+		params.Add("lang", lang)
+	}
+	if domain != "" {
+		params.Add("domain", domain)
+	}
+	if postURL != "" {
+		params.Add("url", postURL)
+	}
+	for _, tag := range tags {
+		params.Add("tag", tag)
+	}
+
+	var response SearchPostsResponse
+	if err := c.GetJSON(ctx, "app.bsky.feed.searchPosts", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// ListCreate creates a list in the Bluesky API
+func (c *Client) ListCreate(ctx context.Context, purpose, name, description string, createdAt time.Time) (map[string]interface{}, error) {
+	url := c.BaseURL + "/xrpc/com.atproto.repo.createRecord"
+
+	request := CreateRecordRequest{
+		Repo:       c.Session.DID,
+		Collection: "app.bsky.graph.list",
+		Record: struct {
+			Name        string `json:"name"`
+			Purpose     string `json:"purpose"`
+			Description string `json:"description,omitempty"`
+			CreatedAt   string `json:"createdAt"`
+			Type        string `json:"$type"`
+		}{
+			Name:        name,
+			Purpose:     purpose,
+			Description: description,
+			CreatedAt:   createdAt.Format(time.RFC3339),
+			Type:        "app.bsky.graph.list",
+		},
+	}
+
+	res, err := c.SendRequest(ctx, "POST", url, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(res, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListItem adds a member to a list in the Bluesky API
+func (c *Client) ListItem(ctx context.Context, listURI, did string, createdAt time.Time) (map[string]interface{}, error) {
+	url := c.BaseURL + "/xrpc/com.atproto.repo.createRecord"
+
+	request := CreateRecordRequest{
+		Repo:       c.Session.DID,
+		Collection: "app.bsky.graph.listitem",
+		Record: struct {
+			Subject   string `json:"subject"`
+			List      string `json:"list"`
+			CreatedAt string `json:"createdAt"`
+			Type      string `json:"$type"`
+		}{
+			Subject:   did,
+			List:      listURI,
+			CreatedAt: createdAt.Format(time.RFC3339),
+			Type:      "app.bsky.graph.listitem",
+		},
+	}
+
+	res, err := c.SendRequest(ctx, "POST", url, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(res, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// ListATURI converts a bsky.app list URL to its AT URI. AT URIs are returned unchanged.
+func (c *Client) ListATURI(ctx context.Context, listURL string) (string, error) {
+	return c.collectionATURI(ctx, listURL, "app.bsky.graph.list")
+}
+
+// QueryLabels retrieves labels applied to subjects matching the URI patterns. patterns may end in a * wildcard.
+func (c *Client) QueryLabels(ctx context.Context, uriPatterns, sources []string, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/com.atproto.label.queryLabels"
+	params := url.Values{}
+	for _, pattern := range uriPatterns {
+		params.Add("uriPatterns", pattern)
+	}
+	for _, source := range sources {
+		params.Add("sources", source)
+	}
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// ResolveDID returns the DID for an actor, looking up the profile when given a handle
+func (c *Client) ResolveDID(ctx context.Context, actor string) (string, error) {
+	if strings.HasPrefix(actor, "did:") {
+		return actor, nil
+	}
+
+	profile, err := c.GetProfile(ctx, actor)
+	if err != nil {
+		return "", err
+	}
+
+	if profile.DID == "" {
+		return "", fmt.Errorf("failed to get DID from profile")
+	}
+
+	return profile.DID, nil
+}
+
+// sleepContext pauses for the given duration or until the context is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// GetServiceAuth mints a short-lived token signed by the account for a service DID (aud), optionally restricted to a
+// single lexicon method (lxm). exp is optional and defaults to 60 seconds on the PDS.
+func (c *Client) GetServiceAuth(ctx context.Context, aud string, exp time.Time, lxm string) (string, error) {
+	baseURL := c.BaseURL + "/xrpc/com.atproto.server.getServiceAuth"
+	params := url.Values{}
+	params.Add("aud", aud)
+	if !exp.IsZero() {
+		params.Add("exp", fmt.Sprintf("%d", exp.Unix()))
+	}
+	if lxm != "" {
+		params.Add("lxm", lxm)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if response.Token == "" {
+		return "", fmt.Errorf("missing token in response")
+	}
+
+	return response.Token, nil
+}
+
+// RequestStats returns the number of HTTP requests sent by the client and the number of requests that failed after
+// retries
+func (c *Client) RequestStats() (requests, failed int64) {
+	return c.requests.Load(), c.failedRequests.Load()
+}
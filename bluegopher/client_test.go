@@ -0,0 +1,701 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewClientCreatesThenResumesSession(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	if c.Session.DID != testDID || c.AuthToken == "" {
+		t.Fatalf("session = %+v, want the session of %s", c.Session, testDID)
+	}
+
+	// the second client resumes the cached session instead of creating another
+	again, err := NewClient(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.AuthToken != c.AuthToken {
+		t.Errorf("resumed token differs from the cached one")
+	}
+	if pds.sessions != 1 {
+		t.Errorf("createSession called %d times, want 1", pds.sessions)
+	}
+}
+
+func TestNewClientRejectsWrongPassword(t *testing.T) {
+	pds := newFakePDS(t)
+	setTestEnv(t, pds)
+	t.Setenv("BLUESKY_PASSWORD", "wrong")
+
+	_, err := NewClient(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "AuthenticationRequired") {
+		t.Fatalf("err = %v, want AuthenticationRequired", err)
+	}
+}
+
+func TestClientRefreshesExpiredToken(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	pds.expireAccessToken()
+
+	profile, err := c.GetProfile(context.Background(), testHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.DID != testDID {
+		t.Errorf("DID = %q, want %q", profile.DID, testDID)
+	}
+	if pds.refreshes != 1 || pds.sessions != 1 {
+		t.Errorf("refreshes = %d, sessions = %d, want 1 and 1", pds.refreshes, pds.sessions)
+	}
+
+	// the refreshed session is cached for the next run
+	cached, err := loadSession(c.Profile)
+	if err != nil || cached == nil || cached.Session.AccessJwt != c.AuthToken {
+		t.Errorf("cached session not updated after refresh: %v", err)
+	}
+}
+
+func TestClientRefreshesExpiredTokenConcurrently(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	pds.expireAccessToken()
+
+	// concurrent workers all fail with the expired token, and only one of them refreshes the session
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetAuthorFeed(context.Background(), testDID, 10, "", "", false)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pds.mu.Lock()
+	defer pds.mu.Unlock()
+	if pds.refreshes != 1 || pds.sessions != 1 {
+		t.Errorf("refreshes = %d, sessions = %d, want 1 and 1", pds.refreshes, pds.sessions)
+	}
+	if c.accessToken() != pds.accessJwt {
+		t.Errorf("client token is not the refreshed one")
+	}
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	pds.queue("app.bsky.actor.getProfile", fakeResponse{Status: http.StatusServiceUnavailable}, fakeResponse{Status: http.StatusBadGateway})
+
+	if _, err := c.GetProfile(context.Background(), testHandle); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(pds.calls("app.bsky.actor.getProfile")); n != 3 {
+		t.Errorf("getProfile sent %d times, want 3", n)
+	}
+
+	// a write that failed with a 500 may have been applied, so it is not retried
+	pds.queue("com.atproto.repo.createRecord", fakeResponse{Status: http.StatusInternalServerError, Body: `{"error":"InternalServerError"}`})
+	if _, err := c.Follow(context.Background(), "did:plc:bob", time.Now()); err == nil {
+		t.Fatal("Follow succeeded, want the 500 error")
+	}
+	if n := len(pds.calls("com.atproto.repo.createRecord")); n != 1 {
+		t.Errorf("createRecord sent %d times, want 1", n)
+	}
+	if requests, failed := c.RequestStats(); failed != 1 || requests < 5 {
+		t.Errorf("RequestStats = %d, %d, want at least 5 requests and 1 failure", requests, failed)
+	}
+}
+
+func TestClientRetriesRateLimited(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	reset := fmt.Sprint(time.Now().Add(time.Hour).Unix())
+	pds.queue("app.bsky.actor.getProfile",
+		fakeResponse{Status: http.StatusTooManyRequests, Header: map[string]string{"retry-after": "1"}, Body: `{"error":"RateLimitExceeded"}`},
+		fakeResponse{Status: http.StatusOK, Header: map[string]string{"ratelimit-limit": "3000", "ratelimit-remaining": "2999", "ratelimit-reset": reset}, Body: `{"did":"did:plc:alice","handle":"alice.test"}`},
+	)
+
+	if _, err := c.GetProfile(context.Background(), testHandle); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(pds.calls("app.bsky.actor.getProfile")); n != 2 {
+		t.Errorf("getProfile sent %d times, want 2", n)
+	}
+	if rl := c.RateLimitStatus(); rl.Limit != 3000 || rl.Remaining != 2999 || rl.Reset.IsZero() {
+		t.Errorf("RateLimitStatus = %+v, want the headers of the last response", rl)
+	}
+}
+
+func TestClientProfiles(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+
+	profile, err := c.GetProfile(ctx, "bob.test")
+	if err != nil || profile.DID != "did:plc:bob" {
+		t.Errorf("GetProfile = %+v, %v", profile, err)
+	}
+
+	profiles, err := c.GetProfiles(ctx, []string{testHandle, "did:plc:bob", "nobody.test"})
+	if err != nil || len(profiles.Profiles) != 2 || profiles.Profiles[1].Handle != "bob.test" {
+		t.Errorf("GetProfiles = %+v, %v", profiles, err)
+	}
+	if _, err := c.GetProfiles(ctx, make([]string, 26)); err == nil {
+		t.Error("GetProfiles accepted 26 actors")
+	}
+
+	if did, err := c.ResolveHandle(ctx, "carol.test"); err != nil || did != "did:plc:carol" {
+		t.Errorf("ResolveHandle = %q, %v", did, err)
+	}
+	before := len(pds.calls("app.bsky.actor.getProfile"))
+	if did, err := c.ResolveDID(ctx, "did:plc:carol"); err != nil || did != "did:plc:carol" {
+		t.Errorf("ResolveDID(did) = %q, %v", did, err)
+	}
+	if did, err := c.ResolveDID(ctx, "bob.test"); err != nil || did != "did:plc:bob" {
+		t.Errorf("ResolveDID(handle) = %q, %v", did, err)
+	}
+	if n := len(pds.calls("app.bsky.actor.getProfile")) - before; n != 1 {
+		t.Errorf("ResolveDID looked up %d profiles, want only the handle", n)
+	}
+	if _, err := c.ResolveDID(ctx, "nobody.test"); err == nil {
+		t.Error("ResolveDID resolved an unknown handle")
+	}
+}
+
+func TestClientPaginatesGraph(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+
+	followers, err := c.AllFollowers(ctx, testHandle)
+	if err != nil || len(followers) != 250 || followers[249].DID != "did:plc:follower249" {
+		t.Errorf("AllFollowers returned %d followers, %v", len(followers), err)
+	}
+	if n := len(pds.calls("app.bsky.graph.getFollowers")); n != 3 {
+		t.Errorf("AllFollowers fetched %d pages, want 3", n)
+	}
+
+	follows, err := c.AllFollows(ctx, testDID)
+	if err != nil || len(follows) != 120 {
+		t.Errorf("AllFollows returned %d follows, %v", len(follows), err)
+	}
+
+	page, err := c.GetAccounts(ctx, "/xrpc/app.bsky.graph.getFollowers", testHandle, 100, "200")
+	if err != nil || len(page["followers"].([]interface{})) != 50 || page["cursor"] != "" {
+		t.Errorf("GetAccounts last page = %d followers, cursor %v, %v", len(page["followers"].([]interface{})), page["cursor"], err)
+	}
+
+	list, members, err := c.AllListMembers(ctx, "at://"+testDID+"/app.bsky.graph.list/3kgophers")
+	if err != nil || len(members) != 150 || list["name"] != "Gophers" {
+		t.Errorf("AllListMembers = %v, %d members, %v", list, len(members), err)
+	}
+
+	mutes, err := c.GetMutes(ctx, 2, "")
+	if err != nil || len(mutes.Mutes) != 2 || mutes.Cursor == "" {
+		t.Fatalf("GetMutes = %+v, %v", mutes, err)
+	}
+	rest, err := c.GetMutes(ctx, 2, mutes.Cursor)
+	if err != nil || len(rest.Mutes) != 1 || rest.Cursor != "" {
+		t.Errorf("GetMutes second page = %+v, %v", rest, err)
+	}
+	if err := c.MuteActor(ctx, "did:plc:bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UnmuteActor(ctx, "did:plc:muted000"); err != nil {
+		t.Fatal(err)
+	}
+	state, err := c.loadModeration(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bob := state.mutes["did:plc:bob"]
+	_, unmuted := state.mutes["did:plc:muted000"]
+	if len(state.mutes) != 3 || !bob || unmuted {
+		t.Errorf("mutes after mute and unmute = %v", state.mutes)
+	}
+}
+
+func TestClientPaginatesFeeds(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+
+	// the repost of the author feed is skipped
+	var texts []string
+	err := c.EachAuthorPost(ctx, testHandle, 2, "", func(post PostView) error {
+		texts = append(texts, post.Record.Text)
+		return nil
+	})
+	if err != nil || len(texts) != 5 || texts[4] != "gopher post 4" {
+		t.Errorf("EachAuthorPost = %q, %v", texts, err)
+	}
+	if n := len(pds.calls("app.bsky.feed.getAuthorFeed")); n != 3 {
+		t.Errorf("EachAuthorPost fetched %d pages, want 3", n)
+	}
+
+	feed, err := c.GetAuthorFeed(ctx, testHandle, 3, "", "posts_no_replies", true)
+	if err != nil || len(feed.Feed) != 3 || feed.Cursor != "3" {
+		t.Errorf("GetAuthorFeed = %+v, %v", feed, err)
+	}
+	q := pds.lastRequest().Query
+	if q.Get("filter") != "posts_no_replies" || q.Get("includePins") != "true" {
+		t.Errorf("getAuthorFeed params = %v", q)
+	}
+
+	feed, err = c.GetAuthorFeed(ctx, testDID, 100, "", "", false)
+	if err != nil || len(feed.Feed) != 6 || len(feed.Feed[1].Reason) == 0 || len(feed.Feed[0].Raw) == 0 {
+		t.Errorf("GetAuthorFeed = %d items, %v", len(feed.Feed), err)
+	}
+
+	search, err := c.SearchPosts(ctx, "gopher", 2, "", "latest", "2024-01-01T00:00:00Z", "", "", testHandle, "en", "", "", []string{"go", "gophers"})
+	if err != nil || len(search.Posts) != 2 {
+		t.Errorf("SearchPosts = %+v, %v", search, err)
+	}
+	if q := pds.lastRequest().Query; q.Get("sort") != "latest" || len(q["tag"]) != 2 || q.Get("author") != testHandle || q.Get("since") == "" {
+		t.Errorf("searchPosts params = %v", q)
+	}
+
+	var found []PostView
+	cursor := ""
+	for {
+		resp, err := c.SearchPosts(ctx, "post", 2, cursor, "", "", "", "", "", "", "", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = append(found, resp.Posts...)
+		if resp.Cursor == "" {
+			break
+		}
+		cursor = resp.Cursor
+	}
+	if len(found) != 5 {
+		t.Errorf("SearchPosts pages returned %d posts, want 5", len(found))
+	}
+
+	for name, get := range map[string]func() (*AuthorFeedResponse, error){
+		"GetTimeline": func() (*AuthorFeedResponse, error) { return c.GetTimeline(ctx, 4, "") },
+		"GetListFeed": func() (*AuthorFeedResponse, error) {
+			return c.GetListFeed(ctx, "at://did:plc:alice/app.bsky.graph.list/3kgophers", 4, "")
+		},
+		"GetFeed": func() (*AuthorFeedResponse, error) {
+			return c.GetFeed(ctx, "at://did:plc:alice/app.bsky.feed.generator/gophers", 4, "")
+		},
+		"GetTimeline page 2": func() (*AuthorFeedResponse, error) { return c.GetTimeline(ctx, 4, "4") },
+	} {
+		resp, err := get()
+		if err != nil || len(resp.Feed) == 0 {
+			t.Errorf("%s = %+v, %v", name, resp, err)
+		}
+	}
+
+	posts, err := c.GetPosts(ctx, []string{"at://did:plc:alice/app.bsky.feed.post/3kpost2", "at://did:plc:alice/app.bsky.feed.post/3kpost4"})
+	if err != nil || len(posts) != 2 || posts[1].Record.Text != "gopher post 4" {
+		t.Errorf("GetPosts = %+v, %v", posts, err)
+	}
+	if _, err := c.GetPosts(ctx, make([]string, 26)); err == nil {
+		t.Error("GetPosts accepted 26 URIs")
+	}
+
+	thread, err := c.GetPostThread(ctx, "at://did:plc:alice/app.bsky.feed.post/3kpost1", 6)
+	if err != nil || thread.Thread.Parent == nil || len(thread.Thread.Replies) != 1 {
+		t.Errorf("GetPostThread = %+v, %v", thread, err)
+	}
+
+	notifications, err := c.ListNotifications(ctx, 50, "")
+	if err != nil || len(notifications.Notifications) != 1 || notifications.Notifications[0].Reason != "like" {
+		t.Errorf("ListNotifications = %+v, %v", notifications, err)
+	}
+}
+
+func TestClientWritesRecords(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+	now := time.Date(2024, 11, 2, 9, 0, 0, 0, time.UTC)
+
+	ref, err := c.Post(ctx, PostRecord{Text: "hello @bob.test see https://go.dev."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts := pds.collection("app.bsky.feed.post")
+	if len(posts) != 1 || posts[0].URI != ref.URI || ref.CID == "" {
+		t.Fatalf("posts = %+v, ref = %+v", posts, ref)
+	}
+	facets, _ := json.Marshal(posts[0].Value["facets"])
+	if !strings.Contains(string(facets), "did:plc:bob") || !strings.Contains(string(facets), `"uri":"https://go.dev"`) {
+		t.Errorf("facets = %s, want the mention of bob and the link", facets)
+	}
+
+	refs, err := c.PostThread(ctx, []string{"one", "two", "three"})
+	if err != nil || len(refs) != 3 {
+		t.Fatalf("PostThread = %v, %v", refs, err)
+	}
+	last := pds.collection("app.bsky.feed.post")[3].Value["reply"].(map[string]interface{})
+	if last["root"].(map[string]interface{})["uri"] != refs[0].URI || last["parent"].(map[string]interface{})["uri"] != refs[1].URI {
+		t.Errorf("reply of the last part = %v", last)
+	}
+
+	for name, write := range map[string]func() (map[string]interface{}, error){
+		"app.bsky.feed.like":    func() (map[string]interface{}, error) { return c.Like(ctx, ref) },
+		"app.bsky.feed.repost":  func() (map[string]interface{}, error) { return c.Repost(ctx, ref) },
+		"app.bsky.graph.follow": func() (map[string]interface{}, error) { return c.Follow(ctx, "did:plc:bob", now) },
+		"app.bsky.graph.block":  func() (map[string]interface{}, error) { return c.Block(ctx, "did:plc:carol", now) },
+		"app.bsky.graph.list": func() (map[string]interface{}, error) {
+			return c.ListCreate(ctx, "app.bsky.graph.defs#curatelist", "Gophers", "", now)
+		},
+		"app.bsky.graph.listitem": func() (map[string]interface{}, error) {
+			return c.ListItem(ctx, "at://did:plc:alice/app.bsky.graph.list/3k", "did:plc:bob", now)
+		},
+		"app.bsky.actor.status": func() (map[string]interface{}, error) {
+			return c.CreateRecord(ctx, CreateRecordRequest{Repo: c.Session.DID, Collection: "app.bsky.actor.status", Rkey: "self", Record: map[string]string{"$type": "app.bsky.actor.status"}})
+		},
+	} {
+		resp, err := write()
+		if err != nil || !strings.HasPrefix(resp["uri"].(string), "at://"+testDID+"/"+name+"/") {
+			t.Errorf("%s: %v, %v", name, resp, err)
+		}
+		if records := pds.collection(name); len(records) != 1 {
+			t.Errorf("%s has %d records, want 1", name, len(records))
+		}
+	}
+	if follow := pds.collection("app.bsky.graph.follow")[0].Value; follow["subject"] != "did:plc:bob" || follow["createdAt"] != "2024-11-02T09:00:00Z" {
+		t.Errorf("follow record = %v", follow)
+	}
+
+	_, _, rkey, _ := splitATURI(ref.URI)
+	if err := c.DeleteRecord(ctx, "app.bsky.feed.post", rkey); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(pds.collection("app.bsky.feed.post")); n != 3 {
+		t.Errorf("%d posts after delete, want 3", n)
+	}
+}
+
+func TestClientAppliesWritesInBatches(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+
+	var writes []WriteOp
+	for i := 0; i < 250; i++ {
+		writes = append(writes, CreateOp("app.bsky.graph.listitem", listItemRecord{Type: "app.bsky.graph.listitem", Subject: testAccount("member", i).DID, List: "at://did:plc:alice/app.bsky.graph.list/3k", CreatedAt: "2024-11-02T09:00:00Z"}))
+	}
+	var done []int
+	if err := c.applyWritesBatched(ctx, writes, 0, func(batch []WriteOp) error {
+		done = append(done, len(batch))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(done) != "[100 100 50]" {
+		t.Errorf("batches = %v, want [100 100 50]", done)
+	}
+
+	// EachRecord pages through listRecords 100 at a time
+	members, err := c.listMembers(ctx, "at://did:plc:alice/app.bsky.graph.list/3k")
+	if err != nil || len(members) != 250 {
+		t.Fatalf("listMembers returned %d members, %v", len(members), err)
+	}
+	if n := len(pds.calls("com.atproto.repo.listRecords")); n != 3 {
+		t.Errorf("EachRecord fetched %d pages, want 3", n)
+	}
+
+	page, err := c.ListRecords(ctx, testDID, "app.bsky.graph.listitem", 10, "245")
+	if err != nil || len(page["records"].([]interface{})) != 5 {
+		t.Errorf("ListRecords last page = %v, %v", page, err)
+	}
+
+	var deletes []WriteOp
+	for did, uris := range members {
+		if strings.HasSuffix(did, "0") {
+			_, _, rkey, _ := splitATURI(uris[0])
+			deletes = append(deletes, DeleteOp("app.bsky.graph.listitem", rkey))
+		}
+	}
+	if _, err := c.ApplyWrites(ctx, deletes); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(pds.collection("app.bsky.graph.listitem")); n != 225 {
+		t.Errorf("%d list items after deleting 25, want 225", n)
+	}
+
+	// every record of a subject added twice is kept
+	duplicate := CreateOp("app.bsky.graph.listitem", writes[1].Value)
+	if _, err := c.ApplyWrites(ctx, []WriteOp{duplicate}); err != nil {
+		t.Fatal(err)
+	}
+	members, err = c.listMembers(ctx, "at://did:plc:alice/app.bsky.graph.list/3k")
+	if did := testAccount("member", 1).DID; err != nil || len(members) != 225 || len(members[did]) != 2 {
+		t.Errorf("listMembers = %d members, %d records of %s, %v", len(members), len(members[did]), did, err)
+	}
+}
+
+func TestClientResolvesURLs(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name string
+		fn   func(context.Context, string) (string, error)
+		in   string
+		want string
+	}{
+		{"ATURI profile", c.ATURI, "https://bsky.app/profile/bob.test", "at://did:plc:bob"},
+		{"ATURI post", c.ATURI, "https://bsky.app/profile/alice.test/post/3kpost0?ref=x", "at://did:plc:alice/app.bsky.feed.post/3kpost0"},
+		{"ATURI unchanged", c.ATURI, "at://did:plc:bob/app.bsky.feed.post/3k", "at://did:plc:bob/app.bsky.feed.post/3k"},
+		{"ListATURI", c.ListATURI, "https://bsky.app/profile/alice.test/lists/3kgophers", "at://did:plc:alice/app.bsky.graph.list/3kgophers"},
+		{"FeedATURI", c.FeedATURI, "https://bsky.app/profile/did:plc:alice/feed/gophers", "at://did:plc:alice/app.bsky.feed.generator/gophers"},
+		{"StarterPackListURI", c.StarterPackListURI, "https://bsky.app/starter-pack/alice.test/3kpack", "at://did:plc:alice/app.bsky.graph.list/3kgophers"},
+	} {
+		got, err := tt.fn(ctx, tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("%s(%q) = %q, %v, want %q", tt.name, tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := c.ListATURI(ctx, "https://bsky.app/profile/alice.test/feed/gophers"); err == nil {
+		t.Error("ListATURI accepted a feed URL")
+	}
+	if _, err := c.ATURI(ctx, "https://bsky.app/search?q=go"); err == nil {
+		t.Error("ATURI accepted a search URL")
+	}
+
+	// bs:atUri converts URLs holding a DID without credentials or a session
+	t.Setenv("BLUESKY_HANDLE", "")
+	t.Setenv("BLUESKY_PASSWORD", "")
+	if err := (Bs{}).AtUri(ctx, "https://bsky.app/profile/did:plc:bob/post/3k"); err != nil {
+		t.Errorf("bs:atUri without credentials: %v", err)
+	}
+	if pds.sessions != 1 {
+		t.Errorf("createSession called %d times, want only the session of the client", pds.sessions)
+	}
+}
+
+// TestClientMethods calls each remaining method against the canned responses of testdata/pds, checking the XRPC
+// method it calls and that the response decodes
+func TestClientMethods(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		name   string
+		nsid   string
+		method string
+		call   func() (interface{}, error)
+	}{
+		{"GetPreferences", "app.bsky.actor.getPreferences", "GET", func() (interface{}, error) { return c.GetPreferences(ctx) }},
+		{"PutPreferences", "app.bsky.actor.putPreferences", "POST", func() (interface{}, error) {
+			return nil, c.PutPreferences(ctx, json.RawMessage(`{"preferences":[]}`))
+		}},
+		{"ListConvos", "chat.bsky.convo.listConvos", "GET", func() (interface{}, error) { return c.ListConvos(ctx, 10, "") }},
+		{"GetMessages", "chat.bsky.convo.getMessages", "GET", func() (interface{}, error) { return c.GetMessages(ctx, "convo1", 10, "") }},
+		{"GetConvoForMembers", "chat.bsky.convo.getConvoForMembers", "GET", func() (interface{}, error) {
+			return c.GetConvoForMembers(ctx, []string{"did:plc:bob"})
+		}},
+		{"SendMessage", "chat.bsky.convo.sendMessage", "POST", func() (interface{}, error) { return c.SendMessage(ctx, "convo1", "hi bob") }},
+		{"QueryLabels", "com.atproto.label.queryLabels", "GET", func() (interface{}, error) {
+			return c.QueryLabels(ctx, []string{"at://did:plc:alice/*"}, []string{"did:plc:labeler"}, 50, "")
+		}},
+		{"GetServiceAuth", "com.atproto.server.getServiceAuth", "GET", func() (interface{}, error) {
+			return c.GetServiceAuth(ctx, "did:web:pds.test", time.Now().Add(time.Minute), "com.atproto.server.createAccount")
+		}},
+		{"GetPopularFeedGenerators", "app.bsky.unspecced.getPopularFeedGenerators", "GET", func() (interface{}, error) {
+			return c.GetPopularFeedGenerators(ctx, "gophers", 10, "")
+		}},
+		{"GetTrendingTopics", "app.bsky.unspecced.getTrendingTopics", "GET", func() (interface{}, error) { return c.GetTrendingTopics(ctx, 10) }},
+		{"GetFeedGenerator", "app.bsky.feed.getFeedGenerator", "GET", func() (interface{}, error) {
+			return c.GetFeedGenerator(ctx, "at://did:plc:alice/app.bsky.feed.generator/gophers")
+		}},
+		{"GetFeedGenerators", "app.bsky.feed.getFeedGenerators", "GET", func() (interface{}, error) {
+			return c.GetFeedGenerators(ctx, []string{"at://did:plc:alice/app.bsky.feed.generator/gophers"})
+		}},
+		{"UpdateHandle", "com.atproto.identity.updateHandle", "POST", func() (interface{}, error) {
+			return nil, c.UpdateHandle(ctx, "alice.example.com")
+		}},
+		{"DescribeServer", "com.atproto.server.describeServer", "GET", func() (interface{}, error) { return c.DescribeServer(ctx) }},
+		{"CheckAccountStatus", "com.atproto.server.checkAccountStatus", "GET", func() (interface{}, error) { return c.CheckAccountStatus(ctx) }},
+		{"ImportRepo", "com.atproto.repo.importRepo", "POST", func() (interface{}, error) { return nil, c.ImportRepo(ctx, []byte("car")) }},
+		{"ListMissingBlobs", "com.atproto.repo.listMissingBlobs", "GET", func() (interface{}, error) { return c.ListMissingBlobs(ctx, 100, "") }},
+		{"UploadBlob", "com.atproto.repo.uploadBlob", "POST", func() (interface{}, error) { return nil, c.UploadBlob(ctx, []byte("png"), "image/png") }},
+		{"GetRecommendedDIDCredentials", "com.atproto.identity.getRecommendedDidCredentials", "GET", func() (interface{}, error) {
+			return c.GetRecommendedDIDCredentials(ctx)
+		}},
+		{"RequestPLCOperationSignature", "com.atproto.identity.requestPlcOperationSignature", "POST", func() (interface{}, error) {
+			return nil, c.RequestPLCOperationSignature(ctx)
+		}},
+		{"SignPLCOperation", "com.atproto.identity.signPlcOperation", "POST", func() (interface{}, error) {
+			return c.SignPLCOperation(ctx, "emailed-token", map[string]interface{}{"rotationKeys": []string{"did:key:zQ3shrotation"}})
+		}},
+		{"SubmitPLCOperation", "com.atproto.identity.submitPlcOperation", "POST", func() (interface{}, error) {
+			return nil, c.SubmitPLCOperation(ctx, json.RawMessage(`{"type":"plc_operation"}`))
+		}},
+		{"ActivateAccount", "com.atproto.server.activateAccount", "POST", func() (interface{}, error) { return nil, c.ActivateAccount(ctx) }},
+		{"DeactivateAccount", "com.atproto.server.deactivateAccount", "POST", func() (interface{}, error) { return nil, c.DeactivateAccount(ctx) }},
+		{"ListBlobs", "com.atproto.sync.listBlobs", "GET", func() (interface{}, error) { return c.ListBlobs(ctx, testDID, "3krev", 100, "") }},
+		{"GetBlob", "com.atproto.sync.getBlob", "GET", func() (interface{}, error) { return c.GetBlob(ctx, testDID, "bafyblob1") }},
+		{"GetRepo", "com.atproto.sync.getRepo", "GET", func() (interface{}, error) { return c.GetRepo(ctx, testDID) }},
+		{"ListRepos", "com.atproto.sync.listRepos", "GET", func() (interface{}, error) { return c.ListRepos(ctx, 100, "") }},
+		{"GetJSON", "app.bsky.feed.getFeedGenerator", "GET", func() (interface{}, error) {
+			var out struct {
+				IsOnline bool `json:"isOnline"`
+			}
+			err := c.GetJSON(ctx, "app.bsky.feed.getFeedGenerator", nil, &out)
+			if err == nil && !out.IsOnline {
+				err = fmt.Errorf("isOnline not decoded")
+			}
+			return out, err
+		}},
+		{"CreateMigratedAccount", "com.atproto.server.createAccount", "POST", func() (interface{}, error) {
+			return nil, c.CreateMigratedAccount(ctx, "service-auth-token", testDID, "alice.new.test", "alice@example.com", "new-password", "")
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.call(); err != nil {
+				t.Fatal(err)
+			}
+			r := pds.lastRequest()
+			if r.NSID != tt.nsid || r.Method != tt.method {
+				t.Errorf("sent %s %s, want %s %s", r.Method, r.NSID, tt.method, tt.nsid)
+			}
+		})
+	}
+
+	// chat requests are proxied to the chat service
+	if proxy := pds.calls("chat.bsky.convo.listConvos")[0].Header.Get("atproto-proxy"); proxy != ChatProxy {
+		t.Errorf("atproto-proxy = %q, want %q", proxy, ChatProxy)
+	}
+	// createAccount is authorized by the service auth token, and the client takes the session of the new account
+	if auth := pds.calls("com.atproto.server.createAccount")[0].Header.Get("Authorization"); auth != "Bearer service-auth-token" {
+		t.Errorf("createAccount Authorization = %q", auth)
+	}
+	if c.Session.Handle != "alice.new.test" || c.AuthToken != "migrated-access" {
+		t.Errorf("session after CreateMigratedAccount = %+v", c.Session)
+	}
+}
+
+func TestClientWriteBudget(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	ctx := context.Background()
+
+	if _, err := c.Follow(ctx, "did:plc:bob", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ApplyWrites(ctx, []WriteOp{CreateOp("app.bsky.graph.block", graphRecord{Type: "app.bsky.graph.block", Subject: "did:plc:carol"}), DeleteOp("app.bsky.graph.follow", "x")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetProfile(ctx, testHandle); err != nil {
+		t.Fatal(err)
+	}
+	// a rejected write is refunded
+	pds.queue("com.atproto.repo.createRecord", fakeResponse{Status: http.StatusBadRequest, Body: `{"error":"InvalidRequest"}`})
+	if _, err := c.Follow(ctx, "did:plc:dave", time.Now()); err == nil {
+		t.Fatal("Follow succeeded, want the queued error")
+	}
+
+	// a second client of the same account shares the budget
+	other, err := NewClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, state, err := other.WriteBudget.reserve(ctx, 0)
+	if err != nil || state.HourPoints != 7 || state.DayPoints != 7 {
+		t.Errorf("budget = %+v, %v, want 7 points: a create, then a create and a delete", state, err)
+	}
+}
+
+func TestWriteBudgetLock(t *testing.T) {
+	t.Setenv("BG_CACHE_DIR", t.TempDir())
+	budget, err := OpenWriteBudget(testDID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// a stale lock left by a crashed process is taken over, and its holder cannot release the new lock
+	stale := budget.path + ".lock"
+	if err := os.WriteFile(stale, []byte("crashed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * writeBudgetLockStale)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := budget.lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeLock(stale, []byte("crashed"))
+	if _, err := os.Stat(stale); err != nil {
+		t.Errorf("lock removed by the holder of the stale lock: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("lock not released: %v", err)
+	}
+}
+
+func TestLoopbackListenAddr(t *testing.T) {
+	for addr, want := range map[string]string{"7788": "127.0.0.1:7788", ":7788": "127.0.0.1:7788", "localhost:7788": "localhost:7788", "[::1]:7788": "[::1]:7788", "0.0.0.0:7788": "", "example.com:7788": ""} {
+		got, err := loopbackListenAddr(addr, "BLUESKY_SESSION_BROKER_PUBLIC")
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("loopbackListenAddr(%q) = %q, %v, want %q", addr, got, err, want)
+		}
+	}
+
+	t.Setenv("BLUESKY_SESSION_BROKER_PUBLIC", "true")
+	if got, err := loopbackListenAddr("0.0.0.0:7788", "BLUESKY_SESSION_BROKER_PUBLIC"); err != nil || got != "0.0.0.0:7788" {
+		t.Errorf("loopbackListenAddr with BLUESKY_SESSION_BROKER_PUBLIC = %q, %v", got, err)
+	}
+}
+
+func TestResponseCacheAllowlist(t *testing.T) {
+	pds := newFakePDS(t)
+	setTestEnv(t, pds)
+	t.Setenv("BLUESKY_CACHE_TTL", "10m")
+	ctx := context.Background()
+	c, err := NewClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// profiles are served from the cache, while notifications and chat requests always reach the server
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetProfile(ctx, testHandle); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ListNotifications(ctx, 50, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.GetProfile(WithProxy(ctx, ChatProxy), testHandle); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(pds.calls("app.bsky.actor.getProfile")); n != 3 {
+		t.Errorf("getProfile requests = %d, want 3: one cached, and two through the chat proxy", n)
+	}
+	if n := len(pds.calls("app.bsky.notification.listNotifications")); n != 2 {
+		t.Errorf("listNotifications requests = %d, want 2", n)
+	}
+}
@@ -0,0 +1,233 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+// labelPropagationRounds bounds label propagation, which usually settles within a few rounds
+const labelPropagationRounds = 50
+
+// Community is a cluster of accounts, with its most central members: those followed by the most other members
+type Community struct {
+	Cluster int             `json:"cluster"`
+	Size    int             `json:"size"`
+	Edges   int             `json:"edges"`
+	Central []CentralMember `json:"central"`
+}
+
+// CentralMember is an account of a community and its number of followers within it
+type CentralMember struct {
+	DID       string `json:"did"`
+	Handle    string `json:"handle,omitempty"`
+	Followers int    `json:"followers"`
+}
+
+// undirected returns the neighbors of every account, from every follow or only from mutual follows
+func (g *socialGraph) undirected(mutual bool) map[string][]string {
+	adjacency := make(map[string][]string, len(g.handles))
+	for edge := range g.edges {
+		reverse := GraphEdge{Src: edge.Dst, Dst: edge.Src, Type: edge.Type}
+		if g.edges[reverse] {
+			// each mutual pair is added once, from its smaller DID
+			if edge.Src < edge.Dst {
+				adjacency[edge.Src] = append(adjacency[edge.Src], edge.Dst)
+				adjacency[edge.Dst] = append(adjacency[edge.Dst], edge.Src)
+			}
+			continue
+		}
+		if !mutual {
+			adjacency[edge.Src] = append(adjacency[edge.Src], edge.Dst)
+			adjacency[edge.Dst] = append(adjacency[edge.Dst], edge.Src)
+		}
+	}
+	for did := range adjacency {
+		sort.Strings(adjacency[did])
+	}
+	return adjacency
+}
+
+// connectedComponents labels every account with the smallest DID of its component
+func connectedComponents(adjacency map[string][]string) map[string]string {
+	labels := make(map[string]string, len(adjacency))
+	for _, start := range sortedKeys(adjacency) {
+		if _, ok := labels[start]; ok {
+			continue
+		}
+		labels[start] = start
+		queue := []string{start}
+		for len(queue) > 0 {
+			did := queue[0]
+			queue = queue[1:]
+			for _, neighbor := range adjacency[did] {
+				if _, ok := labels[neighbor]; !ok {
+					labels[neighbor] = start
+					queue = append(queue, neighbor)
+				}
+			}
+		}
+	}
+	return labels
+}
+
+// labelPropagation labels every account with the most common label of its neighbors until the labels settle. the
+// visiting order and ties are randomized with a fixed seed, so runs on the same graph give the same clusters.
+func labelPropagation(adjacency map[string][]string) map[string]string {
+	nodes := sortedKeys(adjacency)
+	labels := make(map[string]string, len(nodes))
+	for _, did := range nodes {
+		labels[did] = did
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < labelPropagationRounds; round++ {
+		rng.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+		changed := 0
+		for _, did := range nodes {
+			counts := make(map[string]int)
+			best := 0
+			for _, neighbor := range adjacency[did] {
+				counts[labels[neighbor]]++
+				if counts[labels[neighbor]] > best {
+					best = counts[labels[neighbor]]
+				}
+			}
+			// keep the current label when it is among the most common, so settled accounts do not oscillate
+			if counts[labels[did]] == best {
+				continue
+			}
+			var candidates []string
+			for label, n := range counts {
+				if n == best {
+					candidates = append(candidates, label)
+				}
+			}
+			sort.Strings(candidates)
+			labels[did] = candidates[rng.Intn(len(candidates))]
+			changed++
+		}
+		if changed == 0 {
+			log.Printf("labels settled after %d rounds\n", round+1)
+			break
+		}
+	}
+	return labels
+}
+
+// sortedKeys returns the keys of an adjacency map in order
+func sortedKeys(adjacency map[string][]string) []string {
+	keys := make([]string, 0, len(adjacency))
+	for key := range adjacency {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// communities groups the accounts by label, largest first, ranking the members of each by their followers within
+// it. clusters smaller than minSize are left out.
+func communities(g *socialGraph, labels map[string]string, minSize, top int) []Community {
+	members := make(map[string][]string)
+	for did, label := range labels {
+		members[label] = append(members[label], did)
+	}
+
+	followers := make(map[string]int)
+	edges := make(map[string]int)
+	for edge := range g.edges {
+		if label, ok := labels[edge.Src]; ok && label == labels[edge.Dst] {
+			followers[edge.Dst]++
+			edges[label]++
+		}
+	}
+
+	var out []Community
+	for label, dids := range members {
+		if len(dids) < minSize {
+			continue
+		}
+		sort.Slice(dids, func(i, j int) bool {
+			if followers[dids[i]] != followers[dids[j]] {
+				return followers[dids[i]] > followers[dids[j]]
+			}
+			return dids[i] < dids[j]
+		})
+		c := Community{Size: len(dids), Edges: edges[label]}
+		for i, did := range dids {
+			if i == top {
+				break
+			}
+			c.Central = append(c.Central, CentralMember{DID: did, Handle: g.handles[did], Followers: followers[did]})
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Size != out[j].Size {
+			return out[i].Size > out[j].Size
+		}
+		return out[i].Central[0].DID < out[j].Central[0].DID
+	})
+	for i := range out {
+		out[i].Cluster = i + 1
+	}
+	return out
+}
+
+// Communities <input> finds the clusters of a JSONL follow graph, - for standard input, such as the output of
+// bs:crawlGraph, and outputs each with its size and its BG_LIMIT (default 10) most central accounts. BG_CLUSTERING is
+// labels (label propagation, default) or components (connected components), BG_MUTUAL=true only links mutual follows,
+// and clusters under BG_MIN_SIZE accounts (default 3) are left out.
+func (Bs) Communities(ctx context.Context, input string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	g, err := readGraphJSONL(r, p.Subject, p.Direction)
+	if err != nil {
+		return err
+	}
+	g.filterDegree(p.MinDegree)
+	adjacency := g.undirected(p.Mutual)
+
+	var labels map[string]string
+	switch p.Clustering {
+	case "labels":
+		labels = labelPropagation(adjacency)
+	case "components":
+		labels = connectedComponents(adjacency)
+	default:
+		return fmt.Errorf("invalid BG_CLUSTERING %q: must be labels or components", p.Clustering)
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	clusters := communities(g, labels, p.MinSize, p.LimitOr(10))
+	for _, c := range clusters {
+		if err := out.Emit(c); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("%d clusters of at least %d accounts among %d linked accounts and %d follows\n", len(clusters), p.MinSize, len(adjacency), len(g.edges))
+	return nil
+}
@@ -0,0 +1,74 @@
+package bluegopher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config is the blue-gopher configuration file, by default ~/.config/blue-gopher/config.json
+type Config struct {
+	// DefaultProfile is used when BG_PROFILE and BLUESKY_HANDLE are unset
+	DefaultProfile string `json:"defaultProfile,omitempty"`
+	// Profiles are named credential profiles, e.g. work, personal, or bot
+	Profiles map[string]AccountProfile `json:"profiles,omitempty"`
+	// DatabaseURL is the PostgreSQL connection string of the pg targets when DATABASE_URL is unset
+	DatabaseURL string `json:"databaseURL,omitempty"`
+}
+
+// AccountProfile holds the credentials of one account
+type AccountProfile struct {
+	Handle   string `json:"handle"`
+	Password string `json:"password"`
+	// PDSHost overrides PDS discovery for the account
+	PDSHost string `json:"pdsHost,omitempty"`
+}
+
+// configDir returns the directory holding the config file, overridable with BG_CONFIG_DIR
+func configDir() (string, error) {
+	if dir := os.Getenv("BG_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return filepath.Join(dir, "blue-gopher"), nil
+}
+
+// LoadConfig reads the config file. a missing file returns an empty config.
+func LoadConfig() (*Config, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "config.json")
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Profile returns the named profile
+func (cfg *Config) Profile(name string) (AccountProfile, error) {
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return AccountProfile{}, fmt.Errorf("profile %q not found in config", name)
+	}
+	if profile.Handle == "" || profile.Password == "" {
+		return AccountProfile{}, fmt.Errorf("profile %q is missing handle or password", name)
+	}
+	return profile, nil
+}
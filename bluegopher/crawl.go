@@ -0,0 +1,186 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// CrawlState is the breadth-first frontier of bs:crawlGraph
+type CrawlState struct {
+	// Queue are the accounts still to expand, in order
+	Queue []CrawlNode `json:"queue"`
+	// Seen are the DIDs of every account queued so far, including expanded ones
+	Seen []string `json:"seen"`
+}
+
+// CrawlNode is an account of the crawl and its distance from the seed
+type CrawlNode struct {
+	DID   string `json:"did"`
+	Depth int    `json:"depth"`
+}
+
+// GraphEdge is a directed edge of the social graph: src follows dst
+type GraphEdge struct {
+	Src  string `json:"src"`
+	Dst  string `json:"dst"`
+	Type string `json:"type"`
+}
+
+// crawlNeighbors returns up to max accounts an actor follows, or that follow it, paging until the cap
+func (c *Client) crawlNeighbors(ctx context.Context, did string, followers bool, max int, progress *Progress) ([]string, error) {
+	var dids []string
+	cursor := ""
+	for len(dids) < max {
+		var page []FollowerView
+		if followers {
+			resp, err := c.GetFollowers(ctx, did, 100, cursor)
+			if err != nil {
+				return nil, err
+			}
+			page, cursor = resp.Followers, resp.Cursor
+		} else {
+			resp, err := c.GetFollows(ctx, did, 100, cursor)
+			if err != nil {
+				return nil, err
+			}
+			page, cursor = resp.Follows, resp.Cursor
+		}
+		progress.Page()
+
+		for _, account := range page {
+			if len(dids) < max {
+				dids = append(dids, account.DID)
+			}
+		}
+		if cursor == "" {
+			break
+		}
+	}
+	return dids, nil
+}
+
+// CrawlGraph <seedActor> <depth> crawls the follow graph breadth-first from an account up to depth hops and outputs
+// each follow as a {"src","dst","type":"follows"} edge. BG_DIRECTION is follows (default), followers, or both,
+// BG_MAX_PER_NODE caps the accounts fetched per account and direction (default 1000), and BG_MAX_NODES the accounts
+// expanded in total. interrupted crawls resume with BG_RESUME=true.
+func (Bs) CrawlGraph(ctx context.Context, seedActor string, depth int) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+	if depth < 1 {
+		return fmt.Errorf("invalid depth %d: must be at least 1", depth)
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	seed, err := c.ResolveDID(ctx, seedActor)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	cp, err := OpenCheckpoint("bs:crawlGraph", seedActor, strconv.Itoa(depth), p.Direction)
+	if err != nil {
+		return err
+	}
+	if cp.State.Crawl == nil {
+		cp.State.Crawl = &CrawlState{Queue: []CrawlNode{{DID: seed}}, Seen: []string{seed}}
+	}
+	crawl := cp.State.Crawl
+	seen := make(map[string]bool, len(crawl.Seen))
+	for _, did := range crawl.Seen {
+		seen[did] = true
+	}
+
+	progress := StartProgress("bs:crawlGraph", c)
+	defer progress.Stop(&err)
+
+	// edges are deduplicated within a run: with both directions, a follow between two expanded accounts is found from
+	// each side
+	emitted := make(map[GraphEdge]bool)
+	emit := func(edge GraphEdge) error {
+		if p.Direction == "both" {
+			if emitted[edge] {
+				return nil
+			}
+			emitted[edge] = true
+		}
+		progress.Items(1)
+		return out.Emit(edge)
+	}
+
+	failed := 0
+	for len(crawl.Queue) > 0 {
+		if p.MaxNodes > 0 && cp.State.Line >= p.MaxNodes {
+			log.Printf("reached BG_MAX_NODES %d with %d accounts queued\n", p.MaxNodes, len(crawl.Queue))
+			break
+		}
+		node := crawl.Queue[0]
+
+		var edges []GraphEdge
+		var neighbors []string
+		var err error
+		for _, followers := range []bool{false, true} {
+			if (followers && p.Direction == "follows") || (!followers && p.Direction == "followers") {
+				continue
+			}
+			var dids []string
+			dids, err = c.crawlNeighbors(ctx, node.DID, followers, p.MaxPerNode, progress)
+			if err != nil {
+				break
+			}
+			for _, did := range dids {
+				if followers {
+					edges = append(edges, GraphEdge{Src: did, Dst: node.DID, Type: "follows"})
+				} else {
+					edges = append(edges, GraphEdge{Src: node.DID, Dst: did, Type: "follows"})
+				}
+			}
+			neighbors = append(neighbors, dids...)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			// accounts that are deleted, deactivated, or blocking the crawler are skipped
+			log.Printf("failed to crawl %s: %v\n", node.DID, err)
+			failed++
+		}
+
+		for _, edge := range edges {
+			if err := emit(edge); err != nil {
+				return err
+			}
+		}
+		if node.Depth+1 < depth {
+			for _, did := range neighbors {
+				if !seen[did] {
+					seen[did] = true
+					crawl.Seen = append(crawl.Seen, did)
+					crawl.Queue = append(crawl.Queue, CrawlNode{DID: did, Depth: node.Depth + 1})
+				}
+			}
+		}
+
+		// the checkpoint is saved after the edges of every account, so a resumed crawl does not output them again
+		crawl.Queue = crawl.Queue[1:]
+		cp.State.Line++
+		if err := cp.Save(); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("crawled %d accounts from %s (%d failed, %d seen)\n", cp.State.Line, seedActor, failed, len(crawl.Seen))
+	return cp.Done()
+}
@@ -0,0 +1,178 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultPLCDirectory is the directory used to resolve did:plc identifiers
+const defaultPLCDirectory = "https://plc.directory"
+
+// DIDDocument represents the DID document of an account
+type DIDDocument struct {
+	Context            []string `json:"@context"`
+	ID                 string   `json:"id"`
+	AlsoKnownAs        []string `json:"alsoKnownAs"`
+	VerificationMethod []struct {
+		ID                 string `json:"id"`
+		Type               string `json:"type"`
+		Controller         string `json:"controller"`
+		PublicKeyMultibase string `json:"publicKeyMultibase"`
+	} `json:"verificationMethod"`
+	Service []struct {
+		ID              string `json:"id"`
+		Type            string `json:"type"`
+		ServiceEndpoint string `json:"serviceEndpoint"`
+	} `json:"service"`
+}
+
+// PDSEndpoint returns the atproto_pds service endpoint of the DID document
+func (d *DIDDocument) PDSEndpoint() string {
+	for _, service := range d.Service {
+		if strings.HasSuffix(service.ID, "#atproto_pds") {
+			return strings.TrimSuffix(service.ServiceEndpoint, "/")
+		}
+	}
+	return ""
+}
+
+// Handle returns the handle claimed in alsoKnownAs, without the at:// prefix
+func (d *DIDDocument) Handle() string {
+	for _, aka := range d.AlsoKnownAs {
+		if strings.HasPrefix(aka, "at://") {
+			return strings.TrimPrefix(aka, "at://")
+		}
+	}
+	return ""
+}
+
+// plcDirectory returns the PLC directory base URL, overridable with PLC_DIRECTORY
+func plcDirectory() string {
+	if dir := os.Getenv("PLC_DIRECTORY"); dir != "" {
+		return strings.TrimSuffix(dir, "/")
+	}
+	return defaultPLCDirectory
+}
+
+// lookupTXT looks up DNS TXT records, replaced by tests
+var lookupTXT = net.DefaultResolver.LookupTXT
+
+// resolveHandleDNS resolves a handle with the DNS TXT record _atproto.<handle>
+func resolveHandleDNS(ctx context.Context, handle string) (string, error) {
+	records, err := lookupTXT(ctx, "_atproto."+handle)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up _atproto.%s: %w", handle, err)
+	}
+
+	for _, record := range records {
+		if did, ok := strings.CutPrefix(record, "did="); ok {
+			return strings.TrimSpace(did), nil
+		}
+	}
+	return "", fmt.Errorf("no did= TXT record at _atproto.%s", handle)
+}
+
+// resolveHandleWellKnown resolves a handle with https://<handle>/.well-known/atproto-did
+func resolveHandleWellKnown(ctx context.Context, httpClient *http.Client, handle string) (string, error) {
+	body, err := httpGet(ctx, httpClient, "https://"+handle+"/.well-known/atproto-did")
+	if err != nil {
+		return "", err
+	}
+
+	did := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(did, "did:") {
+		return "", fmt.Errorf("invalid DID at https://%s/.well-known/atproto-did", handle)
+	}
+	return did, nil
+}
+
+// resolveHandleToDID resolves a handle without a PDS, trying DNS before the well-known endpoint
+func resolveHandleToDID(ctx context.Context, httpClient *http.Client, handle string) (string, error) {
+	handle = strings.TrimPrefix(strings.ToLower(handle), "@")
+
+	did, dnsErr := resolveHandleDNS(ctx, handle)
+	if dnsErr == nil {
+		return did, nil
+	}
+
+	did, err := resolveHandleWellKnown(ctx, httpClient, handle)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve handle %s: %v; %w", handle, dnsErr, err)
+	}
+	return did, nil
+}
+
+// fetchDIDDocument retrieves the DID document for a did:plc or did:web identifier
+func fetchDIDDocument(ctx context.Context, httpClient *http.Client, did string) (*DIDDocument, error) {
+	switch {
+	case strings.HasPrefix(did, "did:plc:"):
+		return NewPLCClient(httpClient).Resolve(ctx, did)
+	case !strings.HasPrefix(did, "did:web:"):
+		return nil, fmt.Errorf("unsupported DID method: %s", did)
+	}
+
+	body, err := httpGet(ctx, httpClient, "https://"+strings.TrimPrefix(did, "did:web:")+"/.well-known/did.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var doc DIDDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal DID document: %w", err)
+	}
+	return &doc, nil
+}
+
+// DiscoverPDS resolves a handle or DID to the PDS host that serves its repository
+func DiscoverPDS(ctx context.Context, httpClient *http.Client, identifier string) (string, error) {
+	did := identifier
+	if !strings.HasPrefix(identifier, "did:") {
+		var err error
+		did, err = resolveHandleToDID(ctx, httpClient, identifier)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	doc, err := fetchDIDDocument(ctx, httpClient, did)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := doc.PDSEndpoint()
+	if endpoint == "" {
+		return "", fmt.Errorf("no atproto_pds service in DID document for %s", did)
+	}
+	return endpoint, nil
+}
+
+// httpGet fetches a URL without authentication and returns the body of a 200 response
+func httpGet(ctx context.Context, httpClient *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request to %s failed with status code %d", url, res.StatusCode)
+	}
+
+	return body, nil
+}
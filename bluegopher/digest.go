@@ -0,0 +1,318 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// digestMaxPages bounds the pages fetched for a digest, since feeds are not always in chronological order
+const digestMaxPages = 20
+
+// GetFeed retrieves a page of the posts of a feed generator, in the shape of an author feed
+func (c *Client) GetFeed(ctx context.Context, feedURI string, limit int, cursor string) (*AuthorFeedResponse, error) {
+	params := url.Values{}
+	params.Set("feed", feedURI)
+	params.Set("limit", fmt.Sprintf("%d", limit))
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+
+	var response AuthorFeedResponse
+	if err := c.GetJSON(ctx, "app.bsky.feed.getFeed", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Digest is the data of a digest template: the source and period, and the top posts ranked by engagement
+type Digest struct {
+	Title string
+	Link  string
+	From  time.Time
+	To    time.Time
+	Posts []DigestPost
+}
+
+// DigestPost is a post of a digest. Text is the post text with its links rendered in the format of the digest.
+type DigestPost struct {
+	Rank        int
+	URL         string
+	Handle      string
+	DisplayName string
+	CreatedAt   time.Time
+	Text        string
+	Images      []string
+	Likes       int
+	Reposts     int
+	Replies     int
+	Quotes      int
+	Engagement  int
+}
+
+// digestMarkdown is the default Markdown digest template
+const digestMarkdown = `# {{.Title}}
+
+Top posts from {{.From.Format "January 2"}} to {{.To.Format "January 2, 2006"}}{{if .Link}} · [{{.Link}}]({{.Link}}){{end}}
+{{range .Posts}}
+## {{.Rank}}. {{if .DisplayName}}{{.DisplayName}} ({{end}}[@{{.Handle}}](https://bsky.app/profile/{{.Handle}}){{if .DisplayName}}){{end}}
+
+{{.Text}}
+{{range .Images}}
+![]({{.}})
+{{end}}
+❤️ {{.Likes}} · 🔁 {{.Reposts}} · 💬 {{.Replies}} · [{{.CreatedAt.Format "Jan 2, 15:04"}}]({{.URL}})
+{{end}}`
+
+// digestHTML is the default HTML digest template, with inline styles since email clients drop style sheets
+const digestHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body style="font-family: sans-serif; max-width: 600px; margin: 0 auto;">
+<h1>{{.Title}}</h1>
+<p>Top posts from {{.From.Format "January 2"}} to {{.To.Format "January 2, 2006"}}{{if .Link}} · <a href="{{.Link}}">{{.Link}}</a>{{end}}</p>
+{{range .Posts}}<div style="border-top: 1px solid #ddd; padding: 12px 0;">
+<p><strong>{{.Rank}}. {{if .DisplayName}}{{.DisplayName}}{{end}}</strong> <a href="https://bsky.app/profile/{{.Handle}}">@{{.Handle}}</a></p>
+<p>{{raw .Text}}</p>
+{{range .Images}}<p><img src="{{.}}" alt="" style="max-width: 100%;"></p>
+{{end}}<p style="color: #666;">❤️ {{.Likes}} · 🔁 {{.Reposts}} · 💬 {{.Replies}} · <a href="{{.URL}}">{{.CreatedAt.Format "Jan 2, 15:04"}}</a></p>
+</div>
+{{end}}</body>
+</html>
+`
+
+// digestTemplate parses the template of BG_DIGEST_TEMPLATE, or the default template of the format. HTML templates
+// are parsed with html/template, which escapes the data; the rendered post text is passed through with the raw
+// function.
+func digestTemplate(format, path string) (func(w io.Writer, d *Digest) error, error) {
+	text := digestMarkdown
+	if format == "html" {
+		text = digestHTML
+	}
+	name := "digest"
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read digest template: %w", err)
+		}
+		text, name = string(b), path
+	}
+
+	if format == "html" {
+		funcs := htmltemplate.FuncMap{"raw": func(s string) htmltemplate.HTML { return htmltemplate.HTML(s) }}
+		tmpl, err := htmltemplate.New(name).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest template: %w", err)
+		}
+		return func(w io.Writer, d *Digest) error { return tmpl.Execute(w, d) }, nil
+	}
+	funcs := template.FuncMap{"raw": func(s string) string { return s }}
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest template: %w", err)
+	}
+	return func(w io.Writer, d *Digest) error { return tmpl.Execute(w, d) }, nil
+}
+
+// loadDigestPosts fetches the posts of a list, a feed, or a search since a time, with the title and link of the source.
+// reposts are left out, and pages are fetched until the posts are older than since or digestMaxPages is reached.
+func loadDigestPosts(ctx context.Context, c *Client, source string, since time.Time) (string, string, []PostView, error) {
+	var title, link string
+	var next func(cursor string) ([]FeedViewPost, string, error)
+
+	switch {
+	case strings.Contains(source, "/lists/") || strings.Contains(source, "/app.bsky.graph.list/"):
+		listURI, err := c.ListATURI(ctx, source)
+		if err != nil {
+			return "", "", nil, err
+		}
+		list, err := c.GetList(ctx, listURI, 1, "")
+		if err != nil {
+			return "", "", nil, err
+		}
+		view, _ := list["list"].(map[string]interface{})
+		title, _ = view["name"].(string)
+		if link, err = BskyURL(listURI); err != nil {
+			return "", "", nil, err
+		}
+		next = func(cursor string) ([]FeedViewPost, string, error) {
+			resp, err := c.GetListFeed(ctx, listURI, 100, cursor)
+			if err != nil {
+				return nil, "", err
+			}
+			return resp.Feed, resp.Cursor, nil
+		}
+	case strings.Contains(source, "/feed/") || strings.Contains(source, "/app.bsky.feed.generator/"):
+		feedURI, err := c.FeedATURI(ctx, source)
+		if err != nil {
+			return "", "", nil, err
+		}
+		generator, err := c.GetFeedGenerator(ctx, feedURI)
+		if err != nil {
+			return "", "", nil, err
+		}
+		view, _ := generator["view"].(map[string]interface{})
+		title, _ = view["displayName"].(string)
+		if link, err = BskyURL(feedURI); err != nil {
+			return "", "", nil, err
+		}
+		next = func(cursor string) ([]FeedViewPost, string, error) {
+			resp, err := c.GetFeed(ctx, feedURI, 100, cursor)
+			if err != nil {
+				return nil, "", err
+			}
+			return resp.Feed, resp.Cursor, nil
+		}
+	default:
+		title = source
+		link = fmt.Sprintf("%s/search?q=%s", bskyAppURL, url.QueryEscape(source))
+		next = func(cursor string) ([]FeedViewPost, string, error) {
+			resp, err := c.SearchPosts(ctx, source, 100, cursor, "latest", "", "", "", "", "", "", "", nil)
+			if err != nil {
+				return nil, "", err
+			}
+			feed := make([]FeedViewPost, len(resp.Posts))
+			for i := range resp.Posts {
+				feed[i].Post = resp.Posts[i]
+			}
+			return feed, resp.Cursor, nil
+		}
+	}
+
+	var posts []PostView
+	seen := make(map[string]bool)
+	cursor := ""
+	for page := 0; page < digestMaxPages; page++ {
+		feed, nextCursor, err := next(cursor)
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		older := 0
+		for _, item := range feed {
+			t, ok := postTime(item.Post)
+			if !ok || t.Before(since) {
+				older++
+				continue
+			}
+			if len(item.Reason) > 0 || seen[item.Post.URI] {
+				continue
+			}
+			seen[item.Post.URI] = true
+			posts = append(posts, item.Post)
+		}
+
+		// lists and searches are in chronological order, so a page of older posts ends the period
+		if nextCursor == "" || len(feed) == 0 || older == len(feed) {
+			break
+		}
+		cursor = nextCursor
+	}
+	return title, link, posts, nil
+}
+
+// postEngagement returns the engagement counts of a post
+func postEngagement(post *PostView) PostEngagement {
+	return PostEngagement{Likes: post.LikeCount, Reposts: post.RepostCount, Replies: post.ReplyCount, Quotes: post.QuoteCount}
+}
+
+// digestPost converts a post to a DigestPost, with its text rendered in the format of the digest
+func digestPost(post *PostView, format string) (DigestPost, error) {
+	link, err := BskyURL(post.URI)
+	if err != nil {
+		return DigestPost{}, err
+	}
+	createdAt, _ := postTime(*post)
+	e := postEngagement(post)
+	d := DigestPost{
+		URL:         link,
+		Handle:      post.Author.Handle,
+		DisplayName: post.Author.DisplayName,
+		CreatedAt:   createdAt,
+		Text:        renderText(&post.Record, format),
+		Likes:       e.Likes,
+		Reposts:     e.Reposts,
+		Replies:     e.Replies,
+		Quotes:      e.Quotes,
+		Engagement:  e.total(),
+	}
+	for _, img := range postImages(post) {
+		if img.URL != "" {
+			d.Images = append(d.Images, img.URL)
+		}
+	}
+	return d, nil
+}
+
+// Digest <source> <days> renders the top posts of the last days from a list or feed URL, or a search query, ranked by
+// likes, reposts, replies, and quotes, as a Markdown digest, or HTML ready to email with BG_FORMAT=html. BG_LIMIT is
+// the number of posts (default 10), BG_DIGEST_TEMPLATE a custom Go template, and the digest is written to BG_OUTPUT
+// or standard output.
+func (Bs) Digest(ctx context.Context, source string, days int) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+	if days < 1 {
+		return fmt.Errorf("invalid days %d: must be at least 1", days)
+	}
+
+	format, err := documentFormat()
+	if err != nil {
+		return err
+	}
+	render, err := digestTemplate(format, p.DigestTemplate)
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	title, link, posts, err := loadDigestPosts(ctx, c, source, since)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool { return postEngagement(&posts[i]).total() > postEngagement(&posts[j]).total() })
+	limit := p.LimitOr(10)
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	digest := &Digest{Title: title, Link: link, From: since, To: now}
+	if digest.Title == "" {
+		digest.Title = "Digest"
+	}
+	for i := range posts {
+		post, err := digestPost(&posts[i], format)
+		if err != nil {
+			return err
+		}
+		post.Rank = i + 1
+		digest.Posts = append(digest.Posts, post)
+	}
+
+	if err := writeDocument(ctx, func(w io.Writer) error { return render(w, digest) }); err != nil {
+		return err
+	}
+
+	log.Printf("digest of %d posts from %s over %d days\n", len(digest.Posts), source, days)
+	return nil
+}
@@ -0,0 +1,4 @@
+// Package bluegopher implements the blue-gopher targets and the Bluesky client they are built on. the targets are
+// the methods of the Bs, Pg, Js, Lb, Duck, Mcp, and Hello namespaces, run by mage through the magefile at the root of
+// the repository and by the blue-gopher command of cmd/blue-gopher.
+package bluegopher
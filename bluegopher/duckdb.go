@@ -0,0 +1,109 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/magefile/mage/mg"
+)
+
+// Duck holds the DuckDB targets, which load JSONL exports into a local DuckDB database or Parquet files for analytics
+// without a database server. they run the duckdb CLI, since DuckDB has no pure Go driver.
+type Duck mg.Namespace
+
+// duckdbPath returns the duckdb CLI, BG_DUCKDB or duckdb on the PATH
+func duckdbPath() (string, error) {
+	name := envString("BG_DUCKDB", "duckdb")
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("duckdb CLI %q not found: install it from https://duckdb.org or set BG_DUCKDB: %w", name, err)
+	}
+	return path, nil
+}
+
+// runDuckdb runs SQL with the duckdb CLI against a database file, or an in-memory database when database is empty
+func runDuckdb(ctx context.Context, database string, args ...string) error {
+	path, err := duckdbPath()
+	if err != nil {
+		return err
+	}
+
+	if database != "" {
+		args = append([]string{database}, args...)
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("duckdb failed: %w", err)
+	}
+	return nil
+}
+
+// duckString quotes a DuckDB string literal
+func duckString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// duckIdent quotes a DuckDB identifier
+func duckIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// readJSONLines is the DuckDB table function reading a JSONL file with the schema inferred from its items
+func readJSONLines(file string) string {
+	return fmt.Sprintf("read_json_auto(%s, format = 'newline_delimited')", duckString(file))
+}
+
+// writeParquet converts a JSONL file to a zstd-compressed Parquet file
+func writeParquet(ctx context.Context, file, parquetFile string) error {
+	query := fmt.Sprintf("COPY (SELECT * FROM %s) TO %s (FORMAT parquet, COMPRESSION zstd);", readJSONLines(file), duckString(parquetFile))
+	return runDuckdb(ctx, "", "-c", query)
+}
+
+// Import <file> <database> <table> loads a JSONL export into a table of a DuckDB database file, replacing the table
+func (Duck) Import(ctx context.Context, file, database, table string) error {
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	query := fmt.Sprintf("CREATE OR REPLACE TABLE %s AS SELECT * FROM %s; SELECT count(*) AS imported FROM %s;",
+		duckIdent(table), readJSONLines(file), duckIdent(table))
+	return runDuckdb(ctx, database, "-c", query)
+}
+
+// ExportParquet <file> <parquetFile> converts a JSONL export to a Parquet file readable by DuckDB and other engines
+func (Duck) ExportParquet(ctx context.Context, file, parquetFile string) error {
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	if err := writeParquet(ctx, file, parquetFile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Parquet file %s written successfully\n", parquetFile)
+	return nil
+}
+
+// duckdbModes are the duckdb CLI output modes matching each BG_FORMAT
+var duckdbModes = map[string][]string{
+	"jsonl": {"-jsonlines"},
+	"json":  {"-jsonlines"},
+	"csv":   {"-csv"},
+	"tsv":   {"-csv", "-separator", "\t"},
+	"table": {"-column"},
+}
+
+// Query <database> <query> runs a query against a DuckDB database file and outputs the rows in the BG_FORMAT format
+func (Duck) Query(ctx context.Context, database, query string) error {
+	format := envString("BG_FORMAT", "jsonl")
+	mode, ok := duckdbModes[format]
+	if !ok {
+		return fmt.Errorf("invalid BG_FORMAT %q: must be jsonl, csv, tsv, or table", format)
+	}
+	return runDuckdb(ctx, database, append(mode, "-c", query)...)
+}
@@ -0,0 +1,189 @@
+package bluegopher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Embedder computes text embeddings with the provider selected by BG_EMBEDDINGS_PROVIDER
+type Embedder interface {
+	// Model identifies the model, so embeddings of different models are never compared
+	Model() string
+	// Embed returns one embedding per text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// embeddingBatchSize is the number of texts sent per embeddings request
+const embeddingBatchSize = 100
+
+// NewEmbedder returns the embeddings provider configured by the environment:
+//
+//	openai: OPENAI_API_KEY, OPENAI_BASE_URL for compatible servers, model text-embedding-3-small by default
+//	azure:  AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY, and the deployment in BG_EMBEDDINGS_MODEL
+//	ollama: OLLAMA_HOST (default http://localhost:11434), model nomic-embed-text by default
+func NewEmbedder() (Embedder, error) {
+	provider := envString("BG_EMBEDDINGS_PROVIDER", "openai")
+	client := &http.Client{Timeout: 2 * time.Minute}
+
+	switch provider {
+	case "openai":
+		key := os.Getenv("OPENAI_API_KEY")
+		baseURL := envString("OPENAI_BASE_URL", "https://api.openai.com/v1")
+		if key == "" && baseURL == "https://api.openai.com/v1" {
+			return nil, fmt.Errorf("OPENAI_API_KEY must be set for the openai embeddings provider")
+		}
+		return &openAIEmbedder{
+			client: client,
+			url:    strings.TrimSuffix(baseURL, "/") + "/embeddings",
+			header: http.Header{"Authorization": {"Bearer " + key}},
+			model:  envString("BG_EMBEDDINGS_MODEL", "text-embedding-3-small"),
+		}, nil
+	case "azure":
+		endpoint := os.Getenv("AZURE_OPENAI_ENDPOINT")
+		key := os.Getenv("AZURE_OPENAI_API_KEY")
+		deployment := os.Getenv("BG_EMBEDDINGS_MODEL")
+		if endpoint == "" || key == "" || deployment == "" {
+			return nil, fmt.Errorf("AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY, and BG_EMBEDDINGS_MODEL (the deployment) must be set for the azure embeddings provider")
+		}
+		return &openAIEmbedder{
+			client: client,
+			url: fmt.Sprintf("%s/openai/deployments/%s/embeddings?api-version=%s", strings.TrimSuffix(endpoint, "/"),
+				url.PathEscape(deployment), envString("AZURE_OPENAI_API_VERSION", "2024-02-01")),
+			header: http.Header{"api-key": {key}},
+			model:  "azure:" + deployment,
+		}, nil
+	case "ollama":
+		return &ollamaEmbedder{
+			client: client,
+			url:    strings.TrimSuffix(envString("OLLAMA_HOST", "http://localhost:11434"), "/") + "/api/embed",
+			model:  envString("BG_EMBEDDINGS_MODEL", "nomic-embed-text"),
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid BG_EMBEDDINGS_PROVIDER %q: must be openai, azure, or ollama", provider)
+}
+
+// openAIEmbedder calls the OpenAI embeddings API, which Azure OpenAI and many local servers also implement
+type openAIEmbedder struct {
+	client *http.Client
+	url    string
+	header http.Header
+	model  string
+}
+
+func (e *openAIEmbedder) Model() string { return e.model }
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	request := map[string]interface{}{"input": texts}
+	if !strings.HasPrefix(e.model, "azure:") {
+		request["model"] = e.model
+	}
+
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := postEmbeddings(ctx, e.client, e.url, e.header, request, &response); err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, d := range response.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings response has an invalid index %d", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return checkEmbeddings(embeddings)
+}
+
+// ollamaEmbedder calls the embed API of a local Ollama server
+type ollamaEmbedder struct {
+	client *http.Client
+	url    string
+	model  string
+}
+
+func (e *ollamaEmbedder) Model() string { return "ollama:" + e.model }
+
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	request := map[string]interface{}{"model": e.model, "input": texts}
+	if err := postEmbeddings(ctx, e.client, e.url, nil, request, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embeddings response has %d embeddings for %d texts", len(response.Embeddings), len(texts))
+	}
+	return checkEmbeddings(response.Embeddings)
+}
+
+// postEmbeddings sends an embeddings request and decodes the response
+func postEmbeddings(ctx context.Context, client *http.Client, url string, header http.Header, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send embeddings request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("embeddings request failed with status %d: %s", resp.StatusCode, b)
+	}
+	if err := json.Unmarshal(b, response); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	return nil
+}
+
+// checkEmbeddings checks every text got an embedding
+func checkEmbeddings(embeddings [][]float32) ([][]float32, error) {
+	for i, embedding := range embeddings {
+		if len(embedding) == 0 {
+			return nil, fmt.Errorf("embeddings response has no embedding for text %d", i)
+		}
+	}
+	return embeddings, nil
+}
+
+// vectorLiteral formats an embedding as a pgvector literal such as [0.1,0.2]
+func vectorLiteral(embedding []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, v := range embedding {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%g", v)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
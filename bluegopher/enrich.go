@@ -0,0 +1,503 @@
+package bluegopher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+)
+
+// Enricher is a stage of the enrichment pipeline. it adds fields to the enrichment object of each item of a batch,
+// so stages that call an API, such as embeddings, can send the texts of a batch at once.
+type Enricher interface {
+	Enrich(ctx context.Context, batch []*EnrichItem) error
+}
+
+// EnrichItem is an item flowing through the pipeline, with the text found in it and the fields added by the stages
+type EnrichItem struct {
+	Item       map[string]interface{}
+	Text       string
+	Enrichment map[string]interface{}
+}
+
+// enrichers are the stages of the pipeline by name. custom stages are added from Go with RegisterEnricher.
+var enrichers = map[string]func() (Enricher, error){
+	"lang":      func() (Enricher, error) { return langEnricher{}, nil },
+	"sentiment": func() (Enricher, error) { return sentimentEnricher{}, nil },
+	"links":     func() (Enricher, error) { return newLinkEnricher(), nil },
+	"embedding": newEmbeddingEnricher,
+}
+
+// RegisterEnricher adds a pipeline stage, usable by name in BG_ENRICH. call it from the init function of a file
+// added to the package to plug in custom Go stages.
+func RegisterEnricher(name string, factory func() (Enricher, error)) {
+	enrichers[name] = factory
+}
+
+// EnricherFunc adapts a function enriching one item at a time to an Enricher
+type EnricherFunc func(ctx context.Context, item *EnrichItem) error
+
+// Enrich calls the function for each item of the batch
+func (f EnricherFunc) Enrich(ctx context.Context, batch []*EnrichItem) error {
+	for _, item := range batch {
+		if err := f(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newPipeline creates the stages named in order
+func newPipeline(names []string) ([]Enricher, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("BG_ENRICH must name the stages, such as lang,sentiment,links,embedding")
+	}
+	var stages []Enricher
+	for _, name := range names {
+		factory, ok := enrichers[name]
+		if !ok {
+			known := make([]string, 0, len(enrichers))
+			for stage := range enrichers {
+				known = append(known, stage)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown enrichment stage %q: must be one of %s", name, strings.Join(known, ", "))
+		}
+		stage, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create enrichment stage %s: %w", name, err)
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// itemTextPaths are where the text of an item is found: feed items, post views, records, and Jetstream commits
+var itemTextPaths = []string{"post.record.text", "record.text", "commit.record.text", "value.text", "text", "description"}
+
+// itemText returns the text of an item, or empty
+func itemText(item map[string]interface{}) string {
+	for _, path := range itemTextPaths {
+		if text, ok := lookupPath(item, path).(string); ok && text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// langEnricher detects the language of the text: from the script for non-Latin scripts, and from common words for
+// Latin-script languages. it adds lang, or und when undetermined.
+type langEnricher struct{}
+
+// scriptLanguages map Unicode scripts to the language most written in them
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// stopwords are frequent words of Latin-script languages
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "was", "you", "that", "this", "with", "for", "have", "not", "it", "of", "to"},
+	"es": {"el", "la", "los", "las", "que", "y", "es", "por", "para", "con", "una", "del", "pero", "muy", "de"},
+	"fr": {"le", "la", "les", "et", "est", "que", "pour", "une", "des", "pas", "dans", "avec", "je", "sur", "de"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "mit", "ein", "eine", "auf", "für", "auch", "es", "zu"},
+	"pt": {"o", "os", "as", "que", "é", "não", "uma", "com", "para", "em", "do", "da", "mas", "muito", "de"},
+	"it": {"il", "lo", "gli", "che", "è", "non", "una", "per", "con", "del", "della", "ma", "sono", "di", "la"},
+	"nl": {"de", "het", "een", "en", "is", "niet", "dat", "van", "ik", "met", "voor", "op", "maar", "ook", "zijn"},
+}
+
+func (langEnricher) Enrich(ctx context.Context, batch []*EnrichItem) error {
+	for _, item := range batch {
+		item.Enrichment["lang"] = detectLanguage(item.Text)
+	}
+	return nil
+}
+
+// detectLanguage returns the ISO 639-1 code of the language of a text, or und
+func detectLanguage(text string) string {
+	scripts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.table, r) {
+				scripts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "und"
+	}
+	// kana marks Japanese even in text that is mostly kanji
+	if scripts["ja"] > 0 {
+		return "ja"
+	}
+	for _, s := range scriptLanguages {
+		if scripts[s.lang]*2 > letters {
+			return s.lang
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' })
+	best, bestCount := "und", 0
+	for _, lang := range []string{"en", "es", "fr", "de", "pt", "it", "nl"} {
+		count := 0
+		for _, word := range words {
+			for _, stopword := range stopwords[lang] {
+				if word == stopword {
+					count++
+				}
+			}
+		}
+		if count > bestCount {
+			best, bestCount = lang, count
+		}
+	}
+	return best
+}
+
+// sentimentEnricher scores the sentiment of English text from a small lexicon of words and emoji. it adds
+// sentiment, from -1 to 1, and sentimentLabel: positive, negative, or neutral.
+type sentimentEnricher struct{}
+
+// sentimentLexicon are the valence of common words and emoji, from -3 to 3
+var sentimentLexicon = map[string]int{
+	"love": 3, "amazing": 3, "awesome": 3, "excellent": 3, "fantastic": 3, "wonderful": 3, "❤️": 3, "😍": 3,
+	"great": 2, "happy": 2, "good": 2, "nice": 2, "glad": 2, "thanks": 2, "thank": 2, "fun": 2, "beautiful": 2,
+	"excited": 2, "congrats": 2, "😊": 2, "🎉": 2, "😂": 1, "like": 1, "cool": 1, "interesting": 1, "helpful": 1,
+	"hate": -3, "awful": -3, "terrible": -3, "horrible": -3, "worst": -3, "disgusting": -3, "😡": -3,
+	"bad": -2, "sad": -2, "angry": -2, "wrong": -2, "broken": -2, "annoying": -2, "fail": -2, "failed": -2,
+	"sucks": -2, "😢": -2, "😞": -2, "bug": -1, "problem": -1, "sorry": -1, "worried": -1, "tired": -1,
+}
+
+// sentimentNegations flip the valence of the following word
+var sentimentNegations = map[string]bool{"not": true, "no": true, "never": true, "don't": true, "isn't": true, "wasn't": true, "can't": true}
+
+func (sentimentEnricher) Enrich(ctx context.Context, batch []*EnrichItem) error {
+	for _, item := range batch {
+		score := sentimentScore(item.Text)
+		label := "neutral"
+		if score >= 0.05 {
+			label = "positive"
+		} else if score <= -0.05 {
+			label = "negative"
+		}
+		item.Enrichment["sentiment"] = score
+		item.Enrichment["sentimentLabel"] = label
+	}
+	return nil
+}
+
+// sentimentScore sums the valence of the words of a text, normalized to -1 to 1
+func sentimentScore(text string) float64 {
+	sum := 0
+	negate := false
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.Trim(word, ".,!?;:\"()[]")
+		valence := sentimentLexicon[word]
+		if negate {
+			valence = -valence
+		}
+		sum += valence
+		negate = sentimentNegations[word]
+	}
+	if sum == 0 {
+		return 0
+	}
+	// the normalization of VADER, which approaches ±1 as the sum grows
+	score := float64(sum) / math.Sqrt(float64(sum*sum)+15)
+	return float64(int(score*1000)) / 1000
+}
+
+// linkEnricher finds the links of an item, from its link facets and its text, and follows their redirects to the
+// final URL, so shortened links are attributed to their real domain. it adds links: url, expanded, and domain.
+type linkEnricher struct {
+	client *http.Client
+	mu     sync.Mutex
+	// expanded caches the final URL of each link across the run
+	expanded map[string]string
+}
+
+// the links come from untrusted posts, so the client only connects to public addresses: the dialer checks each
+// resolved address, including those of redirects, and no proxy is used, so the dialed address is the link's own
+func newLinkEnricher() *linkEnricher {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: dialPublicOnly}
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: nil, TLSHandshakeTimeout: 10 * time.Second},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+	return &linkEnricher{client: client, expanded: make(map[string]string)}
+}
+
+// dialPublicOnly is a net.Dialer Control function that refuses connections to loopback, private, link-local, and
+// other non-public addresses, such as cloud metadata services at 169.254.169.254
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// cgnatRange is the shared address space of carrier-grade NAT, RFC 6598
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether an address is globally routable
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnatRange.Contains(ip))
+}
+
+func (e *linkEnricher) Enrich(ctx context.Context, batch []*EnrichItem) error {
+	for _, item := range batch {
+		var links []map[string]string
+		for _, link := range itemLinks(item) {
+			expanded := e.expand(ctx, link)
+			links = append(links, map[string]string{"url": link, "expanded": expanded, "domain": linkDomain(expanded)})
+		}
+		if len(links) > 0 {
+			item.Enrichment["links"] = links
+		}
+	}
+	return nil
+}
+
+// itemLinks returns the distinct links of an item: the URIs of its link facets, which hold the full URL of links
+// shortened in the text, or else the URLs in its text
+func itemLinks(item *EnrichItem) []string {
+	var links []string
+	seen := make(map[string]bool)
+	add := func(link string) {
+		link = strings.TrimRight(link, ".,!?;:)")
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	for _, path := range []string{"post.record.facets", "record.facets", "commit.record.facets", "value.facets", "facets"} {
+		facets, ok := lookupPath(item.Item, path).([]interface{})
+		if !ok {
+			continue
+		}
+		for _, facet := range facets {
+			features, _ := lookupPath(asMap(facet), "features").([]interface{})
+			for _, feature := range features {
+				f := asMap(feature)
+				if f["$type"] == "app.bsky.richtext.facet#link" {
+					if uri, ok := f["uri"].(string); ok {
+						add(uri)
+					}
+				}
+			}
+		}
+		break
+	}
+	if len(links) == 0 {
+		for _, match := range linkPattern.FindAllStringSubmatch(item.Text, -1) {
+			add(match[1])
+		}
+	}
+	return links
+}
+
+// asMap returns a JSON object, or nil
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// expand follows the redirects of a link with a HEAD request, falling back to GET for servers that reject HEAD.
+// links that fail are kept as they are.
+func (e *linkEnricher) expand(ctx context.Context, link string) string {
+	e.mu.Lock()
+	expanded, ok := e.expanded[link]
+	e.mu.Unlock()
+	if ok {
+		return expanded
+	}
+
+	expanded = link
+	if scheme, _, _ := strings.Cut(strings.ToLower(link), "://"); scheme != "http" && scheme != "https" {
+		return link
+	}
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, link, nil)
+		if err != nil {
+			break
+		}
+		res, err := e.client.Do(req)
+		if err != nil {
+			log.Printf("failed to expand %s: %v\n", link, err)
+			break
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+		expanded = res.Request.URL.String()
+		break
+	}
+
+	e.mu.Lock()
+	e.expanded[link] = expanded
+	e.mu.Unlock()
+	return expanded
+}
+
+// linkDomain returns the host of a URL without www.
+func linkDomain(link string) string {
+	_, rest, _ := strings.Cut(link, "://")
+	host, _, _ := strings.Cut(rest, "/")
+	host, _, _ = strings.Cut(host, "?")
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// embeddingEnricher embeds the texts of a batch with the provider of BG_EMBEDDINGS_PROVIDER. it adds embedding and
+// embeddingModel. items without text are skipped.
+type embeddingEnricher struct {
+	embedder Embedder
+}
+
+func newEmbeddingEnricher() (Enricher, error) {
+	embedder, err := NewEmbedder()
+	if err != nil {
+		return nil, err
+	}
+	return &embeddingEnricher{embedder: embedder}, nil
+}
+
+func (e *embeddingEnricher) Enrich(ctx context.Context, batch []*EnrichItem) error {
+	var texts []string
+	var items []*EnrichItem
+	for _, item := range batch {
+		if item.Text != "" {
+			texts = append(texts, item.Text)
+			items = append(items, item)
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	embeddings, err := e.embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		item.Enrichment["embedding"] = embeddings[i]
+		item.Enrichment["embeddingModel"] = e.embedder.Model()
+	}
+	return nil
+}
+
+// enrichBatch runs the stages over a batch and emits its items, with the enrichment added under the enrichment key
+func enrichBatch(ctx context.Context, stages []Enricher, batch []*EnrichItem, out *Output) error {
+	for _, stage := range stages {
+		if err := stage.Enrich(ctx, batch); err != nil {
+			return err
+		}
+	}
+	for _, item := range batch {
+		item.Item["enrichment"] = item.Enrichment
+		if err := out.Emit(item.Item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enrich reads JSON items from standard input, such as exported posts or stream events, runs them through the
+// enrichment stages of BG_ENRICH in order, and outputs them with the added fields under enrichment. the stages are
+// lang, sentiment, links, embedding, and any registered with RegisterEnricher.
+func (Bs) Enrich(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	stages, err := newPipeline(p.Enrich)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	progress := StartProgress("bs:enrich", nil)
+	defer progress.Stop(&err)
+
+	dec := json.NewDecoder(bufio.NewReader(os.Stdin))
+	dec.UseNumber()
+	var batch []*EnrichItem
+	for {
+		var item map[string]interface{}
+		err := dec.Decode(&item)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode item: %w", err)
+		}
+
+		batch = append(batch, &EnrichItem{Item: item, Text: itemText(item), Enrichment: make(map[string]interface{})})
+		if len(batch) == embeddingBatchSize {
+			if err := enrichBatch(ctx, stages, batch, out); err != nil {
+				return err
+			}
+			progress.Page()
+			progress.Items(len(batch))
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		if err := enrichBatch(ctx, stages, batch, out); err != nil {
+			return err
+		}
+		progress.Items(len(batch))
+	}
+	return nil
+}
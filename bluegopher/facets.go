@@ -0,0 +1,68 @@
+package bluegopher
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Facet represents an app.bsky.richtext.facet. the byte range indexes the UTF-8 encoded post text.
+type Facet struct {
+	Index    FacetIndex     `json:"index"`
+	Features []FacetFeature `json:"features"`
+}
+
+// FacetIndex is the byte range of a facet
+type FacetIndex struct {
+	ByteStart int `json:"byteStart"`
+	ByteEnd   int `json:"byteEnd"`
+}
+
+// FacetFeature is a mention or link feature of a facet
+type FacetFeature struct {
+	Type string `json:"$type"`
+	DID  string `json:"did,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+var (
+	// mentionPattern matches @handle mentions at the start of the text or after whitespace or an opening bracket
+	mentionPattern = regexp.MustCompile(`(?:^|[\s(\[])(@(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)`)
+	// linkPattern matches http and https URLs
+	linkPattern = regexp.MustCompile(`(?:^|[\s(\[])(https?://[^\s]+)`)
+)
+
+// DetectFacets finds the mentions and links in post text. mentioned handles are resolved to DIDs, and mentions of
+// handles that do not resolve are left as plain text.
+func (c *Client) DetectFacets(ctx context.Context, text string) ([]Facet, error) {
+	var facets []Facet
+
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2], match[3]
+		handle := text[start+1 : end]
+		did, err := c.resolveHandleCached(ctx, handle)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		facets = append(facets, Facet{
+			Index:    FacetIndex{ByteStart: start, ByteEnd: end},
+			Features: []FacetFeature{{Type: "app.bsky.richtext.facet#mention", DID: did}},
+		})
+	}
+
+	for _, match := range linkPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2], match[3]
+		// trailing punctuation usually ends the sentence rather than the URL
+		uri := strings.TrimRight(text[start:end], ".,;:!?)]'\"")
+		end = start + len(uri)
+		facets = append(facets, Facet{
+			Index:    FacetIndex{ByteStart: start, ByteEnd: end},
+			Features: []FacetFeature{{Type: "app.bsky.richtext.facet#link", URI: uri}},
+		})
+	}
+
+	return facets, nil
+}
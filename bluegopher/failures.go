@@ -0,0 +1,142 @@
+package bluegopher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Failure is an input of a bulk run that failed
+type Failure struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Input   string   `json:"input"`
+	Error   string   `json:"error"`
+	Time    string   `json:"time"`
+}
+
+// FailureLog writes the failed inputs of a bulk run as JSON lines so they can be re-run with bs:retryFailed. the file
+// is <command>.failed in the working directory, e.g. bs-listItemBulk.failed, overridable with BG_FAILED, and is only
+// created when an input fails.
+type FailureLog struct {
+	path    string
+	command string
+	args    []string
+
+	mu    sync.Mutex
+	file  *os.File
+	count int
+}
+
+// OpenFailureLog returns the failure log of a command
+func OpenFailureLog(command string, args ...string) *FailureLog {
+	return &FailureLog{
+		path:    envString("BG_FAILED", strings.ReplaceAll(command, ":", "-")+".failed"),
+		command: command,
+		args:    args,
+	}
+}
+
+// Record appends a failed input with its error
+func (fl *FailureLog) Record(input string, err error) error {
+	log.Printf("failed: %s: %v\n", input, err)
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if fl.file == nil {
+		f, err := os.OpenFile(fl.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", fl.path, err)
+		}
+		fl.file = f
+	}
+
+	b, err := json.Marshal(Failure{
+		Command: fl.command,
+		Args:    fl.args,
+		Input:   input,
+		Error:   err.Error(),
+		Time:    time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fl.file.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", fl.path, err)
+	}
+	fl.count++
+	return nil
+}
+
+// Close closes the file and reports the number of failures
+func (fl *FailureLog) Close() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if fl.file == nil {
+		return nil
+	}
+	if bulkTargets[fl.command] {
+		log.Printf("%d failed inputs written to %s, re-run them with: mage bs:retryFailed %s\n", fl.count, fl.path, fl.path)
+	} else {
+		log.Printf("%d failed inputs written to %s\n", fl.count, fl.path)
+	}
+	return fl.file.Close()
+}
+
+// appendFailures appends failures to a failure log
+func appendFailures(path string, failures []Failure) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	w := bufio.NewWriter(f)
+	for _, failure := range failures {
+		b, err := json.Marshal(failure)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// readFailures reads a failure log
+func readFailures(path string) ([]Failure, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var failures []Failure
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var failure Failure
+		if err := json.Unmarshal(scanner.Bytes(), &failure); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		failures = append(failures, failure)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return failures, nil
+}
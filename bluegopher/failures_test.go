@@ -0,0 +1,35 @@
+package bluegopher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetryFailedUnsupportedCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bs-listSync.failed")
+	failures := []Failure{
+		{Command: "bs:followBulk", Input: "alice.test", Error: "timeout"},
+		{Command: "bs:listSync", Args: []string{"https://bsky.app/profile/alice.test/lists/abc"}, Input: "bob.test", Error: "not found"},
+	}
+	if err := appendFailures(path, failures); err != nil {
+		t.Fatal(err)
+	}
+
+	// a command runBulk cannot replay fails the retry before any run starts, leaving the file in place
+	if err := (Bs{}).RetryFailed(context.Background(), path); err == nil {
+		t.Fatal("RetryFailed of an unsupported command succeeded")
+	}
+	if _, err := os.Stat(path + ".retrying"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s.retrying exists after a rejected retry", path)
+	}
+	got, err := readFailures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(failures) || got[1].Command != "bs:listSync" || got[1].Input != "bob.test" {
+		t.Errorf("failures after a rejected retry = %+v, want %+v", got, failures)
+	}
+}
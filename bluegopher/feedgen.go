@@ -0,0 +1,312 @@
+package bluegopher
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feedSkeletonLimit is the page size of getFeedSkeleton requests without a limit, and feedSkeletonMaxLimit the
+// largest the lexicon allows
+const (
+	feedSkeletonLimit    = 50
+	feedSkeletonMaxLimit = 100
+)
+
+// SQLFeed is a feed algorithm defined by a SQL query over the imported tables. the query returns a uri column with
+// the AT URIs of the posts and a sort column, such as created_at, by which the feed is ordered newest first:
+//
+//	SELECT uri, created_at AS sort FROM posts WHERE text_search @@ plainto_tsquery('simple', 'golang')
+//
+// pages are keyset-paginated on (sort, uri), so the query must not order or limit the rows itself.
+type SQLFeed struct {
+	// Name is the record key of the feed generator record, the file name of the query without .sql
+	Name  string
+	Query string
+}
+
+// page returns up to limit post URIs of the feed after a cursor, and the cursor of the next page
+func (f *SQLFeed) page(ctx context.Context, db *sql.DB, limit int, cursor string) ([]string, string, error) {
+	query := fmt.Sprintf(`SELECT uri, sort FROM (%s) AS feed WHERE uri IS NOT NULL AND sort IS NOT NULL
+		ORDER BY sort DESC, uri DESC LIMIT $1`, f.Query)
+	args := []interface{}{limit}
+	if cursor != "" {
+		// a cursor is the sort value and URI of the last post of the previous page
+		i := strings.LastIndex(cursor, "|")
+		if i < 0 {
+			return nil, "", errInvalidFeedCursor
+		}
+		query = fmt.Sprintf(`SELECT uri, sort FROM (%s) AS feed WHERE uri IS NOT NULL AND sort IS NOT NULL
+			AND (sort, uri) < ($2, $3) ORDER BY sort DESC, uri DESC LIMIT $1`, f.Query)
+		args = append(args, cursor[:i], cursor[i+1:])
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query feed %s: %w", f.Name, err)
+	}
+	defer rows.Close()
+
+	var uris []string
+	var uri, sortValue string
+	for rows.Next() {
+		// timestamps scan to RFC 3339 text, which Postgres parses back when comparing the cursor
+		if err := rows.Scan(&uri, &sortValue); err != nil {
+			return nil, "", fmt.Errorf("failed to scan feed %s: %w", f.Name, err)
+		}
+		uris = append(uris, uri)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to query feed %s: %w", f.Name, err)
+	}
+
+	next := ""
+	if len(uris) == limit {
+		next = sortValue + "|" + uri
+	}
+	return uris, next, nil
+}
+
+// errInvalidFeedCursor is returned for cursors that were not issued by page
+var errInvalidFeedCursor = errors.New("invalid cursor")
+
+// loadSQLFeeds reads the <name>.sql files of a directory and checks that each query returns the uri and sort columns
+func loadSQLFeeds(db *sql.DB, dir string) (map[string]*SQLFeed, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no feed queries found: add <name>.sql files to %s", dir)
+	}
+
+	feeds := make(map[string]*SQLFeed, len(files))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read feed: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".sql")
+		feed := &SQLFeed{Name: name, Query: strings.TrimRight(strings.TrimSpace(string(b)), ";")}
+
+		rows, err := db.Query(fmt.Sprintf("SELECT uri, sort FROM (%s) AS feed LIMIT 0", feed.Query))
+		if err != nil {
+			return nil, fmt.Errorf("invalid feed query %s: %w", file, err)
+		}
+		rows.Close()
+		feeds[name] = feed
+	}
+	return feeds, nil
+}
+
+// feedServer serves the SQL feeds with the app.bsky.feed.getFeedSkeleton and describeFeedGenerator methods of a feed
+// generator, and the did:web document of its service DID
+type feedServer struct {
+	db        *sql.DB
+	feeds     map[string]*SQLFeed
+	hostname  string
+	publisher string
+}
+
+// serviceDID returns the did:web of the feed generator
+func (s *feedServer) serviceDID() string {
+	return "did:web:" + s.hostname
+}
+
+// feedURI returns the AT URI of the feed generator record of a feed
+func (s *feedServer) feedURI(name string) string {
+	return fmt.Sprintf("at://%s/app.bsky.feed.generator/%s", s.publisher, name)
+}
+
+// handler routes the XRPC methods, the DID document, and the metrics
+func (s *feedServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/did.json", s.didDocument)
+	mux.HandleFunc("/xrpc/app.bsky.feed.describeFeedGenerator", s.describeFeedGenerator)
+	mux.HandleFunc("/xrpc/app.bsky.feed.getFeedSkeleton", s.getFeedSkeleton)
+	mux.Handle("/metrics", metrics)
+	return mux
+}
+
+// didDocument serves the did:web document, with the service endpoint the AppView sends feed requests to
+func (s *feedServer) didDocument(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"@context": []string{"https://www.w3.org/ns/did/v1"},
+		"id":       s.serviceDID(),
+		"service": []map[string]string{{
+			"id":              "#bsky_fg",
+			"type":            "BskyFeedGenerator",
+			"serviceEndpoint": "https://" + s.hostname,
+		}},
+	})
+}
+
+// describeFeedGenerator lists the AT URIs of the feeds
+func (s *feedServer) describeFeedGenerator(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(s.feeds))
+	for name := range s.feeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	feeds := make([]map[string]string, len(names))
+	for i, name := range names {
+		feeds[i] = map[string]string{"uri": s.feedURI(name)}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"did": s.serviceDID(), "feeds": feeds})
+}
+
+// getFeedSkeleton serves a page of the post URIs of a feed. requests are not authenticated, since SQL feeds are the
+// same for every viewer.
+func (s *feedServer) getFeedSkeleton(w http.ResponseWriter, r *http.Request) {
+	feed, status := "unknown", http.StatusOK
+	defer func() {
+		metrics.Add(metricFeedRequests, 1, feed, strconv.Itoa(status))
+	}()
+
+	q := r.URL.Query()
+	repo, collection, rkey, err := splitATURI(q.Get("feed"))
+	if err != nil || repo != s.publisher || collection != "app.bsky.feed.generator" || s.feeds[rkey] == nil {
+		status = http.StatusBadRequest
+		writeXRPCError(w, status, "UnknownFeed", fmt.Sprintf("unknown feed %q", q.Get("feed")))
+		return
+	}
+	feed = rkey
+
+	limit := feedSkeletonLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > feedSkeletonMaxLimit {
+			status = http.StatusBadRequest
+			writeXRPCError(w, status, "InvalidRequest", fmt.Sprintf("limit must be 1 to %d", feedSkeletonMaxLimit))
+			return
+		}
+	}
+
+	uris, cursor, err := s.feeds[rkey].page(r.Context(), s.db, limit, q.Get("cursor"))
+	if errors.Is(err, errInvalidFeedCursor) {
+		status = http.StatusBadRequest
+		writeXRPCError(w, status, "InvalidRequest", err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		status = http.StatusInternalServerError
+		writeXRPCError(w, status, "InternalServerError", "failed to load the feed")
+		return
+	}
+
+	items := make([]map[string]string, len(uris))
+	for i, post := range uris {
+		items[i] = map[string]string{"post": post}
+	}
+	response := map[string]interface{}{"feed": items}
+	if cursor != "" {
+		response["cursor"] = cursor
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// writeXRPCError writes an XRPC error response
+func writeXRPCError(w http.ResponseWriter, status int, name, message string) {
+	writeJSON(w, status, map[string]string{"error": name, "message": message})
+}
+
+// loopbackListenAddr returns the address a local server listens on: a bare port listens on 127.0.0.1, and other hosts
+// must be loopback addresses unless the publicEnv variable is true
+func loopbackListenAddr(addr, publicEnv string) (string, error) {
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	if host == "" {
+		return net.JoinHostPort("127.0.0.1", port), nil
+	}
+	public, err := envBool(publicEnv, false)
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); !public && host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("refusing to listen on %s, which is not a loopback address: set %s=true to allow it", host, publicEnv)
+	}
+	return addr, nil
+}
+
+// serveHTTP serves a handler on addr until ctx is done, then shuts the server down gracefully
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// ServeFeeds <addr> runs a feed generator serving the SQL feeds of the BG_FEEDS directory, one <name>.sql query per
+// feed, until interrupted. BG_FEEDGEN_HOSTNAME is the public hostname of its did:web, and BG_FEEDGEN_PUBLISHER the DID
+// of the account publishing the app.bsky.feed.generator records, defaulting to the did:web.
+func (Pg) ServeFeeds(ctx context.Context, addr string) error {
+	hostname := os.Getenv("BG_FEEDGEN_HOSTNAME")
+	if hostname == "" {
+		return fmt.Errorf("BG_FEEDGEN_HOSTNAME is required: the public hostname of the feed generator, such as feeds.example.com")
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	feeds, err := loadSQLFeeds(db, envString("BG_FEEDS", "feeds"))
+	if err != nil {
+		return err
+	}
+
+	s := &feedServer{
+		db:        db,
+		feeds:     feeds,
+		hostname:  hostname,
+		publisher: envString("BG_FEEDGEN_PUBLISHER", "did:web:"+hostname),
+	}
+	for name := range feeds {
+		log.Printf("serving feed %s\n", s.feedURI(name))
+	}
+	log.Printf("listening on %s as %s\n", addr, s.serviceDID())
+
+	if err := serveHTTP(ctx, addr, s.handler()); err != nil {
+		return err
+	}
+	fmt.Printf("feed generator stopped successfully\n")
+	return nil
+}
@@ -0,0 +1,103 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// GetPopularFeedGenerators retrieves a page of popular feed generators, optionally filtered by a search query
+func (c *Client) GetPopularFeedGenerators(ctx context.Context, query string, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/app.bsky.unspecced.getPopularFeedGenerators"
+	params := url.Values{}
+	if query != "" {
+		params.Add("query", query)
+	}
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// GetTrendingTopics retrieves the current trending and suggested topics
+func (c *Client) GetTrendingTopics(ctx context.Context, limit int) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/app.bsky.unspecced.getTrendingTopics"
+	params := url.Values{}
+	params.Add("viewer", c.Session.DID)
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// GetFeedGenerator retrieves a feed generator view along with its online and validity status
+func (c *Client) GetFeedGenerator(ctx context.Context, feedURI string) (map[string]interface{}, error) {
+	requestURL := fmt.Sprintf("%s/xrpc/app.bsky.feed.getFeedGenerator?feed=%s", c.BaseURL, url.QueryEscape(feedURI))
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// GetFeedGenerators retrieves the views of multiple feed generators
+func (c *Client) GetFeedGenerators(ctx context.Context, feedURIs []string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/app.bsky.feed.getFeedGenerators"
+	params := url.Values{}
+	for _, feed := range feedURIs {
+		params.Add("feeds", feed)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// FeedATURI converts a bsky.app feed URL to its AT URI. AT URIs are returned unchanged.
+func (c *Client) FeedATURI(ctx context.Context, feedURL string) (string, error) {
+	return c.collectionATURI(ctx, feedURL, "app.bsky.feed.generator")
+}
@@ -0,0 +1,343 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultFirehoseURL is the relay subscribeRepos endpoint used when BG_FIREHOSE_URL is unset
+const defaultFirehoseURL = "wss://bsky.network/xrpc/com.atproto.sync.subscribeRepos"
+
+// firehoseSaveInterval is how often js:firehose saves its sequence number for BG_RESUME
+const firehoseSaveInterval = 5 * time.Second
+
+// errInvalidFrame is wrapped by the errors of firehose frames that cannot be decoded, which are skipped
+var errInvalidFrame = errors.New("invalid firehose frame")
+
+// Firehose subscribes to the com.atproto.sync.subscribeRepos firehose of a relay, decoding the DAG-CBOR frames and
+// the CAR blocks of each commit into one event per record operation
+type Firehose struct {
+	// URL is the subscribeRepos endpoint, BG_FIREHOSE_URL or the Bluesky relay
+	URL string
+	// Collections and DIDs filter the events client-side, since the firehose has no server-side filters. identity and
+	// account events are only filtered by DID, as with Jetstream.
+	Collections []string
+	DIDs        []string
+	// Cursor is the sequence number of the last event handled. 0 starts at the live tail.
+	Cursor int64
+	// Retry is the backoff between reconnects
+	Retry RetryPolicy
+	// OnCursor, when set, is called after every event of a frame is handled and Cursor is advanced past it, so the
+	// cursor it sees is always at a commit boundary. its errors end the stream like the errors of the handler.
+	OnCursor func() error
+
+	dids map[string]bool
+}
+
+// NewFirehose returns a subscription configured from BG_FIREHOSE_URL, BG_COLLECTIONS, BG_DIDS, and BG_CURSOR, the
+// sequence number to replay from
+func NewFirehose(p Params) (*Firehose, error) {
+	f := &Firehose{
+		URL:         envString("BG_FIREHOSE_URL", defaultFirehoseURL),
+		Collections: p.Collections,
+		DIDs:        p.DIDs,
+		Retry:       RetryPolicyFromEnv(),
+	}
+	if p.Cursor != "" {
+		cursor, err := strconv.ParseInt(p.Cursor, 10, 64)
+		if err != nil || cursor < 0 {
+			return nil, fmt.Errorf("invalid BG_CURSOR %q: must be a firehose sequence number", p.Cursor)
+		}
+		f.Cursor = cursor
+	}
+	if len(f.DIDs) > 0 {
+		f.dids = make(map[string]bool, len(f.DIDs))
+		for _, did := range f.DIDs {
+			f.dids[did] = true
+		}
+	}
+	return f, nil
+}
+
+// Run calls handle with each record operation, identity, and account event until ctx is done or handle returns an
+// error. failed connections are retried with backoff, replaying from the cursor.
+func (f *Firehose) Run(ctx context.Context, handle func(*JetstreamEvent) error) error {
+	return reconnect(ctx, "firehose", f.Retry, f.stream, handle)
+}
+
+// stream handles the events of a single connection, and reports whether any event was received
+func (f *Firehose) stream(ctx context.Context, handle func(*JetstreamEvent) error) (bool, error) {
+	params := url.Values{}
+	if f.Cursor > 0 {
+		params.Set("cursor", strconv.FormatInt(f.Cursor, 10))
+	}
+	ws, err := dialWebsocket(ctx, wsURL(f.URL, params))
+	if err != nil {
+		return false, err
+	}
+	defer ws.Close()
+
+	received := false
+	for {
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		// a frame that cannot be decoded is skipped, since reconnecting would replay it forever
+		seq, events, err := decodeFirehoseFrame(message)
+		if errors.Is(err, errInvalidFrame) {
+			log.Printf("skipping firehose frame: %v\n", err)
+			events = nil
+		} else if err != nil {
+			return received, err
+		}
+		// a replay may start at the cursor itself, which was already handled
+		if seq == 0 || seq <= f.Cursor {
+			continue
+		}
+
+		for _, event := range events {
+			if !f.wanted(event) {
+				continue
+			}
+			if err := handle(event); err != nil {
+				return received, err
+			}
+		}
+		f.Cursor = seq
+		if f.OnCursor != nil {
+			if err := f.OnCursor(); err != nil {
+				return received, handlerError{err}
+			}
+		}
+	}
+}
+
+// wanted reports whether an event matches the DID and collection filters
+func (f *Firehose) wanted(event *JetstreamEvent) bool {
+	if f.dids != nil && !f.dids[event.DID] {
+		return false
+	}
+	if event.Commit == nil || len(f.Collections) == 0 {
+		return true
+	}
+	return matchCollection(f.Collections, event.Commit.Collection)
+}
+
+// matchCollection reports whether a collection matches one of the patterns, which are NSIDs or prefixes ending in
+// .*, as with the wantedCollections of Jetstream
+func matchCollection(patterns []string, collection string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(collection, prefix) {
+				return true
+			}
+		} else if pattern == collection {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeFirehoseFrame decodes a firehose message, a DAG-CBOR header followed by a DAG-CBOR body, into its sequence
+// number and events. messages without a sequence number, such as #info, are logged and return 0. frames that cannot
+// be decoded return an error wrapping errInvalidFrame, with their sequence number when it could be read.
+func decodeFirehoseFrame(message []byte) (int64, []*JetstreamEvent, error) {
+	h, rest, err := decodeCBOR(message)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: failed to decode header: %w", errInvalidFrame, err)
+	}
+	header, _ := h.(map[string]interface{})
+	b, _, err := decodeCBOR(rest)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: failed to decode body: %w", errInvalidFrame, err)
+	}
+	body, ok := b.(map[string]interface{})
+	if header == nil || !ok {
+		return 0, nil, errInvalidFrame
+	}
+
+	if op, _ := header["op"].(int64); op == -1 {
+		return 0, nil, fmt.Errorf("firehose error %v: %v", body["error"], body["message"])
+	}
+
+	seq, _ := body["seq"].(int64)
+	t, _ := header["t"].(string)
+	switch t {
+	case "#commit":
+		events, err := commitEvents(seq, body)
+		if err != nil {
+			return seq, nil, fmt.Errorf("%w: %w", errInvalidFrame, err)
+		}
+		return seq, events, nil
+	case "#identity", "#account":
+		event := firehoseEvent(seq, body)
+		raw, err := json.Marshal(cborJSON(body))
+		if err != nil {
+			return seq, nil, fmt.Errorf("%w: failed to encode %s event: %w", errInvalidFrame, t, err)
+		}
+		if t == "#identity" {
+			event.Kind, event.Identity = "identity", raw
+		} else {
+			event.Kind, event.Account = "account", raw
+		}
+		return seq, []*JetstreamEvent{event}, nil
+	case "#info":
+		log.Printf("firehose info: %v %v\n", body["name"], body["message"])
+	}
+	// #sync and newer event types only advance the cursor
+	return seq, nil, nil
+}
+
+// firehoseEvent returns an event with the DID, time, and sequence number of a firehose body
+func firehoseEvent(seq int64, body map[string]interface{}) *JetstreamEvent {
+	event := &JetstreamEvent{Seq: seq}
+	if did, ok := body["did"].(string); ok {
+		event.DID = did
+	} else {
+		event.DID, _ = body["repo"].(string)
+	}
+	if s, ok := body["time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			event.TimeUS = t.UnixMicro()
+		}
+	}
+	return event
+}
+
+// commitEvents returns an event per record operation of a #commit, with the records decoded from its CAR blocks.
+// commits too big for the firehose have no blocks, so their records are left out.
+func commitEvents(seq int64, body map[string]interface{}) ([]*JetstreamEvent, error) {
+	rev, _ := body["rev"].(string)
+	ops, _ := body["ops"].([]interface{})
+
+	var car *CAR
+	if blocks, ok := body["blocks"].([]byte); ok && len(blocks) > 0 {
+		var err error
+		if car, err = readCAR(blocks); err != nil {
+			return nil, fmt.Errorf("failed to read commit blocks of seq %d: %w", seq, err)
+		}
+	}
+
+	events := make([]*JetstreamEvent, 0, len(ops))
+	for _, o := range ops {
+		op, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path, _ := op["path"].(string)
+		collection, rkey, ok := strings.Cut(path, "/")
+		if !ok {
+			continue
+		}
+
+		event := firehoseEvent(seq, body)
+		event.Kind = "commit"
+		event.Commit = &JetstreamCommit{Rev: rev, Collection: collection, RKey: rkey}
+		event.Commit.Operation, _ = op["action"].(string)
+
+		if cid, ok := op["cid"].(CID); ok {
+			event.Commit.CID = cid.String()
+			if car != nil {
+				if record, err := car.Record(cid); err == nil {
+					if event.Commit.Record, err = json.Marshal(cborJSON(record)); err != nil {
+						return nil, fmt.Errorf("failed to encode record %s: %w", path, err)
+					}
+				}
+			}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Firehose streams the record operations, identity, and account events of the relay firehose as JSON lines, in the
+// form of js:subscribe with a seq field. BG_COLLECTIONS, BG_DIDS, and the BG_RULES filter rules select the events,
+// BG_LIMIT stops after the commit that reaches a number of events, and BG_RESUME=true continues from the last saved
+// sequence number. the sequence number is only saved between commits, so a run stopped by BG_LIMIT resumes after the
+// last commit it emitted.
+func (Js) Firehose(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	rules, err := LoadRules()
+	if err != nil {
+		return err
+	}
+
+	f, err := NewFirehose(p)
+	if err != nil {
+		return err
+	}
+
+	cp, err := OpenCheckpoint("js:firehose", f.URL)
+	if err != nil {
+		return err
+	}
+	if f.Cursor == 0 && cp.State.Cursor != "" {
+		if f.Cursor, err = strconv.ParseInt(cp.State.Cursor, 10, 64); err != nil {
+			return fmt.Errorf("invalid saved firehose cursor %q: %w", cp.State.Cursor, err)
+		}
+	}
+	save := func() error {
+		if f.Cursor == 0 {
+			return nil
+		}
+		cp.State.Cursor = strconv.FormatInt(f.Cursor, 10)
+		return cp.Save()
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	progress := StartProgress("js:firehose", nil)
+	defer progress.Stop(&err)
+	serveMetrics(ctx)
+
+	// the limit is checked once the commit of an event is done, so its other operations are not lost on resume
+	emitted := 0
+	saved := time.Now()
+	f.OnCursor = func() error {
+		if p.Limit > 0 && emitted >= p.Limit {
+			return errStopStream
+		}
+		if time.Since(saved) >= firehoseSaveInterval {
+			saved = time.Now()
+			return save()
+		}
+		return nil
+	}
+	err = f.Run(ctx, func(event *JetstreamEvent) error {
+		if !rules.MatchEvent(event) {
+			return nil
+		}
+		if err := out.Emit(event); err != nil {
+			return err
+		}
+		progress.Items(1)
+		emitted++
+		return nil
+	})
+
+	if saveErr := save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if f.Cursor > 0 {
+		log.Printf("resume with BG_RESUME=true or BG_CURSOR=%d\n", f.Cursor)
+	}
+	return err
+}
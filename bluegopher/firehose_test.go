@@ -0,0 +1,123 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCommitFrame encodes a #commit frame with a create operation for each record path
+func testCommitFrame(t *testing.T, seq int64, blocks []byte, paths ...string) []byte {
+	t.Helper()
+	header, err := encodeCBOR(map[string]interface{}{"op": int64(1), "t": "#commit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := []interface{}{}
+	for _, path := range paths {
+		ops = append(ops, map[string]interface{}{"action": "create", "path": path})
+	}
+	body, err := encodeCBOR(map[string]interface{}{"seq": seq, "repo": "did:plc:alice", "rev": "rev", "ops": ops, "blocks": blocks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(header, body...)
+}
+
+// newFakeRelay serves the frames over a subscribeRepos websocket, then keeps the connection open
+func newFakeRelay(t *testing.T, frames ...[]byte) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := acceptWebsocket(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for _, frame := range frames {
+			if err := ws.WriteMessage(frame); err != nil {
+				return
+			}
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestFirehoseSkipsInvalidFrames(t *testing.T) {
+	url := newFakeRelay(t,
+		testCommitFrame(t, 1, nil, "app.bsky.feed.post/a", "app.bsky.feed.post/b"),
+		[]byte{0xff},
+		testCommitFrame(t, 3, []byte{1, 2, 3}, "app.bsky.feed.post/bad"),
+		testCommitFrame(t, 4, nil, "app.bsky.feed.post/c"),
+	)
+
+	f := &Firehose{URL: url, Retry: RetryPolicy{Attempts: 0}}
+	var rkeys []string
+	var cursors []int64
+	f.OnCursor = func() error {
+		cursors = append(cursors, f.Cursor)
+		if f.Cursor == 4 {
+			return errStopStream
+		}
+		return nil
+	}
+	err := f.Run(context.Background(), func(event *JetstreamEvent) error {
+		rkeys = append(rkeys, event.Commit.RKey)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the undecodable frame is skipped, and the commit with invalid blocks advances the cursor without events
+	if strings.Join(rkeys, ",") != "a,b,c" {
+		t.Errorf("events = %v, want a,b,c", rkeys)
+	}
+	if len(cursors) != 3 || cursors[0] != 1 || cursors[1] != 3 || cursors[2] != 4 {
+		t.Errorf("cursors = %v, want [1 3 4]", cursors)
+	}
+}
+
+func TestFirehoseLimitStopsAtCommitBoundary(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BG_CACHE_DIR", dir)
+	t.Setenv("BG_FIREHOSE_URL", newFakeRelay(t,
+		testCommitFrame(t, 1, nil, "app.bsky.feed.post/a", "app.bsky.feed.post/b"),
+		testCommitFrame(t, 2, nil, "app.bsky.feed.post/c"),
+	))
+	t.Setenv("BG_LIMIT", "1")
+	t.Setenv("BG_OUTPUT", filepath.Join(dir, "out.jsonl"))
+
+	if err := (Js{}).Firehose(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// both operations of the commit that reached the limit are emitted, and the saved cursor is after it
+	b, err := os.ReadFile(filepath.Join(dir, "out.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("emitted %d events, want 2:\n%s", len(lines), b)
+	}
+	var event JetstreamEvent
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil || event.Commit.RKey != "b" {
+		t.Errorf("last event = %s, want the b operation", lines[1])
+	}
+
+	t.Setenv("BG_RESUME", "true")
+	cp, err := OpenCheckpoint("js:firehose", os.Getenv("BG_FIREHOSE_URL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.State.Cursor != "1" {
+		t.Errorf("saved cursor = %q, want 1", cp.State.Cursor)
+	}
+}
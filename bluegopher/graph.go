@@ -0,0 +1,313 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// graphRecord is the record of an app.bsky.graph.follow or app.bsky.graph.block
+type graphRecord struct {
+	Type      string `json:"$type"`
+	Subject   string `json:"subject"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// Follow creates a follow record for the given DID
+func (c *Client) Follow(ctx context.Context, did string, createdAt time.Time) (map[string]interface{}, error) {
+	return c.CreateRecord(ctx, CreateRecordRequest{
+		Repo:       c.Session.DID,
+		Collection: "app.bsky.graph.follow",
+		Record: graphRecord{
+			Type:      "app.bsky.graph.follow",
+			Subject:   did,
+			CreatedAt: createdAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// Block creates a block record for the given DID
+func (c *Client) Block(ctx context.Context, did string, createdAt time.Time) (map[string]interface{}, error) {
+	return c.CreateRecord(ctx, CreateRecordRequest{
+		Repo:       c.Session.DID,
+		Collection: "app.bsky.graph.block",
+		Record: graphRecord{
+			Type:      "app.bsky.graph.block",
+			Subject:   did,
+			CreatedAt: createdAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// ListRecords retrieves a page of the records of a collection in a repository
+func (c *Client) ListRecords(ctx context.Context, repo, collection string, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/com.atproto.repo.listRecords"
+	params := url.Values{}
+	params.Add("repo", repo)
+	params.Add("collection", collection)
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// EachRecord calls fn with every record of a collection in a repository
+func (c *Client) EachRecord(ctx context.Context, repo, collection string, fn func(record map[string]interface{}) error) error {
+	cursor := ""
+	for {
+		resp, err := c.ListRecords(ctx, repo, collection, 100, cursor)
+		if err != nil {
+			return err
+		}
+
+		if records, ok := resp["records"].([]interface{}); ok {
+			for _, item := range records {
+				record, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if err := fn(record); err != nil {
+					return err
+				}
+			}
+		}
+
+		if nextCursor, ok := resp["cursor"].(string); ok && nextCursor != "" {
+			cursor = nextCursor
+		} else {
+			return nil
+		}
+	}
+}
+
+// recordSubjects returns the subject DIDs of the authenticated user's follow or block records
+func (c *Client) recordSubjects(ctx context.Context, collection string) (map[string]bool, error) {
+	subjects := make(map[string]bool)
+	err := c.EachRecord(ctx, c.Session.DID, collection, func(record map[string]interface{}) error {
+		value, _ := record["value"].(map[string]interface{})
+		if subject, ok := value["subject"].(string); ok {
+			subjects[subject] = true
+		}
+		return nil
+	})
+	return subjects, err
+}
+
+// resolveActorLine returns the DID of an input line of a bulk target: a JSON object with a did or handle, such as
+// the output of bs:getFollowers, or a bare DID or handle
+func (c *Client) resolveActorLine(ctx context.Context, line string) (string, error) {
+	actor := strings.TrimSpace(line)
+	if strings.HasPrefix(actor, "{") {
+		var data struct {
+			DID    string `json:"did"`
+			Handle string `json:"handle"`
+		}
+		if err := json.Unmarshal([]byte(actor), &data); err != nil {
+			return "", fmt.Errorf("failed to unmarshal line: %w", err)
+		}
+		if data.DID != "" {
+			return data.DID, nil
+		}
+		if data.Handle == "" {
+			return "", fmt.Errorf("invalid data: missing did or handle")
+		}
+		actor = data.Handle
+	}
+
+	actor = strings.TrimPrefix(actor, "@")
+	if strings.HasPrefix(actor, "did:") {
+		return actor, nil
+	}
+	return c.resolveHandleCached(ctx, actor)
+}
+
+// resolveHandleCached resolves a handle to its DID, caching the result for the lifetime of the client so repeated
+// handles in bulk input are resolved once
+func (c *Client) resolveHandleCached(ctx context.Context, handle string) (string, error) {
+	handle = strings.ToLower(handle)
+
+	c.mu.Lock()
+	did, ok := c.handles[handle]
+	c.mu.Unlock()
+	if ok {
+		return did, nil
+	}
+
+	did, err := c.ResolveHandle(ctx, handle)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	if c.handles == nil {
+		c.handles = make(map[string]string)
+	}
+	c.handles[handle] = did
+	c.mu.Unlock()
+	return did, nil
+}
+
+// AllFollowers retrieves every follower of an actor
+func (c *Client) AllFollowers(ctx context.Context, actor string) ([]FollowerView, error) {
+	var followers []FollowerView
+	cursor := ""
+	for {
+		resp, err := c.GetFollowers(ctx, actor, 100, cursor)
+		if err != nil {
+			return nil, err
+		}
+		followers = append(followers, resp.Followers...)
+
+		if resp.Cursor == "" {
+			return followers, nil
+		}
+		cursor = resp.Cursor
+	}
+}
+
+// AllFollows retrieves every account an actor follows
+func (c *Client) AllFollows(ctx context.Context, actor string) ([]FollowerView, error) {
+	var follows []FollowerView
+	cursor := ""
+	for {
+		resp, err := c.GetFollows(ctx, actor, 100, cursor)
+		if err != nil {
+			return nil, err
+		}
+		follows = append(follows, resp.Follows...)
+
+		if resp.Cursor == "" {
+			return follows, nil
+		}
+		cursor = resp.Cursor
+	}
+}
+
+// listItemRecord is the record of an app.bsky.graph.listitem
+type listItemRecord struct {
+	Type      string `json:"$type"`
+	Subject   string `json:"subject"`
+	List      string `json:"list"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// listMembers returns the AT URIs of the authenticated user's listitem records in a list, keyed by subject DID. a
+// subject added more than once has several records, in the order they were listed.
+func (c *Client) listMembers(ctx context.Context, listURI string) (map[string][]string, error) {
+	members := make(map[string][]string)
+	err := c.EachRecord(ctx, c.Session.DID, "app.bsky.graph.listitem", func(record map[string]interface{}) error {
+		value, _ := record["value"].(map[string]interface{})
+		if list, _ := value["list"].(string); list != listURI {
+			return nil
+		}
+		subject, _ := value["subject"].(string)
+		uri, _ := record["uri"].(string)
+		if subject != "" {
+			members[subject] = append(members[subject], uri)
+		}
+		return nil
+	})
+	return members, err
+}
+
+// GetList retrieves a page of a list view and its members
+func (c *Client) GetList(ctx context.Context, listURI string, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/app.bsky.graph.getList"
+	params := url.Values{}
+	params.Add("list", listURI)
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// AllListMembers retrieves the view of any account's list and the DIDs of all its members
+func (c *Client) AllListMembers(ctx context.Context, listURI string) (map[string]interface{}, []string, error) {
+	var list map[string]interface{}
+	var members []string
+	cursor := ""
+	for {
+		resp, err := c.GetList(ctx, listURI, 100, cursor)
+		if err != nil {
+			return nil, nil, err
+		}
+		if list == nil {
+			list, _ = resp["list"].(map[string]interface{})
+		}
+
+		items, _ := resp["items"].([]interface{})
+		for _, item := range items {
+			item, _ := item.(map[string]interface{})
+			subject, _ := item["subject"].(map[string]interface{})
+			if did, ok := subject["did"].(string); ok {
+				members = append(members, did)
+			}
+		}
+
+		if nextCursor, ok := resp["cursor"].(string); ok && nextCursor != "" && len(items) > 0 {
+			cursor = nextCursor
+		} else {
+			return list, members, nil
+		}
+	}
+}
+
+// StarterPackListURI returns the AT URI of the list behind a starter pack, given its bsky.app URL
+// (https://bsky.app/starter-pack/<handle>/<rkey>) or AT URI
+func (c *Client) StarterPackListURI(ctx context.Context, starterPackURL string) (string, error) {
+	uri, err := c.collectionATURI(ctx, starterPackURL, "app.bsky.graph.starterpack")
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("starterPack", uri)
+	var response struct {
+		StarterPack struct {
+			List struct {
+				URI string `json:"uri"`
+			} `json:"list"`
+		} `json:"starterPack"`
+	}
+	if err := c.GetJSON(ctx, "app.bsky.graph.getStarterPack", params, &response); err != nil {
+		return "", err
+	}
+	if response.StarterPack.List.URI == "" {
+		return "", fmt.Errorf("starter pack %s has no list", uri)
+	}
+
+	return response.StarterPack.List.URI, nil
+}
@@ -0,0 +1,351 @@
+package bluegopher
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// socialGraph is a directed follow graph with the handles of the accounts where known
+type socialGraph struct {
+	handles map[string]string
+	edges   map[GraphEdge]bool
+}
+
+func newSocialGraph() *socialGraph {
+	return &socialGraph{handles: make(map[string]string), edges: make(map[GraphEdge]bool)}
+}
+
+// addEdge adds a follow from src to dst, ignoring self-follows
+func (g *socialGraph) addEdge(src, dst string) {
+	if src == "" || dst == "" || src == dst {
+		return
+	}
+	g.edges[GraphEdge{Src: src, Dst: dst, Type: "follows"}] = true
+	for _, did := range []string{src, dst} {
+		if _, ok := g.handles[did]; !ok {
+			g.handles[did] = ""
+		}
+	}
+}
+
+// setHandle records the handle of an account
+func (g *socialGraph) setHandle(did, handle string) {
+	if did != "" && handle != "" {
+		g.handles[did] = handle
+	}
+}
+
+// degrees returns the in-degree and out-degree of every account
+func (g *socialGraph) degrees() (in, out map[string]int) {
+	in, out = make(map[string]int), make(map[string]int)
+	for edge := range g.edges {
+		out[edge.Src]++
+		in[edge.Dst]++
+	}
+	return in, out
+}
+
+// filterDegree removes the accounts with fewer than min edges, in and out, and their edges
+func (g *socialGraph) filterDegree(min int) {
+	if min <= 1 {
+		return
+	}
+	in, out := g.degrees()
+	for did := range g.handles {
+		if in[did]+out[did] < min {
+			delete(g.handles, did)
+		}
+	}
+	for edge := range g.edges {
+		if _, ok := g.handles[edge.Src]; !ok {
+			delete(g.edges, edge)
+		} else if _, ok := g.handles[edge.Dst]; !ok {
+			delete(g.edges, edge)
+		}
+	}
+}
+
+// sortedNodes returns the DIDs of the accounts in order
+func (g *socialGraph) sortedNodes() []string {
+	dids := make([]string, 0, len(g.handles))
+	for did := range g.handles {
+		dids = append(dids, did)
+	}
+	sort.Strings(dids)
+	return dids
+}
+
+// sortedEdges returns the edges in order
+func (g *socialGraph) sortedEdges() []GraphEdge {
+	edges := make([]GraphEdge, 0, len(g.edges))
+	for edge := range g.edges {
+		edges = append(edges, edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Src != edges[j].Src {
+			return edges[i].Src < edges[j].Src
+		}
+		return edges[i].Dst < edges[j].Dst
+	})
+	return edges
+}
+
+// readGraphJSONL reads a graph from JSON lines: {"src","dst"} edges, such as the output of bs:crawlGraph, or the
+// profile views of bs:getFollowers and bs:getFollows, which are the followers or follows of subject according to
+// direction
+func readGraphJSONL(r io.Reader, subject, direction string) (*socialGraph, error) {
+	g := newSocialGraph()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var item struct {
+			Src    string `json:"src"`
+			Dst    string `json:"dst"`
+			DID    string `json:"did"`
+			Handle string `json:"handle"`
+		}
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			return nil, fmt.Errorf("invalid JSON on line %d: %w", lineNum, err)
+		}
+
+		switch {
+		case item.Src != "" && item.Dst != "":
+			g.addEdge(item.Src, item.Dst)
+		case item.DID != "":
+			if subject == "" {
+				return nil, fmt.Errorf("line %d is an account rather than an edge: set BG_SUBJECT to the DID whose followers or follows the file holds", lineNum)
+			}
+			if direction == "followers" {
+				g.addEdge(item.DID, subject)
+			} else {
+				g.addEdge(subject, item.DID)
+			}
+			g.setHandle(item.DID, item.Handle)
+		default:
+			return nil, fmt.Errorf("line %d has neither src and dst nor did", lineNum)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read graph: %w", err)
+	}
+	return g, nil
+}
+
+// readGraphTables reads the graph of the followers and follows tables, with the handles of their profile views
+func readGraphTables(db *sql.DB) (*socialGraph, error) {
+	g := newSocialGraph()
+	// a follower follows its subject, and a subject follows its follows
+	queries := []string{
+		"SELECT did, subject, coalesce(handle, '') FROM followers WHERE did IS NOT NULL",
+		"SELECT subject, did, coalesce(handle, '') FROM follows WHERE did IS NOT NULL",
+	}
+	for i, query := range queries {
+		rows, err := db.Query(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query graph: %w", err)
+		}
+		for rows.Next() {
+			var src, dst, handle string
+			if err := rows.Scan(&src, &dst, &handle); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan edge: %w", err)
+			}
+			g.addEdge(src, dst)
+			if i == 0 {
+				g.setHandle(src, handle)
+			} else {
+				g.setHandle(dst, handle)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read graph: %w", err)
+		}
+	}
+
+	// the subjects themselves are named by the profiles table when they were synced
+	rows, err := db.Query("SELECT did, coalesce(handle, '') FROM profiles WHERE did IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profiles: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var did, handle string
+		if err := rows.Scan(&did, &handle); err != nil {
+			return nil, fmt.Errorf("failed to scan profile: %w", err)
+		}
+		if known, ok := g.handles[did]; ok && known == "" {
+			g.setHandle(did, handle)
+		}
+	}
+	return g, rows.Err()
+}
+
+// writeGraph writes a graph in a format: dot and graphml to the file path, and gephi to the <path>-nodes.csv and
+// <path>-edges.csv spreadsheets of the Gephi import
+func writeGraph(ctx context.Context, g *socialGraph, format, path string) error {
+	switch format {
+	case "dot":
+		return writeOutputFile(ctx, path, func(w io.Writer) error { return writeDOT(w, g) })
+	case "graphml":
+		return writeOutputFile(ctx, path, func(w io.Writer) error { return writeGraphML(w, g) })
+	case "gephi":
+		path = strings.TrimSuffix(path, ".csv")
+		if err := writeOutputFile(ctx, path+"-nodes.csv", func(w io.Writer) error { return writeGephiNodes(w, g) }); err != nil {
+			return err
+		}
+		return writeOutputFile(ctx, path+"-edges.csv", func(w io.Writer) error { return writeGephiEdges(w, g) })
+	default:
+		return fmt.Errorf("invalid graph format %q: must be dot, graphml, or gephi", format)
+	}
+}
+
+// writeDOT writes the Graphviz DOT language, labeling accounts with their handles
+func writeDOT(w io.Writer, g *socialGraph) error {
+	fmt.Fprintln(w, "digraph follows {")
+	for _, did := range g.sortedNodes() {
+		label := did
+		if handle := g.handles[did]; handle != "" {
+			label = handle
+		}
+		fmt.Fprintf(w, "  %s [label=%s];\n", strconv.Quote(did), strconv.Quote(label))
+	}
+	for _, edge := range g.sortedEdges() {
+		fmt.Fprintf(w, "  %s -> %s;\n", strconv.Quote(edge.Src), strconv.Quote(edge.Dst))
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// writeGraphML writes GraphML with the handle and degrees of each account as node data
+func writeGraphML(w io.Writer, g *socialGraph) error {
+	in, out := g.degrees()
+	io.WriteString(w, xml.Header)
+	io.WriteString(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`+"\n")
+	io.WriteString(w, `  <key id="handle" for="node" attr.name="handle" attr.type="string"/>`+"\n")
+	io.WriteString(w, `  <key id="indegree" for="node" attr.name="indegree" attr.type="int"/>`+"\n")
+	io.WriteString(w, `  <key id="outdegree" for="node" attr.name="outdegree" attr.type="int"/>`+"\n")
+	io.WriteString(w, `  <graph id="follows" edgedefault="directed">`+"\n")
+	for _, did := range g.sortedNodes() {
+		fmt.Fprintf(w, "    <node id=\"%s\"><data key=\"handle\">%s</data><data key=\"indegree\">%d</data><data key=\"outdegree\">%d</data></node>\n",
+			xmlEscape(did), xmlEscape(g.handles[did]), in[did], out[did])
+	}
+	for _, edge := range g.sortedEdges() {
+		fmt.Fprintf(w, "    <edge source=\"%s\" target=\"%s\"/>\n", xmlEscape(edge.Src), xmlEscape(edge.Dst))
+	}
+	_, err := io.WriteString(w, "  </graph>\n</graphml>\n")
+	return err
+}
+
+// xmlEscape escapes text for XML content and attributes
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeGephiNodes writes the nodes table of the Gephi spreadsheet import: Id, Label, and degrees
+func writeGephiNodes(w io.Writer, g *socialGraph) error {
+	in, out := g.degrees()
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Id", "Label", "InDegree", "OutDegree"})
+	for _, did := range g.sortedNodes() {
+		label := g.handles[did]
+		if label == "" {
+			label = did
+		}
+		cw.Write([]string{did, label, strconv.Itoa(in[did]), strconv.Itoa(out[did])})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeGephiEdges writes the edges table of the Gephi spreadsheet import: Source, Target, and Type
+func writeGephiEdges(w io.Writer, g *socialGraph) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Source", "Target", "Type"})
+	for _, edge := range g.sortedEdges() {
+		cw.Write([]string{edge.Src, edge.Dst, "Directed"})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// GraphExport <input> <format> <path> converts a JSONL graph to dot, graphml, or gephi files at path. the input,
+// - for standard input, holds bs:crawlGraph edges, or the bs:getFollowers or bs:getFollows accounts of BG_SUBJECT
+// with BG_DIRECTION followers or follows. BG_MIN_DEGREE drops accounts with fewer follows in and out.
+func (Bs) GraphExport(ctx context.Context, input, format, path string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	g, err := readGraphJSONL(r, p.Subject, p.Direction)
+	if err != nil {
+		return err
+	}
+	return exportGraph(ctx, g, format, path, p.MinDegree)
+}
+
+// GraphExport <format> <path> converts the graph of the followers and follows tables to dot, graphml, or gephi files
+// at path. BG_MIN_DEGREE drops accounts with fewer follows in and out.
+func (Pg) GraphExport(ctx context.Context, format, path string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := openSync()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	g, err := readGraphTables(db)
+	if err != nil {
+		return err
+	}
+	return exportGraph(ctx, g, format, path, p.MinDegree)
+}
+
+// exportGraph filters a graph by degree and writes it
+func exportGraph(ctx context.Context, g *socialGraph, format, path string, minDegree int) error {
+	total := len(g.handles)
+	g.filterDegree(minDegree)
+	if len(g.handles) < total {
+		log.Printf("dropped %d of %d accounts with a degree under %d\n", total-len(g.handles), total, minDegree)
+	}
+	if err := writeGraph(ctx, g, format, path); err != nil {
+		return err
+	}
+	fmt.Printf("Graph of %d accounts and %d follows exported successfully\n", len(g.handles), len(g.edges))
+	return nil
+}
@@ -0,0 +1,207 @@
+package bluegopher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// handleMethod is the result of one method of resolving a handle. a method that is not configured has neither a DID
+// nor a problem.
+type handleMethod struct {
+	Name    string
+	Source  string
+	DID     string
+	Problem string
+}
+
+// Status describes the result of the method for the report
+func (m handleMethod) Status() string {
+	switch {
+	case m.Problem != "":
+		return "misconfigured: " + m.Problem
+	case m.DID != "":
+		return m.DID
+	}
+	return "not configured"
+}
+
+// HandleVerification is the result of checking a handle in both directions: the DNS and HTTPS methods must resolve it
+// to the DID, and the DID document must claim the handle in return
+type HandleVerification struct {
+	Handle    string
+	DNS       handleMethod
+	WellKnown handleMethod
+	DID       string
+	// DocumentHandle is the handle claimed by the DID document, and PDS its atproto_pds service
+	DocumentHandle string
+	PDS            string
+	Problems       []string
+}
+
+// checkHandleDNS reads the did= TXT record at _atproto.<handle>, of which there must be exactly one
+func checkHandleDNS(ctx context.Context, handle string) handleMethod {
+	m := handleMethod{Name: "DNS TXT", Source: "_atproto." + handle}
+	records, err := lookupTXT(ctx, m.Source)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return m
+	}
+	if err != nil {
+		m.Problem = fmt.Sprintf("lookup failed: %v", err)
+		return m
+	}
+
+	var dids []string
+	for _, record := range records {
+		if did, ok := strings.CutPrefix(record, "did="); ok {
+			dids = append(dids, strings.TrimSpace(did))
+		}
+	}
+	switch {
+	case len(dids) == 0 && len(records) > 0:
+		m.Problem = fmt.Sprintf("%d TXT records but none starts with did=", len(records))
+	case len(dids) > 1:
+		m.Problem = fmt.Sprintf("%d did= records, exactly one is allowed: %s", len(dids), strings.Join(dids, ", "))
+	case len(dids) == 1 && !strings.HasPrefix(dids[0], "did:"):
+		m.Problem = fmt.Sprintf("record is not a DID: %q", dids[0])
+	case len(dids) == 1:
+		m.DID = dids[0]
+	}
+	return m
+}
+
+// checkHandleWellKnown reads https://<handle>/.well-known/atproto-did, whose body must be the DID alone
+func checkHandleWellKnown(ctx context.Context, httpClient *http.Client, handle string) handleMethod {
+	m := handleMethod{Name: "HTTPS", Source: "https://" + handle + "/.well-known/atproto-did"}
+	req, err := http.NewRequestWithContext(ctx, "GET", m.Source, nil)
+	if err != nil {
+		m.Problem = err.Error()
+		return m
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return m
+		}
+		m.Problem = fmt.Sprintf("request failed: %v", err)
+		return m
+	}
+	defer res.Body.Close()
+	// the DID is short, so a large body is not a DID either
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if err != nil {
+		m.Problem = fmt.Sprintf("failed to read response: %v", err)
+		return m
+	}
+
+	switch did := strings.TrimSpace(string(body)); {
+	case res.StatusCode == http.StatusNotFound:
+	case res.StatusCode != http.StatusOK:
+		m.Problem = fmt.Sprintf("status code %d", res.StatusCode)
+	case !strings.HasPrefix(did, "did:") || strings.ContainsAny(did, " \n<"):
+		if len(did) > 64 {
+			did = did[:64] + "..."
+		}
+		m.Problem = fmt.Sprintf("response is not a DID: %q", did)
+	default:
+		m.DID = did
+	}
+	return m
+}
+
+// verifyHandle resolves a handle with both methods and checks that the DID document claims it
+func verifyHandle(ctx context.Context, httpClient *http.Client, handle string) *HandleVerification {
+	handle = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(handle)), "@")
+	v := &HandleVerification{
+		Handle:    handle,
+		DNS:       checkHandleDNS(ctx, handle),
+		WellKnown: checkHandleWellKnown(ctx, httpClient, handle),
+	}
+
+	for _, m := range []handleMethod{v.DNS, v.WellKnown} {
+		if m.Problem != "" {
+			v.Problems = append(v.Problems, fmt.Sprintf("%s %s is misconfigured: %s", m.Name, m.Source, m.Problem))
+		}
+	}
+	switch {
+	case v.DNS.DID != "" && v.WellKnown.DID != "" && v.DNS.DID != v.WellKnown.DID:
+		v.Problems = append(v.Problems, fmt.Sprintf("DNS TXT resolves to %s but HTTPS to %s", v.DNS.DID, v.WellKnown.DID))
+		return v
+	case v.DNS.DID != "":
+		v.DID = v.DNS.DID
+	case v.WellKnown.DID != "":
+		v.DID = v.WellKnown.DID
+	default:
+		v.Problems = append(v.Problems, "the handle resolves to a DID with neither DNS TXT nor HTTPS")
+		return v
+	}
+
+	doc, err := fetchDIDDocument(ctx, httpClient, v.DID)
+	if err != nil {
+		v.Problems = append(v.Problems, fmt.Sprintf("failed to get the DID document of %s: %v", v.DID, err))
+		return v
+	}
+	v.DocumentHandle = strings.ToLower(doc.Handle())
+	v.PDS = doc.PDSEndpoint()
+	switch {
+	case doc.ID != v.DID:
+		v.Problems = append(v.Problems, fmt.Sprintf("the DID document of %s is for %s", v.DID, doc.ID))
+	case v.DocumentHandle == "":
+		v.Problems = append(v.Problems, fmt.Sprintf("the DID document of %s claims no handle", v.DID))
+	case v.DocumentHandle != handle:
+		v.Problems = append(v.Problems, fmt.Sprintf("the DID document of %s claims %s, not %s: update the handle of the account", v.DID, v.DocumentHandle, handle))
+	}
+	return v
+}
+
+// VerifyHandle <handle> checks that a handle is verified in both directions: the DNS TXT record _atproto.<handle> and
+// https://<handle>/.well-known/atproto-did are checked, and must not disagree, then the DID document they resolve to
+// must claim the handle. it reports which method is configured or misconfigured, and fails when the handle does not
+// verify.
+func (Bs) VerifyHandle(ctx context.Context, handle string) error {
+	opts, err := ClientOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	httpClient, err := newHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+
+	v := verifyHandle(ctx, httpClient, handle)
+	fmt.Printf("%-9s %s: %s\n", v.DNS.Name, v.DNS.Source, v.DNS.Status())
+	fmt.Printf("%-9s %s: %s\n", v.WellKnown.Name, v.WellKnown.Source, v.WellKnown.Status())
+	if v.DID != "" {
+		fmt.Printf("%-9s %s: handle %s, PDS %s\n", "DID", v.DID, orNone(v.DocumentHandle), orNone(v.PDS))
+	}
+
+	if len(v.Problems) > 0 {
+		for _, problem := range v.Problems {
+			log.Printf("%s\n", problem)
+		}
+		return fmt.Errorf("handle %s failed verification: %d problems", v.Handle, len(v.Problems))
+	}
+	var methods []string
+	for _, m := range []handleMethod{v.DNS, v.WellKnown} {
+		if m.DID != "" {
+			methods = append(methods, m.Name)
+		}
+	}
+	fmt.Printf("Handle %s is verified for %s via %s\n", v.Handle, v.DID, strings.Join(methods, " and "))
+	return nil
+}
+
+// orNone returns s, or none when it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// hostTransport sends every request to one test server, keeping the Host of the request
+type hostTransport struct {
+	server *url.URL
+}
+
+func (t hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.server.Scheme
+	req.URL.Host = t.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newHandleTest serves the well-known DIDs of handles and the DID documents of the PLC directory, resolves the TXT
+// records of txt, and returns a client for its servers
+func newHandleTest(t *testing.T, txt map[string][]string, wellKnown map[string]string, handles map[string]string) *http.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/atproto-did" {
+			did, ok := wellKnown[r.Host]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, did)
+			return
+		}
+		did := strings.TrimPrefix(r.URL.Path, "/")
+		handle, ok := handles[did]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%q,"alsoKnownAs":["at://%s"],"service":[{"id":"#atproto_pds","type":"AtprotoPersonalDataServer","serviceEndpoint":"https://pds.test"}]}`, did, handle)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("PLC_DIRECTORY", "http://plc.test")
+
+	lookup := lookupTXT
+	lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		records, ok := txt[name]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return records, nil
+	}
+	t.Cleanup(func() { lookupTXT = lookup })
+
+	u, _ := url.Parse(server.URL)
+	return &http.Client{Transport: hostTransport{server: u}}
+}
+
+func TestVerifyHandle(t *testing.T) {
+	httpClient := newHandleTest(t,
+		map[string][]string{
+			"_atproto.alice.test":    {"did=did:plc:alice"},
+			"_atproto.split.test":    {"did=did:plc:alice"},
+			"_atproto.multiple.test": {"did=did:plc:alice", "did=did:plc:bob"},
+			"_atproto.spf.test":      {"v=spf1 -all"},
+		},
+		map[string]string{
+			"bob.test":   "did:plc:bob\n",
+			"split.test": "did:plc:bob",
+			"html.test":  "<html>did:plc:bob</html>",
+			"moved.test": "did:plc:carol",
+		},
+		map[string]string{
+			"did:plc:alice": "alice.test",
+			"did:plc:bob":   "bob.test",
+			"did:plc:carol": "carol.test",
+		},
+	)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		handle    string
+		did       string
+		dns       string
+		wellKnown string
+		problem   string
+	}{
+		{handle: "alice.test", did: "did:plc:alice", dns: "did:plc:alice", wellKnown: "not configured"},
+		{handle: "@Bob.Test", did: "did:plc:bob", dns: "not configured", wellKnown: "did:plc:bob"},
+		{handle: "split.test", dns: "did:plc:alice", wellKnown: "did:plc:bob", problem: "DNS TXT resolves to did:plc:alice but HTTPS to did:plc:bob"},
+		{handle: "multiple.test", dns: "misconfigured: 2 did= records", wellKnown: "not configured", problem: "neither DNS TXT nor HTTPS"},
+		{handle: "spf.test", dns: "misconfigured: 1 TXT records but none starts with did=", wellKnown: "not configured", problem: "neither"},
+		{handle: "html.test", dns: "not configured", wellKnown: "misconfigured: response is not a DID", problem: "HTTPS https://html.test/.well-known/atproto-did is misconfigured"},
+		{handle: "moved.test", did: "did:plc:carol", dns: "not configured", wellKnown: "did:plc:carol", problem: "claims carol.test, not moved.test"},
+		{handle: "nobody.test", dns: "not configured", wellKnown: "not configured", problem: "neither DNS TXT nor HTTPS"},
+	} {
+		v := verifyHandle(ctx, httpClient, tt.handle)
+		if !strings.HasPrefix(v.DNS.Status(), tt.dns) || !strings.HasPrefix(v.WellKnown.Status(), tt.wellKnown) {
+			t.Errorf("%s: DNS %q, HTTPS %q, want %q and %q", tt.handle, v.DNS.Status(), v.WellKnown.Status(), tt.dns, tt.wellKnown)
+		}
+		if v.DID != tt.did {
+			t.Errorf("%s: DID = %q, want %q", tt.handle, v.DID, tt.did)
+		}
+		problems := strings.Join(v.Problems, "; ")
+		if tt.problem == "" && problems != "" || tt.problem != "" && !strings.Contains(problems, tt.problem) {
+			t.Errorf("%s: problems = %q, want %q", tt.handle, problems, tt.problem)
+		}
+	}
+}
@@ -1,7 +1,4 @@
-//go:build mage
-// +build mage
-
-package main
+package bluegopher
 
 import (
 	"errors"
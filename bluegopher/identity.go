@@ -0,0 +1,42 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// ResolveHandle resolves a handle to its DID
+func (c *Client) ResolveHandle(ctx context.Context, handle string) (string, error) {
+	requestURL := fmt.Sprintf("%s/xrpc/com.atproto.identity.resolveHandle?handle=%s", c.BaseURL, url.QueryEscape(handle))
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		DID string `json:"did"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response.DID, nil
+}
+
+// UpdateHandle changes the handle of the authenticated account. custom-domain handles must already resolve to the account DID.
+func (c *Client) UpdateHandle(ctx context.Context, newHandle string) error {
+	url := c.BaseURL + "/xrpc/com.atproto.identity.updateHandle"
+	request := map[string]string{
+		"handle": newHandle,
+	}
+
+	if _, err := c.SendRequest(ctx, "POST", url, request); err != nil {
+		return err
+	}
+
+	c.Session.Handle = newHandle
+	return nil
+}
@@ -0,0 +1,263 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/magefile/mage/mg"
+)
+
+// Js is the namespace of the streaming targets, which follow the network's records in real time from Jetstream or the
+// relay firehose
+type Js mg.Namespace
+
+// defaultJetstreamURL is the public Jetstream instance used when BG_JETSTREAM_URL is unset
+const defaultJetstreamURL = "wss://jetstream2.us-east.bsky.network/subscribe"
+
+// errStopStream is returned by a stream handler to end the stream without an error
+var errStopStream = errors.New("stop stream")
+
+// handlerError wraps the error of a stream callback other than handle, such as Firehose.OnCursor, so that it ends the
+// stream like an error of handle instead of causing a reconnect
+type handlerError struct {
+	err error
+}
+
+func (e handlerError) Error() string { return e.err.Error() }
+func (e handlerError) Unwrap() error { return e.err }
+
+// JetstreamEvent is an event of a Jetstream subscription: a commit of a record, or an identity or account change.
+// the relay firehose events are converted to the same form.
+type JetstreamEvent struct {
+	DID    string `json:"did"`
+	TimeUS int64  `json:"time_us"`
+	// Seq is the sequence number of a firehose event, which Jetstream events do not have
+	Seq      int64            `json:"seq,omitempty"`
+	Kind     string           `json:"kind"`
+	Commit   *JetstreamCommit `json:"commit,omitempty"`
+	Identity json.RawMessage  `json:"identity,omitempty"`
+	Account  json.RawMessage  `json:"account,omitempty"`
+
+	// Raw is the original JSON of the item, emitted as-is when the item is marshaled
+	Raw json.RawMessage `json:"-"`
+}
+
+// JetstreamCommit is the record change of a commit event. Record is absent for deletes.
+type JetstreamCommit struct {
+	Rev        string          `json:"rev"`
+	Operation  string          `json:"operation"`
+	Collection string          `json:"collection"`
+	RKey       string          `json:"rkey"`
+	Record     json.RawMessage `json:"record,omitempty"`
+	CID        string          `json:"cid,omitempty"`
+}
+
+// URI returns the AT URI of the record of a commit event
+func (e *JetstreamEvent) URI() string {
+	if e.Commit == nil {
+		return ""
+	}
+	return fmt.Sprintf("at://%s/%s/%s", e.DID, e.Commit.Collection, e.Commit.RKey)
+}
+
+// UnmarshalJSON decodes a JetstreamEvent and keeps the original JSON
+func (e *JetstreamEvent) UnmarshalJSON(data []byte) error {
+	type alias JetstreamEvent
+	if err := json.Unmarshal(data, (*alias)(e)); err != nil {
+		return err
+	}
+	e.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON encodes the original JSON when present
+func (e JetstreamEvent) MarshalJSON() ([]byte, error) {
+	if e.Raw != nil {
+		return e.Raw, nil
+	}
+	type alias JetstreamEvent
+	return json.Marshal(alias(e))
+}
+
+// Jetstream subscribes to a Jetstream endpoint, reconnecting after failures and resuming from the last event
+type Jetstream struct {
+	// URL is the subscribe endpoint, BG_JETSTREAM_URL or the public instance
+	URL string
+	// Collections and DIDs are the wantedCollections and wantedDids filters, applied by the server
+	Collections []string
+	DIDs        []string
+	// Cursor is the time_us of the last event handled. 0 starts at the live tail.
+	Cursor int64
+	// Retry is the backoff between reconnects
+	Retry RetryPolicy
+}
+
+// NewJetstream returns a subscription configured from BG_JETSTREAM_URL, BG_COLLECTIONS, BG_DIDS, and BG_CURSOR, the
+// time_us to replay from
+func NewJetstream(p Params) (*Jetstream, error) {
+	js := &Jetstream{
+		URL:         envString("BG_JETSTREAM_URL", defaultJetstreamURL),
+		Collections: p.Collections,
+		DIDs:        p.DIDs,
+		Retry:       RetryPolicyFromEnv(),
+	}
+	if p.Cursor != "" {
+		cursor, err := strconv.ParseInt(p.Cursor, 10, 64)
+		if err != nil || cursor < 0 {
+			return nil, fmt.Errorf("invalid BG_CURSOR %q: must be a Jetstream time_us", p.Cursor)
+		}
+		js.Cursor = cursor
+	}
+	return js, nil
+}
+
+// subscribeURL returns the endpoint with the filters and the cursor to resume from
+func (js *Jetstream) subscribeURL() string {
+	params := url.Values{}
+	for _, collection := range js.Collections {
+		params.Add("wantedCollections", collection)
+	}
+	for _, did := range js.DIDs {
+		params.Add("wantedDids", did)
+	}
+	if js.Cursor > 0 {
+		params.Set("cursor", strconv.FormatInt(js.Cursor, 10))
+	}
+	return wsURL(js.URL, params)
+}
+
+// Run calls handle with each event until ctx is done or handle returns an error. failed connections are retried with
+// backoff, replaying from the cursor, so no event is handled twice. it returns nil when ctx is done or handle
+// returns errStopStream.
+func (js *Jetstream) Run(ctx context.Context, handle func(*JetstreamEvent) error) error {
+	return reconnect(ctx, "jetstream", js.Retry, js.stream, handle)
+}
+
+// reconnect runs a stream until ctx is done or handle returns an error, reconnecting with backoff after connection
+// and decoding errors. the backoff restarts once a connection receives events.
+func reconnect(ctx context.Context, name string, retry RetryPolicy, stream func(context.Context, func(*JetstreamEvent) error) (bool, error), handle func(*JetstreamEvent) error) error {
+	// errors of handle end the stream, while connection and decoding errors are retried
+	var handleErr error
+	handleEvent := func(event *JetstreamEvent) error {
+		if event.TimeUS > 0 {
+			metrics.Set(metricStreamLag, time.Since(time.UnixMicro(event.TimeUS)).Seconds(), name)
+		}
+		handleErr = handle(event)
+		return handleErr
+	}
+
+	for attempt := 0; ; attempt++ {
+		received, err := stream(ctx, handleEvent)
+		var callbackErr handlerError
+		if errors.As(err, &callbackErr) && handleErr == nil {
+			handleErr = callbackErr.err
+		}
+		if errors.Is(handleErr, errStopStream) || ctx.Err() != nil {
+			return nil
+		}
+		if handleErr != nil {
+			return handleErr
+		}
+		if received {
+			attempt = 0
+		}
+
+		delay := retry.Delay(attempt)
+		log.Printf("%s disconnected (%v): reconnecting in %s\n", name, err, delay.Round(time.Millisecond))
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil
+		}
+	}
+}
+
+// stream handles the events of a single connection, and reports whether any event was received
+func (js *Jetstream) stream(ctx context.Context, handle func(*JetstreamEvent) error) (bool, error) {
+	ws, err := dialWebsocket(ctx, js.subscribeURL())
+	if err != nil {
+		return false, err
+	}
+	defer ws.Close()
+
+	received := false
+	for {
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		var event JetstreamEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			return received, fmt.Errorf("failed to parse jetstream event: %w", err)
+		}
+		// a replay starts at the cursor itself, which was already handled
+		if event.TimeUS <= js.Cursor {
+			continue
+		}
+
+		err = handle(&event)
+		if err == nil || errors.Is(err, errStopStream) {
+			js.Cursor = event.TimeUS
+		}
+		if err != nil {
+			return received, err
+		}
+	}
+}
+
+// Subscribe streams the events of a Jetstream endpoint as JSON lines, filtered with BG_COLLECTIONS, BG_DIDS, and the
+// BG_RULES filter rules, and stops after BG_LIMIT events. it reconnects after failures and logs the cursor to resume
+// from with BG_CURSOR.
+func (Js) Subscribe(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	rules, err := LoadRules()
+	if err != nil {
+		return err
+	}
+
+	js, err := NewJetstream(p)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	progress := StartProgress("js:subscribe", nil)
+	defer progress.Stop(&err)
+	serveMetrics(ctx)
+
+	emitted := 0
+	err = js.Run(ctx, func(event *JetstreamEvent) error {
+		if !rules.MatchEvent(event) {
+			return nil
+		}
+		if err := out.Emit(event); err != nil {
+			return err
+		}
+		progress.Items(1)
+		emitted++
+		if p.Limit > 0 && emitted >= p.Limit {
+			return errStopStream
+		}
+		return nil
+	})
+
+	if js.Cursor > 0 {
+		log.Printf("resume with BG_CURSOR=%d\n", js.Cursor)
+	}
+	return err
+}
@@ -0,0 +1,468 @@
+package bluegopher
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/magefile/mage/mg"
+)
+
+// Lb is the namespace of the labeler targets, which issue signed labels stored in Postgres and serve them with
+// com.atproto.label.queryLabels and subscribeLabels, so a moderation team can run a labeler service
+type Lb mg.Namespace
+
+// labelPollInterval is how often subscribeLabels checks for new labels, which other processes may have issued
+const labelPollInterval = time.Second
+
+// queryLabelsLimit is the page size of queryLabels requests without a limit, and queryLabelsMaxLimit the largest the
+// lexicon allows
+const (
+	queryLabelsLimit    = 50
+	queryLabelsMaxLimit = 250
+)
+
+// SignedLabel is a com.atproto.label.defs#label issued by the labeler: a value applied to, or with Neg removed from,
+// an account DID or record AT URI, signed by the labeler Src. Seq is its sequence number in the labels table.
+type SignedLabel struct {
+	Seq int64
+	Ver int64
+	Src string
+	URI string
+	CID string
+	Val string
+	Neg bool
+	Cts string
+	Exp string
+	Sig []byte
+}
+
+// record returns the label as the DAG-CBOR map that is signed, and with its signature for the event streams
+func (l *SignedLabel) record(withSig bool) map[string]interface{} {
+	m := map[string]interface{}{
+		"ver": l.Ver,
+		"src": l.Src,
+		"uri": l.URI,
+		"val": l.Val,
+		"cts": l.Cts,
+	}
+	if l.CID != "" {
+		m["cid"] = l.CID
+	}
+	if l.Neg {
+		m["neg"] = true
+	}
+	if l.Exp != "" {
+		m["exp"] = l.Exp
+	}
+	if withSig {
+		m["sig"] = l.Sig
+	}
+	return m
+}
+
+// MarshalJSON writes the label in its JSON form, with the signature as {"$bytes": base64}
+func (l *SignedLabel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cborJSON(l.record(true)))
+}
+
+// Labeler issues labels signed with the P-256 key of BG_LABELER_KEY on behalf of the labeler account BG_LABELER_DID
+type Labeler struct {
+	DID string
+	key *ecdsa.PrivateKey
+}
+
+// NewLabeler returns the labeler configured by BG_LABELER_DID and BG_LABELER_KEY
+func NewLabeler() (*Labeler, error) {
+	did := os.Getenv("BG_LABELER_DID")
+	if !strings.HasPrefix(did, "did:") {
+		return nil, fmt.Errorf("BG_LABELER_DID is required: the DID of the labeler account")
+	}
+	key, err := parseP256Key(os.Getenv("BG_LABELER_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid BG_LABELER_KEY, create one with lb:generateKey: %w", err)
+	}
+	return &Labeler{DID: did, key: key}, nil
+}
+
+// Sign signs the DAG-CBOR encoding of a label without its signature, as atproto verifies labels. signatures are the
+// 64-byte r and s of a low-S ECDSA signature.
+func (lb *Labeler) Sign(l *SignedLabel) error {
+	b, err := encodeCBOR(l.record(false))
+	if err != nil {
+		return fmt.Errorf("failed to encode label: %w", err)
+	}
+	hash := sha256.Sum256(b)
+	r, s, err := ecdsa.Sign(rand.Reader, lb.key, hash[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign label: %w", err)
+	}
+	// atproto rejects high-S signatures, which are malleable
+	n := lb.key.Curve.Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+	l.Sig = make([]byte, 64)
+	r.FillBytes(l.Sig[:32])
+	s.FillBytes(l.Sig[32:])
+	return nil
+}
+
+// parseP256Key parses a hex-encoded P-256 private key scalar
+func parseP256Key(s string) (*ecdsa.PrivateKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("expected 64 hex characters")
+	}
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(b)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("key out of range")
+	}
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(b)
+	return key, nil
+}
+
+// publicKeyMultibase returns the multibase form of a P-256 public key used in DID documents and did:key: base58btc
+// of the p256-pub multicodec followed by the compressed point
+func publicKeyMultibase(key *ecdsa.PrivateKey) string {
+	point := elliptic.MarshalCompressed(key.Curve, key.X, key.Y)
+	return "z" + base58Encode(append([]byte{0x80, 0x24}, point...))
+}
+
+// base58Alphabet is the bitcoin base58 alphabet of multibase base58btc
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes bytes as base58btc, keeping leading zero bytes as 1s
+func base58Encode(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// issueLabel signs and stores a label, or a negation of one, on a subject
+func issueLabel(subject, val string, neg bool) (*SignedLabel, error) {
+	if !strings.HasPrefix(subject, "at://") && !strings.HasPrefix(subject, "did:") {
+		return nil, fmt.Errorf("invalid subject %q: must be an AT URI or a DID", subject)
+	}
+	if val == "" || len(val) > 128 {
+		return nil, fmt.Errorf("invalid label value %q: must be 1 to 128 bytes", val)
+	}
+
+	lb, err := NewLabeler()
+	if err != nil {
+		return nil, err
+	}
+	l := &SignedLabel{
+		Ver: 1,
+		Src: lb.DID,
+		URI: subject,
+		Val: val,
+		Neg: neg,
+		Cts: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+	}
+	if err := lb.Sign(l); err != nil {
+		return nil, err
+	}
+
+	db, err := openSync()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	err = db.QueryRow(`INSERT INTO labels (src, uri, cid, val, neg, cts, exp, sig)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, NULLIF($7, ''), $8) RETURNING id`,
+		l.Src, l.URI, l.CID, l.Val, l.Neg, l.Cts, l.Exp, l.Sig).Scan(&l.Seq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store label: %w", err)
+	}
+	return l, nil
+}
+
+// scanLabels reads the labels of a query selecting labelColumns
+func scanLabels(rows *sql.Rows) ([]*SignedLabel, error) {
+	defer rows.Close()
+	var labels []*SignedLabel
+	for rows.Next() {
+		l := &SignedLabel{Ver: 1}
+		var cid, exp sql.NullString
+		if err := rows.Scan(&l.Seq, &l.Src, &l.URI, &cid, &l.Val, &l.Neg, &l.Cts, &exp, &l.Sig); err != nil {
+			return nil, fmt.Errorf("failed to scan label: %w", err)
+		}
+		l.CID, l.Exp = cid.String, exp.String
+		labels = append(labels, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read labels: %w", err)
+	}
+	return labels, nil
+}
+
+// labelColumns are the columns scanLabels reads
+const labelColumns = "id, src, uri, cid, val, neg, cts, exp, sig"
+
+// labelServer serves the labels table with the com.atproto.label methods
+type labelServer struct {
+	db *sql.DB
+}
+
+// handler routes the XRPC methods
+func (s *labelServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/xrpc/com.atproto.label.queryLabels", s.queryLabels)
+	mux.HandleFunc("/xrpc/com.atproto.label.subscribeLabels", s.subscribeLabels)
+	return mux
+}
+
+// queryLabels serves the labels of subjects matching uriPatterns, which are exact or end in a * prefix wildcard
+func (s *labelServer) queryLabels(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	patterns := q["uriPatterns"]
+	if len(patterns) == 0 {
+		writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", "uriPatterns is required")
+		return
+	}
+	likes := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(pattern)
+		if prefix, ok := strings.CutSuffix(escaped, "*"); ok {
+			escaped = prefix + "%"
+		}
+		likes[i] = escaped
+	}
+
+	limit := queryLabelsLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > queryLabelsMaxLimit {
+			writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", fmt.Sprintf("limit must be 1 to %d", queryLabelsMaxLimit))
+			return
+		}
+	}
+	var cursor int64
+	if v := q.Get("cursor"); v != "" {
+		var err error
+		if cursor, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", "invalid cursor")
+			return
+		}
+	}
+
+	var sources []string
+	if len(q["sources"]) > 0 {
+		sources = q["sources"]
+	}
+	rows, err := s.db.QueryContext(r.Context(), `SELECT `+labelColumns+` FROM labels
+		WHERE id > $1 AND uri LIKE ANY($2) AND ($3::text[] IS NULL OR src = ANY($3))
+		ORDER BY id LIMIT $4`, cursor, pq.Array(likes), pq.Array(sources), limit)
+	if err != nil {
+		log.Printf("failed to query labels: %v\n", err)
+		writeXRPCError(w, http.StatusInternalServerError, "InternalServerError", "failed to query labels")
+		return
+	}
+	labels, err := scanLabels(rows)
+	if err != nil {
+		log.Printf("%v\n", err)
+		writeXRPCError(w, http.StatusInternalServerError, "InternalServerError", "failed to query labels")
+		return
+	}
+
+	response := map[string]interface{}{"labels": []*SignedLabel{}}
+	if len(labels) > 0 {
+		response["labels"] = labels
+	}
+	if len(labels) == limit {
+		response["cursor"] = strconv.FormatInt(labels[len(labels)-1].Seq, 10)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// subscribeLabels streams the labels after the cursor as #labels frames, then polls for new ones. without a cursor
+// the stream starts at the live tail.
+func (s *labelServer) subscribeLabels(w http.ResponseWriter, r *http.Request) {
+	var latest int64
+	if err := s.db.QueryRowContext(r.Context(), "SELECT COALESCE(MAX(id), 0) FROM labels").Scan(&latest); err != nil {
+		log.Printf("failed to query labels: %v\n", err)
+		writeXRPCError(w, http.StatusInternalServerError, "InternalServerError", "failed to query labels")
+		return
+	}
+	cursor := latest
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		var err error
+		if cursor, err = strconv.ParseInt(v, 10, 64); err != nil || cursor < 0 {
+			writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", "invalid cursor")
+			return
+		}
+	}
+
+	ws, err := acceptWebsocket(w, r)
+	if err != nil {
+		log.Printf("%v\n", err)
+		return
+	}
+	defer ws.Close()
+
+	if cursor > latest {
+		frame, _ := labelFrame(-1, "", map[string]interface{}{"error": "FutureCursor", "message": "cursor is ahead of the stream"})
+		ws.WriteMessage(frame)
+		return
+	}
+
+	// the client only sends pongs, pings, and close frames, and a failed read ends the stream. the pings keep a quiet
+	// stream from reaching the idle timeout of the reads while the client is still connected.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer cancel()
+		ws.KeepAlive(ctx)
+	}()
+
+	ticker := time.NewTicker(labelPollInterval)
+	defer ticker.Stop()
+	for {
+		rows, err := s.db.QueryContext(ctx, `SELECT `+labelColumns+` FROM labels WHERE id > $1 ORDER BY id LIMIT 500`, cursor)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("failed to query labels: %v\n", err)
+			}
+			return
+		}
+		labels, err := scanLabels(rows)
+		if err != nil {
+			log.Printf("%v\n", err)
+			return
+		}
+		for _, l := range labels {
+			frame, err := labelFrame(1, "#labels", map[string]interface{}{
+				"seq":    l.Seq,
+				"labels": []interface{}{l.record(true)},
+			})
+			if err != nil {
+				log.Printf("%v\n", err)
+				return
+			}
+			if err := ws.WriteMessage(frame); err != nil {
+				return
+			}
+			cursor = l.Seq
+		}
+		if len(labels) == 500 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// labelFrame encodes an event stream frame: a DAG-CBOR header with the op and message type, followed by the body
+func labelFrame(op int64, t string, body map[string]interface{}) ([]byte, error) {
+	header := map[string]interface{}{"op": op}
+	if t != "" {
+		header["t"] = t
+	}
+	frame, err := encodeCBOR(header)
+	if err != nil {
+		return nil, err
+	}
+	b, err := encodeCBOR(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode label frame: %w", err)
+	}
+	return append(frame, b...), nil
+}
+
+// GenerateKey prints a new P-256 signing key for BG_LABELER_KEY and the publicKeyMultibase of its #atproto_label
+// verification method, which the DID document of the labeler account must list
+func (Lb) GenerateKey() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	d := make([]byte, 32)
+	key.D.FillBytes(d)
+
+	fmt.Printf("BG_LABELER_KEY=%s\n", hex.EncodeToString(d))
+	fmt.Printf("publicKeyMultibase: %s\n", publicKeyMultibase(key))
+	return nil
+}
+
+// Emit <subject> <val> applies a label value to an account DID or record AT URI, signed by the BG_LABELER_DID labeler
+func (Lb) Emit(subject, val string) error {
+	l, err := issueLabel(subject, val, false)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Label %s applied to %s with seq %d successfully\n", val, subject, l.Seq)
+	return nil
+}
+
+// Negate <subject> <val> removes a label value from an account DID or record AT URI by issuing a negation label
+func (Lb) Negate(subject, val string) error {
+	l, err := issueLabel(subject, val, true)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Label %s negated on %s with seq %d successfully\n", val, subject, l.Seq)
+	return nil
+}
+
+// Serve <addr> serves the issued labels with com.atproto.label.queryLabels and subscribeLabels until interrupted
+func (Lb) Serve(ctx context.Context, addr string) error {
+	db, err := openSync()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s := &labelServer{db: db}
+	log.Printf("listening on %s\n", addr)
+	if err := serveHTTP(ctx, addr, s.handler()); err != nil {
+		return err
+	}
+	fmt.Printf("labeler stopped successfully\n")
+	return nil
+}
@@ -0,0 +1,408 @@
+package bluegopher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/magefile/mage/mg"
+)
+
+type Mcp mg.Namespace
+
+// mcpProtocolVersion is the Model Context Protocol revision the server implements
+const mcpProtocolVersion = "2025-06-18"
+
+// the JSON-RPC error codes of the MCP server
+const (
+	jsonrpcParseError     = -32700
+	jsonrpcMethodNotFound = -32601
+	jsonrpcInvalidParams  = -32602
+)
+
+// jsonrpcRequest is a JSON-RPC 2.0 request, or a notification when it has no id
+type jsonrpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// jsonrpcResponse is a JSON-RPC 2.0 response with a result or an error
+type jsonrpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpTool is a tool the server exposes. write tools create records in the repository of the account.
+type mcpTool struct {
+	Name        string
+	Description string
+	Properties  map[string]interface{}
+	Required    []string
+	Write       bool
+	Call        func(ctx context.Context, args mcpArgs) (interface{}, error)
+}
+
+// mcpArgs are the arguments of a tool call
+type mcpArgs map[string]interface{}
+
+// String returns a string argument, or empty
+func (a mcpArgs) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// Int returns an integer argument, or def. JSON numbers decode as float64.
+func (a mcpArgs) Int(name string, def int) int {
+	if v, ok := a[name].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+// Strings returns a string array argument
+func (a mcpArgs) Strings(name string) []string {
+	values, _ := a[name].([]interface{})
+	var out []string
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// mcpServer serves tools backed by a Client over the stdio transport of the Model Context Protocol
+type mcpServer struct {
+	client *Client
+	tools  []mcpTool
+
+	mu  sync.Mutex
+	out *json.Encoder
+}
+
+// newMCPServer creates a server with the Bluesky tools, including the write tools only when write is set
+func newMCPServer(c *Client, out io.Writer, write bool) *mcpServer {
+	s := &mcpServer{client: c, out: json.NewEncoder(out)}
+	for _, tool := range blueskyTools(c) {
+		if tool.Write && !write {
+			continue
+		}
+		s.tools = append(s.tools, tool)
+	}
+	return s
+}
+
+// blueskyTools returns the tools of the server
+func blueskyTools(c *Client) []mcpTool {
+	str := func(description string) map[string]interface{} {
+		return map[string]interface{}{"type": "string", "description": description}
+	}
+	limit := map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100, "description": "page size, defaults to 25"}
+	cursor := str("cursor of the next page, from a previous result")
+
+	return []mcpTool{
+		{
+			Name:        "search_posts",
+			Description: "Search Bluesky posts. Supports search syntax such as from:handle, and returns a page of posts and a cursor.",
+			Properties: map[string]interface{}{
+				"query":  str("search query"),
+				"author": str("only posts by this handle or DID"),
+				"lang":   str("only posts in this language code, such as en"),
+				"sort":   map[string]interface{}{"type": "string", "enum": []string{"top", "latest"}},
+				"limit":  limit,
+				"cursor": cursor,
+			},
+			Required: []string{"query"},
+			Call: func(ctx context.Context, args mcpArgs) (interface{}, error) {
+				return c.SearchPosts(ctx, args.String("query"), args.Int("limit", 25), args.String("cursor"), args.String("sort"), "", "", "", args.String("author"), args.String("lang"), "", "", nil)
+			},
+		},
+		{
+			Name:        "get_profile",
+			Description: "Get the profile of a Bluesky account: display name, description, and follower, follow, and post counts.",
+			Properties:  map[string]interface{}{"actor": str("handle or DID")},
+			Required:    []string{"actor"},
+			Call: func(ctx context.Context, args mcpArgs) (interface{}, error) {
+				return c.GetProfile(ctx, args.String("actor"))
+			},
+		},
+		{
+			Name:        "get_author_feed",
+			Description: "Get a page of the posts and reposts of a Bluesky account, newest first.",
+			Properties: map[string]interface{}{
+				"actor":  str("handle or DID"),
+				"filter": map[string]interface{}{"type": "string", "enum": []string{"posts_with_replies", "posts_no_replies", "posts_with_media", "posts_and_author_threads"}},
+				"limit":  limit,
+				"cursor": cursor,
+			},
+			Required: []string{"actor"},
+			Call: func(ctx context.Context, args mcpArgs) (interface{}, error) {
+				return c.GetAuthorFeed(ctx, args.String("actor"), args.Int("limit", 25), args.String("cursor"), args.String("filter"), false)
+			},
+		},
+		{
+			Name:        "create_post",
+			Description: "Publish a post from the authenticated Bluesky account. Mentions and links in the text become facets.",
+			Properties: map[string]interface{}{
+				"text":  map[string]interface{}{"type": "string", "maxLength": maxPostLength, "description": "post text"},
+				"langs": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "language codes, such as en"},
+			},
+			Required: []string{"text"},
+			Write:    true,
+			Call: func(ctx context.Context, args mcpArgs) (interface{}, error) {
+				text := args.String("text")
+				if n := len([]rune(text)); n > maxPostLength {
+					return nil, fmt.Errorf("text is %d characters, over the limit of %d", n, maxPostLength)
+				}
+				return c.Post(ctx, PostRecord{Text: text, Langs: args.Strings("langs")})
+			},
+		},
+		{
+			Name:        "get_list",
+			Description: "Get a Bluesky list and a page of its members, by bsky.app URL or AT URI.",
+			Properties:  map[string]interface{}{"list": str("bsky.app list URL or AT URI"), "limit": limit, "cursor": cursor},
+			Required:    []string{"list"},
+			Call: func(ctx context.Context, args mcpArgs) (interface{}, error) {
+				uri, err := c.ListATURI(ctx, args.String("list"))
+				if err != nil {
+					return nil, err
+				}
+				return c.GetList(ctx, uri, args.Int("limit", 25), args.String("cursor"))
+			},
+		},
+		{
+			Name:        "create_list",
+			Description: "Create a list owned by the authenticated Bluesky account.",
+			Properties: map[string]interface{}{
+				"name":        str("list name"),
+				"description": str("list description"),
+				"purpose":     map[string]interface{}{"type": "string", "enum": []string{"curatelist", "modlist"}, "description": "curatelist (default) or modlist"},
+			},
+			Required: []string{"name"},
+			Write:    true,
+			Call: func(ctx context.Context, args mcpArgs) (interface{}, error) {
+				purpose := "app.bsky.graph.defs#" + args.String("purpose")
+				if args.String("purpose") == "" {
+					purpose = "app.bsky.graph.defs#curatelist"
+				}
+				return c.ListCreate(ctx, purpose, args.String("name"), args.String("description"), time.Now().UTC())
+			},
+		},
+		{
+			Name:        "add_to_list",
+			Description: "Add an account to a list owned by the authenticated Bluesky account.",
+			Properties:  map[string]interface{}{"list": str("bsky.app list URL or AT URI"), "actor": str("handle or DID")},
+			Required:    []string{"list", "actor"},
+			Write:       true,
+			Call: func(ctx context.Context, args mcpArgs) (interface{}, error) {
+				uri, err := c.ListATURI(ctx, args.String("list"))
+				if err != nil {
+					return nil, err
+				}
+				did, err := c.ResolveDID(ctx, args.String("actor"))
+				if err != nil {
+					return nil, err
+				}
+				return c.ListItem(ctx, uri, did, time.Now().UTC())
+			},
+		},
+		{
+			Name:        "remove_from_list",
+			Description: "Remove an account from a list owned by the authenticated Bluesky account.",
+			Properties:  map[string]interface{}{"list": str("bsky.app list URL or AT URI"), "actor": str("handle or DID")},
+			Required:    []string{"list", "actor"},
+			Write:       true,
+			Call: func(ctx context.Context, args mcpArgs) (interface{}, error) {
+				uri, err := c.ListATURI(ctx, args.String("list"))
+				if err != nil {
+					return nil, err
+				}
+				did, err := c.ResolveDID(ctx, args.String("actor"))
+				if err != nil {
+					return nil, err
+				}
+				members, err := c.listMembers(ctx, uri)
+				if err != nil {
+					return nil, err
+				}
+				itemURIs, ok := members[did]
+				if !ok {
+					return nil, fmt.Errorf("%s is not in the list", args.String("actor"))
+				}
+				// every listitem record of the account is removed, or it would stay in the list
+				for _, itemURI := range itemURIs {
+					_, collection, rkey, err := splitATURI(itemURI)
+					if err != nil {
+						return nil, err
+					}
+					if err := c.DeleteRecord(ctx, collection, rkey); err != nil {
+						return nil, err
+					}
+				}
+				return map[string]interface{}{"removed": itemURIs}, nil
+			},
+		},
+	}
+}
+
+// Serve reads newline-delimited JSON-RPC messages until in is closed or ctx is done
+func (s *mcpServer) Serve(ctx context.Context, in io.Reader) error {
+	r := bufio.NewReader(in)
+	for ctx.Err() == nil {
+		line, err := r.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			s.handle(ctx, line)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+	}
+	return ctx.Err()
+}
+
+// handle answers a message. notifications, which have no id, get no response.
+func (s *mcpServer) handle(ctx context.Context, line []byte) {
+	var req jsonrpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.write(jsonrpcResponse{ID: json.RawMessage("null"), Error: &jsonrpcError{Code: jsonrpcParseError, Message: err.Error()}})
+		return
+	}
+	if len(req.ID) == 0 {
+		return
+	}
+
+	result, rpcErr := s.dispatch(ctx, req)
+	s.write(jsonrpcResponse{ID: req.ID, Result: result, Error: rpcErr})
+}
+
+// dispatch runs a request method
+func (s *mcpServer) dispatch(ctx context.Context, req jsonrpcRequest) (interface{}, *jsonrpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "blue-gopher", "version": "1.0.0"},
+			"instructions":    "Tools act as the Bluesky account " + s.client.Session.Handle + ". Requests share its rate limit.",
+		}, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		tools := make([]map[string]interface{}, 0, len(s.tools))
+		for _, tool := range s.tools {
+			schema := map[string]interface{}{"type": "object", "properties": tool.Properties}
+			if len(tool.Required) > 0 {
+				schema["required"] = tool.Required
+			}
+			tools = append(tools, map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"inputSchema": schema,
+				"annotations": map[string]interface{}{"readOnlyHint": !tool.Write, "destructiveHint": tool.Name == "remove_from_list", "openWorldHint": true},
+			})
+		}
+		return map[string]interface{}{"tools": tools}, nil
+	case "tools/call":
+		return s.callTool(ctx, req.Params)
+	default:
+		return nil, &jsonrpcError{Code: jsonrpcMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+// callTool runs a tool. failures of the tool are returned as error results the model can read, rather than protocol
+// errors.
+func (s *mcpServer) callTool(ctx context.Context, params json.RawMessage) (interface{}, *jsonrpcError) {
+	var call struct {
+		Name      string  `json:"name"`
+		Arguments mcpArgs `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &call); err != nil {
+		return nil, &jsonrpcError{Code: jsonrpcInvalidParams, Message: err.Error()}
+	}
+
+	var tool *mcpTool
+	for i := range s.tools {
+		if s.tools[i].Name == call.Name {
+			tool = &s.tools[i]
+		}
+	}
+	if tool == nil {
+		return nil, &jsonrpcError{Code: jsonrpcInvalidParams, Message: "unknown tool: " + call.Name}
+	}
+	for _, name := range tool.Required {
+		if call.Arguments.String(name) == "" {
+			return toolResult(fmt.Sprintf("%s is required", name), true), nil
+		}
+	}
+
+	log.Printf("calling %s\n", tool.Name)
+	result, err := tool.Call(ctx, call.Arguments)
+	if err != nil {
+		log.Printf("%s failed: %v\n", tool.Name, err)
+		return toolResult(err.Error(), true), nil
+	}
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return toolResult(err.Error(), true), nil
+	}
+	return toolResult(string(b), false), nil
+}
+
+// toolResult is the text content of a tool call result
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// write sends a response as a line of JSON
+func (s *mcpServer) write(res jsonrpcResponse) {
+	res.JSONRPC = "2.0"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.out.Encode(res); err != nil {
+		log.Printf("failed to write response: %v\n", err)
+	}
+}
+
+// Serve runs a Model Context Protocol server over stdio, exposing search, profiles, author feeds, posting, and list
+// management as tools for LLM agents. the server is read-only unless BG_MCP_WRITE=true adds the tools that write to the
+// account.
+func (Mcp) Serve(ctx context.Context) error {
+	write, err := envBool("BG_MCP_WRITE", false)
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	// stdout carries the protocol, so everything else is logged to stderr
+	log.SetOutput(os.Stderr)
+	s := newMCPServer(c, os.Stdout, write)
+	log.Printf("serving %d MCP tools for %s on stdio\n", len(s.tools), c.Session.Handle)
+	return s.Serve(ctx, os.Stdin)
+}
@@ -0,0 +1,25 @@
+package bluegopher
+
+import (
+	"io"
+	"testing"
+)
+
+func TestMCPServerReadOnlyByDefault(t *testing.T) {
+	c := newTestClient(t, newFakePDS(t))
+
+	for _, write := range []bool{false, true} {
+		writeTools := 0
+		for _, tool := range newMCPServer(c, io.Discard, write).tools {
+			if tool.Write {
+				writeTools++
+			}
+		}
+		if write && writeTools == 0 {
+			t.Errorf("server with write has no write tools")
+		}
+		if !write && writeTools != 0 {
+			t.Errorf("read-only server has %d write tools", writeTools)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// the metrics of the process, served in the Prometheus text format on BG_METRICS_ADDR
+const (
+	metricRequests       = "bluegopher_requests_total"
+	metricRateLimit      = "bluegopher_rate_limit_remaining"
+	metricItems          = "bluegopher_items_processed_total"
+	metricStreamLag      = "bluegopher_stream_lag_seconds"
+	metricFeedRequests   = "bluegopher_feed_requests_total"
+	metricWebhookFailure = "bluegopher_webhook_failures_total"
+	metricCache          = "bluegopher_cache_requests_total"
+	metricScheduleRuns   = "bluegopher_schedule_runs_total"
+)
+
+// metrics is the registry of the process. the client, progress reports, streams, and servers record into it, and it
+// is only served when a long-running target calls serveMetrics.
+var metrics = newMetricsRegistry(
+	metricFamily{name: metricRequests, kind: "counter", help: "API requests by XRPC method and response status, or error when no response was received", labels: []string{"method", "status"}},
+	metricFamily{name: metricRateLimit, kind: "gauge", help: "Requests remaining in the current rate-limit window of the PDS"},
+	metricFamily{name: metricItems, kind: "counter", help: "Items processed by target", labels: []string{"target"}},
+	metricFamily{name: metricStreamLag, kind: "gauge", help: "Seconds between the time of the latest stream event and when it was handled", labels: []string{"stream"}},
+	metricFamily{name: metricFeedRequests, kind: "counter", help: "Feed skeleton requests by feed and response status", labels: []string{"feed", "status"}},
+	metricFamily{name: metricWebhookFailure, kind: "counter", help: "Webhook deliveries that failed after retries"},
+	metricFamily{name: metricCache, kind: "counter", help: "Cacheable GET requests by result: hit, revalidated, or miss", labels: []string{"result"}},
+	metricFamily{name: metricScheduleRuns, kind: "counter", help: "Scheduled target runs by target and result: success, failure, or skipped", labels: []string{"target", "result"}},
+)
+
+// metricFamily is a counter or gauge, with a value per combination of label values
+type metricFamily struct {
+	name   string
+	kind   string
+	help   string
+	labels []string
+
+	// values are keyed by the label values joined with a zero byte
+	values map[string]float64
+}
+
+// metricsRegistry holds the metric families of the process
+type metricsRegistry struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+// newMetricsRegistry creates a registry of metric families
+func newMetricsRegistry(families ...metricFamily) *metricsRegistry {
+	r := &metricsRegistry{families: make(map[string]*metricFamily, len(families))}
+	for i := range families {
+		f := families[i]
+		f.values = make(map[string]float64)
+		r.families[f.name] = &f
+	}
+	return r
+}
+
+// Add adds to the value of a metric with label values in the order the family declares them
+func (r *metricsRegistry) Add(name string, v float64, labelValues ...string) {
+	r.update(name, labelValues, func(old float64) float64 { return old + v })
+}
+
+// Set sets the value of a gauge
+func (r *metricsRegistry) Set(name string, v float64, labelValues ...string) {
+	r.update(name, labelValues, func(float64) float64 { return v })
+}
+
+// update replaces the value of a metric with f of its current value
+func (r *metricsRegistry) update(name string, labelValues []string, f func(float64) float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	family, ok := r.families[name]
+	if !ok || len(labelValues) != len(family.labels) {
+		log.Printf("invalid metric %s with labels %v\n", name, labelValues)
+		return
+	}
+	key := strings.Join(labelValues, "\x00")
+	family.values[key] = f(family.values[key])
+}
+
+// metricSnapshot is a copy of the values of a metric family, sorted by label values
+type metricSnapshot struct {
+	name    string
+	kind    string
+	help    string
+	labels  []string
+	samples []metricSample
+}
+
+// metricSample is a value of a metric and its label values
+type metricSample struct {
+	labelValues []string
+	value       float64
+}
+
+// snapshot copies the metric families, sorted by name
+func (r *metricsRegistry) snapshot() []metricSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshots := make([]metricSnapshot, 0, len(r.families))
+	for _, family := range r.families {
+		keys := make([]string, 0, len(family.values))
+		for key := range family.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		snapshot := metricSnapshot{name: family.name, kind: family.kind, help: family.help, labels: family.labels}
+		for _, key := range keys {
+			var labelValues []string
+			if len(family.labels) > 0 {
+				labelValues = strings.Split(key, "\x00")
+			}
+			snapshot.samples = append(snapshot.samples, metricSample{labelValues: labelValues, value: family.values[key]})
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].name < snapshots[j].name })
+	return snapshots
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (r *metricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, family := range r.snapshot() {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", family.name, family.help, family.name, family.kind)
+		for _, sample := range family.samples {
+			b.WriteString(family.name)
+			if len(family.labels) > 0 {
+				pairs := make([]string, len(family.labels))
+				for i, label := range family.labels {
+					pairs[i] = label + `="` + labelEscaper.Replace(sample.labelValues[i]) + `"`
+				}
+				b.WriteString("{" + strings.Join(pairs, ",") + "}")
+			}
+			b.WriteString(" " + strconv.FormatFloat(sample.value, 'g', -1, 64) + "\n")
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// labelEscaper escapes label values as the text format expects
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// ServeHTTP serves the metrics to a Prometheus scrape
+func (r *metricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// serveMetrics serves /metrics on BG_METRICS_ADDR until ctx is done, when it is set. a failing metrics server is
+// logged rather than stopping the target it monitors.
+func serveMetrics(ctx context.Context) {
+	addr := os.Getenv("BG_METRICS_ADDR")
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	go func() {
+		log.Printf("serving metrics on %s/metrics\n", addr)
+		if err := serveHTTP(ctx, addr, mux); err != nil {
+			log.Printf("metrics server stopped: %v\n", err)
+		}
+	}()
+}
+
+// xrpcMethod returns the XRPC method of a request URL, such as app.bsky.feed.getAuthorFeed, or the host of other
+// URLs, which keeps the requests metric to a label per endpoint
+func xrpcMethod(requestURL string) string {
+	path, _, _ := strings.Cut(requestURL, "?")
+	if i := strings.Index(path, "/xrpc/"); i >= 0 {
+		return path[i+len("/xrpc/"):]
+	}
+	_, host, _ := strings.Cut(path, "://")
+	host, _, _ = strings.Cut(host, "/")
+	return host
+}
@@ -0,0 +1,332 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AccountStatus is the state of an account on its PDS, used to follow the progress of a migration
+type AccountStatus struct {
+	Activated          bool   `json:"activated"`
+	ValidDID           bool   `json:"validDid"`
+	RepoCommit         string `json:"repoCommit"`
+	RepoRev            string `json:"repoRev"`
+	RepoBlocks         int    `json:"repoBlocks"`
+	IndexedRecords     int    `json:"indexedRecords"`
+	PrivateStateValues int    `json:"privateStateValues"`
+	ExpectedBlobs      int    `json:"expectedBlobs"`
+	ImportedBlobs      int    `json:"importedBlobs"`
+}
+
+// DescribeServer retrieves the DID of the PDS and its account requirements, such as whether an invite code is needed
+func (c *Client) DescribeServer(ctx context.Context) (map[string]interface{}, error) {
+	var response map[string]interface{}
+	if err := c.GetJSON(ctx, "com.atproto.server.describeServer", nil, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// CreateMigratedAccount creates an account for an existing DID, authorized by a service auth token of the account's
+// current PDS, and authenticates the client as the new, deactivated account
+func (c *Client) CreateMigratedAccount(ctx context.Context, serviceAuth, did, handle, email, password, inviteCode string) error {
+	request := map[string]string{
+		"did":      did,
+		"handle":   handle,
+		"email":    email,
+		"password": password,
+	}
+	if inviteCode != "" {
+		request["inviteCode"] = inviteCode
+	}
+	headers := map[string]string{"Authorization": "Bearer " + serviceAuth}
+	body, err := c.SendRequestWithHeaders(ctx, "POST", c.BaseURL+"/xrpc/com.atproto.server.createAccount", request, headers)
+	if err != nil {
+		return fmt.Errorf("failed to create account: %w", err)
+	}
+
+	var session CreateSessionResponse
+	if err := json.Unmarshal(body, &session); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if session.AccessJwt == "" {
+		return fmt.Errorf("failed to create account: missing access token")
+	}
+	c.AuthToken = session.AccessJwt
+	c.Session = session
+	return nil
+}
+
+// CheckAccountStatus retrieves the status of the authenticated account on its PDS
+func (c *Client) CheckAccountStatus(ctx context.Context) (*AccountStatus, error) {
+	var status AccountStatus
+	if err := c.GetJSON(ctx, "com.atproto.server.checkAccountStatus", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ImportRepo uploads the CAR export of a repository into the repository of the authenticated account
+func (c *Client) ImportRepo(ctx context.Context, car []byte) error {
+	headers := map[string]string{"Content-Type": "application/vnd.ipld.car"}
+	_, err := c.SendRequestWithHeaders(ctx, "POST", c.BaseURL+"/xrpc/com.atproto.repo.importRepo", car, headers)
+	return err
+}
+
+// ListMissingBlobs retrieves a page of the blobs referenced by the records of the account but not uploaded yet
+func (c *Client) ListMissingBlobs(ctx context.Context, limit int, cursor string) (map[string]interface{}, error) {
+	params := url.Values{}
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+
+	var response map[string]interface{}
+	if err := c.GetJSON(ctx, "com.atproto.repo.listMissingBlobs", params, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// UploadBlob uploads a blob to the repository of the authenticated account
+func (c *Client) UploadBlob(ctx context.Context, blob []byte, mimeType string) error {
+	headers := map[string]string{"Content-Type": mimeType}
+	_, err := c.SendRequestWithHeaders(ctx, "POST", c.BaseURL+"/xrpc/com.atproto.repo.uploadBlob", blob, headers)
+	return err
+}
+
+// PutPreferences replaces the app.bsky preferences of the authenticated account with the output of GetPreferences
+func (c *Client) PutPreferences(ctx context.Context, preferences json.RawMessage) error {
+	_, err := c.SendRequest(ctx, "POST", c.BaseURL+"/xrpc/app.bsky.actor.putPreferences", preferences)
+	return err
+}
+
+// GetRecommendedDIDCredentials retrieves the rotation keys, verification method, handle, and service endpoint the PDS
+// needs in the DID document of the authenticated account
+func (c *Client) GetRecommendedDIDCredentials(ctx context.Context) (map[string]interface{}, error) {
+	var response map[string]interface{}
+	if err := c.GetJSON(ctx, "com.atproto.identity.getRecommendedDidCredentials", nil, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// RequestPLCOperationSignature asks the PDS to email the account a token authorizing a PLC operation
+func (c *Client) RequestPLCOperationSignature(ctx context.Context) error {
+	_, err := c.SendRequest(ctx, "POST", c.BaseURL+"/xrpc/com.atproto.identity.requestPlcOperationSignature", nil)
+	return err
+}
+
+// SignPLCOperation has the PDS sign a PLC operation updating the DID document with credentials, authorized by the
+// emailed token
+func (c *Client) SignPLCOperation(ctx context.Context, token string, credentials map[string]interface{}) (json.RawMessage, error) {
+	request := map[string]interface{}{"token": token}
+	for k, v := range credentials {
+		request[k] = v
+	}
+	body, err := c.SendRequest(ctx, "POST", c.BaseURL+"/xrpc/com.atproto.identity.signPlcOperation", request)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Operation json.RawMessage `json:"operation"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if len(response.Operation) == 0 {
+		return nil, fmt.Errorf("missing operation in response")
+	}
+	return response.Operation, nil
+}
+
+// SubmitPLCOperation has the PDS submit a signed PLC operation to the PLC directory
+func (c *Client) SubmitPLCOperation(ctx context.Context, operation json.RawMessage) error {
+	request := map[string]json.RawMessage{"operation": operation}
+	_, err := c.SendRequest(ctx, "POST", c.BaseURL+"/xrpc/com.atproto.identity.submitPlcOperation", request)
+	return err
+}
+
+// ActivateAccount activates the authenticated account, so its PDS serves it and emits it on the firehose
+func (c *Client) ActivateAccount(ctx context.Context) error {
+	_, err := c.SendRequest(ctx, "POST", c.BaseURL+"/xrpc/com.atproto.server.activateAccount", nil)
+	return err
+}
+
+// DeactivateAccount deactivates the authenticated account on its PDS, keeping its data
+func (c *Client) DeactivateAccount(ctx context.Context) error {
+	_, err := c.SendRequest(ctx, "POST", c.BaseURL+"/xrpc/com.atproto.server.deactivateAccount", map[string]string{})
+	return err
+}
+
+// Migrate <pds> <handle> moves the authenticated account to another PDS, as <handle> there: it creates the account
+// with service auth, imports the repository, uploads the blobs and preferences, updates the PLC DID document, then
+// activates the new account and deactivates the old one. BG_MIGRATE_PASSWORD is the password of the new account, and
+// BG_MIGRATE_EMAIL and BG_INVITE_CODE are optional. updating the DID document needs a token emailed by the current
+// PDS: the first run requests it and stops, and running again with BG_PLC_TOKEN resumes and finishes the migration.
+// the configured account must sign in with its main password, since app passwords cannot sign PLC operations.
+func (Bs) Migrate(ctx context.Context, pds, handle string) error {
+	password := os.Getenv("BG_MIGRATE_PASSWORD")
+	if password == "" {
+		return fmt.Errorf("BG_MIGRATE_PASSWORD is required: the password of the account on the new PDS")
+	}
+	pds = strings.TrimSuffix(pds, "/")
+	if !strings.HasPrefix(pds, "https://") && !strings.HasPrefix(pds, "http://") {
+		pds = "https://" + pds
+	}
+
+	old, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	did := old.Session.DID
+	if strings.TrimSuffix(old.BaseURL, "/") == pds {
+		return fmt.Errorf("%s is already hosted on %s", did, pds)
+	}
+
+	// the new client authenticates with the password of the new account, and its session is not cached
+	target := &Client{BaseURL: pds, HTTPClient: old.HTTPClient, Retry: old.Retry, Identifier: did, password: password}
+
+	// a previous run may have created the account already, in which case the migration resumes
+	if _, err := target.CreateSession(ctx); err == nil {
+		log.Printf("1/6 resuming the migration of %s to %s\n", did, pds)
+	} else {
+		server, err := target.DescribeServer(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to describe %s: %w", pds, err)
+		}
+		serverDID, _ := server["did"].(string)
+		if serverDID == "" {
+			return fmt.Errorf("failed to describe %s: missing DID", pds)
+		}
+		serviceAuth, err := old.GetServiceAuth(ctx, serverDID, time.Now().Add(time.Minute), "com.atproto.server.createAccount")
+		if err != nil {
+			return fmt.Errorf("failed to get service auth for %s: %w", serverDID, err)
+		}
+		email := envString("BG_MIGRATE_EMAIL", old.Session.Email)
+		if err := target.CreateMigratedAccount(ctx, serviceAuth, did, handle, email, password, os.Getenv("BG_INVITE_CODE")); err != nil {
+			return err
+		}
+		log.Printf("1/6 created %s on %s as @%s\n", did, pds, handle)
+	}
+
+	status, err := target.CheckAccountStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Activated {
+		// a previous run activated the new account, and stopped before the old one was deactivated
+		log.Printf("%s is already active on %s: resuming at the deactivation of the old account\n", did, pds)
+		return finishMigration(ctx, old, did, pds, handle)
+	}
+
+	car, err := old.GetRepo(ctx, did)
+	if err != nil {
+		return fmt.Errorf("failed to export repository: %w", err)
+	}
+	if err := target.ImportRepo(ctx, car); err != nil {
+		return fmt.Errorf("failed to import repository: %w", err)
+	}
+	log.Printf("2/6 imported the repository: %d bytes\n", len(car))
+
+	uploaded := 0
+	cursor := ""
+	for {
+		missing, err := target.ListMissingBlobs(ctx, 500, cursor)
+		if err != nil {
+			return err
+		}
+		blobs, _ := missing["blobs"].([]interface{})
+		for _, item := range blobs {
+			blob, _ := item.(map[string]interface{})
+			cid, _ := blob["cid"].(string)
+			if cid == "" {
+				continue
+			}
+			data, err := old.GetBlob(ctx, did, cid)
+			if err != nil {
+				return fmt.Errorf("failed to download blob %s: %w", cid, err)
+			}
+			if err := target.UploadBlob(ctx, data, http.DetectContentType(data)); err != nil {
+				return fmt.Errorf("failed to upload blob %s: %w", cid, err)
+			}
+			uploaded++
+		}
+
+		next, _ := missing["cursor"].(string)
+		if next == "" || next == cursor || len(blobs) == 0 {
+			break
+		}
+		cursor = next
+	}
+	log.Printf("3/6 uploaded %d blobs\n", uploaded)
+
+	preferences, err := old.GetPreferences(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to export preferences: %w", err)
+	}
+	if err := target.PutPreferences(ctx, preferences); err != nil {
+		return fmt.Errorf("failed to import preferences: %w", err)
+	}
+	log.Printf("4/6 migrated the preferences\n")
+
+	if status, err = target.CheckAccountStatus(ctx); err != nil {
+		return err
+	}
+	if status.ImportedBlobs < status.ExpectedBlobs {
+		return fmt.Errorf("%d of %d blobs are missing on %s: run bs:migrate again", status.ExpectedBlobs-status.ImportedBlobs, status.ExpectedBlobs, pds)
+	}
+
+	if !status.ValidDID {
+		if !strings.HasPrefix(did, "did:plc:") {
+			return fmt.Errorf("%s must be updated by hand to point at %s, then run bs:migrate again", did, pds)
+		}
+		token := os.Getenv("BG_PLC_TOKEN")
+		if token == "" {
+			if err := old.RequestPLCOperationSignature(ctx); err != nil {
+				return fmt.Errorf("failed to request a PLC operation token: %w", err)
+			}
+			fmt.Printf("A PLC operation token was emailed to the account: run bs:migrate again with BG_PLC_TOKEN set to finish the migration\n")
+			return nil
+		}
+
+		credentials, err := target.GetRecommendedDIDCredentials(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the DID credentials of %s: %w", pds, err)
+		}
+		operation, err := old.SignPLCOperation(ctx, token, credentials)
+		if err != nil {
+			return fmt.Errorf("failed to sign the PLC operation: %w", err)
+		}
+		if err := target.SubmitPLCOperation(ctx, operation); err != nil {
+			return fmt.Errorf("failed to submit the PLC operation: %w", err)
+		}
+	}
+	log.Printf("5/6 updated the DID document of %s to %s\n", did, pds)
+
+	if err := target.ActivateAccount(ctx); err != nil {
+		return fmt.Errorf("failed to activate the account on %s: %w", pds, err)
+	}
+	return finishMigration(ctx, old, did, pds, handle)
+}
+
+// finishMigration deactivates the account on the old PDS once it is active on the new one
+func finishMigration(ctx context.Context, old *Client, did, pds, handle string) error {
+	if err := old.DeactivateAccount(ctx); err != nil {
+		return fmt.Errorf("failed to deactivate the account on %s: %w", old.BaseURL, err)
+	}
+	log.Printf("6/6 activated the account on %s and deactivated it on %s\n", pds, old.BaseURL)
+
+	fmt.Printf("Account %s migrated to %s successfully: sign in as @%s with the new password from now on\n", did, pds, handle)
+	return nil
+}
@@ -0,0 +1,28 @@
+package bluegopher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMigrateResumesAfterActivation(t *testing.T) {
+	old := newFakePDS(t)
+	setTestEnv(t, old)
+	t.Setenv("BG_MIGRATE_PASSWORD", testPassword)
+
+	// a previous run created and activated the account on the new PDS
+	pds := newFakePDS(t)
+	pds.queue("com.atproto.server.checkAccountStatus", fakeResponse{Status: 200, Body: `{"activated":true,"validDid":true}`})
+
+	if err := (Bs{}).Migrate(context.Background(), pds.URL, "alice.new.test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(old.calls("com.atproto.server.deactivateAccount")); n != 1 {
+		t.Errorf("deactivateAccount called %d times on the old PDS, want 1", n)
+	}
+	for _, nsid := range []string{"com.atproto.server.createAccount", "com.atproto.repo.importRepo", "com.atproto.server.activateAccount"} {
+		if n := len(pds.calls(nsid)); n != 0 {
+			t.Errorf("%s called %d times after the account was activated", nsid, n)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS bluesky (
+	id SERIAL PRIMARY KEY,
+	name TEXT,
+	data JSONB NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS bluesky_name_key_key;
+ALTER TABLE bluesky DROP COLUMN IF EXISTS key;
@@ -0,0 +1,6 @@
+-- the key column identifies an item by the uri of a post or feed item or the did of a profile, and is unique per
+-- import name so re-imports update rows instead of duplicating them. items without either are always inserted.
+-- tables imported into before the key existed may need their duplicate rows removed first with pg:dedupe.
+ALTER TABLE bluesky ADD COLUMN IF NOT EXISTS key TEXT
+	GENERATED ALWAYS AS (COALESCE(data->>'uri', data#>>'{post,uri}', data->>'did')) STORED;
+CREATE UNIQUE INDEX IF NOT EXISTS bluesky_name_key_key ON bluesky (name, key);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS posts, profiles, followers, follows, listitems;
+DROP FUNCTION IF EXISTS bg_timestamptz(text);
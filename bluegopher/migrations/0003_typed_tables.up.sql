@@ -0,0 +1,79 @@
+-- generated columns need an immutable expression, and a text to timestamptz cast is only stable because it depends
+-- on the session time zone. AT Protocol timestamps carry their offset, so the cast is safe to wrap. invalid
+-- timestamps become NULL rather than failing the insert.
+CREATE OR REPLACE FUNCTION bg_timestamptz(value text) RETURNS timestamptz
+LANGUAGE plpgsql IMMUTABLE AS $$
+BEGIN
+	RETURN value::timestamptz;
+EXCEPTION WHEN others THEN
+	RETURN NULL;
+END
+$$;
+
+-- posts holds app.bsky.feed.defs#postView items
+CREATE TABLE IF NOT EXISTS posts (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT,
+	data JSONB NOT NULL,
+	uri TEXT GENERATED ALWAYS AS (data->>'uri') STORED,
+	cid TEXT GENERATED ALWAYS AS (data->>'cid') STORED,
+	did TEXT GENERATED ALWAYS AS (data#>>'{author,did}') STORED,
+	handle TEXT GENERATED ALWAYS AS (data#>>'{author,handle}') STORED,
+	created_at TIMESTAMPTZ GENERATED ALWAYS AS (bg_timestamptz(data#>>'{record,createdAt}')) STORED,
+	imported_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS posts_uri_key ON posts (uri);
+CREATE INDEX IF NOT EXISTS posts_did_created_at_idx ON posts (did, created_at);
+CREATE INDEX IF NOT EXISTS posts_created_at_idx ON posts (created_at);
+
+-- profiles holds app.bsky.actor.defs#profileViewDetailed items
+CREATE TABLE IF NOT EXISTS profiles (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT,
+	data JSONB NOT NULL,
+	did TEXT GENERATED ALWAYS AS (data->>'did') STORED,
+	handle TEXT GENERATED ALWAYS AS (data->>'handle') STORED,
+	created_at TIMESTAMPTZ GENERATED ALWAYS AS (bg_timestamptz(data->>'createdAt')) STORED,
+	imported_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS profiles_did_key ON profiles (did);
+CREATE INDEX IF NOT EXISTS profiles_handle_idx ON profiles (handle);
+
+-- followers and follows hold app.bsky.actor.defs#profileView items. subject is the DID of the account whose
+-- followers or follows they are.
+CREATE TABLE IF NOT EXISTS followers (
+	id BIGSERIAL PRIMARY KEY,
+	subject TEXT NOT NULL,
+	data JSONB NOT NULL,
+	did TEXT GENERATED ALWAYS AS (data->>'did') STORED,
+	handle TEXT GENERATED ALWAYS AS (data->>'handle') STORED,
+	created_at TIMESTAMPTZ GENERATED ALWAYS AS (bg_timestamptz(data->>'createdAt')) STORED,
+	imported_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS followers_subject_did_key ON followers (subject, did);
+CREATE INDEX IF NOT EXISTS followers_did_idx ON followers (did);
+
+CREATE TABLE IF NOT EXISTS follows (
+	id BIGSERIAL PRIMARY KEY,
+	subject TEXT NOT NULL,
+	data JSONB NOT NULL,
+	did TEXT GENERATED ALWAYS AS (data->>'did') STORED,
+	handle TEXT GENERATED ALWAYS AS (data->>'handle') STORED,
+	created_at TIMESTAMPTZ GENERATED ALWAYS AS (bg_timestamptz(data->>'createdAt')) STORED,
+	imported_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS follows_subject_did_key ON follows (subject, did);
+CREATE INDEX IF NOT EXISTS follows_did_idx ON follows (did);
+
+-- listitems holds app.bsky.graph.defs#listItemView items of the list with the AT URI in list
+CREATE TABLE IF NOT EXISTS listitems (
+	id BIGSERIAL PRIMARY KEY,
+	list TEXT NOT NULL,
+	data JSONB NOT NULL,
+	uri TEXT GENERATED ALWAYS AS (data->>'uri') STORED,
+	did TEXT GENERATED ALWAYS AS (data#>>'{subject,did}') STORED,
+	handle TEXT GENERATED ALWAYS AS (data#>>'{subject,handle}') STORED,
+	imported_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS listitems_list_did_idx ON listitems (list, did);
+CREATE UNIQUE INDEX IF NOT EXISTS listitems_uri_key ON listitems (uri);
@@ -0,0 +1,11 @@
+-- sync_state holds the progress of the pg sync targets per endpoint and actor or query: the cursor of an unfinished
+-- run, and the watermark of the newest record of the last complete run
+CREATE TABLE IF NOT EXISTS sync_state (
+	endpoint TEXT NOT NULL,
+	key TEXT NOT NULL,
+	cursor TEXT NOT NULL DEFAULT '',
+	newest TEXT NOT NULL DEFAULT '',
+	synced_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (endpoint, key)
+);
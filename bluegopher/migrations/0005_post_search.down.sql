@@ -0,0 +1,2 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS text_search;
+ALTER TABLE bluesky DROP COLUMN IF EXISTS text_search;
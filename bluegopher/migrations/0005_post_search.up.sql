@@ -0,0 +1,9 @@
+-- full-text search over post text. the simple configuration does not stem, which suits the many languages of
+-- Bluesky posts; an explicit configuration keeps to_tsvector immutable, as generated columns require.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS text_search TSVECTOR
+	GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(data#>>'{record,text}', ''))) STORED;
+CREATE INDEX IF NOT EXISTS posts_text_search_idx ON posts USING GIN (text_search);
+
+ALTER TABLE bluesky ADD COLUMN IF NOT EXISTS text_search TSVECTOR
+	GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(data#>>'{record,text}', data#>>'{post,record,text}', ''))) STORED;
+CREATE INDEX IF NOT EXISTS bluesky_text_search_idx ON bluesky USING GIN (text_search);
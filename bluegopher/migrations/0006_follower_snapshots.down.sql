@@ -0,0 +1 @@
+DROP TABLE IF EXISTS follower_snapshots;
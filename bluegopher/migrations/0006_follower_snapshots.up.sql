@@ -0,0 +1,11 @@
+-- follower_snapshots records the profile counts of an actor each time pg:snapshotFollowers runs, for growth over time
+CREATE TABLE IF NOT EXISTS follower_snapshots (
+	id SERIAL PRIMARY KEY,
+	actor TEXT NOT NULL,
+	handle TEXT NOT NULL DEFAULT '',
+	follower_count INTEGER NOT NULL,
+	follows_count INTEGER NOT NULL,
+	posts_count INTEGER NOT NULL,
+	captured_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS follower_snapshots_actor_captured_at ON follower_snapshots (actor, captured_at);
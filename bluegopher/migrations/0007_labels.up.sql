@@ -0,0 +1,15 @@
+-- labels holds the signed com.atproto.label.defs#label records issued by the Lb labeler. id is the sequence number
+-- of subscribeLabels and the cursor of queryLabels.
+CREATE TABLE IF NOT EXISTS labels (
+	id BIGSERIAL PRIMARY KEY,
+	src TEXT NOT NULL,
+	uri TEXT NOT NULL,
+	cid TEXT,
+	val TEXT NOT NULL,
+	neg BOOLEAN NOT NULL DEFAULT FALSE,
+	cts TEXT NOT NULL,
+	exp TEXT,
+	sig BYTEA NOT NULL
+);
+CREATE INDEX IF NOT EXISTS labels_uri_idx ON labels (uri text_pattern_ops);
+CREATE INDEX IF NOT EXISTS labels_src_idx ON labels (src);
@@ -0,0 +1 @@
+DROP MATERIALIZED VIEW IF EXISTS top_posters, daily_post_volume, follower_counts, engagement_leaders;
@@ -0,0 +1,47 @@
+-- the analytics views summarize the typed tables for dashboards. engagement counts are those of the latest import of
+-- each post, and profile counts those of the latest import of each profile. the views are created empty so the
+-- migration stays quick on large tables; pg:createAnalyticsViews populates them. the unique indexes are what
+-- REFRESH MATERIALIZED VIEW CONCURRENTLY needs.
+CREATE MATERIALIZED VIEW IF NOT EXISTS top_posters AS
+	SELECT did, max(handle) AS handle, count(*) AS posts,
+		count(*) FILTER (WHERE data#>'{record,reply}' IS NULL) AS original_posts,
+		min(created_at) AS first_post, max(created_at) AS last_post
+	FROM posts
+	WHERE did IS NOT NULL
+	GROUP BY did
+WITH NO DATA;
+CREATE UNIQUE INDEX IF NOT EXISTS top_posters_key ON top_posters (did);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS daily_post_volume AS
+	SELECT date_trunc('day', created_at AT TIME ZONE 'UTC')::date AS day, count(*) AS posts,
+		count(DISTINCT did) AS authors
+	FROM posts
+	WHERE created_at IS NOT NULL
+	GROUP BY 1
+WITH NO DATA;
+CREATE UNIQUE INDEX IF NOT EXISTS daily_post_volume_key ON daily_post_volume (day);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS follower_counts AS
+	SELECT p.did, p.handle,
+		(p.data->>'followersCount')::bigint AS followers_count,
+		(p.data->>'followsCount')::bigint AS follows_count,
+		(p.data->>'postsCount')::bigint AS posts_count,
+		(SELECT count(*) FROM followers f WHERE f.subject = p.did) AS imported_followers,
+		p.imported_at
+	FROM profiles p
+	WHERE p.did IS NOT NULL
+WITH NO DATA;
+CREATE UNIQUE INDEX IF NOT EXISTS follower_counts_key ON follower_counts (did);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS engagement_leaders AS
+	SELECT did, max(handle) AS handle, count(*) AS posts,
+		sum((data->>'likeCount')::bigint) AS likes,
+		sum((data->>'repostCount')::bigint) AS reposts,
+		sum((data->>'replyCount')::bigint) AS replies,
+		sum((data->>'quoteCount')::bigint) AS quotes,
+		round(avg(COALESCE((data->>'likeCount')::numeric, 0) + COALESCE((data->>'repostCount')::numeric, 0)), 2) AS avg_engagement
+	FROM posts
+	WHERE did IS NOT NULL
+	GROUP BY did
+WITH NO DATA;
+CREATE UNIQUE INDEX IF NOT EXISTS engagement_leaders_key ON engagement_leaders (did);
@@ -0,0 +1,260 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// ModerationEntry is a block or mute of an account
+type ModerationEntry struct {
+	DID    string `json:"did"`
+	Handle string `json:"handle,omitempty"`
+	// Type is block or mute
+	Type string `json:"type"`
+}
+
+// ModerationAction is a change bs:syncModeration applies to the target account
+type ModerationAction struct {
+	// Action is block, unblock, mute, or unmute
+	Action string `json:"action"`
+	DID    string `json:"did"`
+	Handle string `json:"handle,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+}
+
+// MutesResponse represents the response from getMutes
+type MutesResponse struct {
+	Mutes  []ProfileView `json:"mutes"`
+	Cursor string        `json:"cursor,omitempty"`
+}
+
+// GetMutes retrieves a page of the accounts the authenticated user has muted
+func (c *Client) GetMutes(ctx context.Context, limit int, cursor string) (*MutesResponse, error) {
+	params := url.Values{}
+	if limit > 0 {
+		params.Set("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+
+	var response MutesResponse
+	if err := c.GetJSON(ctx, "app.bsky.graph.getMutes", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// MuteActor mutes an account. mutes are private and stored by the AppView rather than in the repository.
+func (c *Client) MuteActor(ctx context.Context, actor string) error {
+	_, err := c.SendRequest(ctx, "POST", c.BaseURL+"/xrpc/app.bsky.graph.muteActor", map[string]string{"actor": actor})
+	return err
+}
+
+// UnmuteActor unmutes an account
+func (c *Client) UnmuteActor(ctx context.Context, actor string) error {
+	_, err := c.SendRequest(ctx, "POST", c.BaseURL+"/xrpc/app.bsky.graph.unmuteActor", map[string]string{"actor": actor})
+	return err
+}
+
+// moderationState is the blocks and mutes of an account by DID. blocks map to the rkey of their record, mutes to
+// the handle of the muted account.
+type moderationState struct {
+	blocks map[string]string
+	mutes  map[string]string
+}
+
+// loadModeration lists the block records and the mutes of the authenticated user
+func (c *Client) loadModeration(ctx context.Context) (*moderationState, error) {
+	s := &moderationState{blocks: make(map[string]string), mutes: make(map[string]string)}
+
+	err := c.EachRecord(ctx, c.Session.DID, "app.bsky.graph.block", func(record map[string]interface{}) error {
+		value, _ := record["value"].(map[string]interface{})
+		subject, _ := value["subject"].(string)
+		uri, _ := record["uri"].(string)
+		_, _, rkey, err := splitATURI(uri)
+		if subject == "" || err != nil {
+			return nil
+		}
+		s.blocks[subject] = rkey
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocks: %w", err)
+	}
+
+	cursor := ""
+	for {
+		resp, err := c.GetMutes(ctx, 100, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list mutes: %w", err)
+		}
+		for _, account := range resp.Mutes {
+			s.mutes[account.DID] = account.Handle
+		}
+		if resp.Cursor == "" || len(resp.Mutes) == 0 {
+			break
+		}
+		cursor = resp.Cursor
+	}
+
+	log.Printf("%s has %d blocks and %d mutes\n", c.Session.Handle, len(s.blocks), len(s.mutes))
+	return s, nil
+}
+
+// sortedSet returns the keys of a set of DIDs in order
+func sortedSet(set map[string]string) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ExportModeration outputs the blocks and mutes of the account, as {"did","handle","type"} lines with type block or
+// mute. select the account with BG_PROFILE.
+func (Bs) ExportModeration(ctx context.Context) (err error) {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	state, err := c.loadModeration(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	for _, did := range sortedSet(state.blocks) {
+		if err := out.Emit(ModerationEntry{DID: did, Type: "block"}); err != nil {
+			return err
+		}
+	}
+	for _, did := range sortedSet(state.mutes) {
+		if err := out.Emit(ModerationEntry{DID: did, Handle: state.mutes[did], Type: "mute"}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncModeration <fromProfile> <toProfile> copies the blocks and mutes of one account profile of the config file to
+// another, and outputs each change. BG_SYNC_MODE is add (default), which only adds the blocks and mutes the target
+// lacks, or full, which also removes those the source does not have. BG_DRY_RUN=true only reports the changes.
+func (Bs) SyncModeration(ctx context.Context, fromProfile, toProfile string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+	if p.SyncMode != "add" && p.SyncMode != "full" {
+		return fmt.Errorf("invalid BG_SYNC_MODE %q: must be add or full", p.SyncMode)
+	}
+
+	var clients [2]*Client
+	for i, profile := range []string{fromProfile, toProfile} {
+		opts, err := ClientOptionsForProfile(profile)
+		if err != nil {
+			return err
+		}
+		if clients[i], err = NewClientWithOptions(ctx, opts); err != nil {
+			return fmt.Errorf("failed to authenticate profile %s: %w", profile, err)
+		}
+	}
+	from, to := clients[0], clients[1]
+	if from.Session.DID == to.Session.DID {
+		return fmt.Errorf("profiles %s and %s are the same account", fromProfile, toProfile)
+	}
+
+	source, err := from.loadModeration(ctx)
+	if err != nil {
+		return err
+	}
+	target, err := to.loadModeration(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	progress := StartProgress("bs:syncModeration", to)
+	defer progress.Stop(&err)
+
+	changes, failed := 0, 0
+	apply := func(action ModerationAction, do func() error) error {
+		if !p.DryRun {
+			if err := do(); err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				log.Printf("failed to %s %s: %v\n", action.Action, action.DID, err)
+				failed++
+				return nil
+			}
+		}
+		action.DryRun = p.DryRun
+		changes++
+		progress.Items(1)
+		return out.Emit(action)
+	}
+
+	for _, did := range sortedSet(source.blocks) {
+		// an account cannot block or mute itself, so the blocks and mutes of each account on the other are left as
+		// they are
+		if _, ok := target.blocks[did]; ok || did == to.Session.DID {
+			continue
+		}
+		err := apply(ModerationAction{Action: "block", DID: did}, func() error {
+			_, err := to.Block(ctx, did, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, did := range sortedSet(source.mutes) {
+		if _, ok := target.mutes[did]; ok || did == to.Session.DID {
+			continue
+		}
+		if err := apply(ModerationAction{Action: "mute", DID: did, Handle: source.mutes[did]}, func() error { return to.MuteActor(ctx, did) }); err != nil {
+			return err
+		}
+	}
+
+	if p.SyncMode == "full" {
+		for _, did := range sortedSet(target.blocks) {
+			if _, ok := source.blocks[did]; ok || did == from.Session.DID {
+				continue
+			}
+			rkey := target.blocks[did]
+			if err := apply(ModerationAction{Action: "unblock", DID: did}, func() error { return to.DeleteRecord(ctx, "app.bsky.graph.block", rkey) }); err != nil {
+				return err
+			}
+		}
+		for _, did := range sortedSet(target.mutes) {
+			if _, ok := source.mutes[did]; ok || did == from.Session.DID {
+				continue
+			}
+			if err := apply(ModerationAction{Action: "unmute", DID: did, Handle: target.mutes[did]}, func() error { return to.UnmuteActor(ctx, did) }); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Printf("%d changes from %s to %s (%d failed)\n", changes, fromProfile, toProfile, failed)
+	return nil
+}
@@ -0,0 +1,244 @@
+package bluegopher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// mstMaxDepth bounds the height of the MST walked, far above the height of real repositories, so a malformed
+// archive cannot exhaust the stack
+const mstMaxDepth = 128
+
+// RepoRecord is a record of a repository archive, keyed by its collection and rkey
+type RepoRecord struct {
+	Collection string
+	Rkey       string
+	CID        CID
+	Value      interface{}
+}
+
+// Commit decodes the signed commit at the root of a repository archive: the did, rev, and the data CID of the root
+// of its MST
+func (car *CAR) Commit() (map[string]interface{}, error) {
+	if len(car.Roots) == 0 {
+		return nil, fmt.Errorf("the CAR has no root commit")
+	}
+	v, err := car.Record(car.Roots[0])
+	if err != nil {
+		return nil, err
+	}
+	commit, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("root %s is not a commit", car.Roots[0])
+	}
+	if _, ok := commit["data"].(CID); !ok {
+		return nil, fmt.Errorf("commit %s has no data", car.Roots[0])
+	}
+	return commit, nil
+}
+
+// WalkRecords calls fn with every record of a repository archive in key order, walking the MST of the root commit
+func (car *CAR) WalkRecords(fn func(record RepoRecord) error) error {
+	commit, err := car.Commit()
+	if err != nil {
+		return err
+	}
+	return car.walkMST(commit["data"].(CID), 0, func(key string, cid CID) error {
+		collection, rkey, ok := strings.Cut(key, "/")
+		if !ok {
+			return fmt.Errorf("invalid MST key %q", key)
+		}
+		value, err := car.Record(cid)
+		if err != nil {
+			return err
+		}
+		return fn(RepoRecord{Collection: collection, Rkey: rkey, CID: cid, Value: value})
+	})
+}
+
+// walkMST walks an MST node in key order: the subtree left of its entries, then each entry followed by the subtree
+// right of it. entry keys are compressed against the previous key of the node, sharing its first p bytes.
+func (car *CAR) walkMST(cid CID, depth int, fn func(key string, cid CID) error) error {
+	if depth > mstMaxDepth {
+		return fmt.Errorf("MST deeper than %d", mstMaxDepth)
+	}
+	v, err := car.Record(cid)
+	if err != nil {
+		return fmt.Errorf("failed to read MST node: %w", err)
+	}
+	node, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("MST node %s is not a map", cid)
+	}
+
+	if left, ok := node["l"].(CID); ok {
+		if err := car.walkMST(left, depth+1, fn); err != nil {
+			return err
+		}
+	}
+
+	entries, _ := node["e"].([]interface{})
+	var key []byte
+	for _, item := range entries {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("MST node %s has an invalid entry", cid)
+		}
+		prefix, _ := entry["p"].(int64)
+		suffix, _ := entry["k"].([]byte)
+		value, ok := entry["v"].(CID)
+		if !ok || prefix < 0 || int(prefix) > len(key) {
+			return fmt.Errorf("MST node %s has an invalid entry", cid)
+		}
+		key = append(key[:prefix:prefix], suffix...)
+		if err := fn(string(key), value); err != nil {
+			return err
+		}
+		if right, ok := entry["t"].(CID); ok {
+			if err := car.walkMST(right, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validNSID reports whether s has the form of an NSID such as app.bsky.feed.post: dot-separated segments of letters,
+// digits, and hyphens
+func validNSID(s string) bool {
+	segments := strings.Split(s, ".")
+	if len(segments) < 3 {
+		return false
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			return false
+		}
+		for _, r := range segment {
+			if r != '-' && (r < '0' || r > '9') && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readRepoArchive reads the repository of a .car file, or of a backup directory or archive written by bs:backup
+func readRepoArchive(repo string) (*CAR, error) {
+	if strings.HasSuffix(repo, ".car") {
+		data, err := os.ReadFile(repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", repo, err)
+		}
+		return readCAR(data)
+	}
+
+	var car *CAR
+	err := walkBackup(repo, func(name string, r io.Reader) error {
+		if name != backupRepoFile {
+			return nil
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		car, err = readCAR(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if car == nil {
+		return nil, fmt.Errorf("%s is not a backup: %s is missing", repo, backupRepoFile)
+	}
+	return car, nil
+}
+
+// ExtractRecords <repo> <dir> writes the records of a repository archive to one <collection>.jsonl file per
+// collection in dir, without a PDS. repo is a .car file from com.atproto.sync.getRepo, or a backup directory or
+// .tar.gz archive written by bs:backup. each line holds the at:// URI, CID, and JSON value of a record.
+func (Bs) ExtractRecords(repo, dir string) error {
+	car, err := readRepoArchive(repo)
+	if err != nil {
+		return err
+	}
+	if err := car.Verify(); err != nil {
+		return fmt.Errorf("failed to verify repository: %w", err)
+	}
+	commit, err := car.Commit()
+	if err != nil {
+		return err
+	}
+	did, _ := commit["did"].(string)
+	rev, _ := commit["rev"].(string)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	type collectionFile struct {
+		f     *os.File
+		w     *bufio.Writer
+		enc   *json.Encoder
+		count int
+	}
+	files := make(map[string]*collectionFile)
+	defer func() {
+		for _, file := range files {
+			file.f.Close()
+		}
+	}()
+
+	err = car.WalkRecords(func(record RepoRecord) error {
+		file, ok := files[record.Collection]
+		if !ok {
+			// the collection names the output file, so keys of a malformed archive must not escape dir
+			if !validNSID(record.Collection) {
+				return fmt.Errorf("invalid collection %q in the MST", record.Collection)
+			}
+			f, err := os.Create(filepath.Join(dir, record.Collection+".jsonl"))
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			w := bufio.NewWriter(f)
+			enc := json.NewEncoder(w)
+			enc.SetEscapeHTML(false)
+			file = &collectionFile{f: f, w: w, enc: enc}
+			files[record.Collection] = file
+		}
+		file.count++
+		return file.enc.Encode(map[string]interface{}{
+			"uri":   fmt.Sprintf("at://%s/%s/%s", did, record.Collection, record.Rkey),
+			"cid":   record.CID.String(),
+			"value": cborJSON(record.Value),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	collections := make([]string, 0, len(files))
+	total := 0
+	for collection, file := range files {
+		if err := file.w.Flush(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.f.Name(), err)
+		}
+		if err := file.f.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.f.Name(), err)
+		}
+		collections = append(collections, collection)
+		total += file.count
+	}
+	sort.Strings(collections)
+	for _, collection := range collections {
+		fmt.Printf("%-32s %d\n", collection, files[collection].count)
+	}
+	fmt.Printf("Extracted %d records of %s at rev %s to %s\n", total, did, rev, dir)
+	return nil
+}
@@ -0,0 +1,154 @@
+package bluegopher
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCAR builds repository archives from DAG-CBOR blocks
+type testCAR struct {
+	t      *testing.T
+	blocks [][]byte
+}
+
+// add encodes a block and returns its CIDv1
+func (tc *testCAR) add(v interface{}) CID {
+	tc.t.Helper()
+	b, err := encodeCBOR(v)
+	if err != nil {
+		tc.t.Fatal(err)
+	}
+	sum := sha256.Sum256(b)
+	cid := CID(append([]byte{0x01, 0x71, 0x12, 0x20}, sum[:]...))
+	tc.blocks = append(tc.blocks, append(append([]byte(nil), cid...), b...))
+	return cid
+}
+
+// bytes returns the archive with a root
+func (tc *testCAR) bytes(root CID) []byte {
+	tc.t.Helper()
+	header, err := encodeCBOR(map[string]interface{}{"version": 1, "roots": []interface{}{root}})
+	if err != nil {
+		tc.t.Fatal(err)
+	}
+	var b []byte
+	for _, section := range append([][]byte{header}, tc.blocks...) {
+		b = binary.AppendUvarint(b, uint64(len(section)))
+		b = append(b, section...)
+	}
+	return b
+}
+
+// testRepo builds a repository of two collections whose MST has a root node with a left subtree and a subtree right
+// of its entry, with keys compressed against the previous key of their node
+func testRepo(t *testing.T) []byte {
+	tc := &testCAR{t: t}
+	post := func(text string) CID {
+		return tc.add(map[string]interface{}{"$type": "app.bsky.feed.post", "text": text, "createdAt": "2024-11-01T12:00:00Z"})
+	}
+	follow := tc.add(map[string]interface{}{"$type": "app.bsky.graph.follow", "subject": "did:plc:bob", "createdAt": "2024-11-01T12:00:00Z"})
+	entry := func(prefix int, suffix string, value CID, right interface{}) map[string]interface{} {
+		return map[string]interface{}{"p": prefix, "k": []byte(suffix), "v": value, "t": right}
+	}
+
+	left := tc.add(map[string]interface{}{"l": nil, "e": []interface{}{
+		entry(0, "app.bsky.feed.post/3kpost0", post("first <post>"), nil),
+		entry(23, "st1", post("second"), nil),
+	}})
+	right := tc.add(map[string]interface{}{"l": nil, "e": []interface{}{
+		entry(0, "app.bsky.graph.follow/3kfollow0", follow, nil),
+	}})
+	root := tc.add(map[string]interface{}{"l": left, "e": []interface{}{
+		entry(0, "app.bsky.feed.post/3kpost2", post("third"), right),
+	}})
+	commit := tc.add(map[string]interface{}{"did": testDID, "version": 3, "data": root, "rev": "3krev", "prev": nil, "sig": []byte("sig")})
+	return tc.bytes(commit)
+}
+
+func TestWalkRecords(t *testing.T) {
+	car, err := readCAR(testRepo(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := car.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	err = car.WalkRecords(func(record RepoRecord) error {
+		keys = append(keys, record.Collection+"/"+record.Rkey)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "app.bsky.feed.post/3kpost0 app.bsky.feed.post/3kpost1 app.bsky.feed.post/3kpost2 app.bsky.graph.follow/3kfollow0"
+	if got := strings.Join(keys, " "); got != want {
+		t.Errorf("keys = %s, want %s", got, want)
+	}
+}
+
+func TestExtractRecords(t *testing.T) {
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo.car")
+	if err := os.WriteFile(repo, testRepo(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "records")
+	if err := (Bs{}).ExtractRecords(repo, out); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(out, "app.bsky.feed.post.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	type extracted struct {
+		URI   string     `json:"uri"`
+		CID   string     `json:"cid"`
+		Value PostRecord `json:"value"`
+	}
+	var posts []extracted
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var post extracted
+		if err := json.Unmarshal(scanner.Bytes(), &post); err != nil {
+			t.Fatal(err)
+		}
+		posts = append(posts, post)
+	}
+	if len(posts) != 3 || posts[0].URI != "at://"+testDID+"/app.bsky.feed.post/3kpost0" || posts[0].Value.Text != "first <post>" || !strings.HasPrefix(posts[0].CID, "bafyrei") {
+		t.Errorf("posts = %+v", posts)
+	}
+
+	follows, err := os.ReadFile(filepath.Join(out, "app.bsky.graph.follow.jsonl"))
+	if err != nil || strings.Count(string(follows), "\n") != 1 || !strings.Contains(string(follows), `"subject":"did:plc:bob"`) {
+		t.Errorf("follows = %s, %v", follows, err)
+	}
+}
+
+func TestExtractRecordsRejectsPathKeys(t *testing.T) {
+	tc := &testCAR{t: t}
+	record := tc.add(map[string]interface{}{"$type": "app.bsky.feed.post", "text": "escape"})
+	root := tc.add(map[string]interface{}{"l": nil, "e": []interface{}{
+		map[string]interface{}{"p": 0, "k": []byte("../escape/3k"), "v": record, "t": nil},
+	}})
+	commit := tc.add(map[string]interface{}{"did": testDID, "version": 3, "data": root, "rev": "3krev"})
+
+	dir := t.TempDir()
+	repo := filepath.Join(dir, "repo.car")
+	if err := os.WriteFile(repo, tc.bytes(commit), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Bs{}).ExtractRecords(repo, filepath.Join(dir, "records")); err == nil || !strings.Contains(err.Error(), "invalid collection") {
+		t.Errorf("err = %v, want invalid collection", err)
+	}
+}
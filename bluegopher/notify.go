@@ -0,0 +1,326 @@
+package bluegopher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// notificationPages bounds the pages a poll reads to catch up, so a watcher stopped for long does not page through
+// the whole history
+const notificationPages = 10
+
+// notificationSeenLimit is the number of handled notification URIs remembered for deduplication
+const notificationSeenLimit = 1000
+
+// NotificationWatcher polls the notifications of the authenticated account and hands each new one to a handler once,
+// oldest first. what was handled is saved in the cache directory, so a restarted watcher continues where it stopped.
+type NotificationWatcher struct {
+	Client *Client
+	// Reasons are the notification reasons handled, all when empty
+	Reasons []string
+	// Interval is the pause between polls
+	Interval time.Duration
+
+	name  string
+	path  string
+	state notificationState
+	seen  map[string]bool
+	// polled is the indexedAt of the newest notification of the last poll, of any reason
+	polled time.Time
+}
+
+// notificationState is the saved progress of a watcher: the indexedAt of the newest notification handled, and the
+// URIs of the latest ones, since several notifications may share a timestamp
+type notificationState struct {
+	Newest time.Time `json:"newest"`
+	Seen   []string  `json:"seen"`
+}
+
+// NewNotificationWatcher loads the saved state of the named watcher for the account of a client. a watcher without
+// saved state starts after the newest notification, rather than replaying the account's history.
+func NewNotificationWatcher(c *Client, name string, p Params) (*NotificationWatcher, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(name + "\x00" + c.Session.DID))
+	w := &NotificationWatcher{
+		Client:   c,
+		Reasons:  p.Reasons,
+		Interval: p.Interval,
+		name:     name,
+		path:     filepath.Join(dir, "state", "notifications-"+hex.EncodeToString(sum[:8])+".json"),
+		seen:     make(map[string]bool),
+	}
+
+	b, err := os.ReadFile(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification state: %w", err)
+	}
+	if err := json.Unmarshal(b, &w.state); err != nil {
+		return nil, fmt.Errorf("failed to parse notification state %s: %w", w.path, err)
+	}
+	for _, uri := range w.state.Seen {
+		w.seen[uri] = true
+	}
+	return w, nil
+}
+
+// Run polls every Interval until ctx is done or handle returns an error. failed polls are logged and retried at the
+// next interval, while a notification whose handler failed is handled again by the next run.
+func (w *NotificationWatcher) Run(ctx context.Context, handle func(Notification) error) error {
+	if w.state.Newest.IsZero() {
+		if err := w.start(ctx); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		notifications, err := w.Poll(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Printf("failed to poll notifications: %v\n", err)
+		}
+		for _, n := range notifications {
+			if err := handle(n); err != nil {
+				return err
+			}
+			if err := w.markSeen(n); err != nil {
+				return err
+			}
+			metrics.Add(metricItems, 1, w.name)
+		}
+		// once the wanted notifications are handled, the other reasons are skipped too, so they are not paged again
+		if err == nil && w.polled.After(w.state.Newest) {
+			w.state.Newest = w.polled
+			if err := w.save(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// start marks the current notifications as seen
+func (w *NotificationWatcher) start(ctx context.Context) error {
+	resp, err := w.Client.ListNotifications(ctx, 50, "")
+	if err != nil {
+		return err
+	}
+	for i := len(resp.Notifications) - 1; i >= 0; i-- {
+		w.remember(resp.Notifications[i])
+	}
+	if w.state.Newest.IsZero() {
+		w.state.Newest = time.Now().UTC()
+	}
+	if err := w.save(); err != nil {
+		return err
+	}
+	log.Printf("watching notifications after %s\n", w.state.Newest.Format(time.RFC3339))
+	return nil
+}
+
+// Poll returns the notifications with a wanted reason that were not handled yet, oldest first
+func (w *NotificationWatcher) Poll(ctx context.Context) ([]Notification, error) {
+	var unseen []Notification
+	w.polled = time.Time{}
+	cursor := ""
+	for page := 0; page < notificationPages; page++ {
+		resp, err := w.Client.ListNotifications(ctx, 50, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		caughtUp := false
+		for _, n := range resp.Notifications {
+			indexed, err := time.Parse(time.RFC3339Nano, n.IndexedAt)
+			if err == nil && indexed.Before(w.state.Newest) {
+				caughtUp = true
+				break
+			}
+			if err == nil && indexed.After(w.polled) {
+				w.polled = indexed
+			}
+			if !w.seen[n.URI] && w.wanted(n.Reason) {
+				unseen = append(unseen, n)
+			}
+		}
+		if caughtUp || resp.Cursor == "" {
+			break
+		}
+		cursor = resp.Cursor
+	}
+
+	for i, j := 0, len(unseen)-1; i < j; i, j = i+1, j-1 {
+		unseen[i], unseen[j] = unseen[j], unseen[i]
+	}
+	return unseen, nil
+}
+
+// wanted reports whether a notification reason is handled
+func (w *NotificationWatcher) wanted(reason string) bool {
+	if len(w.Reasons) == 0 {
+		return true
+	}
+	for _, r := range w.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// markSeen records a handled notification and saves the state
+func (w *NotificationWatcher) markSeen(n Notification) error {
+	w.remember(n)
+	return w.save()
+}
+
+// remember records a notification as seen, forgetting the oldest URIs beyond notificationSeenLimit
+func (w *NotificationWatcher) remember(n Notification) {
+	if indexed, err := time.Parse(time.RFC3339Nano, n.IndexedAt); err == nil && indexed.After(w.state.Newest) {
+		w.state.Newest = indexed
+	}
+	if !w.seen[n.URI] {
+		w.seen[n.URI] = true
+		w.state.Seen = append(w.state.Seen, n.URI)
+		if len(w.state.Seen) > notificationSeenLimit {
+			delete(w.seen, w.state.Seen[0])
+			w.state.Seen = w.state.Seen[1:]
+		}
+	}
+}
+
+// save writes the state, then renames it into place so an interrupted save does not corrupt it
+func (w *NotificationWatcher) save() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	b, err := json.Marshal(w.state)
+	if err != nil {
+		return err
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("failed to write notification state: %w", err)
+	}
+	return os.Rename(tmp, w.path)
+}
+
+// webhookClient delivers webhooks, with a timeout so a hung endpoint cannot stall the watcher
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// postWebhook posts a JSON body to a webhook URL, retrying failed deliveries with the backoff of the retry policy
+func postWebhook(ctx context.Context, webhook string, body []byte) error {
+	policy := RetryPolicyFromEnv()
+	for attempt := 0; ; attempt++ {
+		err := sendWebhook(ctx, webhook, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.Attempts {
+			metrics.Add(metricWebhookFailure, 1)
+			return fmt.Errorf("failed to deliver webhook after %d attempts: %w", attempt+1, err)
+		}
+		delay := policy.Delay(attempt)
+		log.Printf("webhook delivery failed, retrying in %s: %v\n", delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// sendWebhook makes a single webhook delivery, which fails unless the response status is 2xx
+func sendWebhook(ctx context.Context, webhook string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// WatchNotifications polls the notifications of the account every BG_INTERVAL and outputs each new one with a
+// BG_REASONS reason as a JSON line, also posting it to BG_WEBHOOK when set. notifications are handled once, across
+// restarts, and the first run starts after the newest notification.
+func (Bs) WatchNotifications(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	w, err := NewNotificationWatcher(c, "bs:watchNotifications", p)
+	if err != nil {
+		return err
+	}
+	serveMetrics(ctx)
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	handled := 0
+	err = w.Run(ctx, func(n Notification) error {
+		if p.Webhook != "" {
+			body, err := json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			if err := postWebhook(ctx, p.Webhook, body); err != nil {
+				return err
+			}
+		}
+		if err := out.Emit(n); err != nil {
+			return err
+		}
+		handled++
+		return out.Flush()
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%d notifications handled\n", handled)
+	return nil
+}
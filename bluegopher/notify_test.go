@@ -0,0 +1,45 @@
+package bluegopher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNotificationWatcherSkipsUnwantedReasons(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	w, err := NewNotificationWatcher(c, "test", Params{Reasons: []string{"mention"}, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.state.Newest = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// a like newer than the only mention is not handled, but the watcher still moves past it
+	pds.queue("app.bsky.notification.listNotifications", fakeResponse{Status: 200, Body: `{"notifications":[
+		{"uri":"at://did:plc:bob/app.bsky.feed.like/2","reason":"like","indexedAt":"2024-01-01T00:02:00Z"},
+		{"uri":"at://did:plc:bob/app.bsky.feed.post/1","reason":"mention","indexedAt":"2024-01-01T00:01:00Z"}
+	]}`})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []string
+	err = w.Run(ctx, func(n Notification) error {
+		handled = append(handled, n.Reason)
+		cancel()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 1 || handled[0] != "mention" {
+		t.Errorf("handled %v, want the mention", handled)
+	}
+
+	saved, err := NewNotificationWatcher(c, "test", Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 1, 1, 0, 2, 0, 0, time.UTC); !saved.state.Newest.Equal(want) {
+		t.Errorf("saved newest = %s, want the like at %s", saved.state.Newest, want)
+	}
+}
@@ -0,0 +1,173 @@
+package bluegopher
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds a single request, including reading the response body
+const defaultTimeout = 60 * time.Second
+
+// ClientOptions configures a Client. the zero value of each field uses the default.
+type ClientOptions struct {
+	// Profile names the account, keying its cached session
+	Profile string
+	// Identifier is the handle, DID, or email used to authenticate
+	Identifier string
+	// Password is the app password used to authenticate
+	Password string
+	// BaseURL is the PDS host, defaults to https://bsky.social
+	BaseURL string
+	// Timeout bounds each request including the response body. negative disables the timeout.
+	Timeout time.Duration
+	// Proxy is an HTTP(S) proxy URL. when empty, HTTP_PROXY, HTTPS_PROXY, and NO_PROXY are honored.
+	Proxy string
+	// Transport replaces the default keep-alive transport, e.g. for tests or instrumentation
+	Transport http.RoundTripper
+	// Retry controls how transient failures are retried
+	Retry RetryPolicy
+	// Labelers are the labeler DIDs whose labels are hydrated onto responses
+	Labelers []string
+	// CacheTTL enables the on-disk cache of GET responses, fresh for the TTL. zero disables the cache.
+	CacheTTL time.Duration
+	// WriteBudget is the hourly write limit in points shared by the processes writing to the account, defaults to the
+	// PDS limit of defaultWriteBudget. negative disables the budget.
+	WriteBudget int
+	// SessionBroker is the URL of a bs:sessionBroker that owns the session, with SessionBrokerToken the bearer token of
+	// its API. when set, the session is neither created, cached, nor refreshed by the client.
+	SessionBroker      string
+	SessionBrokerToken string
+	// VCR records responses to fixtures in VCRDir, or replays them offline, when set to record or replay. the session
+	// cache is not used, so recordings start with createSession.
+	VCR    string
+	VCRDir string
+}
+
+// ClientOptionsFromEnv returns options populated from BG_PROFILE and the config file, or BLUESKY_HANDLE and
+// BLUESKY_PASSWORD, plus PDSHOST, BLUESKY_TIMEOUT, BLUESKY_PROXY, BLUESKY_LABELERS, BLUESKY_CACHE_TTL,
+// BLUESKY_WRITE_BUDGET, BLUESKY_SESSION_BROKER, BLUESKY_VCR, and BLUESKY_RETRY_*
+func ClientOptionsFromEnv() (ClientOptions, error) {
+	opts := ClientOptions{
+		Profile:            os.Getenv("BG_PROFILE"),
+		Identifier:         os.Getenv("BLUESKY_HANDLE"),
+		Password:           os.Getenv("BLUESKY_PASSWORD"),
+		BaseURL:            os.Getenv("PDSHOST"),
+		Proxy:              os.Getenv("BLUESKY_PROXY"),
+		Retry:              RetryPolicyFromEnv(),
+		SessionBroker:      os.Getenv("BLUESKY_SESSION_BROKER"),
+		SessionBrokerToken: os.Getenv("BLUESKY_SESSION_BROKER_TOKEN"),
+		VCR:                os.Getenv("BLUESKY_VCR"),
+		VCRDir:             os.Getenv("BLUESKY_VCR_DIR"),
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return opts, err
+	}
+	if opts.Profile == "" && opts.Identifier == "" {
+		opts.Profile = cfg.DefaultProfile
+	}
+
+	if opts.Profile != "" {
+		profile, err := cfg.Profile(opts.Profile)
+		if err != nil {
+			return opts, err
+		}
+		opts.Identifier = profile.Handle
+		opts.Password = profile.Password
+		if opts.BaseURL == "" {
+			opts.BaseURL = profile.PDSHost
+		}
+	} else {
+		// sessions of env credentials are cached under the identifier
+		opts.Profile = opts.Identifier
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("BLUESKY_TIMEOUT")); err == nil {
+		opts.Timeout = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("BLUESKY_CACHE_TTL")); err == nil {
+		opts.CacheTTL = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("BLUESKY_WRITE_BUDGET")); err == nil {
+		opts.WriteBudget = v
+	}
+
+	// comma-separated labeler DIDs, e.g. did:plc:ar7c4by46qjdydhdevvrndac
+	if labelers := os.Getenv("BLUESKY_LABELERS"); labelers != "" {
+		for _, labeler := range strings.Split(labelers, ",") {
+			if labeler = strings.TrimSpace(labeler); labeler != "" {
+				opts.Labelers = append(opts.Labelers, labeler)
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// ClientOptionsForProfile returns the options of ClientOptionsFromEnv for a named profile of the config file, for
+// targets that act on two accounts. PDSHOST still overrides the PDS host of the profile.
+func ClientOptionsForProfile(name string) (ClientOptions, error) {
+	opts, err := ClientOptionsFromEnv()
+	if err != nil {
+		return opts, err
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return opts, err
+	}
+	profile, err := cfg.Profile(name)
+	if err != nil {
+		return opts, err
+	}
+	opts.Profile = name
+	opts.Identifier = profile.Handle
+	opts.Password = profile.Password
+	opts.BaseURL = os.Getenv("PDSHOST")
+	if opts.BaseURL == "" {
+		opts.BaseURL = profile.PDSHost
+	}
+	return opts, nil
+}
+
+// newHTTPClient builds the single HTTP client shared by every request a Client makes
+func newHTTPClient(opts ClientOptions) (*http.Client, error) {
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	} else if timeout < 0 {
+		timeout = 0
+	}
+
+	transport := opts.Transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.MaxIdleConnsPerHost = 16
+		if opts.Proxy != "" {
+			proxyURL, err := url.Parse(opts.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy URL: %w", err)
+			}
+			t.Proxy = http.ProxyURL(proxyURL)
+		}
+		transport = t
+	}
+	if opts.VCR != "" {
+		vcr, err := newVCRTransport(opts.VCR, opts.VCRDir, transport)
+		if err != nil {
+			return nil, err
+		}
+		transport = vcr
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
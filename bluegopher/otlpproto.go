@@ -0,0 +1,198 @@
+package bluegopher
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strconv"
+)
+
+// protoTraces encodes spans as an OTLP ExportTraceServiceRequest, the http/protobuf body of /v1/traces
+func protoTraces(resource otlpResource, spans []otlpSpan) []byte {
+	var scopeSpans protoMessage
+	scopeSpans.message(1, protoScope())
+	for _, span := range spans {
+		scopeSpans.message(2, protoSpan(span))
+	}
+
+	var resourceSpans protoMessage
+	resourceSpans.message(1, protoResource(resource))
+	resourceSpans.message(2, scopeSpans)
+
+	var request protoMessage
+	request.message(1, resourceSpans)
+	return request
+}
+
+// protoMetrics encodes metrics as an OTLP ExportMetricsServiceRequest, the http/protobuf body of /v1/metrics
+func protoMetrics(resource otlpResource, metrics []otlpMetric) []byte {
+	var scopeMetrics protoMessage
+	scopeMetrics.message(1, protoScope())
+	for _, metric := range metrics {
+		var m protoMessage
+		m.string(1, metric.Name)
+		m.string(2, metric.Description)
+		if metric.Sum != nil {
+			var sum protoMessage
+			for _, point := range metric.Sum.DataPoints {
+				sum.message(1, protoDataPoint(point))
+			}
+			sum.varint(2, uint64(metric.Sum.AggregationTemporality))
+			if metric.Sum.IsMonotonic {
+				sum.varint(3, 1)
+			}
+			m.message(7, sum)
+		}
+		if metric.Gauge != nil {
+			var gauge protoMessage
+			for _, point := range metric.Gauge.DataPoints {
+				gauge.message(1, protoDataPoint(point))
+			}
+			m.message(5, gauge)
+		}
+		scopeMetrics.message(2, m)
+	}
+
+	var resourceMetrics protoMessage
+	resourceMetrics.message(1, protoResource(resource))
+	resourceMetrics.message(2, scopeMetrics)
+
+	var request protoMessage
+	request.message(1, resourceMetrics)
+	return request
+}
+
+// protoScope encodes the InstrumentationScope of otlpScope
+func protoScope() protoMessage {
+	var scope protoMessage
+	scope.string(1, otlpScope["name"])
+	return scope
+}
+
+// protoResource encodes a Resource
+func protoResource(resource otlpResource) protoMessage {
+	var m protoMessage
+	protoAttributes(&m, 1, resource.Attributes)
+	return m
+}
+
+// protoSpan encodes a Span, whose IDs are hex and times decimal strings in the JSON encoding
+func protoSpan(span otlpSpan) protoMessage {
+	var m protoMessage
+	m.hexBytes(1, span.TraceID)
+	m.hexBytes(2, span.SpanID)
+	m.hexBytes(4, span.ParentSpanID)
+	m.string(5, span.Name)
+	m.varint(6, uint64(span.Kind))
+	m.fixed64(7, protoNanos(span.StartTimeUnixNano))
+	m.fixed64(8, protoNanos(span.EndTimeUnixNano))
+	protoAttributes(&m, 9, span.Attributes)
+	for _, event := range span.Events {
+		var e protoMessage
+		e.fixed64(1, protoNanos(event.TimeUnixNano))
+		e.string(2, event.Name)
+		protoAttributes(&e, 3, event.Attributes)
+		m.message(11, e)
+	}
+	if span.Status != nil {
+		var status protoMessage
+		status.string(2, span.Status.Message)
+		status.varint(3, uint64(span.Status.Code))
+		m.message(15, status)
+	}
+	return m
+}
+
+// protoDataPoint encodes a NumberDataPoint
+func protoDataPoint(point otlpDataPoint) protoMessage {
+	var m protoMessage
+	m.fixed64(2, protoNanos(point.StartTimeUnixNano))
+	m.fixed64(3, protoNanos(point.TimeUnixNano))
+	m.fixed64(4, math.Float64bits(point.AsDouble))
+	protoAttributes(&m, 7, point.Attributes)
+	return m
+}
+
+// protoAttributes appends KeyValue attributes as a repeated field
+func protoAttributes(m *protoMessage, field int, attrs []otlpKeyValue) {
+	for _, kv := range attrs {
+		var value protoMessage
+		switch v := kv.Value; {
+		case v["stringValue"] != nil:
+			s, _ := v["stringValue"].(string)
+			value.bytes(1, []byte(s))
+		case v["boolValue"] != nil:
+			// the oneof is set even when false, so the zero value is written
+			b, _ := v["boolValue"].(bool)
+			value.tag(2, 0)
+			value = binary.AppendUvarint(value, map[bool]uint64{false: 0, true: 1}[b])
+		case v["intValue"] != nil:
+			s, _ := v["intValue"].(string)
+			n, _ := strconv.ParseInt(s, 10, 64)
+			value.tag(3, 0)
+			value = binary.AppendUvarint(value, uint64(n))
+		case v["doubleValue"] != nil:
+			f, _ := v["doubleValue"].(float64)
+			value.fixed64(4, math.Float64bits(f))
+		}
+
+		var pair protoMessage
+		pair.string(1, kv.Key)
+		pair.message(2, value)
+		m.message(field, pair)
+	}
+}
+
+// protoNanos parses the decimal nanosecond timestamps of the JSON encoding
+func protoNanos(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
+
+// protoMessage is an encoded protobuf message. its methods append fields, skipping the zero values of scalars as
+// proto3 does.
+type protoMessage []byte
+
+// tag appends the key of a field with a wire type: 0 varint, 1 fixed64, 2 length-delimited
+func (m *protoMessage) tag(field int, wireType int) {
+	*m = binary.AppendUvarint(*m, uint64(field)<<3|uint64(wireType))
+}
+
+func (m *protoMessage) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	m.tag(field, 0)
+	*m = binary.AppendUvarint(*m, v)
+}
+
+func (m *protoMessage) fixed64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	m.tag(field, 1)
+	*m = binary.LittleEndian.AppendUint64(*m, v)
+}
+
+// bytes appends a length-delimited field, even when empty, since it may be a set oneof
+func (m *protoMessage) bytes(field int, b []byte) {
+	m.tag(field, 2)
+	*m = binary.AppendUvarint(*m, uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+func (m *protoMessage) string(field int, s string) {
+	if s != "" {
+		m.bytes(field, []byte(s))
+	}
+}
+
+func (m *protoMessage) hexBytes(field int, s string) {
+	if b, err := hex.DecodeString(s); err == nil && len(b) > 0 {
+		m.bytes(field, b)
+	}
+}
+
+func (m *protoMessage) message(field int, sub protoMessage) {
+	m.bytes(field, sub)
+}
@@ -0,0 +1,351 @@
+package bluegopher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Output writes the items emitted by the targets in the format selected with BG_FORMAT: jsonl (default), csv, tsv, or
+// table, which aligns the columns for reading in a terminal and is written when the target finishes.
+// BG_FIELDS projects each jsonl item to the given dotted paths such as did, author.handle, or record.text.
+// csv, tsv, and table write one row per item with the columns of BG_COLUMNS (or BG_FIELDS). without either, the
+// top-level scalar fields of the first item are used. BG_TEMPLATE formats each item with a Go text/template
+// instead, e.g. '{{.handle}} {{.followersCount}}', with json and oneline helper functions.
+type Output struct {
+	format   string
+	fields   []string
+	columns  []string
+	template *template.Template
+	w        *bufio.Writer
+	csv      *csv.Writer
+	table    *tabwriter.Writer
+	header   bool
+	// closer is the file of BG_OUTPUT, closed by Close
+	closer io.Closer
+
+	mu sync.Mutex
+}
+
+// NewOutput creates an Output writing to w, configured from BG_FORMAT and BG_COLUMNS
+func NewOutput(w io.Writer) (*Output, error) {
+	return newOutput(w, envString("BG_FORMAT", "jsonl"))
+}
+
+// newOutput creates an Output writing to w in a format, configured from BG_FIELDS, BG_COLUMNS, and BG_TEMPLATE
+func newOutput(w io.Writer, format string) (*Output, error) {
+	o := &Output{
+		format:  strings.ToLower(format),
+		fields:  envList("BG_FIELDS"),
+		columns: envList("BG_COLUMNS"),
+		w:       bufio.NewWriter(w),
+	}
+	if len(o.columns) == 0 {
+		o.columns = o.fields
+	}
+
+	if text := os.Getenv("BG_TEMPLATE"); text != "" {
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		tmpl, err := template.New("BG_TEMPLATE").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BG_TEMPLATE: %w", err)
+		}
+		o.format = "template"
+		o.template = tmpl
+	}
+
+	switch o.format {
+	case "template":
+	case "jsonl", "json":
+		o.format = "jsonl"
+	case "csv":
+		o.csv = csv.NewWriter(o.w)
+	case "tsv":
+		o.csv = csv.NewWriter(o.w)
+		o.csv.Comma = '\t'
+	case "table":
+		o.table = tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	default:
+		return nil, fmt.Errorf("invalid BG_FORMAT %q: must be jsonl, csv, tsv, or table", o.format)
+	}
+
+	return o, nil
+}
+
+// emitStdout writes the single item of a target that prints one result, with the format of newStdout
+func emitStdout(item interface{}) error {
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	if err := out.Emit(item); err != nil {
+		out.Abort()
+		return err
+	}
+	return out.Close()
+}
+
+// newStdout creates an Output writing to standard output, or to the file or s3:// or az:// URL of BG_OUTPUT
+func newStdout() (*Output, error) {
+	path := os.Getenv("BG_OUTPUT")
+	if path == "" {
+		return NewOutput(os.Stdout)
+	}
+
+	f, err := createFile(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	o, err := NewOutput(f)
+	if err != nil {
+		abortFile(f)
+		return nil, err
+	}
+	o.closer = f
+	return o, nil
+}
+
+// Emit writes a single item. items are flushed per line so output can be piped while a target runs.
+func (o *Output) Emit(item interface{}) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.format == "jsonl" {
+		var b []byte
+		var err error
+		if len(o.fields) > 0 {
+			b, err = project(item, o.fields)
+		} else {
+			b, err = json.Marshal(item)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to marshal item: %w", err)
+		}
+		o.w.Write(b)
+		o.w.WriteByte('\n')
+		return o.w.Flush()
+	}
+
+	m, err := toMap(item)
+	if err != nil {
+		return err
+	}
+
+	if o.template != nil {
+		if err := o.template.Execute(o.w, m); err != nil {
+			return fmt.Errorf("failed to execute BG_TEMPLATE: %w", err)
+		}
+		return o.w.Flush()
+	}
+
+	if !o.header {
+		if len(o.columns) == 0 {
+			o.columns = scalarKeys(m)
+		}
+		if err := o.writeRow(o.columns); err != nil {
+			return fmt.Errorf("failed to write header: %w", err)
+		}
+		o.header = true
+	}
+
+	row := make([]string, len(o.columns))
+	for i, column := range o.columns {
+		row[i] = formatValue(lookupPath(m, column))
+	}
+	if err := o.writeRow(row); err != nil {
+		return fmt.Errorf("failed to write row: %w", err)
+	}
+	return nil
+}
+
+// writeRow writes a csv, tsv, or table row. table rows are only aligned and written on Flush.
+func (o *Output) writeRow(row []string) error {
+	if o.table != nil {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = strings.Join(strings.Fields(cell), " ")
+		}
+		_, err := fmt.Fprintln(o.table, strings.Join(cells, "\t"))
+		return err
+	}
+
+	if err := o.csv.Write(row); err != nil {
+		return err
+	}
+	o.csv.Flush()
+	if err := o.csv.Error(); err != nil {
+		return err
+	}
+	return o.w.Flush()
+}
+
+// Flush writes any buffered output
+func (o *Output) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.csv != nil {
+		o.csv.Flush()
+		if err := o.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if o.table != nil {
+		if err := o.table.Flush(); err != nil {
+			return err
+		}
+	}
+	return o.w.Flush()
+}
+
+// Close flushes the output and closes the file of BG_OUTPUT, which completes its upload. targets defer closeOutput,
+// which returns the error of Close as the error of the target.
+func (o *Output) Close() error {
+	err := o.Flush()
+	o.mu.Lock()
+	if o.closer != nil {
+		if closeErr := o.closer.Close(); err == nil {
+			err = closeErr
+		}
+		o.closer = nil
+	}
+	o.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// Abort closes the output of a target that failed, without publishing a partial s3:// or az:// upload
+func (o *Output) Abort() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closer != nil {
+		abortFile(o.closer)
+		o.closer = nil
+	}
+}
+
+// closeOutput is deferred by targets with a named error result: it aborts the output when the target fails, and
+// otherwise closes it and returns the Close error, so a failed flush or upload fails the target
+func closeOutput(o *Output, err *error) {
+	if *err != nil {
+		o.Abort()
+		return
+	}
+	*err = o.Close()
+}
+
+// templateFuncs are the helper functions available in BG_TEMPLATE
+var templateFuncs = template.FuncMap{
+	// json encodes a value, e.g. {{json .labels}}
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// oneline collapses newlines so multi-line post text stays on one line, e.g. {{oneline .record.text}}
+	"oneline": func(v interface{}) string {
+		return strings.Join(strings.Fields(formatValue(v)), " ")
+	},
+}
+
+// project encodes the given dotted paths of an item as a JSON object, keeping the order of the fields
+func project(item interface{}, fields []string) ([]byte, error) {
+	m, err := toMap(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(lookupPath(m, field))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// toMap converts an item to a generic map, re-encoding typed structs
+func toMap(item interface{}) (map[string]interface{}, error) {
+	if m, ok := item.(map[string]interface{}); ok {
+		return m, nil
+	}
+
+	b, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal item: %w", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("item is not an object: %w", err)
+	}
+	return m, nil
+}
+
+// lookupPath returns the value at a dotted path such as author.handle, or nil when missing
+func lookupPath(m map[string]interface{}, path string) interface{} {
+	var value interface{} = m
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = obj[key]
+	}
+	return value
+}
+
+// formatValue renders a JSON value as a CSV cell. objects and arrays are written as JSON.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// scalarKeys returns the sorted keys of the non-object, non-array fields
+func scalarKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k, v := range m {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
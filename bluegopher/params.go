@@ -0,0 +1,293 @@
+package bluegopher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Params holds the optional parameters of the Bs and Pg targets. mage targets only take positional arguments, so the
+// parameters are read from BG_* environment variables, e.g. BG_LIMIT=50 BG_FILTER=posts_no_replies mage bs:getAuthorFeeds x
+type Params struct {
+	// Limit is the page size. 0 uses the default of the target.
+	Limit int
+	// Cursor is the cursor of the first page
+	Cursor string
+	// Filter is the author feed filter: posts_with_replies, posts_no_replies, posts_with_media, posts_and_author_threads
+	Filter string
+	// IncludePins includes pinned posts in author feeds
+	IncludePins bool
+	// Sort is the search order: latest or top
+	Sort     string
+	Since    string
+	Until    string
+	Mentions string
+	Author   string
+	Lang     string
+	Domain   string
+	URL      string
+	Tags     []string
+	// Query filters targets that accept an optional search query, e.g. popular feed generators
+	Query string
+	// ListPurpose is the purpose of new lists: app.bsky.graph.defs#curatelist or #modlist
+	ListPurpose string
+	// Concurrency is the number of inputs bulk targets process in parallel
+	Concurrency int
+	// DryRun reports the records bulk write targets would create without creating them
+	DryRun bool
+	// WriteDelay is the pause between the records created by bulk write targets
+	WriteDelay time.Duration
+	// Keep excludes posts from bs:prunePosts: pinned, liked (liked by the account itself)
+	Keep []string
+	// Full makes the pg sync targets fetch every record instead of only those newer than the last sync
+	Full bool
+	// BatchSize is the number of rows the pg import targets commit per transaction
+	BatchSize int
+	// Args are the bind parameters of pg:query, read from BG_ARGS as a JSON array
+	Args []interface{}
+	// Location is the time zone of the hour and weekday breakdowns of the analysis targets
+	Location *time.Location
+	// Collections and DIDs filter the events of the streaming targets by record collection, such as
+	// app.bsky.feed.post or app.bsky.feed.*, and by repository
+	Collections []string
+	DIDs        []string
+	// Reasons are the notification reasons the notification watchers handle, such as mention, reply, and follow
+	Reasons []string
+	// Interval is the pause between the polls of the watching targets, such as the notification watchers
+	Interval time.Duration
+	// Webhook is the URL bs:watchNotifications posts new notifications to
+	Webhook string
+	// Addr is the listen address bs:rss serves its feed on
+	Addr string
+	// Direction is the edges bs:crawlGraph follows from each account: follows, followers, or both
+	Direction string
+	// MaxPerNode caps the accounts bs:crawlGraph fetches per account and direction
+	MaxPerNode int
+	// MaxNodes caps the accounts bs:crawlGraph expands, 0 for no limit
+	MaxNodes int
+	// Subject is the DID whose followers or follows a JSONL export of accounts holds, for bs:graphExport
+	Subject string
+	// MinDegree drops the accounts with fewer follows in and out from graph exports
+	MinDegree int
+	// Clustering is the community detection of bs:communities: labels (label propagation) or components
+	Clustering string
+	// Mutual makes bs:communities link only accounts that follow each other
+	Mutual bool
+	// MinSize is the smallest cluster bs:communities reports
+	MinSize int
+	// Enrich are the stages of bs:enrich, in order
+	Enrich []string
+	// ImagesDir is the directory bs:unroll downloads the images of a thread into
+	ImagesDir string
+	// DigestTemplate is the path of a custom Go template for bs:digest
+	DigestTemplate string
+	// SyncMode is add or full: whether bs:syncModeration also removes the blocks and mutes the source lacks
+	SyncMode string
+}
+
+// LoadParams reads the target parameters from the BG_* environment variables
+func LoadParams() (Params, error) {
+	p := Params{
+		Cursor:         os.Getenv("BG_CURSOR"),
+		Filter:         envString("BG_FILTER", "posts_with_replies"),
+		IncludePins:    true,
+		Sort:           envString("BG_SORT", "latest"),
+		Since:          os.Getenv("BG_SINCE"),
+		Until:          os.Getenv("BG_UNTIL"),
+		Mentions:       os.Getenv("BG_MENTIONS"),
+		Author:         os.Getenv("BG_AUTHOR"),
+		Lang:           os.Getenv("BG_LANG"),
+		Domain:         os.Getenv("BG_DOMAIN"),
+		URL:            os.Getenv("BG_URL"),
+		Tags:           envList("BG_TAGS"),
+		Query:          os.Getenv("BG_QUERY"),
+		ListPurpose:    envString("BG_LIST_PURPOSE", "app.bsky.graph.defs#curatelist"),
+		Keep:           envList("BG_KEEP"),
+		Collections:    envList("BG_COLLECTIONS"),
+		Reasons:        envList("BG_REASONS"),
+		Webhook:        os.Getenv("BG_WEBHOOK"),
+		Addr:           os.Getenv("BG_ADDR"),
+		DIDs:           envList("BG_DIDS"),
+		Direction:      envString("BG_DIRECTION", "follows"),
+		Subject:        os.Getenv("BG_SUBJECT"),
+		Clustering:     envString("BG_CLUSTERING", "labels"),
+		Enrich:         envList("BG_ENRICH"),
+		ImagesDir:      os.Getenv("BG_IMAGES_DIR"),
+		DigestTemplate: os.Getenv("BG_DIGEST_TEMPLATE"),
+		SyncMode:       envString("BG_SYNC_MODE", "add"),
+	}
+
+	var err error
+	if p.Limit, err = envInt("BG_LIMIT", 0); err != nil {
+		return p, err
+	}
+	if p.Limit < 0 {
+		return p, fmt.Errorf("invalid BG_LIMIT %d: must be positive", p.Limit)
+	}
+	if p.IncludePins, err = envBool("BG_INCLUDE_PINS", true); err != nil {
+		return p, err
+	}
+	if p.Concurrency, err = envInt("BG_CONCURRENCY", 4); err != nil {
+		return p, err
+	}
+	if p.Concurrency < 1 {
+		return p, fmt.Errorf("invalid BG_CONCURRENCY %d: must be at least 1", p.Concurrency)
+	}
+	if p.DryRun, err = envBool("BG_DRY_RUN", false); err != nil {
+		return p, err
+	}
+	if p.WriteDelay, err = envDuration("BG_WRITE_DELAY", time.Second); err != nil {
+		return p, err
+	}
+	for _, keep := range p.Keep {
+		if keep != "pinned" && keep != "liked" {
+			return p, fmt.Errorf("invalid BG_KEEP %q: must be pinned or liked", keep)
+		}
+	}
+
+	if p.Full, err = envBool("BG_FULL", false); err != nil {
+		return p, err
+	}
+	if p.BatchSize, err = envInt("BG_BATCH_SIZE", 10000); err != nil {
+		return p, err
+	}
+	if p.BatchSize < 1 {
+		return p, fmt.Errorf("invalid BG_BATCH_SIZE %d: must be at least 1", p.BatchSize)
+	}
+	if p.Args, err = envArgs("BG_ARGS"); err != nil {
+		return p, err
+	}
+	if len(p.Reasons) == 0 {
+		p.Reasons = []string{"mention", "reply", "follow"}
+	}
+	if p.Interval, err = envDuration("BG_INTERVAL", 30*time.Second); err != nil {
+		return p, err
+	}
+	if p.Interval < time.Second {
+		return p, fmt.Errorf("invalid BG_INTERVAL %s: must be at least 1s", p.Interval)
+	}
+	if p.Location, err = time.LoadLocation(envString("BG_TZ", "UTC")); err != nil {
+		return p, fmt.Errorf("invalid BG_TZ %q: %w", os.Getenv("BG_TZ"), err)
+	}
+	if p.Direction != "follows" && p.Direction != "followers" && p.Direction != "both" {
+		return p, fmt.Errorf("invalid BG_DIRECTION %q: must be follows, followers, or both", p.Direction)
+	}
+	if p.MaxPerNode, err = envInt("BG_MAX_PER_NODE", 1000); err != nil {
+		return p, err
+	}
+	if p.MaxPerNode < 1 {
+		return p, fmt.Errorf("invalid BG_MAX_PER_NODE %d: must be at least 1", p.MaxPerNode)
+	}
+	if p.MaxNodes, err = envInt("BG_MAX_NODES", 0); err != nil {
+		return p, err
+	}
+	if p.MinDegree, err = envInt("BG_MIN_DEGREE", 0); err != nil {
+		return p, err
+	}
+	if p.Mutual, err = envBool("BG_MUTUAL", false); err != nil {
+		return p, err
+	}
+	if p.MinSize, err = envInt("BG_MIN_SIZE", 3); err != nil {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// LimitOr returns the configured page size, or def when BG_LIMIT is unset
+func (p Params) LimitOr(def int) int {
+	if p.Limit > 0 {
+		return p.Limit
+	}
+	return def
+}
+
+// envString returns the value of an environment variable, or def when unset
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt parses an integer environment variable, returning def when unset
+func envInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q: must be an integer", key, v)
+	}
+	return n, nil
+}
+
+// envBool parses a boolean environment variable, returning def when unset
+func envBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q: must be true or false", key, v)
+	}
+	return b, nil
+}
+
+// envDuration parses a duration environment variable, e.g. 500ms or 2s, returning def when unset
+func envDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q: must be a duration such as 500ms or 2s", key, v)
+	}
+	return d, nil
+}
+
+// envArgs parses a JSON array of query bind parameters. numbers are passed as text to keep their precision, and
+// objects and arrays as JSON for json and jsonb parameters.
+func envArgs(key string) ([]interface{}, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+
+	var args []interface{}
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.UseNumber()
+	if err := dec.Decode(&args); err != nil {
+		return nil, fmt.Errorf("invalid %s %q: must be a JSON array such as [\"alice.bsky.social\", 10]", key, v)
+	}
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case json.Number:
+			args[i] = arg.String()
+		case map[string]interface{}, []interface{}:
+			b, err := json.Marshal(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = string(b)
+		}
+	}
+	return args, nil
+}
+
+// envList splits a comma-separated environment variable, dropping empty items
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
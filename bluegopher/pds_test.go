@@ -0,0 +1,557 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// testHandle and testPassword are the credentials the fake PDS accepts
+	testHandle   = "alice.test"
+	testPassword = "app-password"
+	testDID      = "did:plc:alice"
+)
+
+// fakeRequest is a request received by the fake PDS
+type fakeRequest struct {
+	Method string
+	NSID   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// fakeRecord is a record of the repository of the fake PDS
+type fakeRecord struct {
+	URI   string                 `json:"uri"`
+	CID   string                 `json:"cid"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// fakeResponse is a canned response returned once for a method, before it is handled
+type fakeResponse struct {
+	Status int
+	Header map[string]string
+	Body   string
+}
+
+// fakePDS is an in-memory XRPC server for tests. it implements sessions, profiles, author feeds, timelines, search,
+// followers and follows, lists, mutes, and the records of the account's repository, all with cursor pagination, and
+// serves the canned JSON of testdata/pds/<method>.json for the other methods. every request is recorded.
+type fakePDS struct {
+	*httptest.Server
+	t *testing.T
+
+	mu sync.Mutex
+	// accessJwt and refreshJwt are the current tokens. requests with another access token fail with ExpiredToken.
+	accessJwt  string
+	refreshJwt string
+	tokens     int
+	sessions   int
+	refreshes  int
+	requests   []fakeRequest
+	// queued are responses returned, in order, before a method is handled, such as failures to retry
+	queued map[string][]fakeResponse
+
+	profiles  []Profile
+	posts     map[string][]PostView
+	followers map[string][]ProfileView
+	follows   map[string][]ProfileView
+	lists     map[string][]string
+	mutes     []ProfileView
+	records   map[string][]fakeRecord
+	rkeys     int
+}
+
+// newFakePDS starts a fake PDS with the account alice.test, followed by 250 accounts and following 120, with 5 posts
+// and a repost on its author feed, a list of 150 members, and 3 mutes
+func newFakePDS(t *testing.T) *fakePDS {
+	t.Helper()
+	pds := &fakePDS{
+		t:         t,
+		queued:    make(map[string][]fakeResponse),
+		posts:     make(map[string][]PostView),
+		followers: make(map[string][]ProfileView),
+		follows:   make(map[string][]ProfileView),
+		lists:     make(map[string][]string),
+		records:   make(map[string][]fakeRecord),
+	}
+
+	alice := Profile{DID: testDID, Handle: testHandle, DisplayName: "Alice", FollowersCount: 250, FollowsCount: 120, PostsCount: 5}
+	pds.profiles = append(pds.profiles, alice)
+	for _, name := range []string{"bob", "carol"} {
+		pds.profiles = append(pds.profiles, Profile{DID: "did:plc:" + name, Handle: name + ".test", DisplayName: strings.ToUpper(name[:1]) + name[1:]})
+	}
+
+	for i := 0; i < 250; i++ {
+		pds.followers[testDID] = append(pds.followers[testDID], testAccount("follower", i))
+	}
+	for i := 0; i < 120; i++ {
+		pds.follows[testDID] = append(pds.follows[testDID], testAccount("follow", i))
+	}
+	listURI := "at://" + testDID + "/app.bsky.graph.list/3kgophers"
+	for i := 0; i < 150; i++ {
+		pds.lists[listURI] = append(pds.lists[listURI], testAccount("member", i).DID)
+	}
+	for i := 0; i < 3; i++ {
+		pds.mutes = append(pds.mutes, testAccount("muted", i))
+	}
+
+	author := ProfileView{DID: testDID, Handle: testHandle, DisplayName: "Alice"}
+	created := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		pds.posts[testDID] = append(pds.posts[testDID], PostView{
+			URI:       fmt.Sprintf("at://%s/app.bsky.feed.post/3kpost%d", testDID, i),
+			CID:       fmt.Sprintf("bafypost%d", i),
+			Author:    author,
+			Record:    PostRecord{Type: "app.bsky.feed.post", Text: fmt.Sprintf("gopher post %d", i), CreatedAt: created.Add(-time.Duration(i) * time.Hour).Format(time.RFC3339)},
+			LikeCount: 10 - i,
+			IndexedAt: created.Format(time.RFC3339),
+		})
+	}
+
+	pds.issueTokens()
+	pds.Server = httptest.NewServer(http.HandlerFunc(pds.serve))
+	t.Cleanup(pds.Close)
+	return pds
+}
+
+// testAccount returns a generated account of the fixtures
+func testAccount(kind string, i int) ProfileView {
+	return ProfileView{DID: fmt.Sprintf("did:plc:%s%03d", kind, i), Handle: fmt.Sprintf("%s%03d.test", kind, i)}
+}
+
+// testJWT returns a token with an exp claim, which the client reads to decide when to refresh
+func testJWT(subject string, exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":%q,"exp":%d}`, subject, exp.Unix())))
+	return header + "." + claims + ".sig"
+}
+
+// issueTokens replaces the tokens of the session. the caller holds mu, or the server is not started yet.
+func (pds *fakePDS) issueTokens() {
+	pds.tokens++
+	pds.accessJwt = testJWT(fmt.Sprintf("access-%d", pds.tokens), time.Now().Add(2*time.Hour))
+	pds.refreshJwt = testJWT(fmt.Sprintf("refresh-%d", pds.tokens), time.Now().Add(90*24*time.Hour))
+}
+
+// expireAccessToken rotates the access token, as if the one held by clients expired
+func (pds *fakePDS) expireAccessToken() {
+	pds.mu.Lock()
+	defer pds.mu.Unlock()
+	pds.tokens++
+	pds.accessJwt = testJWT(fmt.Sprintf("access-%d", pds.tokens), time.Now().Add(2*time.Hour))
+}
+
+// queue adds responses returned, in order, the next times a method is called
+func (pds *fakePDS) queue(nsid string, responses ...fakeResponse) {
+	pds.mu.Lock()
+	defer pds.mu.Unlock()
+	pds.queued[nsid] = append(pds.queued[nsid], responses...)
+}
+
+// calls returns the requests received for a method
+func (pds *fakePDS) calls(nsid string) []fakeRequest {
+	pds.mu.Lock()
+	defer pds.mu.Unlock()
+	var calls []fakeRequest
+	for _, r := range pds.requests {
+		if r.NSID == nsid {
+			calls = append(calls, r)
+		}
+	}
+	return calls
+}
+
+// lastRequest returns the latest request received
+func (pds *fakePDS) lastRequest() fakeRequest {
+	pds.mu.Lock()
+	defer pds.mu.Unlock()
+	if len(pds.requests) == 0 {
+		pds.t.Fatal("no requests received")
+	}
+	return pds.requests[len(pds.requests)-1]
+}
+
+// collection returns the records of a collection of the repository
+func (pds *fakePDS) collection(collection string) []fakeRecord {
+	pds.mu.Lock()
+	defer pds.mu.Unlock()
+	return append([]fakeRecord(nil), pds.records[collection]...)
+}
+
+// serve records the request, returns its queued response if any, checks the access token, and handles the method
+func (pds *fakePDS) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	nsid := strings.TrimPrefix(r.URL.Path, "/xrpc/")
+
+	pds.mu.Lock()
+	defer pds.mu.Unlock()
+	pds.requests = append(pds.requests, fakeRequest{Method: r.Method, NSID: nsid, Query: r.URL.Query(), Header: r.Header.Clone(), Body: body})
+
+	if queued := pds.queued[nsid]; len(queued) > 0 {
+		pds.queued[nsid] = queued[1:]
+		for k, v := range queued[0].Header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(queued[0].Status)
+		io.WriteString(w, queued[0].Body)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	switch nsid {
+	case "com.atproto.server.createSession", "com.atproto.server.describeServer", "com.atproto.server.createAccount":
+	case "com.atproto.server.refreshSession":
+		if token != pds.refreshJwt {
+			pds.error(w, http.StatusBadRequest, "ExpiredToken", "Token has expired")
+			return
+		}
+	default:
+		if token != pds.accessJwt {
+			pds.error(w, http.StatusBadRequest, "ExpiredToken", "Token has expired")
+			return
+		}
+	}
+
+	if handle, ok := pds.handlers()[nsid]; ok {
+		handle(w, r.URL.Query(), body)
+		return
+	}
+	pds.canned(w, r.Method, nsid)
+}
+
+// handlers are the methods the fake PDS implements, called with mu held
+func (pds *fakePDS) handlers() map[string]func(w http.ResponseWriter, q url.Values, body []byte) {
+	return map[string]func(w http.ResponseWriter, q url.Values, body []byte){
+		"com.atproto.server.createSession":  pds.createSession,
+		"com.atproto.server.refreshSession": pds.refreshSession,
+		"com.atproto.identity.resolveHandle": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			profile, ok := pds.profile(q.Get("handle"))
+			if !ok {
+				pds.error(w, http.StatusBadRequest, "InvalidRequest", "Unable to resolve handle")
+				return
+			}
+			pds.json(w, map[string]string{"did": profile.DID})
+		},
+		"app.bsky.actor.getProfile": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			profile, ok := pds.profile(q.Get("actor"))
+			if !ok {
+				pds.error(w, http.StatusBadRequest, "InvalidRequest", "Profile not found")
+				return
+			}
+			pds.json(w, profile)
+		},
+		"app.bsky.actor.getProfiles": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			profiles := []Profile{}
+			for _, actor := range q["actors"] {
+				if profile, ok := pds.profile(actor); ok {
+					profiles = append(profiles, profile)
+				}
+			}
+			pds.json(w, map[string]interface{}{"profiles": profiles})
+		},
+		"app.bsky.feed.getAuthorFeed": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			profile, _ := pds.profile(q.Get("actor"))
+			pds.json(w, pds.feedPage(pds.authorFeed(profile.DID), q))
+		},
+		"app.bsky.feed.getTimeline": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			pds.json(w, pds.feedPage(pds.authorFeed(testDID), q))
+		},
+		"app.bsky.feed.getListFeed": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			pds.json(w, pds.feedPage(pds.authorFeed(testDID), q))
+		},
+		"app.bsky.feed.getFeed": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			pds.json(w, pds.feedPage(pds.authorFeed(testDID), q))
+		},
+		"app.bsky.feed.searchPosts": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			var matches []PostView
+			for _, post := range pds.posts[testDID] {
+				if strings.Contains(post.Record.Text, q.Get("q")) {
+					matches = append(matches, post)
+				}
+			}
+			start, end, cursor := fakePage(len(matches), q)
+			pds.json(w, map[string]interface{}{"posts": matches[start:end], "cursor": cursor, "hitsTotal": len(matches)})
+		},
+		"app.bsky.feed.getPosts": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			posts := []PostView{}
+			for _, uri := range q["uris"] {
+				for _, post := range pds.posts[testDID] {
+					if post.URI == uri {
+						posts = append(posts, post)
+					}
+				}
+			}
+			pds.json(w, map[string]interface{}{"posts": posts})
+		},
+		"app.bsky.graph.getFollowers": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			profile, _ := pds.profile(q.Get("actor"))
+			followers := pds.followers[profile.DID]
+			start, end, cursor := fakePage(len(followers), q)
+			pds.json(w, map[string]interface{}{"subject": profile, "followers": followers[start:end], "cursor": cursor})
+		},
+		"app.bsky.graph.getFollows": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			profile, _ := pds.profile(q.Get("actor"))
+			follows := pds.follows[profile.DID]
+			start, end, cursor := fakePage(len(follows), q)
+			pds.json(w, map[string]interface{}{"subject": profile, "follows": follows[start:end], "cursor": cursor})
+		},
+		"app.bsky.graph.getList": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			members, ok := pds.lists[q.Get("list")]
+			if !ok {
+				pds.error(w, http.StatusBadRequest, "InvalidRequest", "List not found")
+				return
+			}
+			start, end, cursor := fakePage(len(members), q)
+			items := []map[string]interface{}{}
+			for _, did := range members[start:end] {
+				items = append(items, map[string]interface{}{"uri": q.Get("list") + "-item-" + did, "subject": map[string]string{"did": did}})
+			}
+			list := map[string]interface{}{"uri": q.Get("list"), "name": "Gophers", "purpose": "app.bsky.graph.defs#curatelist", "listItemCount": len(members)}
+			pds.json(w, map[string]interface{}{"list": list, "items": items, "cursor": cursor})
+		},
+		"app.bsky.graph.getMutes": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			start, end, cursor := fakePage(len(pds.mutes), q)
+			pds.json(w, map[string]interface{}{"mutes": pds.mutes[start:end], "cursor": cursor})
+		},
+		"app.bsky.graph.muteActor": func(w http.ResponseWriter, _ url.Values, body []byte) {
+			var req struct{ Actor string }
+			json.Unmarshal(body, &req)
+			pds.mutes = append(pds.mutes, ProfileView{DID: req.Actor})
+			w.WriteHeader(http.StatusOK)
+		},
+		"app.bsky.graph.unmuteActor": func(w http.ResponseWriter, _ url.Values, body []byte) {
+			var req struct{ Actor string }
+			json.Unmarshal(body, &req)
+			for i, muted := range pds.mutes {
+				if muted.DID == req.Actor {
+					pds.mutes = append(pds.mutes[:i], pds.mutes[i+1:]...)
+					break
+				}
+			}
+			w.WriteHeader(http.StatusOK)
+		},
+		"com.atproto.repo.createRecord": func(w http.ResponseWriter, _ url.Values, body []byte) {
+			var req struct {
+				Repo       string
+				Collection string
+				Rkey       string
+				Record     map[string]interface{}
+			}
+			if err := json.Unmarshal(body, &req); err != nil || req.Repo != testDID || req.Collection == "" || req.Record["$type"] != req.Collection {
+				pds.error(w, http.StatusBadRequest, "InvalidRequest", "Invalid record")
+				return
+			}
+			pds.json(w, pds.createRecord(req.Collection, req.Rkey, req.Record))
+		},
+		"com.atproto.repo.deleteRecord": func(w http.ResponseWriter, _ url.Values, body []byte) {
+			var req struct{ Repo, Collection, Rkey string }
+			json.Unmarshal(body, &req)
+			pds.deleteRecord(req.Collection, req.Rkey)
+			pds.json(w, map[string]interface{}{})
+		},
+		"com.atproto.repo.applyWrites": func(w http.ResponseWriter, _ url.Values, body []byte) {
+			var req struct {
+				Repo   string
+				Writes []WriteOp
+			}
+			if err := json.Unmarshal(body, &req); err != nil || req.Repo != testDID || len(req.Writes) > 200 {
+				pds.error(w, http.StatusBadRequest, "InvalidRequest", "Invalid writes")
+				return
+			}
+			var results []interface{}
+			for _, op := range req.Writes {
+				switch op.Type {
+				case "com.atproto.repo.applyWrites#create":
+					value, _ := op.Value.(map[string]interface{})
+					results = append(results, pds.createRecord(op.Collection, op.Rkey, value))
+				case "com.atproto.repo.applyWrites#delete":
+					pds.deleteRecord(op.Collection, op.Rkey)
+					results = append(results, map[string]interface{}{})
+				}
+			}
+			pds.json(w, map[string]interface{}{"results": results})
+		},
+		"com.atproto.repo.listRecords": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			records := pds.records[q.Get("collection")]
+			start, end, cursor := fakePage(len(records), q)
+			pds.json(w, map[string]interface{}{"records": records[start:end], "cursor": cursor})
+		},
+		"com.atproto.sync.getBlob": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, "blob "+q.Get("cid"))
+		},
+		"com.atproto.sync.getRepo": func(w http.ResponseWriter, q url.Values, _ []byte) {
+			w.Header().Set("Content-Type", "application/vnd.ipld.car")
+			io.WriteString(w, "car "+q.Get("did"))
+		},
+	}
+}
+
+// createSession checks the credentials and issues new tokens
+func (pds *fakePDS) createSession(w http.ResponseWriter, _ url.Values, body []byte) {
+	var req struct{ Identifier, Password string }
+	json.Unmarshal(body, &req)
+	if (req.Identifier != testHandle && req.Identifier != testDID) || req.Password != testPassword {
+		pds.error(w, http.StatusUnauthorized, "AuthenticationRequired", "Invalid identifier or password")
+		return
+	}
+	pds.sessions++
+	pds.issueTokens()
+	pds.json(w, pds.session())
+}
+
+// refreshSession issues new tokens for the refresh token
+func (pds *fakePDS) refreshSession(w http.ResponseWriter, _ url.Values, _ []byte) {
+	pds.refreshes++
+	pds.issueTokens()
+	pds.json(w, pds.session())
+}
+
+// session returns the session of the account with the current tokens
+func (pds *fakePDS) session() CreateSessionResponse {
+	return CreateSessionResponse{DID: testDID, Handle: testHandle, AccessJwt: pds.accessJwt, RefreshJwt: pds.refreshJwt, Active: true}
+}
+
+// profile returns the profile of a handle or DID
+func (pds *fakePDS) profile(actor string) (Profile, bool) {
+	for _, profile := range pds.profiles {
+		if profile.DID == actor || profile.Handle == actor {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+// authorFeed returns the posts of an author with a repost of a post of bob second, as getAuthorFeed does
+func (pds *fakePDS) authorFeed(did string) []FeedViewPost {
+	var feed []FeedViewPost
+	for i, post := range pds.posts[did] {
+		feed = append(feed, FeedViewPost{Post: post})
+		if i == 0 {
+			repost := PostView{URI: "at://did:plc:bob/app.bsky.feed.post/3krepost", CID: "bafyrepost", Author: ProfileView{DID: "did:plc:bob", Handle: "bob.test"}, Record: PostRecord{Type: "app.bsky.feed.post", Text: "reposted"}}
+			feed = append(feed, FeedViewPost{Post: repost, Reason: json.RawMessage(`{"$type":"app.bsky.feed.defs#reasonRepost"}`)})
+		}
+	}
+	return feed
+}
+
+// feedPage returns a page of a feed
+func (pds *fakePDS) feedPage(feed []FeedViewPost, q url.Values) map[string]interface{} {
+	start, end, cursor := fakePage(len(feed), q)
+	return map[string]interface{}{"feed": feed[start:end], "cursor": cursor}
+}
+
+// createRecord adds a record to the repository and returns its uri and cid
+func (pds *fakePDS) createRecord(collection, rkey string, value map[string]interface{}) map[string]string {
+	if rkey == "" {
+		pds.rkeys++
+		rkey = fmt.Sprintf("3krecord%04d", pds.rkeys)
+	}
+	record := fakeRecord{URI: fmt.Sprintf("at://%s/%s/%s", testDID, collection, rkey), CID: "bafy" + rkey, Value: value}
+	pds.records[collection] = append(pds.records[collection], record)
+	return map[string]string{"uri": record.URI, "cid": record.CID}
+}
+
+// deleteRecord removes a record from the repository
+func (pds *fakePDS) deleteRecord(collection, rkey string) {
+	records := pds.records[collection]
+	for i, record := range records {
+		if strings.HasSuffix(record.URI, "/"+rkey) {
+			pds.records[collection] = append(records[:i:i], records[i+1:]...)
+			return
+		}
+	}
+}
+
+// canned serves testdata/pds/<method>.json, or an empty object for procedures without a fixture
+func (pds *fakePDS) canned(w http.ResponseWriter, method, nsid string) {
+	b, err := os.ReadFile(filepath.Join("testdata", "pds", nsid+".json"))
+	if err != nil {
+		if method == http.MethodPost {
+			pds.json(w, map[string]interface{}{})
+			return
+		}
+		pds.error(w, http.StatusNotImplemented, "MethodNotImplemented", "Method Not Implemented")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// fakePage returns the bounds of the page of a list for the limit and cursor parameters, and the cursor of the next
+// page. the cursor is the offset of the page.
+func fakePage(n int, q url.Values) (int, int, string) {
+	limit := 50
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	start, _ := strconv.Atoi(q.Get("cursor"))
+	if start > n {
+		start = n
+	}
+	end := start + limit
+	if end >= n {
+		return start, n, ""
+	}
+	return start, end, strconv.Itoa(end)
+}
+
+// json writes a JSON response
+func (pds *fakePDS) json(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		pds.t.Errorf("failed to write response: %v", err)
+	}
+}
+
+// error writes an XRPC error response
+func (pds *fakePDS) error(w http.ResponseWriter, status int, name, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": name, "message": message})
+}
+
+// newTestClient points the environment at the fake PDS with its credentials, an empty cache and config directory,
+// and fast retries, and returns an authenticated client
+func newTestClient(t *testing.T, pds *fakePDS) *Client {
+	t.Helper()
+	setTestEnv(t, pds)
+	c, err := NewClient(context.Background())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+// setTestEnv sets the environment of newTestClient
+func setTestEnv(t *testing.T, pds *fakePDS) {
+	t.Helper()
+	t.Setenv("PDSHOST", pds.URL)
+	t.Setenv("BLUESKY_HANDLE", testHandle)
+	t.Setenv("BLUESKY_PASSWORD", testPassword)
+	t.Setenv("BG_PROFILE", "")
+	t.Setenv("BG_CACHE_DIR", t.TempDir())
+	t.Setenv("BG_CONFIG_DIR", t.TempDir())
+	t.Setenv("BG_PROGRESS", "0")
+	t.Setenv("BLUESKY_RETRY_DELAY", "1ms")
+	t.Setenv("BLUESKY_RETRY_JITTER", "0")
+	t.Setenv("BLUESKY_CACHE_TTL", "")
+	t.Setenv("BLUESKY_SESSION_BROKER", "")
+	t.Setenv("BLUESKY_WRITE_BUDGET", "")
+	t.Setenv("BLUESKY_VCR", "")
+}
@@ -0,0 +1,1367 @@
+package bluegopher
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/magefile/mage/mg"
+)
+
+type Pg mg.Namespace
+
+// pgEnv are the libpq environment variables honored by lib/pq when the connection string leaves them out
+var pgEnv = []string{"PGHOST", "PGPORT", "PGDATABASE", "PGUSER", "PGPASSWORD", "PGSSLMODE"}
+
+// connectionString returns the PostgreSQL connection string: DATABASE_URL, then databaseURL from the config file,
+// then an empty string when any PG* variable is set, since lib/pq reads those itself
+func connectionString() (string, error) {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn, nil
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.DatabaseURL != "" {
+		return cfg.DatabaseURL, nil
+	}
+
+	for _, key := range pgEnv {
+		if os.Getenv(key) != "" {
+			return "", nil
+		}
+	}
+	return "", fmt.Errorf("no database configured: set DATABASE_URL, the PG* environment variables, or databaseURL in config.json")
+}
+
+// pgIdentifier matches the names of BG_PG_SCHEMA and BG_PG_TABLE. tables are quoted with pq.QuoteIdentifier, but the
+// schema goes unquoted into CREATE SCHEMA and the search_path runtime parameter.
+var pgIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// pgName returns a schema or table name from an environment variable, returning def when unset
+func pgName(key, def string) (string, error) {
+	name := envString(key, def)
+	if !pgIdentifier.MatchString(name) {
+		return "", fmt.Errorf("invalid %s %q: must be a lowercase name of letters, digits, and underscores", key, name)
+	}
+	return name, nil
+}
+
+// withSchema sets the search_path of a connection string, which lib/pq passes to the server as a runtime parameter.
+// public stays on the path so extensions installed there, such as vector and pg_trgm, are still found.
+func withSchema(connStr, schema string) string {
+	searchPath := "search_path=" + schema + ",public"
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		if strings.Contains(connStr, "?") {
+			return connStr + "&" + searchPath
+		}
+		return connStr + "?" + searchPath
+	}
+	return strings.TrimSpace(connStr + " " + searchPath)
+}
+
+// getConnection returns a PostgreSQL database connection. with BG_PG_SCHEMA, every table, including the migrations,
+// is created and queried in that schema, so projects can be isolated in one database.
+func getConnection() (*sql.DB, error) {
+	connStr, err := connectionString()
+	if err != nil {
+		return nil, err
+	}
+
+	schema := ""
+	if os.Getenv("BG_PG_SCHEMA") != "" {
+		if schema, err = pgName("BG_PG_SCHEMA", ""); err != nil {
+			return nil, err
+		}
+		connStr = withSchema(connStr, schema)
+	}
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	if schema != "" {
+		if _, err := db.Exec("CREATE SCHEMA IF NOT EXISTS " + schema); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create schema %s: %w", schema, err)
+		}
+	}
+	return db, nil
+}
+
+// jsonTable returns the table of the JSON line import and query targets, BG_PG_TABLE or bluesky, applying any pending
+// migrations. other tables are created with the columns and indexes the migrations give the bluesky table.
+func jsonTable(db *sql.DB) (string, error) {
+	if err := migrate(db); err != nil {
+		return "", err
+	}
+	table, err := pgName("BG_PG_TABLE", "bluesky")
+	if err != nil {
+		return "", err
+	}
+	if table != "bluesky" {
+		for _, stmt := range jsonTableStatements(table) {
+			if _, err := db.Exec(stmt); err != nil {
+				return "", fmt.Errorf("failed to create table %s: %w", table, err)
+			}
+		}
+	}
+	return table, nil
+}
+
+// jsonTableStatements create a table of JSON lines like the bluesky table, with its own id sequence. they must be kept
+// in step with the migrations of the bluesky table.
+func jsonTableStatements(table string) []string {
+	t := pq.QuoteIdentifier(table)
+	return []string{
+		`CREATE TABLE IF NOT EXISTS ` + t + ` (
+			id SERIAL PRIMARY KEY,
+			name TEXT,
+			data JSONB NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			key TEXT GENERATED ALWAYS AS (` + blueskyKey + `) STORED,
+			text_search TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(` + postTextExpr + `, ''))) STORED
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS ` + pq.QuoteIdentifier(table+"_name_key_key") + ` ON ` + t + ` (name, key)`,
+		`CREATE INDEX IF NOT EXISTS ` + pq.QuoteIdentifier(table+"_text_search_idx") + ` ON ` + t + ` USING GIN (text_search)`,
+	}
+}
+
+// ListTables lists the tables of the current schema, BG_PG_SCHEMA or public
+func (Pg) ListTables() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()")
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Println("Tables in the database:")
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		fmt.Println(tableName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+
+	return nil
+}
+
+// CreateBlueskyTable creates a table for storing JSON objects, applying any pending migrations
+func (Pg) CreateBlueskyTable() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrate(db); err != nil {
+		return err
+	}
+
+	fmt.Println("Table 'bluesky' created successfully")
+	return nil
+}
+
+// DropBlueskyTable drops the bluesky table
+func (Pg) DropBlueskyTable() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	query := "DROP TABLE IF EXISTS bluesky"
+	_, err = db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to drop table: %w", err)
+	}
+	if err := forgetMigrations(db); err != nil {
+		return err
+	}
+
+	fmt.Println("Table 'bluesky' dropped successfully")
+	return nil
+}
+
+// Prune <name> <olderThan> deletes the rows of the bluesky table imported under a name longer ago than a duration,
+// such as 720h for 30 days
+func (Pg) Prune(name string, olderThan time.Duration) error {
+	if olderThan <= 0 {
+		return fmt.Errorf("invalid olderThan %s: must be positive", olderThan)
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec("DELETE FROM "+pq.QuoteIdentifier(table)+" WHERE name = $1 AND created_at < $2", name, time.Now().Add(-olderThan))
+	if err != nil {
+		return fmt.Errorf("failed to prune rows: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to count pruned rows: %w", err)
+	}
+
+	fmt.Printf("%d rows of %s older than %s pruned successfully\n", deleted, name, olderThan)
+	return nil
+}
+
+// Dedupe <key> deletes the rows of the bluesky table that repeat the value of a JSON key within their name, keeping
+// the newest. the key is a dotted path such as uri, post.uri, or did. tables imported into before migration 0002 need
+// this before the migration can add its unique key.
+func (Pg) Dedupe(key string) error {
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	// the table is not migrated, since migration 0002 fails until its duplicates are removed
+	table, err := pgName("BG_PG_TABLE", "bluesky")
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// rows without the key are never duplicates, since the comparison of NULL values is never true
+	result, err := db.Exec(`DELETE FROM `+pq.QuoteIdentifier(table)+` a USING `+pq.QuoteIdentifier(table)+` b
+		WHERE a.name IS NOT DISTINCT FROM b.name
+			AND a.data#>>$1::text[] = b.data#>>$1::text[]
+			AND (a.created_at, a.id) < (b.created_at, b.id)`, pq.Array(strings.Split(key, ".")))
+	if err != nil {
+		return fmt.Errorf("failed to dedupe rows: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to count duplicate rows: %w", err)
+	}
+
+	fmt.Printf("%d duplicate rows by %s deleted successfully\n", deleted, key)
+	return nil
+}
+
+// Stats outputs the size of each table, and the rows, import times, and distinct handles and DIDs of each name in the
+// bluesky table
+func (Pg) Stats() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	var stats DatabaseStats
+	if stats.Tables, err = tableStats(db); err != nil {
+		return err
+	}
+	if stats.Sources, err = sourceStats(db, table); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal stats: %w", err)
+	}
+	fmt.Printf("%s\n", b)
+	return nil
+}
+
+// CreateSchema creates the typed posts, profiles, followers, follows, and listitems tables, applying any pending migrations
+func (Pg) CreateSchema() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrate(db); err != nil {
+		return err
+	}
+
+	fmt.Printf("Tables %s created successfully\n", strings.Join(schemaTables, ", "))
+	return nil
+}
+
+// DropSchema drops the typed tables created by pg:createSchema. the follower_snapshots and labels tables are kept,
+// since their history cannot be synced again.
+func (Pg) DropSchema() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// CASCADE drops the analytics views built on the tables
+	query := "DROP TABLE IF EXISTS " + strings.Join(schemaTables, ", ") + " CASCADE"
+	_, err = db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to drop tables: %w", err)
+	}
+	if err := forgetMigrations(db); err != nil {
+		return err
+	}
+
+	fmt.Printf("Tables %s dropped successfully\n", strings.Join(schemaTables, ", "))
+	return nil
+}
+
+// Migrate applies the pending schema migrations
+func (Pg) Migrate() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrate(db); err != nil {
+		return err
+	}
+
+	fmt.Println("Migrations applied successfully")
+	return nil
+}
+
+// MigrateStatus lists the schema migrations and when they were applied
+func (Pg) MigrateStatus() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		status := "pending"
+		if appliedAt, ok := applied[m.Version]; ok {
+			status = "applied " + appliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Printf("%04d %-20s %s\n", m.Version, m.Name, status)
+	}
+	return nil
+}
+
+// Rollback <steps> reverts the last steps applied schema migrations
+func (Pg) Rollback(steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("invalid steps %d: must be at least 1", steps)
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := rollback(db, steps); err != nil {
+		return err
+	}
+
+	fmt.Println("Migrations rolled back successfully")
+	return nil
+}
+
+// CreateAnalyticsViews creates the top_posters, daily_post_volume, follower_counts, and engagement_leaders materialized views, applying any pending migrations, and populates them
+func (Pg) CreateAnalyticsViews() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := createAnalyticsViews(db); err != nil {
+		return err
+	}
+
+	fmt.Println("Analytics views created successfully")
+	return nil
+}
+
+// RefreshViews refreshes the analytics views with the current data of the typed tables
+func (Pg) RefreshViews() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := refreshAnalyticsViews(db); err != nil {
+		return err
+	}
+
+	fmt.Println("Analytics views refreshed successfully")
+	return nil
+}
+
+// ImportJsonFile imports JSON lines from a file into the bluesky table, in transactions of BG_BATCH_SIZE lines
+func (Pg) ImportJsonFile(filePath, name string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	progress := StartProgress("pg:importJsonFile", nil)
+	defer progress.Stop(&err)
+
+	// re-imports update the rows of items already imported under the same name
+	imported, err := insertJsonLines(db, table, file, name, p.BatchSize, progress)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d JSON lines imported into %s successfully\n", imported, table)
+	return nil
+}
+
+// ImportJsonFileFast imports JSON lines from a file into the bluesky table with COPY, in transactions of BG_BATCH_SIZE lines
+func (Pg) ImportJsonFileFast(filePath, name string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	progress := StartProgress("pg:importJsonFileFast", nil)
+	defer progress.Stop(&err)
+
+	imported, err := copyJsonLines(db, table, file, name, p.BatchSize, progress)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d JSON lines imported into %s successfully\n", imported, table)
+	return nil
+}
+
+// ImportStdin imports JSON lines from standard input into the bluesky table, so the output of bs targets can be piped
+// in directly. lines are committed in batches of BG_BATCH_SIZE.
+func (Pg) ImportStdin(name string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	progress := StartProgress("pg:importStdin", nil)
+	defer progress.Stop(&err)
+
+	imported, err := copyJsonLines(db, table, os.Stdin, name, p.BatchSize, progress)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d JSON lines imported into %s successfully\n", imported, table)
+	return nil
+}
+
+// ImportTable <table> <filePath> <key> upserts JSON lines from a file into a typed table. key is the import name of
+// posts and profiles, the subject DID of followers and follows, and the list AT URI of listitems.
+func (Pg) ImportTable(table, filePath, key string) (err error) {
+	query, err := upsertQuery(table)
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if err := migrate(db); err != nil {
+		return err
+	}
+
+	progress := StartProgress("pg:importTable", nil)
+	defer progress.Stop(&err)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		data, err := typedRow(table, scanner.Text())
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(query, key, data); err != nil {
+			return fmt.Errorf("failed to upsert JSON line: %w", err)
+		}
+		progress.Items(1)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	fmt.Printf("JSON lines imported into %s successfully\n", table)
+	return nil
+}
+
+// CreateIndexes creates GIN indexes on the JSONB data and expression indexes on the handle and author DID of the
+// BG_PG_TABLE table (default bluesky), and GIN indexes on the typed tables
+func (Pg) CreateIndexes() error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := createIndexes(db); err != nil {
+		return err
+	}
+
+	fmt.Println("Indexes created successfully")
+	return nil
+}
+
+// PostsByAuthor <actor> outputs the posts and feed items of an author in the bluesky table, newest import first
+func (Pg) PostsByAuthor(actor string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	// containment queries are served by the GIN index
+	key := "handle"
+	if strings.HasPrefix(actor, "did:") {
+		key = "did"
+	} else {
+		actor = strings.TrimPrefix(actor, "@")
+	}
+	query := `SELECT data FROM ` + pq.QuoteIdentifier(table) + `
+		WHERE data @> jsonb_build_object('author', jsonb_build_object($1::text, $2::text))
+			OR data @> jsonb_build_object('post', jsonb_build_object('author', jsonb_build_object($1::text, $2::text)))
+		ORDER BY id DESC`
+	return queryData(db, p.Limit, query, key, actor)
+}
+
+// PostsContaining <term> outputs the posts and feed items in the bluesky table whose text contains a term, ignoring case
+func (Pg) PostsContaining(term string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	// ILIKE is served by the trigram index when pg:createIndexes could create it
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term) + "%"
+	query := "SELECT data FROM " + pq.QuoteIdentifier(table) + " WHERE " + postTextExpr + " ILIKE $1 ORDER BY id DESC"
+	return queryData(db, p.Limit, query, pattern)
+}
+
+// SearchLocal <query> searches the text of the imported posts and feed items, best matches first. the query supports
+// web search syntax: quoted phrases, or, and -excluded words.
+func (Pg) SearchLocal(query string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	search := `SELECT data FROM (
+			SELECT data, ts_rank(text_search, q) AS rank FROM posts, websearch_to_tsquery('simple', $1) q WHERE text_search @@ q
+			UNION ALL
+			SELECT data, ts_rank(text_search, q) AS rank FROM ` + pq.QuoteIdentifier(table) + `, websearch_to_tsquery('simple', $1) q WHERE text_search @@ q
+		) matches
+		ORDER BY rank DESC`
+	return queryData(db, p.LimitOr(100), search, query)
+}
+
+// EmbedPosts computes embeddings for the posts table that have none yet, with the BG_EMBEDDINGS_PROVIDER provider
+func (Pg) EmbedPosts(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	embedder, err := NewEmbedder()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := createEmbeddingsTable(db); err != nil {
+		return err
+	}
+
+	progress := StartProgress("pg:embedPosts", nil)
+	defer progress.Stop(&err)
+
+	// BG_LIMIT caps the number of posts embedded per run
+	embedded, err := embedPosts(ctx, db, embedder, p.Limit, progress)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d posts embedded with %s successfully\n", embedded, embedder.Model())
+	return nil
+}
+
+// SemanticSearch <query> outputs the posts whose embeddings are nearest to the query, by cosine distance
+func (Pg) SemanticSearch(ctx context.Context, query string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	embedder, err := NewEmbedder()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := createEmbeddingsTable(db); err != nil {
+		return err
+	}
+
+	embeddings, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return err
+	}
+
+	search := `SELECT p.data FROM post_embeddings e JOIN posts p ON p.uri = e.uri
+		WHERE e.model = $2
+		ORDER BY e.embedding <=> $1::vector`
+	return queryData(db, p.LimitOr(20), search, vectorLiteral(embeddings[0]), embedder.Model())
+}
+
+// queryData runs a query selecting a data column and outputs each row's data, at most limit rows when limit > 0
+func queryData(db *sql.DB, limit int, query string, args ...interface{}) (err error) {
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data json.RawMessage
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := out.Emit(data); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+
+	return nil
+}
+
+// QueryHandles queries the bluesky table and selects the "handle" from the JSON column, filtered by name
+func (Pg) QueryHandles(name string) error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query("SELECT data->>'handle' AS handle FROM "+pq.QuoteIdentifier(table)+" WHERE name = $1", name)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	fmt.Printf("Handles in the %s table with name: %s\n", table, name)
+	for rows.Next() {
+		var handle string
+		if err := rows.Scan(&handle); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		fmt.Println(handle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+
+	return nil
+}
+
+// Query runs an arbitrary query, with the bind parameters of BG_ARGS, and outputs the rows in the BG_FORMAT format. a
+// data column holding a JSON object, such as the data of the bluesky table, is output as the row itself.
+func (Pg) Query(query string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	rows, err := db.Query(query, p.Args...)
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	_, err = emitRows(out, rows)
+	return err
+}
+
+// emitRows outputs each row of a query as an object of its columns, and returns the number of rows. a data column
+// holding a JSON object, such as the data of the bluesky table, is output as the row itself.
+func emitRows(out *Output, rows *sql.Rows) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return n, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		result := make(map[string]interface{})
+		for i, col := range columns {
+			result[col] = columnValue(values[i])
+		}
+
+		var item interface{} = result
+		if data, ok := result["data"].(json.RawMessage); ok && len(data) > 0 && data[0] == '{' {
+			item = data
+		}
+		if err := out.Emit(item); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+
+	return n, nil
+}
+
+// Export <source> <outFile> writes the rows of a table or query, with the bind parameters of BG_ARGS, to a JSONL,
+// CSV, TSV, or Parquet file chosen by the extension. text formats are gzipped with a .gz suffix, such as
+// posts.jsonl.gz, and Parquet files are zstd-compressed. outFile can also be an s3:// or az:// URL.
+func (Pg) Export(ctx context.Context, source, outFile string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	format, gzipped, err := exportFormat(outFile)
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	query := exportQuery(source)
+	var exported int
+	if format == "parquet" {
+		exported, err = exportParquet(ctx, db, outFile, query, p.Args...)
+	} else {
+		exported, err = exportRows(ctx, db, outFile, format, gzipped, query, p.Args...)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d rows exported to %s successfully\n", exported, outFile)
+	return nil
+}
+
+// columnValue converts a scanned column for output. lib/pq scans json, jsonb, and some other types as bytes, which
+// are kept as JSON when valid and as text otherwise.
+func columnValue(v interface{}) interface{} {
+	b, ok := v.([]byte)
+	if !ok {
+		return v
+	}
+	if json.Valid(b) {
+		return json.RawMessage(b)
+	}
+	return string(b)
+}
+
+// Ingest <source> writes the events of jetstream or firehose into the typed tables until interrupted, in transactions
+// of BG_BATCH_SIZE events. posts, profiles, and follows go to their tables and other records to the bluesky table with
+// the name stream:<source>. BG_COLLECTIONS, BG_DIDS, and the BG_RULES filter rules select the events, and a restarted
+// run resumes from the last committed batch.
+func (Pg) Ingest(ctx context.Context, source string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	rules, err := LoadRules()
+	if err != nil {
+		return err
+	}
+
+	var js *Jetstream
+	var f *Firehose
+	var endpoint string
+	switch source {
+	case "jetstream":
+		if js, err = NewJetstream(p); err != nil {
+			return err
+		}
+		endpoint = js.URL
+	case "firehose":
+		if f, err = NewFirehose(p); err != nil {
+			return err
+		}
+		endpoint = f.URL
+	default:
+		return fmt.Errorf("invalid source %q: must be jetstream or firehose", source)
+	}
+
+	db, err := openSync()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// BG_CURSOR overrides the checkpoint of the last run
+	state, err := loadSyncState(db, "ingest:"+source, endpoint)
+	if err != nil {
+		return err
+	}
+	if p.Cursor == "" && state.Cursor != "" {
+		cursor, err := strconv.ParseInt(state.Cursor, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s checkpoint %q: %w", source, state.Cursor, err)
+		}
+		if js != nil {
+			js.Cursor = cursor
+		} else {
+			f.Cursor = cursor
+		}
+	}
+
+	progress := StartProgress("pg:ingest", nil)
+	defer progress.Stop(&err)
+
+	w := newIngestWriter(db, "stream:"+source, "ingest:"+source, endpoint, p.BatchSize, progress)
+	go w.run()
+
+	received := 0
+	handle := func(event *JetstreamEvent) error {
+		if !rules.MatchEvent(event) {
+			return nil
+		}
+		if err := w.Enqueue(ctx, event); err != nil {
+			return err
+		}
+		received++
+		if p.Limit > 0 && received >= p.Limit {
+			return errStopStream
+		}
+		return nil
+	}
+	if js != nil {
+		err = js.Run(ctx, handle)
+	} else {
+		err = f.Run(ctx, handle)
+	}
+
+	// the queued events are written even when the stream was interrupted
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d %s events ingested successfully\n", received, source)
+	return nil
+}
+
+// SyncAuthorFeed <actor> fetches the new posts of an author feed into the posts table, with the name authorFeed:<actor>.
+// posts older than the newest one of the last sync are skipped, unless BG_FULL is set.
+func (Pg) SyncAuthorFeed(ctx context.Context, actor string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, err := openSync()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	state, err := loadSyncState(db, "authorFeed", actor)
+	if err != nil {
+		return err
+	}
+	watermark := state.Watermark()
+	if p.Full {
+		watermark = time.Time{}
+	}
+
+	progress := StartProgress("pg:syncAuthorFeed", c)
+	defer progress.Stop(&err)
+
+	source := "authorFeed:" + actor
+	cursor := state.Cursor
+	newest := watermark
+	for {
+		resp, err := c.GetAuthorFeed(ctx, actor, p.LimitOr(100), cursor, p.Filter, false)
+		if err != nil {
+			return err
+		}
+
+		// the feed is newest first, so the first item at or before the watermark ends the sync
+		caughtUp := false
+		posts := make([]interface{}, 0, len(resp.Feed))
+		for _, item := range resp.Feed {
+			t := feedItemTime(item)
+			if !watermark.IsZero() && !t.After(watermark) {
+				caughtUp = true
+				break
+			}
+			if t.After(newest) {
+				newest = t
+			}
+			posts = append(posts, item.Post)
+		}
+		if err := upsertRows(db, "posts", source, posts); err != nil {
+			return err
+		}
+		progress.Page()
+		progress.Items(len(posts))
+
+		if caughtUp || resp.Cursor == "" || len(resp.Feed) == 0 {
+			break
+		}
+		cursor = resp.Cursor
+		if err := state.NextPage(cursor); err != nil {
+			return err
+		}
+	}
+
+	if err := state.Done(formatWatermark(newest)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Posts of %s synced into posts successfully\n", actor)
+	return nil
+}
+
+// followerAnchors is how many of the newest followers pg:syncFollowers keeps as its watermark
+const followerAnchors = 10
+
+// SyncFollowers <actor> fetches the new followers of an actor into the followers table, with the actor's DID as
+// subject. paging stops at any of the newest followers of the last sync, unless BG_FULL is set.
+func (Pg) SyncFollowers(ctx context.Context, actor string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, err := openSync()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	state, err := loadSyncState(db, "followers", actor)
+	if err != nil {
+		return err
+	}
+	anchors := map[string]bool{}
+	if !p.Full {
+		for _, did := range strings.Fields(state.Newest) {
+			anchors[did] = true
+		}
+	}
+
+	progress := StartProgress("pg:syncFollowers", c)
+	defer progress.Stop(&err)
+
+	// followers are listed newest first without the time they followed, so the watermark is the DIDs of the newest
+	// followers. several are kept so that one of them unfollowing does not turn the next sync into a full one. a run
+	// resumed from a cursor keeps the previous watermark, since its first page is not the newest.
+	cursor := state.Cursor
+	newest := state.Newest
+	for {
+		resp, err := c.GetFollowers(ctx, actor, p.LimitOr(100), cursor)
+		if err != nil {
+			return err
+		}
+		if cursor == "" && len(resp.Followers) > 0 {
+			dids := make([]string, 0, followerAnchors)
+			for _, follower := range resp.Followers[:min(len(resp.Followers), followerAnchors)] {
+				dids = append(dids, follower.DID)
+			}
+			newest = strings.Join(dids, " ")
+		}
+
+		caughtUp := false
+		followers := make([]interface{}, 0, len(resp.Followers))
+		for _, follower := range resp.Followers {
+			if anchors[follower.DID] {
+				caughtUp = true
+				break
+			}
+			followers = append(followers, follower)
+		}
+		if err := upsertRows(db, "followers", resp.Subject.DID, followers); err != nil {
+			return err
+		}
+		progress.Page()
+		progress.Items(len(followers))
+
+		if caughtUp || resp.Cursor == "" || len(resp.Followers) == 0 {
+			break
+		}
+		cursor = resp.Cursor
+		if err := state.NextPage(cursor); err != nil {
+			return err
+		}
+	}
+
+	if err := state.Done(newest); err != nil {
+		return err
+	}
+
+	fmt.Printf("Followers of %s synced into followers successfully\n", actor)
+	return nil
+}
+
+// SnapshotFollowers <actor> records the current follower, follows, and posts counts of an actor in follower_snapshots.
+// run it on a schedule to chart growth with pg:followerGrowth.
+func (Pg) SnapshotFollowers(ctx context.Context, actor string) error {
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, err := openSync()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	profile, err := c.GetProfile(ctx, actor)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`INSERT INTO follower_snapshots (actor, handle, follower_count, follows_count, posts_count)
+		VALUES ($1, $2, $3, $4, $5)`, profile.DID, profile.Handle, profile.FollowersCount, profile.FollowsCount, profile.PostsCount)
+	if err != nil {
+		return fmt.Errorf("failed to insert snapshot: %w", err)
+	}
+
+	fmt.Printf("Snapshot of %s recorded successfully: %d followers, %d follows, %d posts\n",
+		profile.Handle, profile.FollowersCount, profile.FollowsCount, profile.PostsCount)
+	return nil
+}
+
+// FollowerGrowth <actor> outputs the follower snapshots of an actor, oldest first, with the change since the previous
+// snapshot and a bar chart of the follower count. BG_LIMIT keeps the latest snapshots.
+func (Pg) FollowerGrowth(actor string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrate(db); err != nil {
+		return err
+	}
+
+	growth, err := followerGrowth(db, actor, p.Limit, p.Location)
+	if err != nil {
+		return err
+	}
+	if len(growth) == 0 {
+		return fmt.Errorf("no snapshots of %s: record them with pg:snapshotFollowers", actor)
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	for _, g := range growth {
+		if err := out.Emit(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncSearch <query> fetches the new posts matching a search query into the posts table, with the name search:<query>.
+// with BG_SORT=latest, posts older than the newest one of the last sync are skipped, unless BG_FULL is set. posts
+// dropped by the BG_RULES filter rules are not stored.
+func (Pg) SyncSearch(ctx context.Context, query string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	rules, err := LoadRules()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	db, err := openSync()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	state, err := loadSyncState(db, "search:"+p.Sort, query)
+	if err != nil {
+		return err
+	}
+	// only latest results are ordered by time
+	watermark := state.Watermark()
+	if p.Full || p.Sort != "latest" {
+		watermark = time.Time{}
+	}
+
+	progress := StartProgress("pg:syncSearch", c)
+	defer progress.Stop(&err)
+
+	source := "search:" + query
+	cursor := state.Cursor
+	newest := watermark
+	for {
+		resp, err := c.SearchPosts(ctx, query, p.LimitOr(100), cursor, p.Sort, "", "", "", "", "", "", "", nil)
+		if err != nil {
+			return err
+		}
+
+		caughtUp := false
+		posts := make([]interface{}, 0, len(resp.Posts))
+		for _, post := range resp.Posts {
+			t, _ := time.Parse(time.RFC3339Nano, post.IndexedAt)
+			if !watermark.IsZero() && !t.After(watermark) {
+				caughtUp = true
+				break
+			}
+			if t.After(newest) {
+				newest = t
+			}
+			if !rules.MatchItem(post) {
+				continue
+			}
+			posts = append(posts, post)
+		}
+		if err := upsertRows(db, "posts", source, posts); err != nil {
+			return err
+		}
+		progress.Page()
+		progress.Items(len(posts))
+
+		if caughtUp || resp.Cursor == "" || len(resp.Posts) == 0 {
+			break
+		}
+		cursor = resp.Cursor
+		if err := state.NextPage(cursor); err != nil {
+			return err
+		}
+	}
+
+	if err := state.Done(formatWatermark(newest)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Posts matching %q synced into posts successfully\n", query)
+	return nil
+}
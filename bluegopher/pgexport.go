@@ -0,0 +1,131 @@
+package bluegopher
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportFormat returns the output format of an export file from its extension: .jsonl, .json, .csv, .tsv, or
+// .parquet, with .gz to gzip the text formats. Parquet files are always zstd-compressed.
+func exportFormat(outFile string) (format string, gzipped bool, err error) {
+	name := strings.ToLower(outFile)
+	if strings.HasSuffix(name, ".gz") {
+		gzipped = true
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	switch filepath.Ext(name) {
+	case ".jsonl", ".json":
+		format = "jsonl"
+	case ".csv":
+		format = "csv"
+	case ".tsv":
+		format = "tsv"
+	case ".parquet":
+		if gzipped {
+			return "", false, fmt.Errorf("invalid export file %s: Parquet files are compressed with zstd, not gzip", outFile)
+		}
+		format = "parquet"
+	default:
+		return "", false, fmt.Errorf("invalid export file %s: the extension must be .jsonl, .csv, .tsv, or .parquet, optionally with .gz", outFile)
+	}
+	return format, gzipped, nil
+}
+
+// exportQuery returns the query of an export source: every row of a table, optionally schema-qualified, or the
+// source itself as a query
+func exportQuery(source string) string {
+	schema, table, qualified := strings.Cut(source, ".")
+	if !qualified {
+		schema, table = "", source
+	}
+	if pgIdentifier.MatchString(table) && (schema == "" || pgIdentifier.MatchString(schema)) {
+		return "SELECT * FROM " + source
+	}
+	return source
+}
+
+// exportRows writes the rows of a query to a file or s3:// or az:// URL in a format, gzipped when set. it returns the
+// number of rows.
+func exportRows(ctx context.Context, db *sql.DB, outFile, format string, gzipped bool, query string, args ...interface{}) (n int, err error) {
+	file, err := createFile(ctx, outFile)
+	if err != nil {
+		return 0, err
+	}
+	// a failed export is aborted, so no truncated object is uploaded
+	defer func() {
+		if err != nil {
+			abortFile(file)
+		}
+	}()
+
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+
+	out, err := newOutput(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	n, err = emitRows(out, rows)
+	if err != nil {
+		return n, err
+	}
+	if err := out.Flush(); err != nil {
+		return n, fmt.Errorf("failed to write file: %w", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return n, fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return n, fmt.Errorf("failed to write file: %w", err)
+	}
+	return n, nil
+}
+
+// exportParquet writes the rows of a query to a Parquet file, through a temporary JSONL file converted by DuckDB.
+// Parquet files for s3:// and az:// URLs are written to a temporary file first, then uploaded.
+func exportParquet(ctx context.Context, db *sql.DB, outFile, query string, args ...interface{}) (int, error) {
+	tmp, err := os.CreateTemp("", "bg-export-*.jsonl")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	n, err := exportRows(ctx, db, tmp.Name(), "jsonl", false, query, args...)
+	if err != nil {
+		return n, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("no rows to export: DuckDB cannot infer the Parquet schema of an empty result")
+	}
+	if !isRemotePath(outFile) {
+		return n, writeParquet(ctx, tmp.Name(), outFile)
+	}
+
+	parquetFile := strings.TrimSuffix(tmp.Name(), ".jsonl") + ".parquet"
+	defer os.Remove(parquetFile)
+	if err := writeParquet(ctx, tmp.Name(), parquetFile); err != nil {
+		return n, err
+	}
+	return n, copyFile(ctx, parquetFile, outFile)
+}
@@ -0,0 +1,153 @@
+package bluegopher
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// blueskyKey is the expression of the generated key column of the bluesky table, added by migration 0002
+const blueskyKey = `COALESCE(data->>'uri', data#>>'{post,uri}', data->>'did')`
+
+// createStagingTable creates the temporary table each batch is copied into. COPY cannot resolve conflicts, so
+// batches are copied into the staging table first and upserted from there.
+const createStagingTable = `CREATE TEMPORARY TABLE bluesky_import (id SERIAL, name TEXT, data JSONB NOT NULL) ON COMMIT DROP`
+
+// upsertStaging moves a batch from the staging table into a table of JSON lines. items repeated within a batch keep
+// their last line, since an upsert cannot update the same row twice.
+func upsertStaging(table string) string {
+	return `INSERT INTO ` + pq.QuoteIdentifier(table) + ` (name, data)
+	SELECT DISTINCT ON (COALESCE(` + blueskyKey + `, 'line:' || id)) name, data
+	FROM bluesky_import
+	ORDER BY COALESCE(` + blueskyKey + `, 'line:' || id), id DESC
+	ON CONFLICT (name, key) DO UPDATE SET data = EXCLUDED.data, created_at = CURRENT_TIMESTAMP`
+}
+
+// copyJsonLines imports JSON lines into a table like the bluesky table with COPY, committing every batchSize lines. it
+// returns the number of lines imported, which are all committed even when a later batch fails.
+func copyJsonLines(db *sql.DB, table string, r io.Reader, name string, batchSize int, progress *Progress) (int, error) {
+	return batchJsonLines(r, batchSize, progress, func(lines []string) error {
+		return copyBatch(db, table, name, lines)
+	})
+}
+
+// insertJsonLines imports JSON lines into a table like the bluesky table with a prepared upsert, committing every
+// batchSize lines. it is slower than copyJsonLines, but every line is upserted in file order.
+func insertJsonLines(db *sql.DB, table string, r io.Reader, name string, batchSize int, progress *Progress) (int, error) {
+	// the statement is prepared once and bound to the transaction of each batch
+	stmt, err := db.Prepare(`INSERT INTO ` + pq.QuoteIdentifier(table) + ` (name, data) VALUES ($1, $2)
+		ON CONFLICT (name, key) DO UPDATE SET data = EXCLUDED.data, created_at = CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	return batchJsonLines(r, batchSize, progress, func(lines []string) error {
+		return insertBatch(db, stmt, name, lines)
+	})
+}
+
+// batchJsonLines reads non-empty lines and passes them to importBatch in batches of batchSize lines, logging each
+// committed batch. it returns the number of lines imported before the first error.
+func batchJsonLines(r io.Reader, batchSize int, progress *Progress, importBatch func(lines []string) error) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	imported := 0
+	batch := make([]string, 0, batchSize)
+	for {
+		more := scanner.Scan()
+		if more {
+			if line := scanner.Text(); strings.TrimSpace(line) != "" {
+				batch = append(batch, line)
+			}
+		}
+
+		if len(batch) > 0 && (len(batch) == batchSize || !more) {
+			if err := importBatch(batch); err != nil {
+				return imported, fmt.Errorf("failed to import lines %d to %d: %w", imported+1, imported+len(batch), err)
+			}
+			log.Printf("%s: committed lines %d to %d\n", progress.name, imported+1, imported+len(batch))
+			imported += len(batch)
+			progress.Page()
+			progress.Items(len(batch))
+			batch = batch[:0]
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return imported, fmt.Errorf("error reading input: %w", err)
+	}
+	return imported, nil
+}
+
+// insertBatch upserts a batch of JSON lines with a prepared statement in a single transaction
+func insertBatch(db *sql.DB, stmt *sql.Stmt, name string, lines []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	txStmt := tx.Stmt(stmt)
+	defer txStmt.Close()
+	for _, line := range lines {
+		if _, err := txStmt.Exec(name, line); err != nil {
+			return fmt.Errorf("failed to insert JSON line: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// copyBatch imports a batch of JSON lines in a single transaction
+func copyBatch(db *sql.DB, table, name string, lines []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(createStagingTable); err != nil {
+		return fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("bluesky_import", "name", "data"))
+	if err != nil {
+		return fmt.Errorf("failed to start copy: %w", err)
+	}
+	for _, line := range lines {
+		if _, err := stmt.Exec(name, line); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy JSON line: %w", err)
+		}
+	}
+	// the final Exec without arguments flushes the buffered rows
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return fmt.Errorf("failed to copy JSON lines: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to finish copy: %w", err)
+	}
+
+	if _, err := tx.Exec(upsertStaging(table)); err != nil {
+		return fmt.Errorf("failed to upsert JSON lines: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package bluegopher
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ingestFlushInterval is the longest an ingested event waits before its batch is written, when the stream is slower
+// than BG_BATCH_SIZE events per interval
+const ingestFlushInterval = 2 * time.Second
+
+// ingestQueries write the records of stream events into the typed tables. records without a typed table are kept in
+// the bluesky table under the stream's name, keyed by their AT URI.
+var ingestQueries = map[string]string{
+	"post": `INSERT INTO posts (name, data) VALUES ($1, $2)
+		ON CONFLICT (uri) DO UPDATE SET name = EXCLUDED.name, data = EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+	"deletePost": `DELETE FROM posts WHERE uri = $1`,
+	// profile records and identity events each carry part of a profile, so they are merged
+	"profile": `INSERT INTO profiles (name, data) VALUES ($1, $2)
+		ON CONFLICT (did) DO UPDATE SET data = profiles.data || EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+	"follow": `INSERT INTO follows (subject, data) VALUES ($1, $2)
+		ON CONFLICT (subject, did) DO UPDATE SET data = EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+	"follower": `INSERT INTO followers (subject, data) VALUES ($1, $2)
+		ON CONFLICT (subject, did) DO UPDATE SET data = EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+	// a deleted follow has no record, so the follow is found by the AT URI kept in its data
+	"deleteFollow":   `DELETE FROM follows WHERE subject = $1 AND data->>'uri' = $2`,
+	"deleteFollower": `DELETE FROM followers WHERE did = $1 AND data->>'uri' = $2`,
+	"record": `INSERT INTO bluesky (name, data) VALUES ($1, $2)
+		ON CONFLICT (name, key) DO UPDATE SET data = EXCLUDED.data, created_at = CURRENT_TIMESTAMP`,
+	"deleteRecord": `DELETE FROM bluesky WHERE name = $1 AND key = $2`,
+	"checkpoint": `INSERT INTO sync_state (endpoint, key, cursor) VALUES ($1, $2, $3)
+		ON CONFLICT (endpoint, key) DO UPDATE SET cursor = EXCLUDED.cursor, updated_at = CURRENT_TIMESTAMP`,
+}
+
+// ingestWrite is a statement of ingestQueries and its arguments
+type ingestWrite struct {
+	query string
+	args  []interface{}
+}
+
+// ingestWrites returns the writes of a stream event. posts are stored in the shape of a postView, and follows in both
+// the follows of the author and the followers of the subject.
+func ingestWrites(name string, event *JetstreamEvent) ([]ingestWrite, error) {
+	if event.Kind == "identity" {
+		var identity struct {
+			Handle string `json:"handle"`
+		}
+		if err := json.Unmarshal(event.Identity, &identity); err != nil || identity.Handle == "" {
+			return nil, nil
+		}
+		data, err := json.Marshal(map[string]string{"did": event.DID, "handle": identity.Handle})
+		return []ingestWrite{{"profile", []interface{}{name, string(data)}}}, err
+	}
+	if event.Commit == nil {
+		return nil, nil
+	}
+
+	uri := event.URI()
+	deleted := event.Commit.Operation == "delete"
+	if !deleted && len(event.Commit.Record) == 0 {
+		// records of commits too big for the firehose are not available
+		return nil, nil
+	}
+
+	switch event.Commit.Collection {
+	case "app.bsky.feed.post":
+		if deleted {
+			return []ingestWrite{{"deletePost", []interface{}{uri}}}, nil
+		}
+		data, err := json.Marshal(map[string]interface{}{
+			"uri":       uri,
+			"cid":       event.Commit.CID,
+			"author":    map[string]string{"did": event.DID},
+			"record":    event.Commit.Record,
+			"indexedAt": time.UnixMicro(event.TimeUS).UTC().Format(time.RFC3339Nano),
+		})
+		return []ingestWrite{{"post", []interface{}{name, string(data)}}}, err
+	case "app.bsky.actor.profile":
+		if deleted {
+			return nil, nil
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(event.Commit.Record, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse profile record %s: %w", uri, err)
+		}
+		delete(data, "$type")
+		data["did"] = event.DID
+		b, err := json.Marshal(data)
+		return []ingestWrite{{"profile", []interface{}{name, string(b)}}}, err
+	case "app.bsky.graph.follow":
+		if deleted {
+			return []ingestWrite{
+				{"deleteFollow", []interface{}{event.DID, uri}},
+				{"deleteFollower", []interface{}{event.DID, uri}},
+			}, nil
+		}
+		var follow struct {
+			Subject   string `json:"subject"`
+			CreatedAt string `json:"createdAt"`
+		}
+		if err := json.Unmarshal(event.Commit.Record, &follow); err != nil || follow.Subject == "" {
+			return nil, nil
+		}
+		followed, err := json.Marshal(map[string]string{"did": follow.Subject, "uri": uri, "createdAt": follow.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+		follower, err := json.Marshal(map[string]string{"did": event.DID, "uri": uri, "createdAt": follow.CreatedAt})
+		return []ingestWrite{
+			{"follow", []interface{}{event.DID, string(followed)}},
+			{"follower", []interface{}{follow.Subject, string(follower)}},
+		}, err
+	}
+
+	if deleted {
+		return []ingestWrite{{"deleteRecord", []interface{}{name, uri}}}, nil
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"uri":        uri,
+		"cid":        event.Commit.CID,
+		"did":        event.DID,
+		"collection": event.Commit.Collection,
+		"record":     event.Commit.Record,
+		"time_us":    event.TimeUS,
+	})
+	return []ingestWrite{{"record", []interface{}{name, string(data)}}}, err
+}
+
+// ingestWriter writes stream events to the database in batches from a bounded queue. a full queue blocks the stream,
+// which stops reading from the connection until the database catches up.
+type ingestWriter struct {
+	db        *sql.DB
+	name      string
+	endpoint  string
+	key       string
+	batchSize int
+	progress  *Progress
+
+	events chan *JetstreamEvent
+	done   chan struct{}
+	err    error
+}
+
+// newIngestWriter creates a writer of the events of a stream, checkpointed in sync_state under endpoint and key
+func newIngestWriter(db *sql.DB, name, endpoint, key string, batchSize int, progress *Progress) *ingestWriter {
+	return &ingestWriter{
+		db:        db,
+		name:      name,
+		endpoint:  endpoint,
+		key:       key,
+		batchSize: batchSize,
+		progress:  progress,
+		events:    make(chan *JetstreamEvent, batchSize),
+		done:      make(chan struct{}),
+	}
+}
+
+// Enqueue queues an event, waiting while the queue is full. it returns the error of a failed writer.
+func (w *ingestWriter) Enqueue(ctx context.Context, event *JetstreamEvent) error {
+	select {
+	case w.events <- event:
+		return nil
+	case <-w.done:
+		return w.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes the queued events and returns the error of the writer
+func (w *ingestWriter) Close() error {
+	close(w.events)
+	<-w.done
+	return w.err
+}
+
+// run writes batches of BG_BATCH_SIZE events, or what arrived within ingestFlushInterval, until the queue is closed
+func (w *ingestWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(ingestFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*JetstreamEvent, 0, w.batchSize)
+	for {
+		select {
+		case event, ok := <-w.events:
+			if !ok {
+				w.err = w.write(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) < w.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+
+		if err := w.write(batch); err != nil {
+			w.err = err
+			return
+		}
+		batch = batch[:0]
+	}
+}
+
+// write stores a batch and its checkpoint in a single transaction. the checkpoint is one before the cursor of the
+// last event, so a resumed stream rewrites that event rather than skip the rest of its firehose commit.
+func (w *ingestWriter) write(batch []*JetstreamEvent) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmts := make(map[string]*sql.Stmt)
+	exec := func(query string, args ...interface{}) error {
+		stmt, ok := stmts[query]
+		if !ok {
+			var err error
+			if stmt, err = tx.Prepare(ingestQueries[query]); err != nil {
+				return fmt.Errorf("failed to prepare statement: %w", err)
+			}
+			stmts[query] = stmt
+		}
+		_, err := stmt.Exec(args...)
+		return err
+	}
+
+	for _, event := range batch {
+		writes, err := ingestWrites(w.name, event)
+		if err != nil {
+			return err
+		}
+		for _, write := range writes {
+			if err := exec(write.query, write.args...); err != nil {
+				return fmt.Errorf("failed to write %s: %w", event.URI(), err)
+			}
+		}
+	}
+
+	cursor := streamCursor(batch[len(batch)-1]) - 1
+	if err := exec("checkpoint", w.endpoint, w.key, strconv.FormatInt(cursor, 10)); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	w.progress.Page()
+	w.progress.Items(len(batch))
+	return nil
+}
+
+// streamCursor returns the cursor of an event: the sequence number of a firehose event, or the time_us of a
+// Jetstream event
+func streamCursor(event *JetstreamEvent) int64 {
+	if event.Seq > 0 {
+		return event.Seq
+	}
+	return event.TimeUS
+}
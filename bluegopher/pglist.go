@@ -0,0 +1,269 @@
+package bluegopher
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// starterPackMaxMembers is the most accounts a starter pack can hold
+const starterPackMaxMembers = 150
+
+// starterPackRecord is the record of an app.bsky.graph.starterpack
+type starterPackRecord struct {
+	Type      string `json:"$type"`
+	Name      string `json:"name"`
+	List      string `json:"list"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// queryActors runs a query and returns the accounts of its did column, or its handle column, or else its first
+// column, in order and without duplicates. the query runs in a read-only transaction, so it cannot change the database.
+func queryActors(ctx context.Context, db *sql.DB, query string, args []interface{}) ([]string, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	column := 0
+	for _, name := range []string{"handle", "did"} {
+		for i, c := range columns {
+			if strings.EqualFold(c, name) {
+				column = i
+			}
+		}
+	}
+
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	var actors []string
+	seen := make(map[string]bool)
+	for rows.Next() {
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		actor := strings.TrimSpace(fmt.Sprint(columnValue(values[column])))
+		if values[column] == nil || actor == "" || seen[actor] {
+			continue
+		}
+		seen[actor] = true
+		actors = append(actors, actor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+	return actors, nil
+}
+
+// BuildList <listURL> <query> adds the accounts returned by a query, from its did or handle column or else its first
+// column, to a list or to the list of a starter pack. with starter-pack:<name> as the URL, a new starter pack of the
+// accounts is created. members already in the list are kept, and BG_ARGS holds the bind parameters of the query.
+// BG_DRY_RUN=true only outputs the accounts that would be added.
+func (Pg) BuildList(ctx context.Context, listURL, query string) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	actors, err := queryActors(ctx, db, query, p.Args)
+	if err != nil {
+		return err
+	}
+	log.Printf("query returned %d accounts\n", len(actors))
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	var dids []string
+	unresolved := 0
+	seen := make(map[string]bool)
+	for _, actor := range actors {
+		did, err := c.resolveActorLine(ctx, actor)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Printf("failed: %s: %v\n", actor, err)
+			unresolved++
+			continue
+		}
+		if !seen[did] {
+			seen[did] = true
+			dids = append(dids, did)
+		}
+	}
+	if unresolved > 0 {
+		log.Printf("%d accounts could not be resolved and are not added\n", unresolved)
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	var listURI string
+	members := make(map[string][]string)
+	name, newStarterPack := strings.CutPrefix(listURL, "starter-pack:")
+	switch {
+	case newStarterPack:
+		if name == "" {
+			return fmt.Errorf("invalid starter pack %q: expected starter-pack:<name>", listURL)
+		}
+		if len(dids) > starterPackMaxMembers {
+			return fmt.Errorf("query returned %d accounts: starter packs hold at most %d", len(dids), starterPackMaxMembers)
+		}
+	case strings.Contains(listURL, "/starter-pack/") || strings.Contains(listURL, "/app.bsky.graph.starterpack/"):
+		if listURI, err = c.StarterPackListURI(ctx, listURL); err != nil {
+			return err
+		}
+	default:
+		if listURI, err = c.ListATURI(ctx, listURL); err != nil {
+			return err
+		}
+	}
+	if listURI != "" {
+		if members, err = c.listMembers(ctx, listURI); err != nil {
+			return fmt.Errorf("failed to list current members: %w", err)
+		}
+	}
+
+	var adds []string
+	for _, did := range dids {
+		if _, ok := members[did]; !ok {
+			adds = append(adds, did)
+		}
+	}
+	log.Printf("list has %d members: %d to add\n", len(members), len(adds))
+
+	if p.DryRun {
+		// a new starter pack has no list yet, so its starter-pack:<name> stands in for the list URI
+		list := listURI
+		if newStarterPack {
+			list = listURL
+		}
+		for _, did := range adds {
+			if err := out.Emit(map[string]interface{}{"list": list, "action": "add", "subject": did}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if newStarterPack {
+		// the list of a starter pack is a reference list, which is not shown as a list of its own
+		list, err := c.ListCreate(ctx, "app.bsky.graph.defs#referencelist", name, "", time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if listURI, ok = list["uri"].(string); !ok {
+			return fmt.Errorf("failed to get URI of the new list")
+		}
+
+		// a starter pack that fails to be created leaves no list behind, even when interrupted
+		defer func() {
+			if err != nil {
+				if discardErr := c.discardList(context.WithoutCancel(ctx), listURI); discardErr != nil {
+					log.Printf("failed to delete the list %s of the failed starter pack: %v\n", listURI, discardErr)
+				} else {
+					log.Printf("deleted the list %s of the failed starter pack\n", listURI)
+				}
+			}
+		}()
+	}
+
+	writes := make([]WriteOp, 0, len(adds))
+	createdAt := time.Now().UTC().Format(time.RFC3339)
+	for _, did := range adds {
+		writes = append(writes, CreateOp("app.bsky.graph.listitem", listItemRecord{
+			Type:      "app.bsky.graph.listitem",
+			Subject:   did,
+			List:      listURI,
+			CreatedAt: createdAt,
+		}))
+	}
+	emit := func(batch []WriteOp) error {
+		for _, op := range batch {
+			record := op.Value.(listItemRecord)
+			if err := out.Emit(map[string]interface{}{"list": listURI, "action": "add", "subject": record.Subject}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := c.applyWritesBatched(ctx, writes, p.WriteDelay, emit); err != nil {
+		return fmt.Errorf("failed to add members to %s: %w", listURI, err)
+	}
+
+	if newStarterPack {
+		resp, err := c.CreateRecord(ctx, CreateRecordRequest{
+			Repo:       c.Session.DID,
+			Collection: "app.bsky.graph.starterpack",
+			Record: starterPackRecord{
+				Type:      "app.bsky.graph.starterpack",
+				Name:      name,
+				List:      listURI,
+				CreatedAt: createdAt,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create starter pack: %w", err)
+		}
+		uri, _ := resp["uri"].(string)
+		if link, err := BskyURL(uri); err == nil {
+			log.Printf("created starter pack %s\n", link)
+		}
+	}
+
+	log.Printf("added %d accounts to %s\n", len(adds), listURI)
+	return nil
+}
+
+// discardList deletes a list of the account and its items
+func (c *Client) discardList(ctx context.Context, listURI string) error {
+	_, _, listRkey, err := splitATURI(listURI)
+	if err != nil {
+		return err
+	}
+	members, err := c.listMembers(ctx, listURI)
+	if err != nil {
+		return fmt.Errorf("failed to list members: %w", err)
+	}
+	var writes []WriteOp
+	for _, uris := range members {
+		for _, uri := range uris {
+			if _, _, rkey, err := splitATURI(uri); err == nil {
+				writes = append(writes, DeleteOp("app.bsky.graph.listitem", rkey))
+			}
+		}
+	}
+	writes = append(writes, DeleteOp("app.bsky.graph.list", listRkey))
+	return c.applyWritesBatched(ctx, writes, 0, nil)
+}
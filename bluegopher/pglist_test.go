@@ -0,0 +1,29 @@
+package bluegopher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDiscardList(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+
+	list := pds.createRecord("app.bsky.graph.list", "", map[string]interface{}{"$type": "app.bsky.graph.list", "name": "pack"})["uri"]
+	other := pds.createRecord("app.bsky.graph.list", "", map[string]interface{}{"$type": "app.bsky.graph.list", "name": "other"})["uri"]
+	for _, item := range []struct{ list, subject string }{{list, "did:plc:bob"}, {list, "did:plc:carol"}, {other, "did:plc:bob"}} {
+		pds.createRecord("app.bsky.graph.listitem", "", map[string]interface{}{"$type": "app.bsky.graph.listitem", "list": item.list, "subject": item.subject})
+	}
+
+	if err := c.discardList(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+
+	// the list and its items are deleted, and the other list keeps its item
+	if lists := pds.collection("app.bsky.graph.list"); len(lists) != 1 || lists[0].URI != other {
+		t.Errorf("lists = %+v, want only %s", lists, other)
+	}
+	if items := pds.collection("app.bsky.graph.listitem"); len(items) != 1 || items[0].Value["list"] != other {
+		t.Errorf("list items = %+v, want only the item of %s", items, other)
+	}
+}
@@ -0,0 +1,114 @@
+package bluegopher
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// schemaTables are the tables created by the typed table migrations. each typed table keeps the original item in data,
+// with the commonly queried fields extracted into generated columns. the follower snapshots and the labels issued by
+// lb:emit are history that cannot be synced again, so their tables are not dropped with the others.
+var schemaTables = []string{"posts", "profiles", "followers", "follows", "listitems", "sync_state"}
+
+// upsertQueries insert or update a row of each typed table. $1 is the name, subject, or list column and $2 the data.
+var upsertQueries = map[string]string{
+	"posts": `INSERT INTO posts (name, data) VALUES ($1, $2)
+		ON CONFLICT (uri) DO UPDATE SET name = EXCLUDED.name, data = EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+	"profiles": `INSERT INTO profiles (name, data) VALUES ($1, $2)
+		ON CONFLICT (did) DO UPDATE SET name = EXCLUDED.name, data = EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+	"followers": `INSERT INTO followers (subject, data) VALUES ($1, $2)
+		ON CONFLICT (subject, did) DO UPDATE SET data = EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+	"follows": `INSERT INTO follows (subject, data) VALUES ($1, $2)
+		ON CONFLICT (subject, did) DO UPDATE SET data = EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+	"listitems": `INSERT INTO listitems (list, data) VALUES ($1, $2)
+		ON CONFLICT (uri) DO UPDATE SET list = EXCLUDED.list, data = EXCLUDED.data, imported_at = CURRENT_TIMESTAMP`,
+}
+
+// upsertQuery returns the upsert of a typed table
+func upsertQuery(table string) (string, error) {
+	query, ok := upsertQueries[table]
+	if !ok {
+		tables := make([]string, 0, len(upsertQueries))
+		for t := range upsertQueries {
+			tables = append(tables, t)
+		}
+		sort.Strings(tables)
+		return "", fmt.Errorf("unknown table %q: must be one of %s", table, strings.Join(tables, ", "))
+	}
+	return query, nil
+}
+
+// typedRow returns the data of a typed table row from a JSON line. feed items such as the output of
+// bs:getAuthorFeeds are unwrapped to their post.
+func typedRow(table, line string) (string, error) {
+	if table != "posts" {
+		return line, nil
+	}
+
+	var item struct {
+		Post json.RawMessage `json:"post"`
+	}
+	if err := json.Unmarshal([]byte(line), &item); err != nil {
+		return "", fmt.Errorf("failed to parse JSON line: %w", err)
+	}
+	if len(item.Post) > 0 && item.Post[0] == '{' {
+		return string(item.Post), nil
+	}
+	return line, nil
+}
+
+// postTextExpr is the text of a post or feed item in the bluesky table
+const postTextExpr = `COALESCE(data#>>'{record,text}', data#>>'{post,record,text}')`
+
+// indexStatements create the GIN and expression indexes used by the pg query helpers on a table of JSON lines and the
+// typed tables. jsonb_path_ops indexes are smaller than the default operator class and serve the @> containment
+// queries of the helpers.
+func indexStatements(table string) []string {
+	t := pq.QuoteIdentifier(table)
+	return []string{
+		`CREATE INDEX IF NOT EXISTS ` + pq.QuoteIdentifier(table+"_data_idx") + ` ON ` + t + ` USING GIN (data jsonb_path_ops)`,
+		`CREATE INDEX IF NOT EXISTS ` + pq.QuoteIdentifier(table+"_handle_idx") + ` ON ` + t + ` ((data->>'handle'))`,
+		`CREATE INDEX IF NOT EXISTS ` + pq.QuoteIdentifier(table+"_author_did_idx") + ` ON ` + t + ` ((data->'author'->>'did'))`,
+		`CREATE INDEX IF NOT EXISTS ` + pq.QuoteIdentifier(table+"_post_author_did_idx") + ` ON ` + t + ` ((data#>>'{post,author,did}'))`,
+		`CREATE INDEX IF NOT EXISTS posts_data_idx ON posts USING GIN (data jsonb_path_ops)`,
+		`CREATE INDEX IF NOT EXISTS profiles_data_idx ON profiles USING GIN (data jsonb_path_ops)`,
+	}
+}
+
+// trigramStatements index the post text of a table of JSON lines for substring searches. pg_trgm ships with
+// PostgreSQL, but creating an extension needs privileges the database user may lack, so these are optional.
+func trigramStatements(table string) []string {
+	return []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS ` + pq.QuoteIdentifier(table+"_text_trgm_idx") + ` ON ` + pq.QuoteIdentifier(table) + ` USING GIN ((` + postTextExpr + `) gin_trgm_ops)`,
+	}
+}
+
+// createIndexes creates the indexes of the BG_PG_TABLE table (default bluesky) and the typed tables, and the trigram
+// index when pg_trgm is available. the indexes are not part of the migrations since they slow down imports.
+func createIndexes(db *sql.DB) error {
+	table, err := jsonTable(db)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range indexStatements(table) {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	for _, statement := range trigramStatements(table) {
+		if _, err := db.Exec(statement); err != nil {
+			log.Printf("skipping the trigram index of post text: %v\n", err)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package bluegopher
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// growthChartWidth is the width of the longest bar of the follower growth chart
+const growthChartWidth = 40
+
+// FollowerGrowth is a follower snapshot with the change since the previous snapshot and a bar of the follower count
+type FollowerGrowth struct {
+	CapturedAt string `json:"capturedAt"`
+	Followers  int    `json:"followers"`
+	Follows    int    `json:"follows"`
+	Posts      int    `json:"posts"`
+	Change     int    `json:"change"`
+	Chart      string `json:"chart"`
+}
+
+// followerGrowth reads the snapshots of an actor, oldest first, the last limit snapshots when limit > 0. the actor
+// matches the DID or the handle at the time of the snapshot.
+func followerGrowth(db *sql.DB, actor string, limit int, loc *time.Location) ([]FollowerGrowth, error) {
+	query := `SELECT captured_at, follower_count, follows_count, posts_count FROM follower_snapshots
+		WHERE actor = $1 OR handle = $1
+		ORDER BY captured_at DESC`
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := db.Query(query, actor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var growth []FollowerGrowth
+	for rows.Next() {
+		var capturedAt time.Time
+		var g FollowerGrowth
+		if err := rows.Scan(&capturedAt, &g.Followers, &g.Follows, &g.Posts); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		g.CapturedAt = capturedAt.In(loc).Format(time.RFC3339)
+		growth = append(growth, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+
+	// the query reads newest first so the limit keeps the latest snapshots
+	for i, j := 0, len(growth)-1; i < j; i, j = i+1, j-1 {
+		growth[i], growth[j] = growth[j], growth[i]
+	}
+	chartGrowth(growth)
+	return growth, nil
+}
+
+// chartGrowth sets the change and bar of each snapshot. bars are scaled between the lowest and highest follower
+// counts, so small changes of large accounts stay visible.
+func chartGrowth(growth []FollowerGrowth) {
+	if len(growth) == 0 {
+		return
+	}
+
+	low, high := growth[0].Followers, growth[0].Followers
+	for _, g := range growth {
+		if g.Followers < low {
+			low = g.Followers
+		}
+		if g.Followers > high {
+			high = g.Followers
+		}
+	}
+
+	for i := range growth {
+		if i > 0 {
+			growth[i].Change = growth[i].Followers - growth[i-1].Followers
+		}
+		width := growthChartWidth
+		if high > low {
+			width = 1 + (growthChartWidth-1)*(growth[i].Followers-low)/(high-low)
+		}
+		growth[i].Chart = strings.Repeat("#", width)
+	}
+}
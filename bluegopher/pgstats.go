@@ -0,0 +1,96 @@
+package bluegopher
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// itemHandleExpr and itemDIDExpr are the handle and DID of a profile, post, or feed item in the bluesky table
+const (
+	itemHandleExpr = `COALESCE(data->>'handle', data#>>'{author,handle}', data#>>'{post,author,handle}')`
+	itemDIDExpr    = `COALESCE(data->>'did', data#>>'{author,did}', data#>>'{post,author,did}')`
+)
+
+// TableStats is the size of a table or materialized view. Rows is the planner's estimate, which is -1 for a table
+// that was never analyzed.
+type TableStats struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	Bytes int64  `json:"bytes"`
+	Size  string `json:"size"`
+}
+
+// SourceStats summarizes the rows of the bluesky table imported under one name. Oldest and Newest are zero when none
+// of its rows has a created_at.
+type SourceStats struct {
+	Name    string    `json:"name"`
+	Rows    int64     `json:"rows"`
+	Oldest  time.Time `json:"oldest"`
+	Newest  time.Time `json:"newest"`
+	Handles int64     `json:"handles"`
+	DIDs    int64     `json:"dids"`
+}
+
+// DatabaseStats is the overview output by pg:stats
+type DatabaseStats struct {
+	Tables  []TableStats  `json:"tables"`
+	Sources []SourceStats `json:"sources"`
+}
+
+// tableStats returns the sizes of the tables and materialized views of the current schema, largest first
+func tableStats(db *sql.DB) ([]TableStats, error) {
+	rows, err := db.Query(`SELECT c.relname, c.reltuples::bigint, pg_total_relation_size(c.oid),
+			pg_size_pretty(pg_total_relation_size(c.oid))
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'm') AND n.nspname = current_schema()
+		ORDER BY pg_total_relation_size(c.oid) DESC, c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table sizes: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []TableStats
+	for rows.Next() {
+		var t TableStats
+		if err := rows.Scan(&t.Table, &t.Rows, &t.Bytes, &t.Size); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+	return tables, nil
+}
+
+// sourceStats returns the row counts, import times, and distinct accounts per name of a table of JSON lines
+func sourceStats(db *sql.DB, table string) ([]SourceStats, error) {
+	rows, err := db.Query(`SELECT COALESCE(name, ''), count(*), min(created_at), max(created_at),
+			count(DISTINCT ` + itemHandleExpr + `), count(DISTINCT ` + itemDIDExpr + `)
+		FROM ` + pq.QuoteIdentifier(table) + `
+		GROUP BY name
+		ORDER BY count(*) DESC, name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sources: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []SourceStats
+	for rows.Next() {
+		// min and max are NULL when no row of the source has a created_at
+		var s SourceStats
+		var oldest, newest sql.NullTime
+		if err := rows.Scan(&s.Name, &s.Rows, &oldest, &newest, &s.Handles, &s.DIDs); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		s.Oldest, s.Newest = oldest.Time, newest.Time
+		sources = append(sources, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+	return sources, nil
+}
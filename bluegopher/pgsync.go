@@ -0,0 +1,138 @@
+package bluegopher
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// upsertRows upserts items into a typed table in a single transaction. key is the name, subject, or list column of
+// the rows.
+func upsertRows(db *sql.DB, table, key string, items []interface{}) error {
+	query, err := upsertQuery(table)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		if _, err := stmt.Exec(key, string(data)); err != nil {
+			return fmt.Errorf("failed to upsert into %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// openSync connects to the database and creates the typed tables the sync targets write to
+func openSync() (*sql.DB, error) {
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// SyncState is the progress of a sync target for one endpoint and actor or query. Cursor is the next page of an
+// unfinished run, and Newest the watermark of the last complete run: the newest indexedAt of a feed, or the
+// space-separated DIDs of the newest followers.
+type SyncState struct {
+	Endpoint string
+	Key      string
+	Cursor   string
+	Newest   string
+
+	db *sql.DB
+}
+
+// loadSyncState reads the sync state of an endpoint and key. a source that was never synced has an empty state.
+func loadSyncState(db *sql.DB, endpoint, key string) (*SyncState, error) {
+	s := &SyncState{Endpoint: endpoint, Key: key, db: db}
+	err := db.QueryRow("SELECT cursor, newest FROM sync_state WHERE endpoint = $1 AND key = $2", endpoint, key).Scan(&s.Cursor, &s.Newest)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	if s.Cursor != "" {
+		log.Printf("resuming %s %s from cursor %s\n", endpoint, key, s.Cursor)
+	}
+	return s, nil
+}
+
+// save writes the sync state
+func (s *SyncState) save(syncedAt interface{}) error {
+	_, err := s.db.Exec(`INSERT INTO sync_state (endpoint, key, cursor, newest, synced_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (endpoint, key) DO UPDATE SET cursor = EXCLUDED.cursor, newest = EXCLUDED.newest,
+			synced_at = COALESCE(EXCLUDED.synced_at, sync_state.synced_at), updated_at = CURRENT_TIMESTAMP`,
+		s.Endpoint, s.Key, s.Cursor, s.Newest, syncedAt)
+	if err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+// NextPage records the cursor of the next page, so an interrupted run resumes there
+func (s *SyncState) NextPage(cursor string) error {
+	s.Cursor = cursor
+	return s.save(nil)
+}
+
+// Done records a complete run with the watermark of its newest record
+func (s *SyncState) Done(newest string) error {
+	s.Cursor = ""
+	s.Newest = newest
+	return s.save(time.Now().UTC())
+}
+
+// Watermark returns the newest indexedAt of the last complete run, zero when the source was never fully synced
+func (s *SyncState) Watermark() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s.Newest)
+	return t
+}
+
+// formatWatermark formats the newest indexedAt of a run, empty when the run found no records
+func formatWatermark(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// feedItemTime returns when a feed item was added to the feed: the time of the repost, or the post's indexedAt
+func feedItemTime(item FeedViewPost) time.Time {
+	indexedAt := item.Post.IndexedAt
+	if len(item.Reason) > 0 {
+		var reason struct {
+			IndexedAt string `json:"indexedAt"`
+		}
+		if err := json.Unmarshal(item.Reason, &reason); err == nil && reason.IndexedAt != "" {
+			indexedAt = reason.IndexedAt
+		}
+	}
+	t, _ := time.Parse(time.RFC3339Nano, indexedAt)
+	return t
+}
@@ -0,0 +1,121 @@
+package bluegopher
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// embeddingStatements create the post_embeddings table. pgvector is an extension that may not be installed, so the
+// table is created on demand rather than by a migration. the vector column has no fixed dimensions, since they
+// depend on the model, so nearest-neighbor searches scan the embeddings of one model without an index.
+var embeddingStatements = []string{
+	`CREATE EXTENSION IF NOT EXISTS vector`,
+	`CREATE TABLE IF NOT EXISTS post_embeddings (
+		uri TEXT NOT NULL,
+		model TEXT NOT NULL,
+		embedding VECTOR NOT NULL,
+		embedded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (uri, model)
+	)`,
+}
+
+// createEmbeddingsTable creates the post_embeddings table and the pgvector extension
+func createEmbeddingsTable(db *sql.DB) error {
+	if err := migrate(db); err != nil {
+		return err
+	}
+	for _, statement := range embeddingStatements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create post_embeddings, is pgvector installed? %w", err)
+		}
+	}
+	return nil
+}
+
+// embedPosts computes the missing embeddings of the posts table with an embedder, at most limit posts when limit > 0.
+// it returns the number of posts embedded.
+func embedPosts(ctx context.Context, db *sql.DB, embedder Embedder, limit int, progress *Progress) (int, error) {
+	embedded := 0
+	for limit == 0 || embedded < limit {
+		batch := embeddingBatchSize
+		if limit > 0 && limit-embedded < batch {
+			batch = limit - embedded
+		}
+
+		uris, texts, err := postsWithoutEmbeddings(db, embedder.Model(), batch)
+		if err != nil {
+			return embedded, err
+		}
+		if len(uris) == 0 {
+			break
+		}
+
+		embeddings, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return embedded, err
+		}
+		if err := storeEmbeddings(db, embedder.Model(), uris, embeddings); err != nil {
+			return embedded, err
+		}
+
+		embedded += len(uris)
+		progress.Page()
+		progress.Items(len(uris))
+	}
+	return embedded, nil
+}
+
+// postsWithoutEmbeddings returns posts with text that have no embedding of a model yet
+func postsWithoutEmbeddings(db *sql.DB, model string, limit int) ([]string, []string, error) {
+	rows, err := db.Query(`SELECT p.uri, p.data#>>'{record,text}' FROM posts p
+		WHERE p.uri IS NOT NULL AND COALESCE(p.data#>>'{record,text}', '') <> ''
+			AND NOT EXISTS (SELECT 1 FROM post_embeddings e WHERE e.uri = p.uri AND e.model = $1)
+		ORDER BY p.id
+		LIMIT $2`, model, limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var uris, texts []string
+	for rows.Next() {
+		var uri, text string
+		if err := rows.Scan(&uri, &text); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		uris = append(uris, uri)
+		texts = append(texts, text)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error occurred during row iteration: %w", err)
+	}
+	return uris, texts, nil
+}
+
+// storeEmbeddings upserts the embeddings of a batch of posts in a single transaction
+func storeEmbeddings(db *sql.DB, model string, uris []string, embeddings [][]float32) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO post_embeddings (uri, model, embedding) VALUES ($1, $2, $3::vector)
+		ON CONFLICT (uri, model) DO UPDATE SET embedding = EXCLUDED.embedding, embedded_at = CURRENT_TIMESTAMP`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, uri := range uris {
+		if _, err := stmt.Exec(uri, model, vectorLiteral(embeddings[i])); err != nil {
+			return fmt.Errorf("failed to store embedding: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
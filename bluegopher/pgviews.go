@@ -0,0 +1,62 @@
+package bluegopher
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// analyticsViews are the materialized views over the typed tables that migration 0008 creates
+var analyticsViews = []string{"top_posters", "daily_post_volume", "follower_counts", "engagement_leaders"}
+
+// createAnalyticsViews applies the pending migrations, which create the views empty, and populates the views that
+// were never refreshed
+func createAnalyticsViews(db *sql.DB) error {
+	if err := migrate(db); err != nil {
+		return err
+	}
+
+	for _, view := range analyticsViews {
+		populated, err := viewPopulated(db, view)
+		if err != nil {
+			return err
+		}
+		if populated {
+			continue
+		}
+		if _, err := db.Exec("REFRESH MATERIALIZED VIEW " + view); err != nil {
+			return fmt.Errorf("failed to populate view %s: %w", view, err)
+		}
+	}
+	return nil
+}
+
+// refreshAnalyticsViews refreshes the materialized views without blocking queries against them. a view that was
+// never populated cannot be refreshed concurrently, so it is refreshed in full.
+func refreshAnalyticsViews(db *sql.DB) error {
+	for _, view := range analyticsViews {
+		populated, err := viewPopulated(db, view)
+		if err != nil {
+			return err
+		}
+		query := "REFRESH MATERIALIZED VIEW CONCURRENTLY " + view
+		if !populated {
+			query = "REFRESH MATERIALIZED VIEW " + view
+		}
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to refresh view %s: %w", view, err)
+		}
+		log.Printf("refreshed view %s\n", view)
+	}
+	return nil
+}
+
+// viewPopulated reports whether a materialized view holds data
+func viewPopulated(db *sql.DB, view string) (bool, error) {
+	var populated bool
+	err := db.QueryRow("SELECT ispopulated FROM pg_matviews WHERE schemaname = current_schema() AND matviewname = $1", view).Scan(&populated)
+	if err != nil {
+		return false, fmt.Errorf("failed to check view %s: %w", view, err)
+	}
+	return populated, nil
+}
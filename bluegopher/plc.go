@@ -0,0 +1,255 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PLCClient reads DID documents and their operation logs from a PLC directory
+type PLCClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewPLCClient returns a client of the directory of PLC_DIRECTORY, or of plc.directory
+func NewPLCClient(httpClient *http.Client) *PLCClient {
+	return &PLCClient{BaseURL: plcDirectory(), HTTPClient: httpClient}
+}
+
+// PLCService is a service of a PLC operation, such as the atproto_pds of an account
+type PLCService struct {
+	Type     string `json:"type"`
+	Endpoint string `json:"endpoint"`
+}
+
+// PLCOperation is a signed operation of a DID: plc_operation sets the whole state of the DID, plc_tombstone
+// deactivates it, and the legacy create operation of 2022 accounts names a single handle, key, and PDS
+type PLCOperation struct {
+	Type                string                `json:"type"`
+	RotationKeys        []string              `json:"rotationKeys,omitempty"`
+	VerificationMethods map[string]string     `json:"verificationMethods,omitempty"`
+	AlsoKnownAs         []string              `json:"alsoKnownAs,omitempty"`
+	Services            map[string]PLCService `json:"services,omitempty"`
+	Prev                *string               `json:"prev"`
+	Sig                 string                `json:"sig"`
+
+	// the fields of the legacy create operation
+	SigningKey  string `json:"signingKey,omitempty"`
+	RecoveryKey string `json:"recoveryKey,omitempty"`
+	Handle      string `json:"handle,omitempty"`
+	Service     string `json:"service,omitempty"`
+}
+
+// PLCState is the state of a DID after an operation, with the legacy create operation normalized to the fields of
+// plc_operation
+type PLCState struct {
+	DID                 string                `json:"did"`
+	RotationKeys        []string              `json:"rotationKeys"`
+	VerificationMethods map[string]string     `json:"verificationMethods"`
+	AlsoKnownAs         []string              `json:"alsoKnownAs"`
+	Services            map[string]PLCService `json:"services"`
+}
+
+// State returns the state of a DID set by an operation, which is empty for a tombstone
+func (op PLCOperation) State() PLCState {
+	if op.Type != "create" {
+		return PLCState{RotationKeys: op.RotationKeys, VerificationMethods: op.VerificationMethods, AlsoKnownAs: op.AlsoKnownAs, Services: op.Services}
+	}
+	return PLCState{
+		RotationKeys:        []string{op.RecoveryKey, op.SigningKey},
+		VerificationMethods: map[string]string{"atproto": op.SigningKey},
+		AlsoKnownAs:         []string{"at://" + op.Handle},
+		Services:            map[string]PLCService{"atproto_pds": {Type: "AtprotoPersonalDataServer", Endpoint: op.Service}},
+	}
+}
+
+// Handle returns the handle of the state, without the at:// prefix
+func (s PLCState) Handle() string {
+	for _, aka := range s.AlsoKnownAs {
+		if handle, ok := strings.CutPrefix(aka, "at://"); ok {
+			return handle
+		}
+	}
+	return ""
+}
+
+// PDS returns the endpoint of the atproto_pds service of the state
+func (s PLCState) PDS() string {
+	return s.Services["atproto_pds"].Endpoint
+}
+
+// PLCLogEntry is an operation of the audit log of a DID. nullified operations were overridden by a rotation key of
+// higher priority within 72 hours, and are not part of the current history.
+type PLCLogEntry struct {
+	DID       string       `json:"did"`
+	Operation PLCOperation `json:"operation"`
+	CID       string       `json:"cid"`
+	Nullified bool         `json:"nullified"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// get fetches a path of a did:plc from the directory and decodes its JSON
+func (p *PLCClient) get(ctx context.Context, did, path string, v interface{}) error {
+	if !strings.HasPrefix(did, "did:plc:") {
+		return fmt.Errorf("%s is not a did:plc: only did:plc identifiers are in the PLC directory", did)
+	}
+	body, err := httpGet(ctx, p.HTTPClient, strings.TrimSuffix(p.BaseURL, "/")+"/"+url.PathEscape(did)+path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to unmarshal PLC directory response: %w", err)
+	}
+	return nil
+}
+
+// Resolve returns the DID document of a DID
+func (p *PLCClient) Resolve(ctx context.Context, did string) (*DIDDocument, error) {
+	var doc DIDDocument
+	if err := p.get(ctx, did, "", &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Data returns the current state of a DID
+func (p *PLCClient) Data(ctx context.Context, did string) (*PLCState, error) {
+	var state PLCState
+	if err := p.get(ctx, did, "/data", &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Log returns the operations of the current history of a DID, oldest first
+func (p *PLCClient) Log(ctx context.Context, did string) ([]PLCOperation, error) {
+	var ops []PLCOperation
+	if err := p.get(ctx, did, "/log", &ops); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// AuditLog returns every operation of a DID, including the nullified ones, oldest first
+func (p *PLCClient) AuditLog(ctx context.Context, did string) ([]PLCLogEntry, error) {
+	var entries []PLCLogEntry
+	if err := p.get(ctx, did, "/log/audit", &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// plcChanges describes what an operation changed from the previous state of the DID
+func plcChanges(prev *PLCState, op PLCOperation) []string {
+	if op.Type == "plc_tombstone" {
+		return []string{"deactivated the DID"}
+	}
+	state := op.State()
+	if prev == nil {
+		return []string{fmt.Sprintf("created with handle %s on %s", state.Handle(), state.PDS())}
+	}
+
+	var changes []string
+	if prev.Handle() != state.Handle() {
+		changes = append(changes, fmt.Sprintf("handle %s -> %s", prev.Handle(), state.Handle()))
+	}
+	if prev.PDS() != state.PDS() {
+		changes = append(changes, fmt.Sprintf("pds %s -> %s", prev.PDS(), state.PDS()))
+	}
+	if prev.VerificationMethods["atproto"] != state.VerificationMethods["atproto"] {
+		changes = append(changes, "signing key changed")
+	}
+	if strings.Join(prev.RotationKeys, ",") != strings.Join(state.RotationKeys, ",") {
+		changes = append(changes, fmt.Sprintf("rotation keys changed (%d -> %d)", len(prev.RotationKeys), len(state.RotationKeys)))
+	}
+	if strings.Join(prev.AlsoKnownAs, ",") != strings.Join(state.AlsoKnownAs, ",") && prev.Handle() == state.Handle() {
+		changes = append(changes, "alsoKnownAs changed")
+	}
+
+	// services other than the PDS, such as the atproto_labeler of a labeler account
+	names := make(map[string]bool)
+	for name := range prev.Services {
+		names[name] = true
+	}
+	for name := range state.Services {
+		names[name] = true
+	}
+	var services []string
+	for name := range names {
+		if name != "atproto_pds" && prev.Services[name] != state.Services[name] {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	for _, name := range services {
+		changes = append(changes, fmt.Sprintf("service %s %s -> %s", name, prev.Services[name].Endpoint, state.Services[name].Endpoint))
+	}
+
+	if len(changes) == 0 {
+		changes = append(changes, "no change")
+	}
+	return changes
+}
+
+// DidHistory <did> prints the audit log of a did:plc, or of the DID of a handle, from the PLC directory: one item per
+// operation with its time, the handle and PDS it set, and what it changed, such as handle changes and account
+// migrations between PDS hosts. nullified operations, overridden by a recovery, are listed but do not change the state
+// the next operation is compared against.
+func (Bs) DidHistory(ctx context.Context, did string) (err error) {
+	opts, err := ClientOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	httpClient, err := newHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(did, "did:") {
+		handle := did
+		if did, err = resolveHandleToDID(ctx, httpClient, handle); err != nil {
+			return err
+		}
+		log.Printf("resolved %s to %s\n", handle, did)
+	}
+
+	entries, err := NewPLCClient(httpClient).AuditLog(ctx, did)
+	if err != nil {
+		return fmt.Errorf("failed to get the audit log of %s: %w", did, err)
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	var prev *PLCState
+	for _, entry := range entries {
+		changes := plcChanges(prev, entry.Operation)
+		state := entry.Operation.State()
+		item := map[string]interface{}{
+			"createdAt": entry.CreatedAt.Format(time.RFC3339),
+			"cid":       entry.CID,
+			"type":      entry.Operation.Type,
+			"handle":    state.Handle(),
+			"pds":       state.PDS(),
+			"nullified": entry.Nullified,
+			"changes":   strings.Join(changes, "; "),
+		}
+		if err := out.Emit(item); err != nil {
+			return err
+		}
+		if !entry.Nullified {
+			prev = &state
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testAuditLog is the audit log of an account created in 2022, which changed its handle, had a change nullified by
+// its recovery key, and migrated to another PDS
+const testAuditLog = `[
+  {"did":"did:plc:alice","cid":"bafyop1","nullified":false,"createdAt":"2022-11-17T00:00:00.000Z",
+   "operation":{"type":"create","signingKey":"did:key:zsigning1","recoveryKey":"did:key:zrecovery","handle":"alice.bsky.social","service":"https://bsky.social","prev":null,"sig":"s1"}},
+  {"did":"did:plc:alice","cid":"bafyop2","nullified":false,"createdAt":"2023-04-01T00:00:00.000Z",
+   "operation":{"type":"plc_operation","rotationKeys":["did:key:zrecovery","did:key:zsigning1"],"verificationMethods":{"atproto":"did:key:zsigning1"},"alsoKnownAs":["at://alice.test"],"services":{"atproto_pds":{"type":"AtprotoPersonalDataServer","endpoint":"https://bsky.social"}},"prev":"bafyop1","sig":"s2"}},
+  {"did":"did:plc:alice","cid":"bafyop3","nullified":true,"createdAt":"2023-05-01T00:00:00.000Z",
+   "operation":{"type":"plc_operation","rotationKeys":["did:key:zattacker"],"verificationMethods":{"atproto":"did:key:zattacker"},"alsoKnownAs":["at://evil.test"],"services":{"atproto_pds":{"type":"AtprotoPersonalDataServer","endpoint":"https://evil.test"}},"prev":"bafyop2","sig":"s3"}},
+  {"did":"did:plc:alice","cid":"bafyop4","nullified":false,"createdAt":"2024-02-01T00:00:00.000Z",
+   "operation":{"type":"plc_operation","rotationKeys":["did:key:zrecovery","did:key:zsigning2"],"verificationMethods":{"atproto":"did:key:zsigning2"},"alsoKnownAs":["at://alice.test"],"services":{"atproto_pds":{"type":"AtprotoPersonalDataServer","endpoint":"https://pds.alice.test"},"atproto_labeler":{"type":"AtprotoLabeler","endpoint":"https://labeler.alice.test"}},"prev":"bafyop2","sig":"s4"}}
+]`
+
+// newFakePLC serves the audit log of did:plc:alice and points PLC_DIRECTORY at it
+func newFakePLC(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /did:plc:alice/log/audit", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testAuditLog))
+	})
+	mux.HandleFunc("GET /did:plc:alice/data", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"did":"did:plc:alice","alsoKnownAs":["at://alice.test"],"services":{"atproto_pds":{"type":"AtprotoPersonalDataServer","endpoint":"https://pds.alice.test"}}}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	t.Setenv("PLC_DIRECTORY", server.URL)
+	return server
+}
+
+func TestPLCClient(t *testing.T) {
+	newFakePLC(t)
+	plc := NewPLCClient(http.DefaultClient)
+	ctx := context.Background()
+
+	entries, err := plc.AuditLog(ctx, "did:plc:alice")
+	if err != nil || len(entries) != 4 || !entries[2].Nullified || entries[0].CreatedAt.Year() != 2022 {
+		t.Fatalf("AuditLog = %+v, %v", entries, err)
+	}
+	if state := entries[0].Operation.State(); state.Handle() != "alice.bsky.social" || state.PDS() != "https://bsky.social" || state.VerificationMethods["atproto"] != "did:key:zsigning1" {
+		t.Errorf("state of the legacy create = %+v", state)
+	}
+
+	data, err := plc.Data(ctx, "did:plc:alice")
+	if err != nil || data.Handle() != "alice.test" || data.PDS() != "https://pds.alice.test" {
+		t.Errorf("Data = %+v, %v", data, err)
+	}
+
+	if _, err := plc.Log(ctx, "did:plc:bob"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Log of an unknown DID: err = %v", err)
+	}
+	if _, err := plc.AuditLog(ctx, "did:web:alice.test"); err == nil {
+		t.Error("AuditLog accepted a did:web")
+	}
+}
+
+func TestDidHistory(t *testing.T) {
+	newFakePLC(t)
+	output := filepath.Join(t.TempDir(), "history.jsonl")
+	t.Setenv("BG_OUTPUT", output)
+	t.Setenv("BG_FORMAT", "jsonl")
+	t.Setenv("BG_PROFILE", "")
+	t.Setenv("BLUESKY_HANDLE", "")
+	t.Setenv("BG_CONFIG_DIR", t.TempDir())
+
+	if err := (Bs{}).DidHistory(context.Background(), "did:plc:alice"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var item struct {
+			Changes   string `json:"changes"`
+			Nullified bool   `json:"nullified"`
+		}
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, item.Changes)
+	}
+
+	want := []string{
+		"created with handle alice.bsky.social on https://bsky.social",
+		"handle alice.bsky.social -> alice.test",
+		"handle alice.test -> evil.test; pds https://bsky.social -> https://evil.test; signing key changed; rotation keys changed (2 -> 1)",
+		// compared against the state before the nullified operation
+		"pds https://bsky.social -> https://pds.alice.test; signing key changed; rotation keys changed (2 -> 2); service atproto_labeler  -> https://labeler.alice.test",
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes =\n%s\nwant\n%s", strings.Join(changes, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,206 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPostLength is the maximum length of post text in graphemes. lengths are counted in runes, which is never less
+// than the number of graphemes, so text within the limit in runes is always accepted.
+const maxPostLength = 300
+
+// ReplyRef represents the reply reference of an app.bsky.feed.post record
+type ReplyRef struct {
+	Root   StrongRef `json:"root"`
+	Parent StrongRef `json:"parent"`
+}
+
+// Post creates an app.bsky.feed.post record and returns its reference. mentions and links in the text are turned into
+// facets unless the post already has facets.
+func (c *Client) Post(ctx context.Context, post PostRecord) (StrongRef, error) {
+	post.Type = "app.bsky.feed.post"
+	if post.CreatedAt == "" {
+		post.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	if post.Facets == nil {
+		facets, err := c.DetectFacets(ctx, post.Text)
+		if err != nil {
+			return StrongRef{}, err
+		}
+		if len(facets) > 0 {
+			if post.Facets, err = json.Marshal(facets); err != nil {
+				return StrongRef{}, err
+			}
+		}
+	}
+
+	resp, err := c.CreateRecord(ctx, CreateRecordRequest{
+		Repo:       c.Session.DID,
+		Collection: "app.bsky.feed.post",
+		Record:     post,
+	})
+	if err != nil {
+		return StrongRef{}, err
+	}
+
+	uri, _ := resp["uri"].(string)
+	cid, _ := resp["cid"].(string)
+	if uri == "" || cid == "" {
+		return StrongRef{}, fmt.Errorf("failed to get uri and cid of the created post")
+	}
+	return StrongRef{URI: uri, CID: cid}, nil
+}
+
+// subjectRecord is the record of an app.bsky.feed.like or app.bsky.feed.repost
+type subjectRecord struct {
+	Type      string    `json:"$type"`
+	Subject   StrongRef `json:"subject"`
+	CreatedAt string    `json:"createdAt"`
+}
+
+// Like creates a like record for a post
+func (c *Client) Like(ctx context.Context, subject StrongRef) (map[string]interface{}, error) {
+	return c.CreateRecord(ctx, CreateRecordRequest{
+		Repo:       c.Session.DID,
+		Collection: "app.bsky.feed.like",
+		Record: subjectRecord{
+			Type:      "app.bsky.feed.like",
+			Subject:   subject,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// Repost creates a repost record for a post
+func (c *Client) Repost(ctx context.Context, subject StrongRef) (map[string]interface{}, error) {
+	return c.CreateRecord(ctx, CreateRecordRequest{
+		Repo:       c.Session.DID,
+		Collection: "app.bsky.feed.repost",
+		Record: subjectRecord{
+			Type:      "app.bsky.feed.repost",
+			Subject:   subject,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// PostThread posts the parts of a thread as a reply chain and returns their references
+func (c *Client) PostThread(ctx context.Context, parts []string) ([]StrongRef, error) {
+	refs := make([]StrongRef, 0, len(parts))
+	for i, text := range parts {
+		post := PostRecord{Text: text}
+		if i > 0 {
+			post.Reply = &ReplyRef{Root: refs[0], Parent: refs[i-1]}
+		}
+
+		ref, err := c.Post(ctx, post)
+		if err != nil {
+			return refs, fmt.Errorf("failed to post part %d of %d: %w", i+1, len(parts), err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// splitThread splits text into numbered parts of at most limit runes, at sentence boundaries where possible, then at
+// word boundaries. a single part is not numbered.
+func splitThread(text string, limit int) []string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+
+	// reserve room for the " n/total" suffix, growing it until the numbering fits
+	reserve := len(" 9/9")
+	for {
+		parts := packText(text, limit-reserve)
+		suffix := fmt.Sprintf(" %d/%d", len(parts), len(parts))
+		if len(suffix) <= reserve {
+			for i := range parts {
+				parts[i] = fmt.Sprintf("%s %d/%d", parts[i], i+1, len(parts))
+			}
+			return parts
+		}
+		reserve = len(suffix)
+	}
+}
+
+// packText greedily packs the sentences of text into parts of at most limit runes
+func packText(text string, limit int) []string {
+	var parts []string
+	var current strings.Builder
+	flush := func() {
+		if part := strings.TrimSpace(current.String()); part != "" {
+			parts = append(parts, part)
+		}
+		current.Reset()
+	}
+
+	for _, sentence := range splitSentences(text) {
+		if utf8.RuneCountInString(strings.TrimSpace(current.String()+sentence)) <= limit {
+			current.WriteString(sentence)
+			continue
+		}
+		flush()
+		if utf8.RuneCountInString(strings.TrimSpace(sentence)) <= limit {
+			current.WriteString(sentence)
+			continue
+		}
+
+		// the sentence alone is too long: split it between words, and words longer than a part between runes
+		for _, word := range strings.Fields(sentence) {
+			for utf8.RuneCountInString(word) > limit {
+				flush()
+				runes := []rune(word)
+				parts = append(parts, string(runes[:limit]))
+				word = string(runes[limit:])
+			}
+			if current.Len() > 0 && utf8.RuneCountInString(current.String())+1+utf8.RuneCountInString(word) > limit {
+				flush()
+			}
+			if current.Len() > 0 {
+				current.WriteByte(' ')
+			}
+			current.WriteString(word)
+		}
+		current.WriteByte(' ')
+	}
+	flush()
+
+	return parts
+}
+
+// splitSentences splits text after sentence-ending punctuation and line breaks, keeping the following whitespace with
+// each sentence so joined sentences keep their original spacing
+func splitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '.', '!', '?', '\n':
+		default:
+			continue
+		}
+		if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		sentences = append(sentences, string(runes[start:end]))
+		start = end
+		i = end - 1
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return sentences
+}
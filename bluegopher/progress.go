@@ -0,0 +1,138 @@
+package bluegopher
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress periodically reports the throughput of a long-running target to stderr, and a summary when it stops. the
+// interval is set with BG_PROGRESS (default 10s, 0 disables the periodic reports).
+type Progress struct {
+	name   string
+	client *Client
+	start  time.Time
+	// requests and failed are the client request counts when the target started
+	requests int64
+	failed   int64
+
+	pages atomic.Int64
+	items atomic.Int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	// span traces the target, and parents the spans of its requests
+	span *Span
+}
+
+// StartProgress starts reporting the progress of a target. client may be nil for targets that do not use the API.
+func StartProgress(name string, client *Client) *Progress {
+	p := &Progress{
+		name:   name,
+		client: client,
+		start:  time.Now(),
+		stop:   make(chan struct{}),
+	}
+	if client != nil {
+		p.requests, p.failed = client.RequestStats()
+	}
+
+	p.span = startTargetSpan(name)
+
+	interval, err := envDuration("BG_PROGRESS", 10*time.Second)
+	if err != nil {
+		log.Printf("%v: using 10s\n", err)
+	}
+	if interval <= 0 {
+		return p
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				log.Println(p.String())
+			}
+		}
+	}()
+
+	return p
+}
+
+// Page records a fetched page
+func (p *Progress) Page() {
+	n := p.pages.Add(1)
+	p.span.AddEvent("page", map[string]interface{}{"page": n, "items": p.items.Load()})
+}
+
+// Items records emitted items
+func (p *Progress) Items(n int) {
+	p.items.Add(int64(n))
+	metrics.Add(metricItems, float64(n), p.name)
+}
+
+// String formats the current progress
+func (p *Progress) String() string {
+	elapsed := time.Since(p.start)
+	items := p.items.Load()
+	rate := float64(items) / elapsed.Seconds()
+
+	s := fmt.Sprintf("%s: %d pages | %d items | %.1f items/sec | %s elapsed", p.name, p.pages.Load(), items, rate, elapsed.Round(time.Second))
+	if p.client != nil {
+		if rl := p.client.RateLimitStatus(); rl.Limit > 0 {
+			s += fmt.Sprintf(" | rate limit %d/%d remaining", rl.Remaining, rl.Limit)
+		}
+	}
+	return s
+}
+
+// Summary formats the totals of the run
+func (p *Progress) Summary() string {
+	elapsed := time.Since(p.start)
+	items := p.items.Load()
+
+	s := fmt.Sprintf("%s summary: %d items | %d pages", p.name, items, p.pages.Load())
+	if p.client != nil {
+		requests, failed := p.client.RequestStats()
+		s += fmt.Sprintf(" | %d requests | %d errors", requests-p.requests, failed-p.failed)
+	}
+	s += fmt.Sprintf(" | %s elapsed | %.1f items/sec", elapsed.Round(time.Millisecond), float64(items)/elapsed.Seconds())
+	if p.client != nil {
+		if rl := p.client.RateLimitStatus(); rl.Limit > 0 {
+			s += fmt.Sprintf(" | rate limit %d/%d remaining", rl.Remaining, rl.Limit)
+			if wait := time.Until(rl.Reset); wait > 0 {
+				s += fmt.Sprintf(", resets in %s", wait.Round(time.Second))
+			}
+		}
+	}
+	return s
+}
+
+// Stop stops the periodic reports, logs the summary, and ends the span of the target with its error. it is deferred
+// with the named error result of the target, as defer progress.Stop(&err).
+func (p *Progress) Stop(err *error) {
+	select {
+	case <-p.stop:
+		return
+	default:
+		close(p.stop)
+	}
+	p.wg.Wait()
+	log.Println(p.Summary())
+
+	p.span.SetAttr("bluegopher.pages", p.pages.Load())
+	p.span.SetAttr("bluegopher.items", p.items.Load())
+	var targetErr error
+	if err != nil {
+		targetErr = *err
+	}
+	endTargetSpan(p.span, targetErr)
+}
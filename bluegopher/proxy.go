@@ -0,0 +1,31 @@
+package bluegopher
+
+import (
+	"context"
+)
+
+// atproto-proxy service references for services reachable through the PDS
+const (
+	AppViewProxy = "did:web:api.bsky.app#bsky_appview"
+	ChatProxy    = "did:web:api.bsky.chat#bsky_chat"
+)
+
+// proxyKey is the context key holding the atproto-proxy header value
+type proxyKey struct{}
+
+// LabelerProxy returns the atproto-proxy service reference for a labeler DID
+func LabelerProxy(did string) string {
+	return did + "#atproto_labeler"
+}
+
+// WithProxy returns a context that routes requests made with it to the given service via the atproto-proxy header,
+// e.g. WithProxy(ctx, ChatProxy) or WithProxy(ctx, LabelerProxy(did))
+func WithProxy(ctx context.Context, proxy string) context.Context {
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// proxyFromContext returns the atproto-proxy header value set with WithProxy
+func proxyFromContext(ctx context.Context) string {
+	proxy, _ := ctx.Value(proxyKey{}).(string)
+	return proxy
+}
@@ -0,0 +1,87 @@
+package bluegopher
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// rateLimitReserve is the remaining request budget at which requests wait for the window to reset
+	rateLimitReserve = 5
+	// maxRateLimitRetries is the number of times a 429 response is retried
+	maxRateLimitRetries = 5
+	// defaultRateLimitWait is used when a 429 response carries no reset information
+	defaultRateLimitWait = 60 * time.Second
+)
+
+// RateLimit is the most recent rate-limit state reported by the ratelimit-* response headers
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Policy    string
+}
+
+// RateLimitStatus returns the most recent rate-limit state seen by the client
+func (c *Client) RateLimitStatus() RateLimit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rateLimit
+}
+
+// updateRateLimit records the rate-limit state from the response headers, if present
+func (c *Client) updateRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("ratelimit-remaining"))
+	if err != nil {
+		return
+	}
+
+	rl := RateLimit{
+		Remaining: remaining,
+		Policy:    header.Get("ratelimit-policy"),
+	}
+	if limit, err := strconv.Atoi(header.Get("ratelimit-limit")); err == nil {
+		rl.Limit = limit
+	}
+	if reset, err := strconv.ParseInt(header.Get("ratelimit-reset"), 10, 64); err == nil {
+		rl.Reset = time.Unix(reset, 0)
+	}
+
+	c.mu.Lock()
+	c.rateLimit = rl
+	c.mu.Unlock()
+	metrics.Set(metricRateLimit, float64(remaining))
+}
+
+// waitForRateLimit sleeps until the rate-limit window resets when the remaining budget is nearly exhausted
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	rl := c.RateLimitStatus()
+	if rl.Reset.IsZero() || rl.Remaining > rateLimitReserve {
+		return nil
+	}
+
+	wait := time.Until(rl.Reset)
+	if wait <= 0 {
+		return nil
+	}
+
+	log.Printf("rate limit nearly exhausted (%d/%d remaining): waiting %s for reset\n", rl.Remaining, rl.Limit, wait.Round(time.Second))
+	spanFromContext(ctx).AddEvent("rate limit wait", map[string]interface{}{"wait_ms": wait.Milliseconds()})
+	return sleepContext(ctx, wait)
+}
+
+// rateLimitWait returns how long to wait before retrying a 429 response
+func rateLimitWait(header http.Header) time.Duration {
+	if reset, err := strconv.ParseInt(header.Get("ratelimit-reset"), 10, 64); err == nil {
+		if wait := time.Until(time.Unix(reset, 0)); wait > 0 {
+			return wait + time.Second
+		}
+	}
+	if seconds, err := strconv.Atoi(header.Get("retry-after")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultRateLimitWait
+}
@@ -0,0 +1,106 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// applyWritesBatchSize is the number of operations sent in one applyWrites request. the PDS accepts up to 200.
+const applyWritesBatchSize = 100
+
+// WriteOp is a create or delete operation of com.atproto.repo.applyWrites
+type WriteOp struct {
+	Type       string      `json:"$type"`
+	Collection string      `json:"collection"`
+	Rkey       string      `json:"rkey,omitempty"`
+	Value      interface{} `json:"value,omitempty"`
+}
+
+// CreateOp returns an operation creating a record with a new TID as its rkey. since the rkey is set before the write
+// is sent, the URI of the record is known up front, and a batch sent again after a lost response fails on the
+// existing records instead of creating duplicates.
+func CreateOp(collection string, value interface{}) WriteOp {
+	return WriteOp{Type: "com.atproto.repo.applyWrites#create", Collection: collection, Rkey: NewTID(), Value: value}
+}
+
+// DeleteOp returns an operation deleting a record
+func DeleteOp(collection, rkey string) WriteOp {
+	return WriteOp{Type: "com.atproto.repo.applyWrites#delete", Collection: collection, Rkey: rkey}
+}
+
+// ApplyWrites applies a batch of operations to the authenticated user's repository in a single commit
+func (c *Client) ApplyWrites(ctx context.Context, writes []WriteOp) (map[string]interface{}, error) {
+	url := c.BaseURL + "/xrpc/com.atproto.repo.applyWrites"
+	request := map[string]interface{}{
+		"repo":   c.Session.DID,
+		"writes": writes,
+	}
+
+	res, err := c.SendRequest(ctx, "POST", url, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(res, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return result, nil
+}
+
+// applyWritesBatched applies operations in batches of applyWritesBatchSize, pausing for delay between batches. done is
+// called with the operations of each applied batch.
+func (c *Client) applyWritesBatched(ctx context.Context, writes []WriteOp, delay time.Duration, done func(batch []WriteOp) error) error {
+	for i := 0; i < len(writes); i += applyWritesBatchSize {
+		end := i + applyWritesBatchSize
+		if end > len(writes) {
+			end = len(writes)
+		}
+
+		if i > 0 {
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
+		}
+		log.Printf("applying writes %d-%d of %d\n", i+1, end, len(writes))
+		if _, err := c.ApplyWrites(ctx, writes[i:end]); err != nil {
+			return err
+		}
+		if done != nil {
+			if err := done(writes[i:end]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteRecord deletes a record from the authenticated user's repository
+func (c *Client) DeleteRecord(ctx context.Context, collection, rkey string) error {
+	url := c.BaseURL + "/xrpc/com.atproto.repo.deleteRecord"
+	request := map[string]string{
+		"repo":       c.Session.DID,
+		"collection": collection,
+		"rkey":       rkey,
+	}
+
+	_, err := c.SendRequest(ctx, "POST", url, request)
+	return err
+}
+
+// splitATURI splits an at:// URI into its repo, collection, and rkey
+func splitATURI(uri string) (repo, collection, rkey string, err error) {
+	if !strings.HasPrefix(uri, "at://") {
+		return "", "", "", fmt.Errorf("invalid AT URI %q", uri)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(uri, "at://"), "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid AT URI %q: expected at://<repo>/<collection>/<rkey>", uri)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
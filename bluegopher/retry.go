@@ -0,0 +1,102 @@
+package bluegopher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls exponential backoff for transient failures: 5xx responses, connection resets, and timeouts
+type RetryPolicy struct {
+	// Attempts is the number of retries after the first request. 0 disables retries.
+	Attempts int
+	// BaseDelay is the delay before the first retry, doubled for each subsequent retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries
+	MaxDelay time.Duration
+	// Jitter is the fraction (0-1) of each delay that is randomized
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the retry policy used when no overrides are set
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:  5,
+		BaseDelay: 1 * time.Second,
+		MaxDelay:  60 * time.Second,
+		Jitter:    0.2,
+	}
+}
+
+// RetryPolicyFromEnv returns the default retry policy overridden by BLUESKY_RETRY_ATTEMPTS, BLUESKY_RETRY_DELAY, and BLUESKY_RETRY_JITTER
+func RetryPolicyFromEnv() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	if v, err := strconv.Atoi(os.Getenv("BLUESKY_RETRY_ATTEMPTS")); err == nil && v >= 0 {
+		policy.Attempts = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("BLUESKY_RETRY_DELAY")); err == nil && v > 0 {
+		policy.BaseDelay = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("BLUESKY_RETRY_JITTER"), 64); err == nil && v >= 0 && v <= 1 {
+		policy.Jitter = v
+	}
+	return policy
+}
+
+// Delay returns the backoff delay before the given retry attempt, starting at 0
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		spread := float64(delay) * p.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+// backoff logs the failure and sleeps for the policy delay of the given attempt
+func (c *Client) backoff(ctx context.Context, attempt int, reason string) error {
+	delay := c.Retry.Delay(attempt)
+	log.Printf("request failed (%s): retrying in %s (attempt %d/%d)\n", reason, delay.Round(time.Millisecond), attempt+1, c.Retry.Attempts)
+	span := spanFromContext(ctx)
+	span.SetAttr("bluegopher.retries", attempt+1)
+	span.AddEvent("retry", map[string]interface{}{"reason": reason, "delay_ms": delay.Milliseconds()})
+	return sleepContext(ctx, delay)
+}
+
+// retryableStatus reports whether a response status is worth retrying. only gateway errors are retried for
+// non-GET requests, since the server may have applied a write that failed with a 500.
+func retryableStatus(method string, status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return status >= 500 && method == http.MethodGet
+}
+
+// retryableError reports whether a transport error is transient. connection resets and timeouts on writes may
+// have reached the server, so only GET requests are retried for those.
+func retryableError(method string, err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	if method != http.MethodGet {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
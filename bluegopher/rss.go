@@ -0,0 +1,333 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rssTitleLength is the number of runes of post text kept in item titles
+const rssTitleLength = 80
+
+// GetListFeed retrieves a page of the posts of a list's members, in the shape of an author feed
+func (c *Client) GetListFeed(ctx context.Context, listURI string, limit int, cursor string) (*AuthorFeedResponse, error) {
+	params := url.Values{}
+	params.Set("list", listURI)
+	params.Set("limit", fmt.Sprintf("%d", limit))
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+
+	var response AuthorFeedResponse
+	if err := c.GetJSON(ctx, "app.bsky.feed.getListFeed", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// syndicationFeed is the channel of an RSS or Atom document: the author or list and its latest posts
+type syndicationFeed struct {
+	Title       string
+	Link        string
+	Description string
+	Posts       []PostView
+}
+
+// loadSyndicationFeed fetches the latest posts of an actor, or of a list given by bsky.app URL or AT URI. reposts are
+// left out, so the feed holds what the author or list members wrote.
+func loadSyndicationFeed(ctx context.Context, c *Client, p Params, source string) (*syndicationFeed, error) {
+	limit := p.LimitOr(50)
+	f := &syndicationFeed{}
+	var resp *AuthorFeedResponse
+
+	if strings.Contains(source, "/lists/") || strings.Contains(source, "/app.bsky.graph.list/") {
+		listURI, err := c.ListATURI(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		list, err := c.GetList(ctx, listURI, 1, "")
+		if err != nil {
+			return nil, err
+		}
+		view, _ := list["list"].(map[string]interface{})
+		f.Title, _ = view["name"].(string)
+		f.Description, _ = view["description"].(string)
+		if f.Link, err = BskyURL(listURI); err != nil {
+			return nil, err
+		}
+		if resp, err = c.GetListFeed(ctx, listURI, limit, ""); err != nil {
+			return nil, err
+		}
+	} else {
+		actor := source
+		if strings.HasPrefix(source, "https://") {
+			uri, err := c.ATURI(ctx, source)
+			if err != nil {
+				return nil, err
+			}
+			actor = strings.TrimPrefix(uri, "at://")
+		}
+		profile, err := c.GetProfile(ctx, actor)
+		if err != nil {
+			return nil, err
+		}
+		f.Title = profile.DisplayName
+		if f.Title == "" {
+			f.Title = "@" + profile.Handle
+		}
+		f.Description = profile.Description
+		f.Link = fmt.Sprintf("%s/profile/%s", bskyAppURL, profile.Handle)
+		if resp, err = c.GetAuthorFeed(ctx, profile.DID, limit, "", p.Filter, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.Description == "" {
+		f.Description = "Bluesky posts of " + f.Title
+	}
+	for _, item := range resp.Feed {
+		if len(item.Reason) == 0 {
+			f.Posts = append(f.Posts, item.Post)
+		}
+	}
+	return f, nil
+}
+
+// postTitle returns the first line of a post's text, shortened to rssTitleLength runes
+func postTitle(post PostView) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(post.Record.Text), "\n")
+	if line == "" {
+		return "Post by @" + post.Author.Handle
+	}
+	if runes := []rune(line); len(runes) > rssTitleLength {
+		return string(runes[:rssTitleLength-1]) + "…"
+	}
+	return line
+}
+
+// rssDocument is an RSS 2.0 document, with the author of each item in dc:creator since RSS authors must be email
+// addresses
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"dc:creator"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// atomFeed is an Atom 1.0 document
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Link     atomLink    `xml:"link"`
+	Updated  string      `xml:"updated"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    atomAuthor  `xml:"author"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// render encodes the feed as an Atom document, or as RSS 2.0 otherwise. items link to the posts on bsky.app, and
+// their ids are the AT URIs of the posts.
+func (f *syndicationFeed) render(atom bool) ([]byte, error) {
+	updated := time.Now().UTC()
+	if len(f.Posts) > 0 {
+		if t, ok := postTime(f.Posts[0]); ok {
+			updated = t.UTC()
+		}
+	}
+
+	var doc interface{}
+	if atom {
+		feed := atomFeed{
+			Title:    f.Title,
+			Subtitle: f.Description,
+			ID:       f.Link,
+			Link:     atomLink{Href: f.Link},
+			Updated:  updated.Format(time.RFC3339),
+		}
+		for _, post := range f.Posts {
+			link, err := BskyURL(post.URI)
+			if err != nil {
+				return nil, err
+			}
+			t, _ := postTime(post)
+			created := t.UTC().Format(time.RFC3339)
+			feed.Entries = append(feed.Entries, atomEntry{
+				Title:     postTitle(post),
+				ID:        post.URI,
+				Link:      atomLink{Href: link},
+				Published: created,
+				Updated:   created,
+				Author:    atomAuthor{Name: "@" + post.Author.Handle, URI: fmt.Sprintf("%s/profile/%s", bskyAppURL, post.Author.Handle)},
+				Content:   atomContent{Type: "text", Value: post.Record.Text},
+			})
+		}
+		doc = feed
+	} else {
+		rss := rssDocument{
+			Version: "2.0",
+			DC:      "http://purl.org/dc/elements/1.1/",
+			Channel: rssChannel{
+				Title:         f.Title,
+				Link:          f.Link,
+				Description:   f.Description,
+				LastBuildDate: updated.Format(time.RFC1123Z),
+			},
+		}
+		for _, post := range f.Posts {
+			link, err := BskyURL(post.URI)
+			if err != nil {
+				return nil, err
+			}
+			t, _ := postTime(post)
+			rss.Channel.Items = append(rss.Channel.Items, rssItem{
+				Title:       postTitle(post),
+				Link:        link,
+				GUID:        rssGUID{Value: post.URI},
+				PubDate:     t.UTC().Format(time.RFC1123Z),
+				Creator:     "@" + post.Author.Handle,
+				Description: post.Record.Text,
+			})
+		}
+		doc = rss
+	}
+
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode feed: %w", err)
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// Rss <source> <outFile> writes the latest posts of an actor or a list URL as an RSS 2.0 document, or as Atom when
+// outFile ends in .atom. with BG_ADDR it also serves the document over HTTP, refreshed every BG_INTERVAL.
+func (Bs) Rss(ctx context.Context, source, outFile string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	atom := strings.EqualFold(filepath.Ext(outFile), ".atom")
+	build := func() ([]byte, error) {
+		f, err := loadSyndicationFeed(ctx, c, p, source)
+		if err != nil {
+			return nil, err
+		}
+		b, err := f.render(atom)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(outFile, b, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write feed: %w", err)
+		}
+		return b, nil
+	}
+
+	doc, err := build()
+	if err != nil {
+		return err
+	}
+	if p.Addr == "" {
+		fmt.Printf("Feed of %s written to %s successfully\n", source, outFile)
+		return nil
+	}
+
+	var mu sync.Mutex
+	go func() {
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			b, err := build()
+			if err != nil {
+				log.Printf("failed to refresh feed: %v\n", err)
+				continue
+			}
+			mu.Lock()
+			doc = b
+			mu.Unlock()
+		}
+	}()
+
+	contentType := "application/rss+xml; charset=utf-8"
+	if atom {
+		contentType = "application/atom+xml; charset=utf-8"
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		b := doc
+		mu.Unlock()
+		w.Header().Set("Content-Type", contentType)
+		w.Write(b)
+	})
+
+	log.Printf("serving the feed of %s on %s\n", source, p.Addr)
+	if err := serveHTTP(ctx, p.Addr, handler); err != nil {
+		return err
+	}
+	fmt.Printf("Feed server stopped successfully\n")
+	return nil
+}
@@ -0,0 +1,234 @@
+package bluegopher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules filter streamed and searched posts before they are output or stored. DID rules apply to every event, while
+// the text and language rules apply to posts only. the rules are read from the YAML file of BG_RULES, which may also
+// be JSON:
+//
+//	keywords: [golang, gopher]
+//	regex:
+//	  - '\bgo ?1\.\d+\b'
+//	exclude_keywords: [giveaway]
+//	exclude_regex: []
+//	languages: [en, de]
+//	allow_dids: []
+//	deny_dids: [did:plc:spammer]
+//
+// posts are kept when they contain one of the keywords or match one of the regex, ignoring case, and dropped when they
+// contain one of the exclude_keywords or match one of the exclude_regex. a language also matches its regional variants,
+// so en matches en-US. with allow_dids, only the events of those repositories are kept.
+type Rules struct {
+	Keywords        []string
+	Patterns        []*regexp.Regexp
+	ExcludeKeywords []string
+	ExcludePatterns []*regexp.Regexp
+	Languages       []string
+	AllowDIDs       map[string]bool
+	DenyDIDs        map[string]bool
+}
+
+// ruleKeys are the keys of a rules file
+var ruleKeys = []string{"keywords", "regex", "exclude_keywords", "exclude_regex", "languages", "allow_dids", "deny_dids"}
+
+// LoadRules reads the rules of BG_RULES, with the keywords of BG_KEYWORDS added. without either, every event matches.
+func LoadRules() (*Rules, error) {
+	values := make(map[string][]string)
+	if file := os.Getenv("BG_RULES"); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rules: %w", err)
+		}
+		if values, err = parseRules(b); err != nil {
+			return nil, fmt.Errorf("invalid rules %s: %w", file, err)
+		}
+	}
+	values["keywords"] = append(values["keywords"], envList("BG_KEYWORDS")...)
+	return newRules(values)
+}
+
+// newRules compiles the values of a rules file
+func newRules(values map[string][]string) (*Rules, error) {
+	r := &Rules{
+		Keywords:        lowerAll(values["keywords"]),
+		ExcludeKeywords: lowerAll(values["exclude_keywords"]),
+		Languages:       lowerAll(values["languages"]),
+		AllowDIDs:       setOf(values["allow_dids"]),
+		DenyDIDs:        setOf(values["deny_dids"]),
+	}
+	var err error
+	if r.Patterns, err = compileAll(values["regex"]); err != nil {
+		return nil, err
+	}
+	if r.ExcludePatterns, err = compileAll(values["exclude_regex"]); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// MatchDID reports whether the DID rules keep a repository
+func (r *Rules) MatchDID(did string) bool {
+	if r.DenyDIDs[did] {
+		return false
+	}
+	return len(r.AllowDIDs) == 0 || r.AllowDIDs[did]
+}
+
+// MatchPost reports whether the rules keep a post by its author DID, text, and languages
+func (r *Rules) MatchPost(did, text string, langs []string) bool {
+	if !r.MatchDID(did) {
+		return false
+	}
+
+	if len(r.Languages) > 0 && !matchLanguage(r.Languages, langs) {
+		return false
+	}
+
+	lower := strings.ToLower(text)
+	if matchText(r.ExcludeKeywords, r.ExcludePatterns, text, lower) {
+		return false
+	}
+	if len(r.Keywords) == 0 && len(r.Patterns) == 0 {
+		return true
+	}
+	return matchText(r.Keywords, r.Patterns, text, lower)
+}
+
+// MatchEvent reports whether the rules keep a stream event. deletes of posts are kept, since their text is unknown.
+func (r *Rules) MatchEvent(event *JetstreamEvent) bool {
+	if event.Commit == nil || event.Commit.Collection != "app.bsky.feed.post" || event.Commit.Operation == "delete" {
+		return r.MatchDID(event.DID)
+	}
+
+	var record struct {
+		Text  string   `json:"text"`
+		Langs []string `json:"langs"`
+	}
+	json.Unmarshal(event.Commit.Record, &record)
+	return r.MatchPost(event.DID, record.Text, record.Langs)
+}
+
+// MatchItem reports whether the rules keep a postView output item, such as a search result
+func (r *Rules) MatchItem(item interface{}) bool {
+	m, err := toMap(item)
+	if err != nil {
+		return true
+	}
+	did, _ := lookupPath(m, "author.did").(string)
+	text, _ := lookupPath(m, "record.text").(string)
+	var langs []string
+	if list, ok := lookupPath(m, "record.langs").([]interface{}); ok {
+		for _, lang := range list {
+			if s, ok := lang.(string); ok {
+				langs = append(langs, s)
+			}
+		}
+	}
+	return r.MatchPost(did, text, langs)
+}
+
+// matchText reports whether a text contains one of the lowercase keywords or matches one of the patterns
+func matchText(keywords []string, patterns []*regexp.Regexp, text, lower string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLanguage reports whether one of the languages of a post is wanted. a wanted language matches its regional
+// variants, so en matches en-US.
+func matchLanguage(wanted, langs []string) bool {
+	for _, lang := range langs {
+		lang = strings.ToLower(lang)
+		for _, w := range wanted {
+			if lang == w || strings.HasPrefix(lang, w+"-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compileAll compiles case-insensitive regular expressions
+func compileAll(exprs []string) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		pattern, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", expr, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// lowerAll lowercases strings
+func lowerAll(values []string) []string {
+	lower := make([]string, len(values))
+	for i, v := range values {
+		lower[i] = strings.ToLower(v)
+	}
+	return lower
+}
+
+// setOf returns a set of strings, nil when empty
+func setOf(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// parseRules parses a rules file, a YAML or JSON mapping of string lists. JSON is parsed as JSON, since YAML rejects
+// the tab indentation JSON files may have.
+func parseRules(data []byte) (map[string][]string, error) {
+	values := make(map[string][]string)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+	} else if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = make(map[string][]string)
+	}
+	return values, checkRuleKeys(values)
+}
+
+// checkRuleKeys rejects unknown keys, which are most likely typos
+func checkRuleKeys(values map[string][]string) error {
+	for key := range values {
+		known := false
+		for _, k := range ruleKeys {
+			known = known || k == key
+		}
+		if !known {
+			keys := append([]string(nil), ruleKeys...)
+			sort.Strings(keys)
+			return fmt.Errorf("unknown key %q: must be one of %s", key, strings.Join(keys, ", "))
+		}
+	}
+	return nil
+}
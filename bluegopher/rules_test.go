@@ -0,0 +1,33 @@
+package bluegopher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	want := map[string][]string{
+		"keywords":  {"golang", "gopher"},
+		"regex":     {`\bgo ?1\.\d+\b`},
+		"deny_dids": {"did:plc:spammer"},
+	}
+	for name, data := range map[string]string{
+		"yaml": "keywords: [golang, gopher]  # either keyword\nregex:\n  - '\\bgo ?1\\.\\d+\\b'\ndeny_dids: [did:plc:spammer]\n",
+		"json": "{\n\t\"keywords\": [\"golang\", \"gopher\"],\n\t\"regex\": [\"\\\\bgo ?1\\\\.\\\\d+\\\\b\"],\n\t\"deny_dids\": [\"did:plc:spammer\"]\n}\n",
+	} {
+		got, err := parseRules([]byte(data))
+		if err != nil {
+			t.Fatalf("parseRules of %s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parseRules of %s = %v, want %v", name, got, want)
+		}
+	}
+
+	if got, err := parseRules(nil); err != nil || len(got) != 0 {
+		t.Errorf("parseRules of an empty file = %v, %v", got, err)
+	}
+	if _, err := parseRules([]byte("keyword: [golang]\n")); err == nil {
+		t.Error("parseRules of an unknown key succeeded")
+	}
+}
@@ -0,0 +1,419 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schedule is when a scheduled entry runs
+type schedule interface {
+	// Next returns the first run time after t, or the zero time when there is none
+	Next(t time.Time) time.Time
+}
+
+// everySchedule runs at a fixed interval from the start of the scheduler
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSchedule is a five-field cron expression: minute, hour, day of month, month, and day of week. each field is a
+// bit set of the values it matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set when the day fields are *. like cron, a day matches either day field when both are
+	// restricted.
+	domAny, dowAny bool
+}
+
+// cronMacros are the cron expressions of the @ shorthands
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronMonths and cronWeekdays are the names accepted in the month and day of week fields
+var (
+	cronMonths = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	cronWeekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// parseCron parses a five-field cron expression or an @ shorthand
+func parseCron(spec string) (*cronSchedule, error) {
+	if expr, ok := cronMacros[spec]; ok {
+		spec = expr
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", spec)
+	}
+
+	s := &cronSchedule{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	bounds := []struct {
+		set      *uint64
+		min, max int
+		names    map[string]int
+	}{
+		{&s.minute, 0, 59, nil},
+		{&s.hour, 0, 23, nil},
+		{&s.dom, 1, 31, nil},
+		{&s.month, 1, 12, cronMonths},
+		// 7 is also Sunday
+		{&s.dow, 0, 7, cronWeekdays},
+	}
+	for i, b := range bounds {
+		set, err := parseCronField(fields[i], b.min, b.max, b.names)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+		*b.set = set
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges, and steps such as 5, 1-5, */15, or mon-fri. names
+// are looked up in the names of the field, nil for fields without names.
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	value := func(s string) (int, error) {
+		n, ok := names[strings.ToLower(s)]
+		if !ok {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil {
+				return 0, fmt.Errorf("%q is not a value from %d to %d", s, min, max)
+			}
+		}
+		if n < min || n > max {
+			return 0, fmt.Errorf("%q is not a value from %d to %d", s, min, max)
+		}
+		return n, nil
+	}
+
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = value(b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			n, err := value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = n
+			if !hasStep {
+				hi = n
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			set |= 1 << n
+		}
+	}
+	return set, nil
+}
+
+// matchesDay reports whether a day matches the day of month and day of week fields
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first minute after t that matches the expression, in the location of t, skipping months, days,
+// and hours that do not match. expressions that never match, such as 0 0 30 2 *, return the zero time.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// envAssignment matches the KEY=value environment variables of a schedule entry
+var envAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// scheduleEntry is a line of a schedule file: when to run a target, its arguments, and the environment variables set
+// for it
+type scheduleEntry struct {
+	line     int
+	spec     string
+	schedule schedule
+	env      []string
+	target   string
+	args     []string
+}
+
+// String returns the target and arguments of the entry, for logs
+func (e *scheduleEntry) String() string {
+	return strings.Join(append([]string{e.target}, e.args...), " ")
+}
+
+// splitScheduleLine splits a line into fields on whitespace, keeping quoted text together. single quotes keep their
+// text as is, and double quotes allow \" and \\ escapes.
+func splitScheduleLine(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			field.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// parseSchedule parses a schedule file. each line is a schedule, optional KEY=value environment variables, and a
+// target with its arguments:
+//
+//	@daily BG_LIMIT=100 pg:snapshotFollowers alice.bsky.social
+//	*/30 * * * * pg:syncSearch "#golang"
+//	@every 6h BG_OUTPUT=digest.md bs:digest "#golang" 1
+//
+// schedules are five-field cron expressions, @hourly, @daily, @weekly, @monthly, @yearly, or @every <duration>.
+// blank lines and lines starting with # are ignored.
+func parseSchedule(data string) ([]*scheduleEntry, error) {
+	var entries []*scheduleEntry
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, err := splitScheduleLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		e := &scheduleEntry{line: i + 1}
+		switch {
+		case fields[0] == "@every":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: @every without a duration", i+1)
+			}
+			d, err := time.ParseDuration(fields[1])
+			if err != nil || d < time.Minute {
+				return nil, fmt.Errorf("line %d: invalid @every duration %q: must be at least 1m", i+1, fields[1])
+			}
+			e.spec, e.schedule, fields = "@every "+fields[1], everySchedule(d), fields[2:]
+		case strings.HasPrefix(fields[0], "@"):
+			s, err := parseCron(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			e.spec, e.schedule, fields = fields[0], s, fields[1:]
+		default:
+			if len(fields) < 5 {
+				return nil, fmt.Errorf("line %d: expected a schedule and a target", i+1)
+			}
+			spec := strings.Join(fields[:5], " ")
+			s, err := parseCron(spec)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			e.spec, e.schedule, fields = spec, s, fields[5:]
+		}
+
+		for len(fields) > 0 && envAssignment.MatchString(fields[0]) {
+			e.env = append(e.env, fields[0])
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("line %d: missing target", i+1)
+		}
+		e.target, e.args = fields[0], fields[1:]
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entries")
+	}
+	return entries, nil
+}
+
+// runScheduled runs an entry as a child process of the running binary, which accepts the same target names as mage,
+// with the environment of the scheduler plus the variables of the entry
+func runScheduled(ctx context.Context, executable string, e *scheduleEntry) error {
+	cmd := exec.CommandContext(ctx, executable, append([]string{e.target}, e.args...)...)
+	cmd.Env = append(os.Environ(), e.env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// Schedule <file> runs targets on a schedule, such as daily follower snapshots into Postgres or hourly search syncs,
+// until interrupted. each line of the file is a cron expression or @hourly, @daily, @weekly, @monthly, or @every
+// <duration>, optional KEY=value environment variables, and a target with its arguments. cron times are in BG_TZ
+// (default UTC). a run still going when the entry is due again is not started twice.
+func (Bs) Schedule(ctx context.Context, file string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read schedule: %w", err)
+	}
+	entries, err := parseSchedule(string(data))
+	if err != nil {
+		return fmt.Errorf("invalid schedule %s: %w", file, err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the running binary: %w", err)
+	}
+
+	serveMetrics(ctx)
+
+	now := time.Now().In(p.Location)
+	next := make([]time.Time, len(entries))
+	for i, e := range entries {
+		next[i] = e.schedule.Next(now)
+		if next[i].IsZero() {
+			return fmt.Errorf("line %d: schedule %q never runs", e.line, e.spec)
+		}
+		log.Printf("%s: %s, next at %s\n", e, e.spec, next[i].Format(time.RFC3339))
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	running := make([]bool, len(entries))
+	var mu sync.Mutex
+
+	for {
+		soonest := 0
+		for i := range entries {
+			if next[i].Before(next[soonest]) {
+				soonest = i
+			}
+		}
+		if err := sleepContext(ctx, time.Until(next[soonest])); err != nil {
+			log.Printf("scheduler stopped\n")
+			return nil
+		}
+
+		now := time.Now().In(p.Location)
+		for i, e := range entries {
+			if next[i].After(now) {
+				continue
+			}
+			next[i] = e.schedule.Next(now)
+
+			mu.Lock()
+			if running[i] {
+				mu.Unlock()
+				log.Printf("%s: skipped, the previous run is still going\n", e)
+				metrics.Add(metricScheduleRuns, 1, e.target, "skipped")
+				continue
+			}
+			running[i] = true
+			mu.Unlock()
+
+			wg.Add(1)
+			go func(i int, e *scheduleEntry) {
+				defer wg.Done()
+				start := time.Now()
+				log.Printf("%s: started\n", e)
+				err := runScheduled(ctx, executable, e)
+
+				mu.Lock()
+				running[i] = false
+				mu.Unlock()
+				if err != nil {
+					log.Printf("%s: failed after %s: %v\n", e, time.Since(start).Round(time.Second), err)
+					metrics.Add(metricScheduleRuns, 1, e.target, "failure")
+					return
+				}
+				log.Printf("%s: finished in %s\n", e, time.Since(start).Round(time.Second))
+				metrics.Add(metricScheduleRuns, 1, e.target, "success")
+			}(i, e)
+		}
+	}
+}
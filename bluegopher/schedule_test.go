@@ -0,0 +1,59 @@
+package bluegopher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, spec := range []string{"@daily", "@hourly", "*/15 * * * *", "0 9-17/2 * * mon-fri", "30 4 1,15 * 5", "0 0 * jan,DEC sun", "0 0 * * 7"} {
+		if _, err := parseCron(spec); err != nil {
+			t.Errorf("parseCron(%q): %v", spec, err)
+		}
+	}
+
+	// month names are only months and weekday names only weekdays
+	for _, spec := range []string{"sat * * * *", "0 mon * * *", "0 0 * * dec", "0 0 * sun *", "0 0 jan * *", "60 * * * *", "0 0 0 * *", "0 0 * * 8", "5-1 * * * *", "*/0 * * * *", "* * * *", "@often"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Friday 2024-11-01 10:07
+	from := time.Date(2024, 11, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@hourly", time.Date(2024, 11, 1, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 11, 2, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 11, 3, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 11, 1, 10, 15, 0, 0, time.UTC)},
+		{"7 * * * *", time.Date(2024, 11, 1, 11, 7, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, 11, 1, 13, 0, 0, 0, time.UTC)},
+		// 7 is Sunday like 0
+		{"0 0 * * 7", time.Date(2024, 11, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 8 * * mon-fri", time.Date(2024, 11, 4, 8, 0, 0, 0, time.UTC)},
+		// with both day fields restricted, either one matches: the 15th or a Tuesday
+		{"0 0 15 * tue", time.Date(2024, 11, 5, 0, 0, 0, 0, time.UTC)},
+		// with only the day of month restricted, the day of week does not matter
+		{"0 0 15 * *", time.Date(2024, 11, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 feb *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.spec)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// scoreFeedLimit is the number of recent feed items the posting cadence of an account is judged on
+const scoreFeedLimit = 50
+
+// ProfileScore is the heuristic spam score of an account, from 0 to 100, and the signals that raised it
+type ProfileScore struct {
+	DID            string   `json:"did"`
+	Handle         string   `json:"handle"`
+	Score          int      `json:"score"`
+	Reasons        []string `json:"reasons"`
+	AgeDays        int      `json:"ageDays"`
+	FollowersCount int      `json:"followersCount"`
+	FollowsCount   int      `json:"followsCount"`
+	PostsCount     int      `json:"postsCount"`
+}
+
+// scoreProfile scores an account on its age, follower/follow ratio, profile completeness, and the cadence and
+// content of its recent feed. the weights are rough: the score is for sorting a follower list before a manual
+// review, not for blocking automatically.
+func scoreProfile(profile *Profile, feed []FeedViewPost, now time.Time) ProfileScore {
+	s := ProfileScore{
+		DID:            profile.DID,
+		Handle:         profile.Handle,
+		FollowersCount: profile.FollowersCount,
+		FollowsCount:   profile.FollowsCount,
+		PostsCount:     profile.PostsCount,
+		Reasons:        []string{},
+	}
+	add := func(points int, reason string) {
+		s.Score += points
+		s.Reasons = append(s.Reasons, reason)
+	}
+
+	if createdAt, err := time.Parse(time.RFC3339Nano, profile.CreatedAt); err == nil {
+		age := now.Sub(createdAt)
+		s.AgeDays = int(age.Hours() / 24)
+		switch {
+		case age < 7*24*time.Hour:
+			add(25, "created in the last week")
+		case age < 30*24*time.Hour:
+			add(15, "created in the last month")
+		}
+	}
+
+	if profile.FollowsCount >= 500 {
+		ratio := float64(profile.FollowersCount) / float64(profile.FollowsCount)
+		switch {
+		case ratio < 0.05:
+			add(20, fmt.Sprintf("follows %d accounts with %d followers", profile.FollowsCount, profile.FollowersCount))
+		case ratio < 0.2:
+			add(10, fmt.Sprintf("follows %d accounts with %d followers", profile.FollowsCount, profile.FollowersCount))
+		}
+	}
+
+	if profile.Avatar == "" {
+		add(15, "default avatar")
+	}
+	if profile.DisplayName == "" && profile.Description == "" {
+		add(10, "no display name or description")
+	}
+	if name, _, _ := strings.Cut(profile.Handle, "."); countDigits(name) >= 4 {
+		add(5, "handle with many digits")
+	}
+
+	if profile.PostsCount == 0 {
+		add(10, "no posts")
+	}
+	scoreCadence(feed, add)
+
+	if s.Score > 100 {
+		s.Score = 100
+	}
+	return s
+}
+
+// scoreCadence adds the signals of the recent feed of an account: bursts of posts, mostly reposts, or mostly links
+func scoreCadence(feed []FeedViewPost, add func(points int, reason string)) {
+	if len(feed) < 5 {
+		return
+	}
+
+	var times []time.Time
+	reposts, links := 0, 0
+	for _, item := range feed {
+		if len(item.Reason) > 0 {
+			reposts++
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, item.Post.Record.CreatedAt); err == nil {
+			times = append(times, t)
+		}
+		if strings.Contains(item.Post.Record.Text, "http") || strings.Contains(string(item.Post.Embed), "app.bsky.embed.external") {
+			links++
+		}
+	}
+
+	if reposts*10 >= len(feed)*9 {
+		add(10, fmt.Sprintf("%d of the last %d feed items are reposts", reposts, len(feed)))
+	}
+	if posts := len(feed) - reposts; posts >= 5 && links*5 >= posts*4 {
+		add(10, fmt.Sprintf("%d of the last %d posts link elsewhere", links, posts))
+	}
+
+	if len(times) >= 5 {
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		gaps := make([]time.Duration, 0, len(times)-1)
+		for i := 1; i < len(times); i++ {
+			gaps = append(gaps, times[i].Sub(times[i-1]))
+		}
+		sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+		if median := gaps[len(gaps)/2]; median < time.Minute {
+			add(20, fmt.Sprintf("posts every %s on median", median.Round(time.Second)))
+		}
+	}
+}
+
+// countDigits counts the ASCII digits of a string
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// ScoreProfiles reads accounts from standard input and outputs a heuristic spam score from 0 to 100 for each, with
+// the reasons for it. lines are JSON objects with a did or handle, such as the output of bs:getFollowers, or a DID or
+// handle.
+func (Bs) ScoreProfiles(ctx context.Context) (err error) {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	out, err := newStdout()
+	if err != nil {
+		return err
+	}
+	defer closeOutput(out, &err)
+
+	cp, err := OpenCheckpoint("bs:scoreProfiles")
+	if err != nil {
+		return err
+	}
+
+	progress := StartProgress("bs:scoreProfiles", c)
+	defer progress.Stop(&err)
+
+	failed := OpenFailureLog("bs:scoreProfiles")
+	defer failed.Close()
+
+	score := func(ctx context.Context, _ int, line string) error {
+		err := func() error {
+			did, err := c.resolveActorLine(ctx, line)
+			if err != nil {
+				return err
+			}
+			// exports of followers and follows lack the counts, so the detailed profile is always fetched
+			profile, err := c.GetProfile(ctx, did)
+			if err != nil {
+				return err
+			}
+			feed, err := c.GetAuthorFeed(ctx, did, scoreFeedLimit, "", "posts_with_replies", false)
+			if err != nil {
+				return err
+			}
+			progress.Items(1)
+			return out.Emit(scoreProfile(profile, feed.Feed, time.Now()))
+		}()
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return failed.Record(line, err)
+		}
+		return nil
+	}
+	completed := func(lines int) error {
+		cp.State.Line = lines
+		return cp.Save()
+	}
+	if err := forEachLine(ctx, os.Stdin, cp.State.Line, p.Concurrency, score, completed); err != nil {
+		return err
+	}
+
+	return cp.Done()
+}
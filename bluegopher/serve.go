@@ -0,0 +1,204 @@
+package bluegopher
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxServeBody bounds the JSON request bodies of the REST proxy
+const maxServeBody = 1 << 20
+
+// apiServer exposes a Client over a small JSON HTTP API, so local tools reuse its session, retries, and rate limiting
+type apiServer struct {
+	client *Client
+	token  string
+}
+
+// handler routes the API, behind bearer token authentication
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /feed", s.feed)
+	mux.HandleFunc("GET /search", s.search)
+	mux.HandleFunc("POST /post", s.post)
+	mux.HandleFunc("POST /follow", s.follow)
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the bearer token
+func (s *apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeXRPCError(w, http.StatusUnauthorized, "AuthRequired", "a valid bearer token is required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// feed returns a page of the author feed of the actor parameter, or of the home timeline without one
+func (s *apiServer) feed(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, ok := limitParam(w, q.Get("limit"))
+	if !ok {
+		return
+	}
+
+	var resp interface{}
+	var err error
+	if actor := q.Get("actor"); actor != "" {
+		resp, err = s.client.GetAuthorFeed(r.Context(), actor, limit, q.Get("cursor"), q.Get("filter"), q.Get("includePins") == "true")
+	} else {
+		resp, err = s.client.GetTimeline(r.Context(), limit, q.Get("cursor"))
+	}
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// search returns a page of searchPosts results for the q parameter
+func (s *apiServer) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("q") == "" {
+		writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", "q is required")
+		return
+	}
+	limit, ok := limitParam(w, q.Get("limit"))
+	if !ok {
+		return
+	}
+
+	resp, err := s.client.SearchPosts(r.Context(), q.Get("q"), limit, q.Get("cursor"), q.Get("sort"), q.Get("since"), q.Get("until"), q.Get("mentions"), q.Get("author"), q.Get("lang"), q.Get("domain"), q.Get("url"), q["tag"])
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// post creates a post from a JSON body with text, and optionally langs and reply, and returns its uri and cid
+func (s *apiServer) post(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text  string    `json:"text"`
+		Langs []string  `json:"langs"`
+		Reply *ReplyRef `json:"reply"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", "text is required")
+		return
+	}
+	if n := len([]rune(req.Text)); n > maxPostLength {
+		writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", fmt.Sprintf("text is %d characters, over the limit of %d", n, maxPostLength))
+		return
+	}
+
+	ref, err := s.client.Post(r.Context(), PostRecord{Text: req.Text, Langs: req.Langs, Reply: req.Reply})
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ref)
+}
+
+// follow follows the handle or DID of a JSON body with actor, and returns the uri of the follow record
+func (s *apiServer) follow(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Actor string `json:"actor"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Actor == "" {
+		writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", "actor is required")
+		return
+	}
+
+	did, err := s.client.ResolveDID(r.Context(), req.Actor)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	resp, err := s.client.Follow(r.Context(), did, time.Now().UTC())
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"did": did, "uri": resp["uri"], "cid": resp["cid"]})
+}
+
+// limitParam parses an optional limit parameter, writing an error response when it is invalid
+func limitParam(w http.ResponseWriter, v string) (int, bool) {
+	if v == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > 100 {
+		writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", "limit must be between 1 and 100")
+		return 0, false
+	}
+	return limit, true
+}
+
+// decodeBody decodes a JSON request body, writing an error response when it is invalid
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxServeBody)).Decode(v); err != nil {
+		writeXRPCError(w, http.StatusBadRequest, "InvalidRequest", fmt.Sprintf("invalid JSON body: %v", err))
+		return false
+	}
+	return true
+}
+
+// writeUpstreamError reports a failed API request as a bad gateway, since the request reached this server fine
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	log.Printf("request failed: %v\n", err)
+	writeXRPCError(w, http.StatusBadGateway, "UpstreamFailure", err.Error())
+}
+
+// Serve <addr> serves a local JSON API backed by the authenticated client until interrupted: GET /feed, GET /search,
+// POST /post, and POST /follow. requests need the bearer token of BG_SERVE_TOKEN, or the token logged at startup. a bare
+// port such as 8080 listens on 127.0.0.1, and non-loopback addresses need BG_SERVE_PUBLIC=true.
+func (Bs) Serve(ctx context.Context, addr string) error {
+	// the API writes posts and follows, and its bearer token travels over plain HTTP
+	addr, err := loopbackListenAddr(addr, "BG_SERVE_PUBLIC")
+	if err != nil {
+		return err
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	token := os.Getenv("BG_SERVE_TOKEN")
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("failed to generate token: %w", err)
+		}
+		token = hex.EncodeToString(b)
+		log.Printf("BG_SERVE_TOKEN is not set: requests need the header Authorization: Bearer %s\n", token)
+	}
+
+	s := &apiServer{client: c, token: token}
+	log.Printf("serving the API of %s on %s\n", c.Session.Handle, addr)
+	if err := serveHTTP(ctx, addr, s.handler()); err != nil {
+		return err
+	}
+	fmt.Printf("API server stopped successfully\n")
+	return nil
+}
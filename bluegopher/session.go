@@ -0,0 +1,241 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sessionExpiryMargin is how long before expiry a cached access token is refreshed
+const sessionExpiryMargin = 5 * time.Minute
+
+// cachedSession is the session of one profile persisted between runs
+type cachedSession struct {
+	BaseURL string                `json:"baseURL"`
+	Session CreateSessionResponse `json:"session"`
+}
+
+// sessionPath returns the cache file of a profile's session
+func sessionPath(profile string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	// profiles named after a DID or email need to be safe file names
+	name := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r == '_' || r == '@' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, profile)
+	return filepath.Join(dir, "sessions", name+".json"), nil
+}
+
+// loadSession reads the cached session of a profile. a missing cache returns nil.
+func loadSession(profile string) (*cachedSession, error) {
+	path, err := sessionPath(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session cache: %w", err)
+	}
+
+	var cached cachedSession
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil, fmt.Errorf("failed to parse session cache %s: %w", path, err)
+	}
+	return &cached, nil
+}
+
+// saveSession writes the session of a profile to the cache, readable only by the current user
+func saveSession(profile string, cached cachedSession) error {
+	path, err := sessionPath(profile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create session cache directory: %w", err)
+	}
+
+	b, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		return fmt.Errorf("failed to write session cache: %w", err)
+	}
+	return nil
+}
+
+// jwtExpiry returns the exp claim of a JWT without verifying its signature
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("malformed JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed JWT payload: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// tokenValid reports whether a JWT is not expiring within the given margin
+func tokenValid(token string, margin time.Duration) bool {
+	exp, err := jwtExpiry(token)
+	return err == nil && time.Until(exp) > margin
+}
+
+// RefreshSession exchanges the refresh token for a new access token and sets the AuthToken on the client
+func (c *Client) RefreshSession(ctx context.Context) (*CreateSessionResponse, error) {
+	refreshJwt := c.refreshToken()
+	if refreshJwt == "" {
+		return nil, fmt.Errorf("failed to refresh session: missing refresh token")
+	}
+
+	url := c.BaseURL + "/xrpc/com.atproto.server.refreshSession"
+	headers := map[string]string{
+		"Authorization": "Bearer " + refreshJwt,
+	}
+	// doRequest is used directly so an expired refresh token does not trigger another refresh
+	res, body, err := c.doRequest(ctx, "POST", url, nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to refresh session: status code %d: %s", res.StatusCode, body)
+	}
+
+	var session CreateSessionResponse
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+	if session.AccessJwt == "" {
+		return nil, fmt.Errorf("failed to refresh session: missing access token")
+	}
+
+	c.setSession(session)
+	c.saveSession()
+	return &session, nil
+}
+
+// refreshExpired refreshes the session after a request failed with the expired access token. when another request
+// has already refreshed it, the new token is used as-is. with a session broker, the broker refreshes it.
+func (c *Client) refreshExpired(ctx context.Context, expired string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if c.accessToken() != expired {
+		return nil
+	}
+	if c.broker != "" {
+		return c.brokerSession(ctx, expired)
+	}
+	_, err := c.RefreshSession(ctx)
+	return err
+}
+
+// accessToken returns the current access token
+func (c *Client) accessToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.AuthToken
+}
+
+// refreshToken returns the current refresh token
+func (c *Client) refreshToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Session.RefreshJwt
+}
+
+// setSession replaces the session and the access token. requests read the DID and handle of the session without
+// holding mu, so those are only written when they change, which a refresh of the same account never does.
+func (c *Client) setSession(session CreateSessionResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.AuthToken = session.AccessJwt
+	if session.DID != c.Session.DID || session.Handle != c.Session.Handle {
+		c.Session = session
+		return
+	}
+	c.Session.DIDDoc = session.DIDDoc
+	c.Session.Email = session.Email
+	c.Session.EmailConfirmed = session.EmailConfirmed
+	c.Session.EmailAuthFactor = session.EmailAuthFactor
+	c.Session.AccessJwt = session.AccessJwt
+	c.Session.RefreshJwt = session.RefreshJwt
+	c.Session.Active = session.Active
+}
+
+// resumeSession authenticates from the cached session of the client profile, refreshing it when the access token
+// is about to expire. it returns false when a new session must be created.
+func (c *Client) resumeSession(ctx context.Context) bool {
+	if c.Profile == "" {
+		return false
+	}
+	cached, err := loadSession(c.Profile)
+	if err != nil || cached == nil || cached.Session.AccessJwt == "" {
+		return false
+	}
+	// credentials for the profile changed since the session was cached
+	if cached.Session.Handle != c.Identifier && cached.Session.DID != c.Identifier && cached.Session.Email != c.Identifier {
+		return false
+	}
+
+	baseURL := c.BaseURL
+	if c.BaseURL == "" {
+		c.BaseURL = cached.BaseURL
+	}
+	c.Session = cached.Session
+	c.AuthToken = cached.Session.AccessJwt
+	if tokenValid(c.AuthToken, sessionExpiryMargin) {
+		return true
+	}
+
+	if tokenValid(c.refreshToken(), 0) {
+		if _, err := c.RefreshSession(ctx); err == nil {
+			return true
+		}
+	}
+
+	c.BaseURL = baseURL
+	c.Session = CreateSessionResponse{}
+	c.AuthToken = ""
+	return false
+}
+
+// saveSession caches the current session of the client profile, logging failures since the session is still usable
+func (c *Client) saveSession() {
+	if c.Profile == "" {
+		return
+	}
+	c.mu.Lock()
+	cached := cachedSession{BaseURL: c.BaseURL, Session: c.Session}
+	c.mu.Unlock()
+	if err := saveSession(c.Profile, cached); err != nil {
+		log.Printf("failed to cache session: %v\n", err)
+	}
+}
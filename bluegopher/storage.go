@@ -0,0 +1,609 @@
+package bluegopher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// storagePartSize is the size of the parts of multipart uploads. S3 needs at least 5 MiB per part but the last, and
+// allows 10,000 parts, so objects up to about 80 GiB can be uploaded.
+const storagePartSize = 8 << 20
+
+// storageClient sends the requests of remote uploads. parts are retried by storageDo, so there is no overall timeout.
+var storageClient = &http.Client{}
+
+// isRemotePath reports whether a path is an s3://bucket/key or az://container/blob URL rather than a local file
+func isRemotePath(path string) bool {
+	return strings.HasPrefix(path, "s3://") || strings.HasPrefix(path, "az://")
+}
+
+// createFile creates a local file, or an object written by a streaming multipart upload to an s3:// or az:// URL. the
+// upload is completed by Close, and a failed upload is aborted. writers closed on an error path must be closed with
+// abortFile instead, so a truncated object is never published.
+func createFile(ctx context.Context, path string) (io.WriteCloser, error) {
+	var up objectUploader
+	var err error
+	switch {
+	case strings.HasPrefix(path, "s3://"):
+		up, err = newS3Uploader(path)
+	case strings.HasPrefix(path, "az://"):
+		up, err = newAzureUploader(path)
+	default:
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file: %w", err)
+		}
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &remoteWriter{ctx: ctx, path: path, up: up}, nil
+}
+
+// writeOutputFile writes a local file or an s3:// or az:// object with a buffered writer, aborting the upload when
+// write fails
+func writeOutputFile(ctx context.Context, path string, write func(w io.Writer) error) error {
+	f, err := createFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		abortFile(f)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		abortFile(f)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	log.Printf("wrote %s\n", path)
+	return nil
+}
+
+// copyFile copies a local file to a local path or a remote URL
+func copyFile(ctx context.Context, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := createFile(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		abortFile(out)
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return out.Close()
+}
+
+// abortFile closes a file created by createFile on an error path: remote uploads are aborted rather than completed,
+// and local files are closed
+func abortFile(f io.Closer) {
+	if w, ok := f.(*remoteWriter); ok {
+		w.Abort()
+		return
+	}
+	f.Close()
+}
+
+// objectUploader uploads an object to a cloud storage service: in a single request when it is smaller than a part,
+// otherwise in numbered parts that are committed together
+type objectUploader interface {
+	put(ctx context.Context, data []byte) error
+	uploadPart(ctx context.Context, number int, data []byte) error
+	complete(ctx context.Context) error
+	abort(ctx context.Context)
+}
+
+// remoteWriter buffers writes into parts of storagePartSize and uploads each part as it fills
+type remoteWriter struct {
+	ctx   context.Context
+	path  string
+	up    objectUploader
+	buf   bytes.Buffer
+	parts int
+	err   error
+	done  bool
+}
+
+func (w *remoteWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.done {
+		return 0, fmt.Errorf("write to closed upload %s", w.path)
+	}
+	w.buf.Write(p)
+	for w.buf.Len() >= storagePartSize {
+		if err := w.flushPart(storagePartSize); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flushPart uploads the next n buffered bytes as a part
+func (w *remoteWriter) flushPart(n int) error {
+	w.parts++
+	if err := w.up.uploadPart(w.ctx, w.parts, w.buf.Next(n)); err != nil {
+		w.fail(fmt.Errorf("failed to upload part %d of %s: %w", w.parts, w.path, err))
+	}
+	return w.err
+}
+
+// fail records the error of the upload and aborts it
+func (w *remoteWriter) fail(err error) {
+	w.err = err
+	if w.parts > 0 {
+		w.up.abort(context.Background())
+	}
+}
+
+// Abort discards the upload without publishing the object. closing after aborting is a no-op.
+func (w *remoteWriter) Abort() {
+	if w.done || w.err != nil {
+		return
+	}
+	w.done = true
+	w.fail(fmt.Errorf("upload of %s aborted", w.path))
+}
+
+// Close uploads the rest of the buffer and completes the upload. closing again is a no-op, so Close can be both
+// deferred and checked.
+func (w *remoteWriter) Close() error {
+	if w.done || w.err != nil {
+		return w.err
+	}
+	w.done = true
+
+	if w.parts == 0 {
+		if err := w.up.put(w.ctx, w.buf.Bytes()); err != nil {
+			w.err = fmt.Errorf("failed to upload %s: %w", w.path, err)
+		}
+		return w.err
+	}
+	if w.buf.Len() > 0 {
+		if err := w.flushPart(w.buf.Len()); err != nil {
+			return err
+		}
+	}
+	if err := w.up.complete(w.ctx); err != nil {
+		w.fail(fmt.Errorf("failed to complete the upload of %s: %w", w.path, err))
+	}
+	return w.err
+}
+
+// storageDo sends a request built by newRequest, retrying transient failures with the backoff of the retry policy.
+// it returns the response headers and body of a 2xx response.
+func storageDo(ctx context.Context, newRequest func() (*http.Request, error)) (http.Header, []byte, error) {
+	policy := RetryPolicyFromEnv()
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := storageClient.Do(req.WithContext(ctx))
+		var body []byte
+		if err == nil {
+			body, err = io.ReadAll(res.Body)
+			res.Body.Close()
+			if err == nil && (res.StatusCode < 200 || res.StatusCode > 299) {
+				err = fmt.Errorf("request failed with status code %d: %s", res.StatusCode, body)
+				if res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+					return nil, nil, err
+				}
+			}
+		}
+		if err == nil {
+			return res.Header, body, nil
+		}
+		if attempt >= policy.Attempts || ctx.Err() != nil {
+			return nil, nil, err
+		}
+		delay := policy.Delay(attempt)
+		log.Printf("upload failed, retrying in %s: %v\n", delay, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// s3Uploader uploads to Amazon S3 or an S3-compatible service, signing requests with AWS Signature Version 4. it is
+// configured with AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION or AWS_DEFAULT_REGION, and
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL for other services, such as MinIO or Cloudflare R2.
+type s3Uploader struct {
+	// objectURL is the URL of the object, virtual-hosted on AWS and path-style on other endpoints
+	objectURL    string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+
+	uploadID string
+	etags    []string
+}
+
+// newS3Uploader creates an uploader for an s3://bucket/key URL
+func newS3Uploader(path string) (*s3Uploader, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(path, "s3://"), "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URL %s: must be s3://<bucket>/<key>", path)
+	}
+	u := &s3Uploader{
+		region:       envString("AWS_REGION", envString("AWS_DEFAULT_REGION", "us-east-1")),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if u.accessKey == "" || u.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required to write to %s", path)
+	}
+
+	if endpoint := envString("AWS_ENDPOINT_URL_S3", os.Getenv("AWS_ENDPOINT_URL")); endpoint != "" {
+		u.objectURL = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + escapeObjectKey(key)
+	} else {
+		u.objectURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, u.region, escapeObjectKey(key))
+	}
+	return u, nil
+}
+
+// escapeObjectKey escapes each segment of an object key, strictly enough for SigV4 signing
+func escapeObjectKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters of RFC 3986, which is stricter than
+// url.PathEscape and url.QueryEscape as SigV4 requires
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// request sends a signed request to the object URL with a query string
+func (u *s3Uploader) request(ctx context.Context, method, query string, body []byte) (http.Header, []byte, error) {
+	return storageDo(ctx, func() (*http.Request, error) {
+		target := u.objectURL
+		if query != "" {
+			target += "?" + query
+		}
+		req, err := http.NewRequest(method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		u.sign(req, body, time.Now().UTC())
+		return req, nil
+	})
+}
+
+// sign adds the AWS Signature Version 4 authorization of a request, signing its host and every header already set
+func (u *s3Uploader) sign(req *http.Request, body []byte, now time.Time) {
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if u.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", u.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// keys without a value, such as uploads, are signed with an empty value
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			params = append(params, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + u.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+u.secretKey), date)
+	for _, part := range []string{u.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		u.accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with a key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (u *s3Uploader) put(ctx context.Context, data []byte) error {
+	_, _, err := u.request(ctx, http.MethodPut, "", data)
+	return err
+}
+
+func (u *s3Uploader) uploadPart(ctx context.Context, number int, data []byte) error {
+	if u.uploadID == "" {
+		_, body, err := u.request(ctx, http.MethodPost, "uploads=", nil)
+		if err != nil {
+			return fmt.Errorf("failed to start multipart upload: %w", err)
+		}
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+			return fmt.Errorf("failed to start multipart upload: missing upload ID")
+		}
+		u.uploadID = result.UploadID
+	}
+
+	query := fmt.Sprintf("partNumber=%d&uploadId=%s", number, uriEncode(u.uploadID))
+	header, _, err := u.request(ctx, http.MethodPut, query, data)
+	if err != nil {
+		return err
+	}
+	u.etags = append(u.etags, header.Get("ETag"))
+	return nil
+}
+
+func (u *s3Uploader) complete(ctx context.Context) error {
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	upload := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	for i, etag := range u.etags {
+		upload.Parts = append(upload.Parts, part{PartNumber: i + 1, ETag: etag})
+	}
+	b, err := xml.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	// S3 can report a failed completion in the body of a 200 response
+	_, body, err := u.request(ctx, http.MethodPost, "uploadId="+uriEncode(u.uploadID), b)
+	if err == nil && bytes.Contains(body, []byte("<Error>")) {
+		err = fmt.Errorf("%s", body)
+	}
+	return err
+}
+
+func (u *s3Uploader) abort(ctx context.Context) {
+	if u.uploadID == "" {
+		return
+	}
+	if _, _, err := u.request(ctx, http.MethodDelete, "uploadId="+uriEncode(u.uploadID), nil); err != nil {
+		log.Printf("failed to abort multipart upload: %v\n", err)
+	}
+}
+
+// azureVersion is the Blob service REST API version of the requests
+const azureVersion = "2021-08-06"
+
+// azureUploader uploads block blobs to Azure Blob Storage, authorized with a shared key or a SAS token. it is
+// configured with AZURE_STORAGE_CONNECTION_STRING, or AZURE_STORAGE_ACCOUNT with AZURE_STORAGE_KEY or
+// AZURE_STORAGE_SAS_TOKEN.
+type azureUploader struct {
+	blobURL string
+	account string
+	key     []byte
+	sas     string
+
+	blocks []string
+}
+
+// newAzureUploader creates an uploader for an az://container/blob URL
+func newAzureUploader(path string) (*azureUploader, error) {
+	container, blob, _ := strings.Cut(strings.TrimPrefix(path, "az://"), "/")
+	if container == "" || blob == "" {
+		return nil, fmt.Errorf("invalid Azure URL %s: must be az://<container>/<blob>", path)
+	}
+
+	u := &azureUploader{account: os.Getenv("AZURE_STORAGE_ACCOUNT"), sas: os.Getenv("AZURE_STORAGE_SAS_TOKEN")}
+	key := os.Getenv("AZURE_STORAGE_KEY")
+	endpoint := ""
+	if conn := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); conn != "" {
+		for _, field := range strings.Split(conn, ";") {
+			name, value, _ := strings.Cut(field, "=")
+			switch name {
+			case "AccountName":
+				u.account = value
+			case "AccountKey":
+				key = value
+			case "SharedAccessSignature":
+				u.sas = value
+			case "BlobEndpoint":
+				endpoint = value
+			}
+		}
+	}
+	if u.account == "" && endpoint == "" {
+		return nil, fmt.Errorf("AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_CONNECTION_STRING is required to write to %s", path)
+	}
+	if key != "" {
+		var err error
+		if u.key, err = base64.StdEncoding.DecodeString(key); err != nil {
+			return nil, fmt.Errorf("invalid Azure storage account key: %w", err)
+		}
+	} else if u.sas == "" {
+		return nil, fmt.Errorf("AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN is required to write to %s", path)
+	}
+	u.sas = strings.TrimPrefix(u.sas, "?")
+
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", u.account)
+	}
+	u.blobURL = strings.TrimSuffix(endpoint, "/") + "/" + container + "/" + escapeObjectKey(blob)
+	return u, nil
+}
+
+// request sends a request to the blob URL with a query string, signed with the shared key or carrying the SAS token
+func (u *azureUploader) request(ctx context.Context, method, query string, headers map[string]string, body []byte) error {
+	_, _, err := storageDo(ctx, func() (*http.Request, error) {
+		target := u.blobURL
+		params := []string{}
+		if query != "" {
+			params = append(params, query)
+		}
+		if u.key == nil {
+			params = append(params, u.sas)
+		}
+		if len(params) > 0 {
+			target += "?" + strings.Join(params, "&")
+		}
+		req, err := http.NewRequest(method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-ms-version", azureVersion)
+		req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if u.key != nil {
+			u.sign(req, len(body))
+		}
+		return req, nil
+	})
+	return err
+}
+
+// sign adds the Shared Key authorization of a request
+func (u *azureUploader) sign(req *http.Request, length int) {
+	contentLength := ""
+	if length > 0 {
+		contentLength = fmt.Sprint(length)
+	}
+
+	var msHeaders []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			msHeaders = append(msHeaders, lower+":"+strings.TrimSpace(req.Header.Get(name)))
+		}
+	}
+	sort.Strings(msHeaders)
+
+	resource := "/" + u.account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		resource += "\n" + strings.ToLower(key) + ":" + strings.Join(values, ",")
+	}
+
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, sent as x-ms-date
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		strings.Join(msHeaders, "\n"),
+		resource,
+	}, "\n")
+	signature := base64.StdEncoding.EncodeToString(hmacSHA256(u.key, stringToSign))
+	req.Header.Set("Authorization", "SharedKey "+u.account+":"+signature)
+}
+
+func (u *azureUploader) put(ctx context.Context, data []byte) error {
+	return u.request(ctx, http.MethodPut, "", map[string]string{"x-ms-blob-type": "BlockBlob"}, data)
+}
+
+func (u *azureUploader) uploadPart(ctx context.Context, number int, data []byte) error {
+	// block IDs must have the same length within a blob
+	id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%06d", number)))
+	if err := u.request(ctx, http.MethodPut, "comp=block&blockid="+uriEncode(id), nil, data); err != nil {
+		return err
+	}
+	u.blocks = append(u.blocks, id)
+	return nil
+}
+
+func (u *azureUploader) complete(ctx context.Context) error {
+	list := struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{Latest: u.blocks}
+	b, err := xml.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return u.request(ctx, http.MethodPut, "comp=blocklist", map[string]string{"Content-Type": "application/xml"}, append([]byte(xml.Header), b...))
+}
+
+// abort does nothing: uncommitted blocks are discarded by Azure after a week
+func (u *azureUploader) abort(ctx context.Context) {}
@@ -0,0 +1,83 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// ListBlobs retrieves a page of blob CIDs for an account, optionally only those since a repo revision
+func (c *Client) ListBlobs(ctx context.Context, did, since string, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/com.atproto.sync.listBlobs"
+	params := url.Values{}
+	params.Add("did", did)
+	if since != "" {
+		params.Add("since", since)
+	}
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
+
+// GetBlob downloads the raw bytes of a blob by its CID
+func (c *Client) GetBlob(ctx context.Context, did, cid string) ([]byte, error) {
+	baseURL := c.BaseURL + "/xrpc/com.atproto.sync.getBlob"
+	params := url.Values{}
+	params.Add("did", did)
+	params.Add("cid", cid)
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	return c.SendRequest(ctx, "GET", requestURL, nil)
+}
+
+// GetRepo downloads the repository of an account as a CAR file
+func (c *Client) GetRepo(ctx context.Context, did string) ([]byte, error) {
+	baseURL := c.BaseURL + "/xrpc/com.atproto.sync.getRepo"
+	params := url.Values{}
+	params.Add("did", did)
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	return c.SendRequest(ctx, "GET", requestURL, nil)
+}
+
+// ListRepos retrieves a page of the repositories hosted on the PDS
+func (c *Client) ListRepos(ctx context.Context, limit int, cursor string) (map[string]interface{}, error) {
+	baseURL := c.BaseURL + "/xrpc/com.atproto.sync.listRepos"
+	params := url.Values{}
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,472 @@
+package bluegopher
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the span kinds of OTLP
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+)
+
+// the batching of the OTLP exporter: spans are exported when a root span ends, and otherwise once spanBatchSize spans
+// are buffered or spanExportInterval has passed, so long-running targets export as they go
+const (
+	spanBatchSize      = 512
+	spanExportInterval = 10 * time.Second
+)
+
+// processStart is the start time of the cumulative metrics
+var processStart = time.Now()
+
+// Span is a traced operation, such as an API request or the pagination loop of a target. methods of a nil Span do
+// nothing, so code is instrumented the same whether tracing is configured or not.
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+
+	mu     sync.Mutex
+	attrs  map[string]interface{}
+	events []spanEvent
+}
+
+// spanEvent is a point in time within a span, such as a retry or a fetched page
+type spanEvent struct {
+	name  string
+	time  time.Time
+	attrs map[string]interface{}
+}
+
+type spanContextKey struct{}
+
+// activeSpan is the span of the running target, set by StartProgress, which parents spans started without one in
+// their context
+var activeSpan struct {
+	sync.Mutex
+	span *Span
+}
+
+// startSpan starts a span, the child of the span in ctx or of the span of the running target. it returns a nil span
+// when no OTLP endpoint is configured.
+func startSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if telemetry() == nil {
+		return ctx, nil
+	}
+
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		activeSpan.Lock()
+		parent = activeSpan.span
+		activeSpan.Unlock()
+	}
+	s := newSpan(name, kind, parent)
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// newSpan creates a span, the root of a new trace when parent is nil
+func newSpan(name string, kind int, parent *Span) *Span {
+	s := &Span{name: name, kind: kind, start: time.Now(), attrs: make(map[string]interface{})}
+	rand.Read(s.spanID[:])
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	return s
+}
+
+// startTargetSpan starts the root span of a target and makes it the active span. it returns nil when tracing is not
+// configured or another target is active, since targets started within a target belong to its trace.
+func startTargetSpan(name string) *Span {
+	if telemetry() == nil {
+		return nil
+	}
+	activeSpan.Lock()
+	defer activeSpan.Unlock()
+	if activeSpan.span != nil {
+		return nil
+	}
+	activeSpan.span = newSpan(name, spanKindInternal, nil)
+	return activeSpan.span
+}
+
+// endTargetSpan ends the root span of a target, which exports the trace and the metrics
+func endTargetSpan(s *Span, err error) {
+	if s == nil {
+		return
+	}
+	activeSpan.Lock()
+	if activeSpan.span == s {
+		activeSpan.span = nil
+	}
+	activeSpan.Unlock()
+	s.End(err)
+}
+
+// spanFromContext returns the span of a context, or nil
+func spanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// SetAttr sets an attribute of the span: a string, bool, int, int64, or float64
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+// AddEvent records an event in the span
+func (s *Span) AddEvent(name string, attrs map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.events = append(s.events, spanEvent{name: name, time: time.Now(), attrs: attrs})
+	s.mu.Unlock()
+}
+
+// traceparent returns the W3C traceparent header of the span, which propagates the trace to the server
+func (s *Span) traceparent() string {
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-01"
+}
+
+// End ends the span, failed when err is not nil, and queues it for export
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	exporter := telemetry()
+	end := time.Now()
+
+	s.mu.Lock()
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        otlpAttributes(s.attrs),
+	}
+	for _, event := range s.events {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(event.time.UnixNano(), 10),
+			Name:         event.name,
+			Attributes:   otlpAttributes(event.attrs),
+		})
+	}
+	s.mu.Unlock()
+
+	root := s.parentID == [8]byte{}
+	if !root {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if err != nil {
+		span.Status = &otlpStatus{Code: 2, Message: err.Error()}
+	}
+	exporter.queue(span, root)
+}
+
+// otlpExporter exports spans and the metrics registry to an OpenTelemetry collector with OTLP over HTTP, in its
+// protobuf encoding or, with OTEL_EXPORTER_OTLP_PROTOCOL=http/json, its JSON encoding
+type otlpExporter struct {
+	tracesURL  string
+	metricsURL string
+	headers    map[string]string
+	resource   otlpResource
+	client     *http.Client
+	// protobuf selects the http/protobuf protocol, the default of the specification
+	protobuf bool
+
+	mu         sync.Mutex
+	spans      []otlpSpan
+	lastExport time.Time
+	// exportMu serializes exports, so spans and metrics arrive in order
+	exportMu sync.Mutex
+}
+
+// telemetry returns the OTLP exporter configured from the standard OpenTelemetry environment variables, or nil
+var telemetry = sync.OnceValue(newOTLPExporter)
+
+// newOTLPExporter creates an exporter from OTEL_EXPORTER_OTLP_ENDPOINT, or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT and
+// OTEL_EXPORTER_OTLP_METRICS_ENDPOINT, with OTEL_EXPORTER_OTLP_PROTOCOL, OTEL_EXPORTER_OTLP_HEADERS, and
+// OTEL_SERVICE_NAME. it returns nil when no endpoint is set or OTEL_SDK_DISABLED is true.
+func newOTLPExporter() *otlpExporter {
+	if disabled, _ := envBool("OTEL_SDK_DISABLED", false); disabled {
+		return nil
+	}
+	base := strings.TrimSuffix(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/")
+	e := &otlpExporter{
+		tracesURL:  os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		metricsURL: os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"),
+		headers:    make(map[string]string),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		lastExport: time.Now(),
+	}
+	if base != "" {
+		if e.tracesURL == "" {
+			e.tracesURL = base + "/v1/traces"
+		}
+		if e.metricsURL == "" {
+			e.metricsURL = base + "/v1/metrics"
+		}
+	}
+	if e.tracesURL == "" && e.metricsURL == "" {
+		return nil
+	}
+	switch protocol := envString("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf"); protocol {
+	case "http/protobuf":
+		e.protobuf = true
+	case "http/json":
+	default:
+		log.Printf("OTEL_EXPORTER_OTLP_PROTOCOL %s is not supported: exporting with http/protobuf\n", protocol)
+		e.protobuf = true
+	}
+
+	// headers are comma-separated key=value pairs with URL-encoded values
+	for _, pair := range envList("OTEL_EXPORTER_OTLP_HEADERS") {
+		key, value, _ := strings.Cut(pair, "=")
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		e.headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	e.resource = otlpResource{Attributes: otlpAttributes(map[string]interface{}{
+		"service.name":           envString("OTEL_SERVICE_NAME", "blue-gopher"),
+		"telemetry.sdk.language": "go",
+	})}
+	return e
+}
+
+// queue buffers an ended span, and exports the buffer when the span is a root, the batch is full, or the export
+// interval has passed
+func (e *otlpExporter) queue(span otlpSpan, root bool) {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	due := root || len(e.spans) >= spanBatchSize || time.Since(e.lastExport) >= spanExportInterval
+	e.mu.Unlock()
+	if due {
+		e.Export(root)
+	}
+}
+
+// Export sends the buffered spans, and the metrics when final or once per export interval. failed exports are logged
+// and dropped, since telemetry must not fail a target.
+func (e *otlpExporter) Export(final bool) {
+	e.exportMu.Lock()
+	defer e.exportMu.Unlock()
+
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	exportMetrics := final || time.Since(e.lastExport) >= spanExportInterval
+	e.lastExport = time.Now()
+	e.mu.Unlock()
+
+	if len(spans) > 0 && e.tracesURL != "" {
+		var body interface{} = map[string]interface{}{"resourceSpans": []interface{}{map[string]interface{}{
+			"resource":   e.resource,
+			"scopeSpans": []interface{}{map[string]interface{}{"scope": otlpScope, "spans": spans}},
+		}}}
+		if e.protobuf {
+			body = protoTraces(e.resource, spans)
+		}
+		if err := e.post(e.tracesURL, body); err != nil {
+			log.Printf("failed to export %d spans: %v\n", len(spans), err)
+		}
+	}
+
+	if exportMetrics && e.metricsURL != "" {
+		points := otlpMetrics(metrics)
+		var body interface{} = map[string]interface{}{"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource":     e.resource,
+			"scopeMetrics": []interface{}{map[string]interface{}{"scope": otlpScope, "metrics": points}},
+		}}}
+		if e.protobuf {
+			body = protoMetrics(e.resource, points)
+		}
+		if err := e.post(e.metricsURL, body); err != nil {
+			log.Printf("failed to export metrics: %v\n", err)
+		}
+	}
+}
+
+// post sends an OTLP request: a protobuf message as encoded by protoTraces or protoMetrics, or a value encoded as JSON
+func (e *otlpExporter) post(endpoint string, body interface{}) error {
+	contentType := "application/x-protobuf"
+	b, ok := body.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("collector responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// otlpScope is the instrumentation scope of the spans and metrics
+var otlpScope = map[string]string{"name": "blue-gopher"}
+
+// the JSON encoding of OTLP, where trace and span IDs are hex and 64-bit integers are strings
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// otlpAttributes converts attributes to OTLP key-values, sorted by key
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attrs[key].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: key, Value: value})
+	}
+	return kvs
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+}
+
+// otlpSum is a cumulative (aggregationTemporality 2) sum
+type otlpSum struct {
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+// otlpMetrics converts the registry to OTLP metrics: counters to cumulative monotonic sums and gauges to gauges
+func otlpMetrics(r *metricsRegistry) []otlpMetric {
+	start := strconv.FormatInt(processStart.UnixNano(), 10)
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	var out []otlpMetric
+	for _, family := range r.snapshot() {
+		var points []otlpDataPoint
+		for _, sample := range family.samples {
+			attrs := make(map[string]interface{}, len(family.labels))
+			for i, label := range family.labels {
+				attrs[label] = sample.labelValues[i]
+			}
+			points = append(points, otlpDataPoint{
+				Attributes:        otlpAttributes(attrs),
+				StartTimeUnixNano: start,
+				TimeUnixNano:      now,
+				AsDouble:          sample.value,
+			})
+		}
+		if len(points) == 0 {
+			continue
+		}
+
+		metric := otlpMetric{Name: family.name, Description: family.help}
+		if family.kind == "counter" {
+			metric.Sum = &otlpSum{AggregationTemporality: 2, IsMonotonic: true, DataPoints: points}
+		} else {
+			metric.Gauge = &otlpGauge{DataPoints: points}
+		}
+		out = append(out, metric)
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package bluegopher
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// protoFields decodes the fields of a protobuf message into their values by field number: uint64 for varint and
+// fixed64 fields, and []byte for length-delimited fields
+func protoFields(t *testing.T, b []byte) map[int][]interface{} {
+	t.Helper()
+	fields := make(map[int][]interface{})
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("invalid protobuf key")
+		}
+		b = b[n:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatalf("invalid varint of field %d", field)
+			}
+			fields[field] = append(fields[field], v)
+			b = b[n:]
+		case 1:
+			fields[field] = append(fields[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				t.Fatalf("invalid length of field %d", field)
+			}
+			fields[field] = append(fields[field], b[n:n+int(l)])
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+// protoField returns the only message of a field
+func protoField(t *testing.T, fields map[int][]interface{}, field int) map[int][]interface{} {
+	t.Helper()
+	if len(fields[field]) != 1 {
+		t.Fatalf("field %d has %d values, want 1", field, len(fields[field]))
+	}
+	return protoFields(t, fields[field][0].([]byte))
+}
+
+// runTracedTarget runs a target that fails with err against a collector, and returns the content type and body of
+// its trace export
+func runTracedTarget(t *testing.T, protocol string, err error) (string, []byte) {
+	t.Helper()
+	var contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" {
+			contentType = r.Header.Get("Content-Type")
+			body, _ = io.ReadAll(r.Body)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", protocol)
+	t.Setenv("BG_PROGRESS", "0")
+
+	// telemetry is configured once per process, so it is reset around the test
+	telemetry = sync.OnceValue(newOTLPExporter)
+	defer func() { telemetry = sync.OnceValue(newOTLPExporter) }()
+
+	target := func() (targetErr error) {
+		progress := StartProgress("bs:test", nil)
+		defer progress.Stop(&targetErr)
+		progress.span.SetAttr("bluegopher.flag", true)
+		return err
+	}
+	target()
+	return contentType, body
+}
+
+func TestTargetSpanProtobuf(t *testing.T) {
+	contentType, body := runTracedTarget(t, "", errors.New("target failed"))
+	if contentType != "application/x-protobuf" {
+		t.Fatalf("Content-Type = %q, want application/x-protobuf by default", contentType)
+	}
+
+	request := protoFields(t, body)
+	resourceSpans := protoField(t, request, 1)
+	scopeSpans := protoField(t, resourceSpans, 2)
+	if scope := protoField(t, scopeSpans, 1); string(scope[1][0].([]byte)) != "blue-gopher" {
+		t.Errorf("scope = %q", scope[1][0])
+	}
+	span := protoField(t, scopeSpans, 2)
+	if name := string(span[5][0].([]byte)); name != "bs:test" {
+		t.Errorf("span name = %q", name)
+	}
+	if len(span[1][0].([]byte)) != 16 || len(span[2][0].([]byte)) != 8 {
+		t.Errorf("trace and span IDs are %d and %d bytes, want 16 and 8", len(span[1][0].([]byte)), len(span[2][0].([]byte)))
+	}
+	if start, end := span[7][0].(uint64), span[8][0].(uint64); start == 0 || end < start {
+		t.Errorf("span times = %d, %d", start, end)
+	}
+
+	status := protoField(t, span, 15)
+	if code, message := status[3][0].(uint64), string(status[2][0].([]byte)); code != 2 || message != "target failed" {
+		t.Errorf("status = %d %q, want 2 (error) with the target error", code, message)
+	}
+
+	// the pages and items set by Stop are int attributes, and the flag a bool one
+	attrs := make(map[string]map[int][]interface{})
+	for _, kv := range span[9] {
+		pair := protoFields(t, kv.([]byte))
+		attrs[string(pair[1][0].([]byte))] = protoField(t, pair, 2)
+	}
+	if v := attrs["bluegopher.flag"][2]; len(v) != 1 || v[0].(uint64) != 1 {
+		t.Errorf("bluegopher.flag = %v, want bool true", attrs["bluegopher.flag"])
+	}
+	if v := attrs["bluegopher.items"][3]; len(v) != 1 || v[0].(uint64) != 0 {
+		t.Errorf("bluegopher.items = %v, want int 0", attrs["bluegopher.items"])
+	}
+}
+
+func TestTargetSpanJSON(t *testing.T) {
+	contentType, body := runTracedTarget(t, "http/json", nil)
+	if contentType != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", contentType)
+	}
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatal(err)
+	}
+	span := request.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if span.Name != "bs:test" || span.Status != nil {
+		t.Errorf("span = %+v, want bs:test without an error status", span)
+	}
+}
+
+func TestProtoDataPoint(t *testing.T) {
+	point := protoFields(t, protoDataPoint(otlpDataPoint{StartTimeUnixNano: "1", TimeUnixNano: "2", AsDouble: 1.5}))
+	if point[2][0].(uint64) != 1 || point[3][0].(uint64) != 2 || math.Float64frombits(point[4][0].(uint64)) != 1.5 {
+		t.Errorf("data point = %v", point)
+	}
+}
@@ -0,0 +1,97 @@
+package bluegopher
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tidAlphabet is the sortable base32 of TIDs, whose characters sort in the order of their values
+const tidAlphabet = "234567abcdefghijklmnopqrstuvwxyz"
+
+// tidLength is the length of a TID: 64 bits in 13 characters of 5 bits, the top bit always 0
+const tidLength = 13
+
+// tidClock hands out TIDs that increase within the process, even when called within the same microsecond or when the
+// wall clock steps back
+var tidClock struct {
+	mu      sync.Mutex
+	last    int64
+	clockID uint64
+}
+
+func init() {
+	// the clock ID keeps TIDs of processes writing to the same repository at the same microsecond apart
+	var b [2]byte
+	rand.Read(b[:])
+	tidClock.clockID = uint64(binary.BigEndian.Uint16(b[:])) & 0x3ff
+}
+
+// NewTID returns a timestamp identifier for the current time, the record key of most atproto records. TIDs sort in
+// the order they were generated, so records written by one run keep their order in the repository.
+func NewTID() string {
+	tidClock.mu.Lock()
+	defer tidClock.mu.Unlock()
+
+	now := time.Now().UnixMicro()
+	if now <= tidClock.last {
+		now = tidClock.last + 1
+	}
+	tidClock.last = now
+	return formatTID(now, tidClock.clockID)
+}
+
+// TIDAt returns the TID of a time and clock ID, for deterministic record keys such as those of records copied from
+// another repository at their original creation time
+func TIDAt(t time.Time, clockID uint) string {
+	return formatTID(t.UnixMicro(), uint64(clockID)&0x3ff)
+}
+
+// formatTID encodes microseconds since the Unix epoch in 53 bits followed by a 10-bit clock ID
+func formatTID(micros int64, clockID uint64) string {
+	v := (uint64(micros)&(1<<53-1))<<10 | clockID
+	var b [tidLength]byte
+	for i := tidLength - 1; i >= 0; i-- {
+		b[i] = tidAlphabet[v&31]
+		v >>= 5
+	}
+	return string(b[:])
+}
+
+// ParseTID decodes the time and clock ID of a TID
+func ParseTID(s string) (time.Time, uint, error) {
+	if len(s) != tidLength || strings.IndexByte("234567abcdefghij", s[0]) < 0 {
+		return time.Time{}, 0, fmt.Errorf("invalid TID %q", s)
+	}
+	var v uint64
+	for i := 0; i < len(s); i++ {
+		n := strings.IndexByte(tidAlphabet, s[i])
+		if n < 0 {
+			return time.Time{}, 0, fmt.Errorf("invalid TID %q", s)
+		}
+		v = v<<5 | uint64(n)
+	}
+	return time.UnixMicro(int64(v >> 10)).UTC(), uint(v & 0x3ff), nil
+}
+
+// Tid <value> prints a new TID when value is now, the time and clock ID of a TID, or the TID of an RFC 3339 time
+// with clock ID 0
+func (Bs) Tid(value string) error {
+	if value == "now" {
+		fmt.Println(NewTID())
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		fmt.Println(TIDAt(t, 0))
+		return nil
+	}
+	t, clockID, err := ParseTID(value)
+	if err != nil {
+		return fmt.Errorf("%q is neither now, a TID, nor an RFC 3339 time", value)
+	}
+	fmt.Printf("%s clock %d\n", t.Format(time.RFC3339Nano), clockID)
+	return nil
+}
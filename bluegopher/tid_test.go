@@ -0,0 +1,52 @@
+package bluegopher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewTIDIncreases(t *testing.T) {
+	last := ""
+	for i := 0; i < 1000; i++ {
+		tid := NewTID()
+		if len(tid) != tidLength || tid <= last {
+			t.Fatalf("TID %d = %q after %q, want a longer-sorting TID of %d characters", i, tid, last, tidLength)
+		}
+		last = tid
+	}
+	if ts, _, err := ParseTID(last); err != nil || time.Since(ts) > time.Minute {
+		t.Errorf("ParseTID(%q) = %v, %v, want about now", last, ts, err)
+	}
+}
+
+func TestTIDRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		time    time.Time
+		clockID uint
+	}{
+		{time.Date(2024, 11, 2, 9, 0, 0, 123456000, time.UTC), 0},
+		{time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), 1023},
+		{time.Unix(0, 0).UTC(), 7},
+	} {
+		tid := TIDAt(tt.time, tt.clockID)
+		got, clockID, err := ParseTID(tid)
+		if err != nil || !got.Equal(tt.time) || clockID != tt.clockID {
+			t.Errorf("ParseTID(TIDAt(%v, %d)) = %v, %d, %v", tt.time, tt.clockID, got, clockID, err)
+		}
+	}
+
+	if earlier, later := TIDAt(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1023), TIDAt(time.Date(2024, 1, 1, 0, 0, 0, 1000, time.UTC), 0); earlier >= later {
+		t.Errorf("%s sorts after %s", earlier, later)
+	}
+
+	// a TID of the atproto specification
+	if got, _, err := ParseTID("3jzfcijpj2z2a"); err != nil || TIDAt(got, 0)[:11] != "3jzfcijpj2z" {
+		t.Errorf("ParseTID(3jzfcijpj2z2a) = %v, %v", got, err)
+	}
+
+	for _, invalid := range []string{"", "3jzfcijpj2z2", "3jzfcijpj2z2a1", "zjzfcijpj2z2a", "3jzfcijpj2z2A", "3jzfcijpj2z21"} {
+		if _, _, err := ParseTID(invalid); err == nil {
+			t.Errorf("ParseTID(%q) succeeded", invalid)
+		}
+	}
+}
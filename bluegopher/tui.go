@@ -0,0 +1,475 @@
+package bluegopher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tuiHelp is the key help of bs:tui
+const tuiHelp = "↑/↓ move · enter thread · l like · r repost · R reply · n next page · t timeline · a author · m notifications · esc back · q quit"
+
+// tuiItem is a row of a view. post is nil for rows that cannot be opened, such as follows.
+type tuiItem struct {
+	label string
+	post  *PostView
+}
+
+// tuiView is a page of the home timeline, an author feed, notifications, or a thread
+type tuiView struct {
+	title string
+	items []tuiItem
+	// next loads the next page of the view, nil when there is none
+	next func(ctx context.Context) (*tuiView, error)
+}
+
+// tuiViewMsg is a loaded view. push keeps the current view to go back to.
+type tuiViewMsg struct {
+	view *tuiView
+	push bool
+}
+
+// tuiStatusMsg is the result of a like, repost, or reply
+type tuiStatusMsg string
+
+// tuiErrMsg is a failed request, shown in the status line while the TUI keeps running
+type tuiErrMsg struct{ err error }
+
+// tuiModel is the bubbletea model of bs:tui
+type tuiModel struct {
+	ctx   context.Context
+	c     *Client
+	limit int
+
+	view *tuiView
+	// back are the views replaced by opening a thread or paging, restored with esc
+	back []*tuiView
+	// cursor is the selected item and offset the first item shown
+	cursor, offset int
+	width, height  int
+
+	// prompt names the line being typed, such as author or reply, and is empty otherwise
+	prompt string
+	input  string
+	// reply is the post the typed reply answers
+	reply   *PostView
+	status  string
+	loading bool
+}
+
+// newTUIModel returns the model of bs:tui, which starts on the home timeline
+func newTUIModel(ctx context.Context, c *Client, limit int) *tuiModel {
+	return &tuiModel{ctx: ctx, c: c, limit: limit, width: 80, height: 24, loading: true, status: "loading…"}
+}
+
+// Init loads the home timeline
+func (m *tuiModel) Init() tea.Cmd {
+	return m.load(false, func(ctx context.Context) (*tuiView, error) { return m.timeline(ctx, "") })
+}
+
+// Update handles the loaded views, the results of actions, and the keys
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scroll()
+	case tuiViewMsg:
+		m.loading = false
+		m.status = ""
+		if msg.push && m.view != nil {
+			m.back = append(m.back, m.view)
+		}
+		m.view = msg.view
+		m.cursor, m.offset = 0, 0
+	case tuiStatusMsg:
+		m.loading = false
+		m.status = string(msg)
+	case tuiErrMsg:
+		m.loading = false
+		m.status = "error: " + msg.err.Error()
+	case tea.KeyMsg:
+		if m.prompt != "" {
+			return m, m.typed(msg)
+		}
+		return m, m.key(msg)
+	}
+	return m, nil
+}
+
+// key runs the command of a key
+func (m *tuiModel) key(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+			m.scroll()
+		}
+		return nil
+	case "down", "j":
+		if m.view != nil && m.cursor < len(m.view.items)-1 {
+			m.cursor++
+			m.scroll()
+		}
+		return nil
+	case "esc", "backspace":
+		if len(m.back) > 0 {
+			m.view = m.back[len(m.back)-1]
+			m.back = m.back[:len(m.back)-1]
+			m.cursor, m.offset, m.status = 0, 0, ""
+		}
+		return nil
+	}
+
+	// the other commands send requests, one at a time
+	if m.loading {
+		return nil
+	}
+	switch msg.String() {
+	case "t":
+		m.back = nil
+		return m.load(false, func(ctx context.Context) (*tuiView, error) { return m.timeline(ctx, "") })
+	case "m":
+		m.back = nil
+		return m.load(false, func(ctx context.Context) (*tuiView, error) { return m.notifications(ctx, "") })
+	case "a":
+		m.prompt, m.input = "author", ""
+	case "n", " ":
+		if m.view == nil || m.view.next == nil {
+			m.status = "no more pages"
+			return nil
+		}
+		return m.load(true, m.view.next)
+	case "enter", "o":
+		if post := m.selected(); post != nil {
+			return m.load(true, func(ctx context.Context) (*tuiView, error) { return m.thread(ctx, post.URI) })
+		}
+	case "l", "r":
+		post := m.selected()
+		if post == nil {
+			return nil
+		}
+		like := msg.String() == "l"
+		m.loading, m.status = true, "sending…"
+		return func() tea.Msg {
+			ref := StrongRef{URI: post.URI, CID: post.CID}
+			var err error
+			if like {
+				_, err = m.c.Like(m.ctx, ref)
+			} else {
+				_, err = m.c.Repost(m.ctx, ref)
+			}
+			if err != nil {
+				return tuiErrMsg{err}
+			}
+			if like {
+				return tuiStatusMsg("liked the post of @" + post.Author.Handle)
+			}
+			return tuiStatusMsg("reposted the post of @" + post.Author.Handle)
+		}
+	case "R":
+		if post := m.selected(); post != nil {
+			m.prompt, m.input, m.reply = "reply", "", post
+		}
+	}
+	return nil
+}
+
+// typed edits the prompt line, submitting it with enter and discarding it with esc
+func (m *tuiModel) typed(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.prompt, m.input, m.reply = "", "", nil
+	case tea.KeyBackspace:
+		if r := []rune(m.input); len(r) > 0 {
+			m.input = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		m.input += " "
+	case tea.KeyRunes:
+		m.input += string(msg.Runes)
+	case tea.KeyEnter:
+		prompt, input, post := m.prompt, strings.TrimSpace(m.input), m.reply
+		m.prompt, m.input, m.reply = "", "", nil
+		if input == "" {
+			return nil
+		}
+		if prompt == "author" {
+			m.back = nil
+			return m.load(false, func(ctx context.Context) (*tuiView, error) { return m.authorFeed(ctx, input, "") })
+		}
+		m.loading, m.status = true, "sending…"
+		return func() tea.Msg {
+			parent := StrongRef{URI: post.URI, CID: post.CID}
+			root := parent
+			if post.Record.Reply != nil {
+				root = post.Record.Reply.Root
+			}
+			ref, err := m.c.Post(m.ctx, PostRecord{Text: input, Reply: &ReplyRef{Root: root, Parent: parent}})
+			if err != nil {
+				return tuiErrMsg{err}
+			}
+			return tuiStatusMsg("replied: " + ref.URI)
+		}
+	}
+	return nil
+}
+
+// load returns the command loading a view
+func (m *tuiModel) load(push bool, load func(ctx context.Context) (*tuiView, error)) tea.Cmd {
+	m.loading, m.status = true, "loading…"
+	return func() tea.Msg {
+		view, err := load(m.ctx)
+		if err != nil {
+			return tuiErrMsg{err}
+		}
+		return tuiViewMsg{view: view, push: push}
+	}
+}
+
+// selected returns the post of the selected item, setting the status when it is not a post
+func (m *tuiModel) selected() *PostView {
+	if m.view == nil || len(m.view.items) == 0 {
+		return nil
+	}
+	post := m.view.items[m.cursor].post
+	if post == nil {
+		m.status = "not a post"
+	}
+	return post
+}
+
+// rows returns the number of screen lines left for the items
+func (m *tuiModel) rows() int {
+	// the title, a blank line, the status, and the help
+	if rows := m.height - 4; rows > 0 {
+		return rows
+	}
+	return 1
+}
+
+// scroll moves the offset so the selected item is on screen
+func (m *tuiModel) scroll() {
+	if m.view == nil {
+		return
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	for m.offset < m.cursor {
+		lines := 0
+		for _, item := range m.view.items[m.offset : m.cursor+1] {
+			lines += strings.Count(item.label, "\n") + 1
+		}
+		if lines <= m.rows() {
+			break
+		}
+		m.offset++
+	}
+}
+
+// View renders the title, the items on screen with the selected one marked, and the status or prompt line
+func (m *tuiModel) View() string {
+	var b strings.Builder
+	title := "bs:tui"
+	if m.view != nil {
+		title += " · " + m.view.title
+		if len(m.back) > 0 {
+			title += " (esc back)"
+		}
+	}
+	b.WriteString(m.truncate(title) + "\n\n")
+
+	lines := 0
+	if m.view != nil {
+		if len(m.view.items) == 0 {
+			b.WriteString("  (nothing here)\n")
+			lines++
+		}
+		for i := m.offset; i < len(m.view.items) && lines < m.rows(); i++ {
+			for j, line := range strings.Split(m.view.items[i].label, "\n") {
+				if lines == m.rows() {
+					break
+				}
+				marker := "  "
+				if i == m.cursor && j == 0 {
+					marker = "> "
+				}
+				b.WriteString(m.truncate(marker+line) + "\n")
+				lines++
+			}
+		}
+	}
+	for ; lines < m.rows(); lines++ {
+		b.WriteString("\n")
+	}
+
+	switch {
+	case m.prompt != "":
+		b.WriteString(m.truncate(m.prompt+": "+m.input+"█") + "\n")
+	default:
+		b.WriteString(m.truncate(m.status) + "\n")
+	}
+	b.WriteString(m.truncate(tuiHelp))
+	return b.String()
+}
+
+// truncate cuts a line to the width of the terminal
+func (m *tuiModel) truncate(line string) string {
+	if r := []rune(line); m.width > 0 && len(r) > m.width {
+		return string(r[:m.width-1]) + "…"
+	}
+	return line
+}
+
+// timeline loads a page of the home timeline
+func (m *tuiModel) timeline(ctx context.Context, cursor string) (*tuiView, error) {
+	resp, err := m.c.GetTimeline(ctx, m.limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+	return feedView("home timeline", resp, func(ctx context.Context) (*tuiView, error) { return m.timeline(ctx, resp.Cursor) }), nil
+}
+
+// authorFeed loads a page of an author feed
+func (m *tuiModel) authorFeed(ctx context.Context, actor, cursor string) (*tuiView, error) {
+	resp, err := m.c.GetAuthorFeed(ctx, actor, m.limit, cursor, "posts_with_replies", true)
+	if err != nil {
+		return nil, err
+	}
+	return feedView("@"+strings.TrimPrefix(actor, "@"), resp, func(ctx context.Context) (*tuiView, error) { return m.authorFeed(ctx, actor, resp.Cursor) }), nil
+}
+
+// feedView returns the view of a page of feed items
+func feedView(title string, resp *AuthorFeedResponse, next func(ctx context.Context) (*tuiView, error)) *tuiView {
+	view := &tuiView{title: title}
+	for i := range resp.Feed {
+		item := resp.Feed[i]
+		label := formatPost(&item.Post)
+		if len(item.Reason) > 0 && strings.Contains(string(item.Reason), "reasonRepost") {
+			label = "reposted: " + label
+		}
+		view.items = append(view.items, tuiItem{label: label, post: &resp.Feed[i].Post})
+	}
+	if resp.Cursor != "" {
+		view.next = next
+	}
+	return view
+}
+
+// notifications loads a page of notifications with the posts they refer to
+func (m *tuiModel) notifications(ctx context.Context, cursor string) (*tuiView, error) {
+	resp, err := m.c.ListNotifications(ctx, m.limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// replies, mentions, and quotes are posts; likes and reposts refer to a post of the account
+	subjects := make([]string, len(resp.Notifications))
+	var uris []string
+	for i, n := range resp.Notifications {
+		switch n.Reason {
+		case "reply", "mention", "quote":
+			subjects[i] = n.URI
+		case "like", "repost":
+			subjects[i] = n.ReasonSubject
+		}
+		if subjects[i] != "" {
+			uris = append(uris, subjects[i])
+		}
+	}
+	posts := make(map[string]*PostView)
+	for i := 0; i < len(uris); i += 25 {
+		end := i + 25
+		if end > len(uris) {
+			end = len(uris)
+		}
+		views, err := m.c.GetPosts(ctx, uris[i:end])
+		if err != nil {
+			return nil, err
+		}
+		for j := range views {
+			posts[views[j].URI] = &views[j]
+		}
+	}
+
+	view := &tuiView{title: "notifications"}
+	for i, n := range resp.Notifications {
+		label := fmt.Sprintf("@%s %s", n.Author.Handle, n.Reason)
+		post := posts[subjects[i]]
+		if post != nil {
+			label += ": " + formatPost(post)
+		}
+		view.items = append(view.items, tuiItem{label: label, post: post})
+	}
+	if resp.Cursor != "" {
+		view.next = func(ctx context.Context) (*tuiView, error) { return m.notifications(ctx, resp.Cursor) }
+	}
+	return view, nil
+}
+
+// thread loads a post with its parents and direct replies
+func (m *tuiModel) thread(ctx context.Context, uri string) (*tuiView, error) {
+	resp, err := m.c.GetPostThread(ctx, uri, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var parents []tuiItem
+	for parent := resp.Thread.Parent; parent != nil; parent = parent.Parent {
+		if parent.Post.URI == "" {
+			parents = append(parents, tuiItem{label: "(unavailable post)"})
+			continue
+		}
+		parents = append(parents, tuiItem{label: formatPost(&parent.Post), post: &parent.Post})
+	}
+
+	view := &tuiView{title: "thread"}
+	for i := len(parents) - 1; i >= 0; i-- {
+		view.items = append(view.items, parents[i])
+	}
+	view.items = append(view.items, tuiItem{label: "» " + formatPost(&resp.Thread.Post), post: &resp.Thread.Post})
+	for i := range resp.Thread.Replies {
+		reply := &resp.Thread.Replies[i]
+		if reply.Post.URI == "" {
+			continue
+		}
+		view.items = append(view.items, tuiItem{label: "↳ " + formatPost(&reply.Post), post: &reply.Post})
+	}
+	return view, nil
+}
+
+// formatPost formats a post as a header line and its indented text
+func formatPost(post *PostView) string {
+	name := "@" + post.Author.Handle
+	if post.Author.DisplayName != "" {
+		name = post.Author.DisplayName + " " + name
+	}
+
+	text := strings.ReplaceAll(strings.TrimSpace(post.Record.Text), "\n", "\n    ")
+	return fmt.Sprintf("%s · %s\n    %s\n    replies %d | reposts %d | likes %d", name, formatAge(post.IndexedAt), text, post.ReplyCount, post.RepostCount, post.LikeCount)
+}
+
+// formatAge formats an RFC 3339 timestamp as the time since then, e.g. 5m or 3d
+func formatAge(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+
+	age := time.Since(t)
+	switch {
+	case age < time.Minute:
+		return "now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
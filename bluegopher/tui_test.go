@@ -0,0 +1,77 @@
+package bluegopher
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestTUI(t *testing.T) {
+	pds := newFakePDS(t)
+	c := newTestClient(t, pds)
+	m := newTUIModel(context.Background(), c, 3)
+
+	// send updates the model with a message and the messages of the commands it returns, as the program does
+	send := func(msg tea.Msg) {
+		t.Helper()
+		_, cmd := m.Update(msg)
+		for cmd != nil {
+			_, cmd = m.Update(cmd())
+		}
+	}
+	keys := func(s string) {
+		t.Helper()
+		for _, r := range s {
+			send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+	send(m.Init()())
+	send(tea.WindowSizeMsg{Width: 60, Height: 12})
+
+	if m.view == nil || m.view.title != "home timeline" || len(m.view.items) != 3 || m.view.next == nil {
+		t.Fatalf("first view = %+v, want a page of 3 timeline items", m.view)
+	}
+	if view := m.View(); !strings.Contains(view, "> Alice @alice.test") || !strings.Contains(view, "enter thread") {
+		t.Errorf("View() =\n%s\nwant the selected first post and the help", view)
+	}
+
+	// the second item is the repost of a post of bob
+	keys("jl")
+	if likes := pds.collection("app.bsky.feed.like"); len(likes) != 1 || likes[0].Value["subject"].(map[string]interface{})["uri"] != "at://did:plc:bob/app.bsky.feed.post/3krepost" {
+		t.Errorf("likes = %+v, want a like of the repost", likes)
+	}
+	if !strings.Contains(m.status, "liked") {
+		t.Errorf("status = %q after a like", m.status)
+	}
+
+	keys("Rhi gopher")
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	posts := pds.collection("app.bsky.feed.post")
+	if len(posts) != 1 || posts[0].Value["text"] != "hi gopher" || posts[0].Value["reply"] == nil {
+		t.Errorf("posts = %+v, want the reply", posts)
+	}
+
+	// the next page and an opened thread go back to the timeline with esc
+	keys("n")
+	if len(m.back) != 1 || m.cursor != 0 {
+		t.Fatalf("next page kept %d views, cursor %d", len(m.back), m.cursor)
+	}
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.view.title != "thread" || len(m.back) != 2 {
+		t.Fatalf("view = %q with %d views to go back to, want the thread", m.view.title, len(m.back))
+	}
+	send(tea.KeyMsg{Type: tea.KeyEsc})
+	send(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.view.title != "home timeline" || len(m.back) != 0 {
+		t.Errorf("view = %q after going back twice, want the timeline", m.view.title)
+	}
+
+	keys("a")
+	keys("bob.test")
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.view.title != "@bob.test" {
+		t.Errorf("view = %q, want the author feed of bob.test", m.view.title)
+	}
+}
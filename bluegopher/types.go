@@ -0,0 +1,392 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Label represents a com.atproto.label.defs#label applied to a profile or post
+type Label struct {
+	Src string `json:"src"`
+	URI string `json:"uri"`
+	CID string `json:"cid,omitempty"`
+	Val string `json:"val"`
+	Neg bool   `json:"neg,omitempty"`
+	Cts string `json:"cts"`
+}
+
+// ProfileView represents an app.bsky.actor.defs#profileView as returned in follower and follow lists
+type ProfileView struct {
+	DID         string          `json:"did"`
+	Handle      string          `json:"handle"`
+	DisplayName string          `json:"displayName,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Avatar      string          `json:"avatar,omitempty"`
+	CreatedAt   string          `json:"createdAt,omitempty"`
+	IndexedAt   string          `json:"indexedAt,omitempty"`
+	Labels      []Label         `json:"labels,omitempty"`
+	Viewer      json.RawMessage `json:"viewer,omitempty"`
+
+	// Raw is the original JSON of the item, emitted as-is when the item is marshaled
+	Raw json.RawMessage `json:"-"`
+}
+
+// FollowerView is a ProfileView returned by getFollowers and getFollows
+type FollowerView = ProfileView
+
+// Profile represents an app.bsky.actor.defs#profileViewDetailed as returned by getProfile and getProfiles
+type Profile struct {
+	DID            string          `json:"did"`
+	Handle         string          `json:"handle"`
+	DisplayName    string          `json:"displayName,omitempty"`
+	Description    string          `json:"description,omitempty"`
+	Avatar         string          `json:"avatar,omitempty"`
+	Banner         string          `json:"banner,omitempty"`
+	FollowersCount int             `json:"followersCount"`
+	FollowsCount   int             `json:"followsCount"`
+	PostsCount     int             `json:"postsCount"`
+	CreatedAt      string          `json:"createdAt,omitempty"`
+	IndexedAt      string          `json:"indexedAt,omitempty"`
+	Labels         []Label         `json:"labels,omitempty"`
+	PinnedPost     json.RawMessage `json:"pinnedPost,omitempty"`
+	Viewer         json.RawMessage `json:"viewer,omitempty"`
+
+	// Raw is the original JSON of the item, emitted as-is when the item is marshaled
+	Raw json.RawMessage `json:"-"`
+}
+
+// StrongRef represents a com.atproto.repo.strongRef
+type StrongRef struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+// PostRecord represents an app.bsky.feed.post record
+type PostRecord struct {
+	Type      string          `json:"$type"`
+	Text      string          `json:"text"`
+	CreatedAt string          `json:"createdAt"`
+	Langs     []string        `json:"langs,omitempty"`
+	Facets    json.RawMessage `json:"facets,omitempty"`
+	Embed     json.RawMessage `json:"embed,omitempty"`
+	Reply     *ReplyRef       `json:"reply,omitempty"`
+}
+
+// PostView represents an app.bsky.feed.defs#postView
+type PostView struct {
+	URI         string          `json:"uri"`
+	CID         string          `json:"cid"`
+	Author      ProfileView     `json:"author"`
+	Record      PostRecord      `json:"record"`
+	Embed       json.RawMessage `json:"embed,omitempty"`
+	ReplyCount  int             `json:"replyCount"`
+	RepostCount int             `json:"repostCount"`
+	LikeCount   int             `json:"likeCount"`
+	QuoteCount  int             `json:"quoteCount"`
+	IndexedAt   string          `json:"indexedAt"`
+	Labels      []Label         `json:"labels,omitempty"`
+	Viewer      json.RawMessage `json:"viewer,omitempty"`
+
+	// Raw is the original JSON of the item, emitted as-is when the item is marshaled
+	Raw json.RawMessage `json:"-"`
+}
+
+// FeedViewPost represents an app.bsky.feed.defs#feedViewPost as returned by getAuthorFeed and getTimeline
+type FeedViewPost struct {
+	Post   PostView        `json:"post"`
+	Reply  json.RawMessage `json:"reply,omitempty"`
+	Reason json.RawMessage `json:"reason,omitempty"`
+
+	// Raw is the original JSON of the item, emitted as-is when the item is marshaled
+	Raw json.RawMessage `json:"-"`
+}
+
+// AuthorFeedResponse represents the response from getAuthorFeed
+type AuthorFeedResponse struct {
+	Feed   []FeedViewPost `json:"feed"`
+	Cursor string         `json:"cursor,omitempty"`
+}
+
+// ProfilesResponse represents the response from getProfiles
+type ProfilesResponse struct {
+	Profiles []Profile `json:"profiles"`
+}
+
+// FollowersResponse represents the response from getFollowers
+type FollowersResponse struct {
+	Subject   ProfileView    `json:"subject"`
+	Followers []FollowerView `json:"followers"`
+	Cursor    string         `json:"cursor,omitempty"`
+}
+
+// FollowsResponse represents the response from getFollows
+type FollowsResponse struct {
+	Subject ProfileView    `json:"subject"`
+	Follows []FollowerView `json:"follows"`
+	Cursor  string         `json:"cursor,omitempty"`
+}
+
+// SearchPostsResponse represents the response from searchPosts
+type SearchPostsResponse struct {
+	Posts     []PostView `json:"posts"`
+	Cursor    string     `json:"cursor,omitempty"`
+	HitsTotal int        `json:"hitsTotal,omitempty"`
+}
+
+// ThreadViewPost represents an app.bsky.feed.defs#threadViewPost. parents and replies that were deleted or blocked
+// have an empty Post.
+type ThreadViewPost struct {
+	Post    PostView         `json:"post"`
+	Parent  *ThreadViewPost  `json:"parent,omitempty"`
+	Replies []ThreadViewPost `json:"replies,omitempty"`
+}
+
+// PostThreadResponse represents the response from getPostThread
+type PostThreadResponse struct {
+	Thread ThreadViewPost `json:"thread"`
+}
+
+// Notification represents an app.bsky.notification.listNotifications#notification
+type Notification struct {
+	URI           string          `json:"uri"`
+	CID           string          `json:"cid"`
+	Author        ProfileView     `json:"author"`
+	Reason        string          `json:"reason"`
+	ReasonSubject string          `json:"reasonSubject,omitempty"`
+	Record        json.RawMessage `json:"record"`
+	IsRead        bool            `json:"isRead"`
+	IndexedAt     string          `json:"indexedAt"`
+	Labels        []Label         `json:"labels,omitempty"`
+
+	// Raw is the original JSON of the item, emitted as-is when the item is marshaled
+	Raw json.RawMessage `json:"-"`
+}
+
+// NotificationsResponse represents the response from listNotifications
+type NotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+	Cursor        string         `json:"cursor,omitempty"`
+	SeenAt        string         `json:"seenAt,omitempty"`
+}
+
+// UnmarshalJSON decodes a ProfileView and keeps the original JSON
+func (p *ProfileView) UnmarshalJSON(data []byte) error {
+	type alias ProfileView
+	if err := json.Unmarshal(data, (*alias)(p)); err != nil {
+		return err
+	}
+	p.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON encodes the original JSON when present
+func (p ProfileView) MarshalJSON() ([]byte, error) {
+	if p.Raw != nil {
+		return p.Raw, nil
+	}
+	type alias ProfileView
+	return json.Marshal(alias(p))
+}
+
+// UnmarshalJSON decodes a Profile and keeps the original JSON
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	type alias Profile
+	if err := json.Unmarshal(data, (*alias)(p)); err != nil {
+		return err
+	}
+	p.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON encodes the original JSON when present
+func (p Profile) MarshalJSON() ([]byte, error) {
+	if p.Raw != nil {
+		return p.Raw, nil
+	}
+	type alias Profile
+	return json.Marshal(alias(p))
+}
+
+// UnmarshalJSON decodes a PostView and keeps the original JSON
+func (p *PostView) UnmarshalJSON(data []byte) error {
+	type alias PostView
+	if err := json.Unmarshal(data, (*alias)(p)); err != nil {
+		return err
+	}
+	p.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON encodes the original JSON when present
+func (p PostView) MarshalJSON() ([]byte, error) {
+	if p.Raw != nil {
+		return p.Raw, nil
+	}
+	type alias PostView
+	return json.Marshal(alias(p))
+}
+
+// UnmarshalJSON decodes a FeedViewPost and keeps the original JSON
+func (f *FeedViewPost) UnmarshalJSON(data []byte) error {
+	type alias FeedViewPost
+	if err := json.Unmarshal(data, (*alias)(f)); err != nil {
+		return err
+	}
+	f.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON encodes the original JSON when present
+func (f FeedViewPost) MarshalJSON() ([]byte, error) {
+	if f.Raw != nil {
+		return f.Raw, nil
+	}
+	type alias FeedViewPost
+	return json.Marshal(alias(f))
+}
+
+// UnmarshalJSON decodes a Notification and keeps the original JSON
+func (n *Notification) UnmarshalJSON(data []byte) error {
+	type alias Notification
+	if err := json.Unmarshal(data, (*alias)(n)); err != nil {
+		return err
+	}
+	n.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON encodes the original JSON when present
+func (n Notification) MarshalJSON() ([]byte, error) {
+	if n.Raw != nil {
+		return n.Raw, nil
+	}
+	type alias Notification
+	return json.Marshal(alias(n))
+}
+
+// GetJSON sends a GET request to an XRPC method and decodes the response into out, which may be a typed struct or a map
+func (c *Client) GetJSON(ctx context.Context, method string, params url.Values, out interface{}) error {
+	requestURL := c.BaseURL + "/xrpc/" + method
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+
+	body, err := c.SendRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return nil
+}
+
+// GetFollowers retrieves a page of followers as a FollowersResponse
+func (c *Client) GetFollowers(ctx context.Context, actor string, limit int, cursor string) (*FollowersResponse, error) {
+	var response FollowersResponse
+	if err := c.GetJSON(ctx, "app.bsky.graph.getFollowers", accountParams(actor, limit, cursor), &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// GetFollows retrieves a page of follows as a FollowsResponse
+func (c *Client) GetFollows(ctx context.Context, actor string, limit int, cursor string) (*FollowsResponse, error) {
+	var response FollowsResponse
+	if err := c.GetJSON(ctx, "app.bsky.graph.getFollows", accountParams(actor, limit, cursor), &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// accountParams builds the query parameters shared by getFollowers and getFollows
+func accountParams(actor string, limit int, cursor string) url.Values {
+	params := url.Values{}
+	params.Add("actor", actor)
+	if limit > 0 {
+		params.Add("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Add("cursor", cursor)
+	}
+	return params
+}
+
+// GetPosts retrieves the views of up to 25 posts by AT URI
+func (c *Client) GetPosts(ctx context.Context, uris []string) ([]PostView, error) {
+	if len(uris) > 25 {
+		return nil, fmt.Errorf("too many posts: maximum allowed is 25")
+	}
+
+	params := url.Values{}
+	for _, uri := range uris {
+		params.Add("uris", uri)
+	}
+
+	var response struct {
+		Posts []PostView `json:"posts"`
+	}
+	if err := c.GetJSON(ctx, "app.bsky.feed.getPosts", params, &response); err != nil {
+		return nil, err
+	}
+
+	return response.Posts, nil
+}
+
+// GetTimeline retrieves a page of the authenticated user's home timeline as an AuthorFeedResponse
+func (c *Client) GetTimeline(ctx context.Context, limit int, cursor string) (*AuthorFeedResponse, error) {
+	params := url.Values{}
+	if limit > 0 {
+		params.Set("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+
+	var response AuthorFeedResponse
+	if err := c.GetJSON(ctx, "app.bsky.feed.getTimeline", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// GetPostThread retrieves a post with its parents and replies up to depth levels as a PostThreadResponse
+func (c *Client) GetPostThread(ctx context.Context, uri string, depth int) (*PostThreadResponse, error) {
+	params := url.Values{}
+	params.Set("uri", uri)
+	if depth > 0 {
+		params.Set("depth", fmt.Sprintf("%d", depth))
+	}
+
+	var response PostThreadResponse
+	if err := c.GetJSON(ctx, "app.bsky.feed.getPostThread", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// ListNotifications retrieves a page of the authenticated user's notifications as a NotificationsResponse
+func (c *Client) ListNotifications(ctx context.Context, limit int, cursor string) (*NotificationsResponse, error) {
+	params := url.Values{}
+	if limit > 0 {
+		params.Set("limit", fmt.Sprintf("%d", limit))
+	}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+
+	var response NotificationsResponse
+	if err := c.GetJSON(ctx, "app.bsky.notification.listNotifications", params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,466 @@
+package bluegopher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unrollDepth is the depth of replies requested per getPostThread call. threads longer than this are fetched again
+// from their last loaded post.
+const unrollDepth = 100
+
+// unrollImage is an image embedded in a post of a thread
+type unrollImage struct {
+	CID      string
+	MimeType string
+	Alt      string
+	// URL is the CDN URL of the full-size image, linked when images are not downloaded
+	URL string
+	// Path is the path of the downloaded image relative to the document
+	Path string
+}
+
+// unrollEmbed is the part of an app.bsky.embed.images, external, record, or recordWithMedia embed the unroller renders
+type unrollEmbed struct {
+	Type   string `json:"$type"`
+	Images []struct {
+		Alt   string `json:"alt"`
+		Image struct {
+			Ref struct {
+				Link string `json:"$link"`
+			} `json:"ref"`
+			MimeType string `json:"mimeType"`
+		} `json:"image"`
+		Fullsize string `json:"fullsize"`
+	} `json:"images"`
+	External *struct {
+		URI         string `json:"uri"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	} `json:"external"`
+	Record json.RawMessage `json:"record"`
+	Media  *unrollEmbed    `json:"media"`
+}
+
+// media returns the embed holding the images and link card: the media of a recordWithMedia embed, or the embed itself
+func (e *unrollEmbed) media() *unrollEmbed {
+	if e.Media != nil {
+		return e.Media
+	}
+	return e
+}
+
+// quotedURI returns the AT URI of the post quoted by a record or recordWithMedia embed, or empty
+func (e *unrollEmbed) quotedURI() string {
+	var record struct {
+		URI    string          `json:"uri"`
+		Record json.RawMessage `json:"record"`
+	}
+	if len(e.Record) == 0 || json.Unmarshal(e.Record, &record) != nil {
+		return ""
+	}
+	// recordWithMedia nests the strong ref of the quoted record once more
+	if record.URI == "" && len(record.Record) > 0 {
+		json.Unmarshal(record.Record, &record)
+	}
+	if !strings.Contains(record.URI, "/app.bsky.feed.post/") {
+		return ""
+	}
+	return record.URI
+}
+
+// selfThread returns the posts of a thread by its author, in order: the consecutive parents of the post by the same
+// author, the post, and the chain of replies the author made to themselves after it. when the author replied to
+// themselves more than once, the earliest reply is followed.
+func (c *Client) selfThread(ctx context.Context, uri string) ([]PostView, error) {
+	resp, err := c.GetPostThread(ctx, uri, unrollDepth)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Thread.Post.URI == "" {
+		return nil, fmt.Errorf("post %s is not found or not visible", uri)
+	}
+	author := resp.Thread.Post.Author.DID
+
+	var parents []PostView
+	for parent := resp.Thread.Parent; parent != nil && parent.Post.Author.DID == author; parent = parent.Parent {
+		parents = append(parents, parent.Post)
+	}
+	posts := make([]PostView, 0, len(parents)+1)
+	for i := len(parents) - 1; i >= 0; i-- {
+		posts = append(posts, parents[i])
+	}
+
+	node := resp.Thread
+	fetched := uri
+	for {
+		posts = append(posts, node.Post)
+		var next *ThreadViewPost
+		for i := range node.Replies {
+			reply := &node.Replies[i]
+			if reply.Post.Author.DID != author {
+				continue
+			}
+			if next == nil || reply.Post.Record.CreatedAt < next.Post.Record.CreatedAt {
+				next = reply
+			}
+		}
+		if next != nil {
+			node = *next
+			continue
+		}
+		// replies are cut at the requested depth, so a post with replies but none loaded is fetched again
+		if len(node.Replies) == 0 && node.Post.ReplyCount > 0 && node.Post.URI != fetched {
+			fetched = node.Post.URI
+			more, err := c.GetPostThread(ctx, fetched, unrollDepth)
+			if err != nil {
+				return nil, err
+			}
+			if len(more.Thread.Replies) > 0 {
+				node = more.Thread
+				posts = posts[:len(posts)-1]
+				continue
+			}
+		}
+		break
+	}
+	return posts, nil
+}
+
+// postImages returns the images embedded in a post, with the CID and MIME type from the record and the full-size URL
+// from the view
+func postImages(post *PostView) []unrollImage {
+	var record, view unrollEmbed
+	if len(post.Record.Embed) > 0 {
+		json.Unmarshal(post.Record.Embed, &record)
+	}
+	if len(post.Embed) > 0 {
+		json.Unmarshal(post.Embed, &view)
+	}
+
+	recordImages, viewImages := record.media().Images, view.media().Images
+	var images []unrollImage
+	for i := 0; i < len(recordImages) || i < len(viewImages); i++ {
+		var img unrollImage
+		if i < len(recordImages) {
+			img = unrollImage{CID: recordImages[i].Image.Ref.Link, MimeType: recordImages[i].Image.MimeType, Alt: recordImages[i].Alt}
+		}
+		if i < len(viewImages) {
+			img.URL = viewImages[i].Fullsize
+			if img.Alt == "" {
+				img.Alt = viewImages[i].Alt
+			}
+		}
+		images = append(images, img)
+	}
+	return images
+}
+
+// imageExtension returns the file extension of an image MIME type
+func imageExtension(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
+
+// downloadImages downloads the images of the posts into dir, named by CID, and sets their paths relative to the
+// document. images that were already downloaded are kept.
+func (c *Client) downloadImages(ctx context.Context, did string, images []*unrollImage, dir, rel string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	for _, img := range images {
+		if img.CID == "" {
+			continue
+		}
+		name := img.CID + imageExtension(img.MimeType)
+		path := filepath.Join(dir, name)
+		img.Path = filepath.ToSlash(filepath.Join(rel, name))
+		if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+			continue
+		}
+
+		blob, err := c.GetBlob(ctx, did, img.CID)
+		if err != nil {
+			return fmt.Errorf("failed to download image %s: %w", img.CID, err)
+		}
+		if err := os.WriteFile(path, blob, 0644); err != nil {
+			return fmt.Errorf("failed to write image %s: %w", img.CID, err)
+		}
+	}
+	return nil
+}
+
+// renderText renders the text of a post with its link and mention facets as Markdown or HTML links
+func renderText(record *PostRecord, format string) string {
+	var facets []Facet
+	if len(record.Facets) > 0 {
+		json.Unmarshal(record.Facets, &facets)
+	}
+	sort.Slice(facets, func(i, j int) bool { return facets[i].Index.ByteStart < facets[j].Index.ByteStart })
+
+	text := record.Text
+	escape := func(s string) string {
+		if format == "html" {
+			return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>\n")
+		}
+		return strings.ReplaceAll(s, "\n", "  \n")
+	}
+	link := func(label, href string) string {
+		if format == "html" {
+			return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(href), html.EscapeString(label))
+		}
+		return fmt.Sprintf("[%s](%s)", strings.NewReplacer("[", `\[`, "]", `\]`).Replace(label), markdownHref(href))
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, facet := range facets {
+		start, end := facet.Index.ByteStart, facet.Index.ByteEnd
+		if start < pos || end > len(text) || start >= end {
+			continue
+		}
+		href := ""
+		for _, feature := range facet.Features {
+			switch feature.Type {
+			case "app.bsky.richtext.facet#link":
+				href = webHref(feature.URI)
+			case "app.bsky.richtext.facet#mention":
+				href = fmt.Sprintf("%s/profile/%s", bskyAppURL, feature.DID)
+			}
+		}
+		if href == "" {
+			continue
+		}
+		b.WriteString(escape(text[pos:start]))
+		b.WriteString(link(text[start:end], href))
+		pos = end
+	}
+	b.WriteString(escape(text[pos:]))
+	return b.String()
+}
+
+// webHref returns a link URI of a post as an href if it is an http or https URL, and "" otherwise, since the records
+// of other accounts can link javascript: or data: URIs that would run in the rendered document
+func webHref(uri string) string {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// markdownHref escapes the parentheses of an href, which would end a markdown link early
+func markdownHref(href string) string {
+	return strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(href)
+}
+
+// documentFormat returns the format of the documents of bs:unroll and bs:digest from BG_FORMAT: markdown (default) or
+// html
+func documentFormat() (string, error) {
+	format := strings.ToLower(envString("BG_FORMAT", "markdown"))
+	switch format {
+	case "markdown", "md":
+		return "markdown", nil
+	case "html":
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid BG_FORMAT %q: must be markdown or html", format)
+	}
+}
+
+// writeDocument writes a document to standard output, or to the file or s3:// or az:// URL of BG_OUTPUT
+func writeDocument(ctx context.Context, write func(w io.Writer) error) error {
+	output := os.Getenv("BG_OUTPUT")
+	if output == "" {
+		if err := write(os.Stdout); err != nil {
+			return fmt.Errorf("failed to write document: %w", err)
+		}
+		return nil
+	}
+	return writeOutputFile(ctx, output, write)
+}
+
+// unrollPost is a post of the document with its images
+type unrollPost struct {
+	Post   *PostView
+	Images []*unrollImage
+}
+
+// writeThread writes the posts of a thread as a Markdown or HTML document
+func writeThread(w io.Writer, posts []unrollPost, format string) error {
+	root := posts[0].Post
+	author := root.Author.DisplayName
+	if author == "" {
+		author = root.Author.Handle
+	}
+	source, err := BskyURL(root.URI)
+	if err != nil {
+		return err
+	}
+	date := root.Record.CreatedAt
+	if t, err := time.Parse(time.RFC3339Nano, date); err == nil {
+		date = t.Format("January 2, 2006")
+	}
+	title := fmt.Sprintf("Thread by %s", author)
+
+	var b strings.Builder
+	if format == "html" {
+		fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n<article>\n", html.EscapeString(title))
+		fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+		fmt.Fprintf(&b, "<p><a href=\"%s\">@%s</a> · %s</p>\n", html.EscapeString(source), html.EscapeString(root.Author.Handle), html.EscapeString(date))
+	} else {
+		fmt.Fprintf(&b, "# %s\n\n", title)
+		fmt.Fprintf(&b, "[@%s](%s) · %s\n", root.Author.Handle, source, date)
+	}
+
+	for _, p := range posts {
+		post := p.Post
+		var embed unrollEmbed
+		if len(post.Embed) > 0 {
+			json.Unmarshal(post.Embed, &embed)
+		}
+
+		if format == "html" {
+			fmt.Fprintf(&b, "<p>%s</p>\n", renderText(&post.Record, format))
+		} else {
+			fmt.Fprintf(&b, "\n%s\n", renderText(&post.Record, format))
+		}
+
+		for _, img := range p.Images {
+			src := img.Path
+			if src == "" {
+				src = img.URL
+			}
+			if src == "" {
+				continue
+			}
+			if format == "html" {
+				fmt.Fprintf(&b, "<figure><img src=\"%s\" alt=\"%s\"></figure>\n", html.EscapeString(src), html.EscapeString(img.Alt))
+			} else {
+				fmt.Fprintf(&b, "\n![%s](%s)\n", strings.ReplaceAll(img.Alt, "\n", " "), src)
+			}
+		}
+
+		if external := embed.media().External; external != nil {
+			label := external.Title
+			if label == "" {
+				label = external.URI
+			}
+			// a link card whose URI is not a web link, such as a javascript: URI, is left out
+			href := webHref(external.URI)
+			switch {
+			case href == "":
+			case format == "html":
+				fmt.Fprintf(&b, "<p>🔗 <a href=\"%s\">%s</a></p>\n", html.EscapeString(href), html.EscapeString(label))
+			default:
+				fmt.Fprintf(&b, "\n> 🔗 [%s](%s)\n", label, markdownHref(href))
+			}
+		}
+
+		if quoted := embed.quotedURI(); quoted != "" {
+			if quotedURL, err := BskyURL(quoted); err == nil {
+				if format == "html" {
+					fmt.Fprintf(&b, "<blockquote><a href=\"%s\">Quoted post</a></blockquote>\n", html.EscapeString(quotedURL))
+				} else {
+					fmt.Fprintf(&b, "\n> [Quoted post](%s)\n", quotedURL)
+				}
+			}
+		}
+	}
+
+	if format == "html" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">Originally posted on Bluesky</a></p>\n</article>\n</body>\n</html>\n", html.EscapeString(source))
+	} else {
+		fmt.Fprintf(&b, "\n---\n\n[Originally posted on Bluesky](%s)\n", source)
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// Unroll <postURL> fetches the thread an author wrote by replying to themselves, from a bsky.app URL or AT URI of
+// any of its posts, and writes it as a single Markdown document, or HTML with BG_FORMAT=html. the document is written
+// to BG_OUTPUT or standard output. images are downloaded into BG_IMAGES_DIR, by default an images directory next to a
+// local BG_OUTPUT, and linked from the CDN otherwise.
+func (Bs) Unroll(ctx context.Context, postURL string) error {
+	p, err := LoadParams()
+	if err != nil {
+		return err
+	}
+
+	format, err := documentFormat()
+	if err != nil {
+		return err
+	}
+
+	output := os.Getenv("BG_OUTPUT")
+	local := output != "" && !isRemotePath(output)
+	imagesDir := p.ImagesDir
+	if imagesDir == "" && local {
+		imagesDir = filepath.Join(filepath.Dir(output), "images")
+	}
+
+	c, err := NewClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	uri, err := c.collectionATURI(ctx, postURL, "app.bsky.feed.post")
+	if err != nil {
+		return err
+	}
+
+	thread, err := c.selfThread(ctx, uri)
+	if err != nil {
+		return err
+	}
+
+	posts := make([]unrollPost, len(thread))
+	var images []*unrollImage
+	for i := range thread {
+		posts[i].Post = &thread[i]
+		for _, img := range postImages(&thread[i]) {
+			img := img
+			posts[i].Images = append(posts[i].Images, &img)
+			images = append(images, &img)
+		}
+	}
+
+	if imagesDir != "" && len(images) > 0 {
+		// images are linked relative to the document, or by the path given when writing to standard output
+		rel := imagesDir
+		if local {
+			if r, err := filepath.Rel(filepath.Dir(output), imagesDir); err == nil {
+				rel = r
+			}
+		}
+		if err := c.downloadImages(ctx, thread[0].Author.DID, images, imagesDir, rel); err != nil {
+			return err
+		}
+	}
+
+	if err := writeDocument(ctx, func(w io.Writer) error { return writeThread(w, posts, format) }); err != nil {
+		return err
+	}
+
+	log.Printf("unrolled %d posts with %d images by %s\n", len(posts), len(images), thread[0].Author.Handle)
+	return nil
+}
@@ -0,0 +1,41 @@
+package bluegopher
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRenderTextLinks(t *testing.T) {
+	text := "see docs and here"
+	facets, _ := json.Marshal([]Facet{
+		{Index: FacetIndex{ByteStart: 4, ByteEnd: 8}, Features: []FacetFeature{{Type: "app.bsky.richtext.facet#link", URI: "https://go.dev/doc/(faq)"}}},
+		{Index: FacetIndex{ByteStart: 13, ByteEnd: 17}, Features: []FacetFeature{{Type: "app.bsky.richtext.facet#link", URI: "javascript:alert(1)"}}},
+	})
+	record := &PostRecord{Text: text, Facets: facets}
+
+	// only http and https links are rendered, so a javascript: URI is left as plain text
+	for format, want := range map[string]string{
+		"html":     `see <a href="https://go.dev/doc/(faq)">docs</a> and here`,
+		"markdown": `see [docs](https://go.dev/doc/%28faq%29) and here`,
+	} {
+		if got := renderText(record, format); got != want {
+			t.Errorf("%s = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestWebHref(t *testing.T) {
+	for uri, want := range map[string]string{
+		"https://example.com/a?b=c": "https://example.com/a?b=c",
+		"http://example.com":        "http://example.com",
+		"javascript:alert(1)":       "",
+		"JavaScript:alert(1)":       "",
+		"data:text/html,<script>":   "",
+		"//example.com":             "",
+		"https:///path":             "",
+	} {
+		if got := webHref(uri); got != want {
+			t.Errorf("webHref(%q) = %q, want %q", uri, got, want)
+		}
+	}
+}
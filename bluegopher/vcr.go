@@ -0,0 +1,262 @@
+package bluegopher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// vcrRecord sends requests and saves the sanitized responses as fixtures, and vcrReplay serves the fixtures
+	// without touching the network
+	vcrRecord = "record"
+	vcrReplay = "replay"
+	// defaultVCRDir is where fixtures are kept when BLUESKY_VCR_DIR is not set
+	defaultVCRDir = "testdata/vcr"
+	// vcrExpiry is the exp claim of the tokens in fixtures, 2100-01-01, so replayed sessions never need refreshing
+	vcrExpiry = 4102444800
+)
+
+// vcrRedactedKeys are the JSON fields whose values are replaced in fixtures, in request and response bodies. session
+// tokens are listed too, so a token that is not a JWT is redacted rather than kept as is.
+var vcrRedactedKeys = map[string]bool{
+	"password":    true,
+	"appPassword": true,
+	"accessJwt":   true,
+	"refreshJwt":  true,
+	"email":       true,
+	"token":       true,
+	"inviteCode":  true,
+	"recoveryKey": true,
+}
+
+// vcrHeaders are the response headers kept in fixtures, which the client reads for rate limits and caching
+var vcrHeaders = []string{"Content-Type", "Ratelimit-Limit", "Ratelimit-Remaining", "Ratelimit-Reset", "Ratelimit-Policy", "Retry-After", "Etag", "Last-Modified"}
+
+// vcrInteraction is one recorded request and its response. JSON bodies are kept readable, and other bodies such as
+// CAR files and blobs are kept as base64.
+type vcrInteraction struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Request json.RawMessage   `json:"request,omitempty"`
+	Status  int               `json:"status"`
+	Header  map[string]string `json:"header,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	RawBody []byte            `json:"rawBody,omitempty"`
+}
+
+// vcrTransport records the responses of a transport to fixture files, or replays them, so the targets making many
+// requests can be tested deterministically and offline. a request is matched by its method, URL, and how many times
+// the same request was sent before in the run, so paginated and repeated requests replay in order. request bodies are
+// not matched since writes carry timestamps, and XRPC requests are matched without the host so fixtures replay
+// against any PDSHOST.
+type vcrTransport struct {
+	mode string
+	dir  string
+	next http.RoundTripper
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+// newVCRTransport wraps a transport in the record or replay mode, keeping fixtures in dir
+func newVCRTransport(mode, dir string, next http.RoundTripper) (*vcrTransport, error) {
+	if mode != vcrRecord && mode != vcrReplay {
+		return nil, fmt.Errorf("invalid VCR mode %q: want %s or %s", mode, vcrRecord, vcrReplay)
+	}
+	if dir == "" {
+		dir = defaultVCRDir
+	}
+	if mode == vcrRecord {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create VCR directory: %w", err)
+		}
+	}
+	return &vcrTransport{mode: mode, dir: dir, next: next, seen: make(map[string]int)}, nil
+}
+
+// RoundTrip records or replays the response of a request
+func (t *vcrTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := vcrURL(req)
+	path := t.path(req.Method, target)
+
+	if t.mode == vcrReplay {
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no recorded response for %s %s in %s", req.Method, target, t.dir)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VCR fixture: %w", err)
+		}
+		var interaction vcrInteraction
+		if err := json.Unmarshal(b, &interaction); err != nil {
+			return nil, fmt.Errorf("failed to parse VCR fixture %s: %w", path, err)
+		}
+		return interaction.response(req), nil
+	}
+
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		if requestBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := vcrInteraction{Method: req.Method, URL: target, Status: res.StatusCode, Header: make(map[string]string)}
+	if json.Valid(requestBody) {
+		interaction.Request = sanitizeJSON(requestBody)
+	}
+	for _, name := range vcrHeaders {
+		if v := res.Header.Get(name); v != "" {
+			interaction.Header[name] = v
+		}
+	}
+	if json.Valid(body) {
+		interaction.Body = sanitizeJSON(body)
+	} else {
+		interaction.RawBody = body
+	}
+	b, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write VCR fixture: %w", err)
+	}
+	return res, nil
+}
+
+// path returns the fixture file of the next occurrence of a request, named after its XRPC method for browsing
+func (t *vcrTransport) path(method, target string) string {
+	key := method + " " + target
+	t.mu.Lock()
+	n := t.seen[key]
+	t.seen[key]++
+	t.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(key))
+	name := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, xrpcMethod(target))
+	return filepath.Join(t.dir, fmt.Sprintf("%s-%s-%d.json", name, hex.EncodeToString(sum[:4]), n))
+}
+
+// vcrURL returns the URL a request is matched by: the path and query of XRPC requests, and the whole URL otherwise,
+// such as DID documents fetched from plc.directory
+func vcrURL(req *http.Request) string {
+	if strings.HasPrefix(req.URL.Path, "/xrpc/") {
+		return req.URL.RequestURI()
+	}
+	return req.URL.String()
+}
+
+// response builds the recorded response of a request
+func (i vcrInteraction) response(req *http.Request) *http.Response {
+	body := []byte(i.Body)
+	if i.RawBody != nil {
+		body = i.RawBody
+	}
+	header := make(http.Header)
+	for name, v := range i.Header {
+		header.Set(name, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// sanitizeJSON replaces the credentials of a JSON body: the values of vcrRedactedKeys, and JWTs anywhere, which are
+// replaced by unsigned tokens with the same claims and an expiry of vcrExpiry
+func sanitizeJSON(b []byte) json.RawMessage {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return b
+	}
+	sanitized, err := json.Marshal(sanitizeValue("", v))
+	if err != nil {
+		return b
+	}
+	return sanitized
+}
+
+// sanitizeValue sanitizes a decoded JSON value found under a key
+func sanitizeValue(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = sanitizeValue(k, value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = sanitizeValue(key, value)
+		}
+		return v
+	case string:
+		if token, ok := sanitizeJWT(v); ok {
+			return token
+		}
+		if vcrRedactedKeys[key] && v != "" {
+			return "REDACTED"
+		}
+	}
+	return v
+}
+
+// sanitizeJWT returns an unsigned copy of a JWT whose expiry is vcrExpiry, or false when s is not a JWT
+func sanitizeJWT(s string) (string, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "eyJ") {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", false
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", false
+	}
+	claims["exp"] = vcrExpiry
+	payload, err = json.Marshal(claims)
+	if err != nil {
+		return "", false
+	}
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + ".redacted", true
+}
@@ -0,0 +1,102 @@
+package bluegopher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVCRRecordsAndReplays(t *testing.T) {
+	pds := newFakePDS(t)
+	setTestEnv(t, pds)
+	dir := t.TempDir()
+	t.Setenv("BLUESKY_VCR", vcrRecord)
+	t.Setenv("BLUESKY_VCR_DIR", dir)
+
+	run := func() ([]ProfileView, StrongRef, []byte) {
+		t.Helper()
+		ctx := context.Background()
+		c, err := NewClient(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		followers, err := c.AllFollowers(ctx, testHandle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := c.Post(ctx, PostRecord{Text: "recorded post"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		car, err := c.GetRepo(ctx, testDID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return followers, ref, car
+	}
+
+	followers, ref, car := run()
+	recorded := pds.requests
+
+	// fixtures hold neither the password nor the tokens of the session
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != len(recorded) {
+		t.Errorf("%d fixtures for %d requests", len(files), len(recorded))
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{testPassword, pds.accessJwt, pds.refreshJwt} {
+			if strings.Contains(string(b), secret) {
+				t.Errorf("%s contains a secret: %s", filepath.Base(file), b)
+			}
+		}
+	}
+
+	// the replay needs no server, and ignores the PDS host of the recording
+	pds.Close()
+	t.Setenv("BLUESKY_VCR", vcrReplay)
+	t.Setenv("PDSHOST", "http://pds.invalid")
+	t.Setenv("BLUESKY_PASSWORD", "")
+
+	replayed, replayedRef, replayedCAR := run()
+	if len(replayed) != len(followers) || replayed[249].DID != followers[249].DID {
+		t.Errorf("replayed %d followers, recorded %d", len(replayed), len(followers))
+	}
+	if replayedRef != ref || string(replayedCAR) != string(car) {
+		t.Errorf("replay = %+v, %q, want %+v, %q", replayedRef, replayedCAR, ref, car)
+	}
+
+	c, err := NewClient(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetProfile(context.Background(), "carol.test"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("unrecorded request: err = %v", err)
+	}
+}
+
+func TestSanitizeJSON(t *testing.T) {
+	token := testJWT("did:plc:alice", time.Now().Add(time.Hour))
+	got := string(sanitizeJSON([]byte(`{"identifier":"alice.test","password":"hunter2","accessJwt":"` + token + `","refreshJwt":"opaque-refresh","appPassword":"abcd-efgh-ijkl-mnop","count":12345678901234567890,"items":[{"email":"a@example.com"}]}`)))
+
+	for _, want := range []string{`"identifier":"alice.test"`, `"password":"REDACTED"`, `"email":"REDACTED"`, `"refreshJwt":"REDACTED"`, `"appPassword":"REDACTED"`, `"count":12345678901234567890`, `.redacted"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("sanitizeJSON = %s, want %s", got, want)
+		}
+	}
+	if strings.Contains(got, token) {
+		t.Errorf("sanitizeJSON kept the token: %s", got)
+	}
+
+	_, jwt, _ := strings.Cut(got, `"accessJwt":"`)
+	jwt, _, _ = strings.Cut(jwt, `"`)
+	if exp, err := jwtExpiry(jwt); err != nil || exp.Unix() != vcrExpiry {
+		t.Errorf("sanitized token expires at %v, %v, want %d", exp, err, vcrExpiry)
+	}
+}
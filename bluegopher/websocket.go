@@ -0,0 +1,377 @@
+package bluegopher
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// websocket opcodes of RFC 6455
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsAcceptGUID is appended to the handshake key to compute the Sec-WebSocket-Accept header
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessage caps the size of a message, well above the largest firehose frames
+const wsMaxMessage = 32 << 20
+
+// wsIdleTimeout closes a connection that received nothing, not even a ping, for this long
+const wsIdleTimeout = 2 * time.Minute
+
+// wsPingInterval is how often KeepAlive pings the peer, well within its idle timeout
+var wsPingInterval = 30 * time.Second
+
+// wsConn is a minimal websocket connection, enough to read the event streams of Jetstream and the relay firehose
+// and to serve event streams such as subscribeLabels: it reads text and binary messages, answers pings, and closes
+// cleanly. it does not support extensions such as permessage-deflate.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// server is set on connections accepted by acceptWebsocket, whose frames are masked by the client instead
+	server bool
+
+	mu   sync.Mutex
+	stop func() bool
+}
+
+// wsCloseError is the close frame sent by the server
+type wsCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket closed by the server with code %d: %s", e.Code, e.Reason)
+}
+
+// dialWebsocket opens a websocket connection to a ws:// or wss:// URL. the connection is closed when ctx is done.
+func dialWebsocket(ctx context.Context, rawURL string) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket URL %q: %w", rawURL, err)
+	}
+
+	host := u.Host
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "wss":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	default:
+		return nil, fmt.Errorf("invalid websocket URL %q: the scheme must be ws or wss", rawURL)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", u.Host, err)
+	}
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to connect to %s: %w", u.Host, err)
+		}
+		conn = tlsConn
+	}
+
+	ws := &wsConn{conn: conn, r: bufio.NewReaderSize(conn, 64*1024)}
+	if err := ws.handshake(u); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ws.stop = context.AfterFunc(ctx, func() { conn.Close() })
+	return ws, nil
+}
+
+// handshake upgrades the connection to a websocket
+func (ws *wsConn) handshake(u *url.URL) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate websocket key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.EscapedPath(), RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Host:       u.Host,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if req.URL.Path == "" {
+		req.URL.Path = "/"
+	}
+
+	ws.conn.SetDeadline(time.Now().Add(30 * time.Second))
+	defer ws.conn.SetDeadline(time.Time{})
+	if err := req.Write(ws.conn); err != nil {
+		return fmt.Errorf("failed to send websocket handshake: %w", err)
+	}
+
+	resp, err := http.ReadResponse(ws.r, req)
+	if err != nil {
+		return fmt.Errorf("failed to read websocket handshake: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("websocket handshake failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return fmt.Errorf("websocket handshake failed: invalid Sec-WebSocket-Accept header")
+	}
+	return nil
+}
+
+// ReadMessage returns the next text or binary message, answering pings while it waits. a close frame from the peer is
+// returned as a *wsCloseError.
+func (ws *wsConn) ReadMessage() (opcode byte, message []byte, err error) {
+	for {
+		ws.conn.SetReadDeadline(time.Now().Add(wsIdleTimeout))
+		fin, op, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case wsPing:
+			if err := ws.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			closeErr := &wsCloseError{Code: 1005}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			ws.writeFrame(wsClose, payload[:min(len(payload), 2)])
+			return 0, nil, closeErr
+		case wsText, wsBinary:
+			if opcode != 0 {
+				return 0, nil, fmt.Errorf("websocket protocol error: a new message started before the last one finished")
+			}
+			opcode = op
+		case wsContinuation:
+			if opcode == 0 {
+				return 0, nil, fmt.Errorf("websocket protocol error: continuation without a message")
+			}
+		default:
+			return 0, nil, fmt.Errorf("websocket protocol error: unknown opcode %d", op)
+		}
+
+		if len(message)+len(payload) > wsMaxMessage {
+			return 0, nil, fmt.Errorf("websocket message larger than %d bytes", wsMaxMessage)
+		}
+		message = append(message, payload...)
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// readFrame reads a single frame. frames from the client are always masked, and frames from the server never are.
+func (ws *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("websocket protocol error: unexpected extension bits")
+	}
+	// control frames must not be fragmented, and carry at most 125 bytes
+	if opcode >= wsClose && (!fin || header[1]&0x7f > 125) {
+		return false, 0, nil, fmt.Errorf("websocket protocol error: fragmented or oversized control frame")
+	}
+	masked := header[1]&0x80 != 0
+	if masked != ws.server {
+		return false, 0, nil, fmt.Errorf("websocket protocol error: unexpected frame masking")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxMessage {
+		return false, 0, nil, fmt.Errorf("websocket frame larger than %d bytes", wsMaxMessage)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage writes a binary message in a single frame
+func (ws *wsConn) WriteMessage(message []byte) error {
+	return ws.writeFrame(wsBinary, message)
+}
+
+// writeFrame writes a single frame, masked when written by a client as RFC 6455 requires
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var maskBit byte = 0x80
+	if ws.server {
+		maskBit = 0
+	}
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	if ws.server {
+		frame = append(frame, payload...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return fmt.Errorf("failed to generate websocket mask: %w", err)
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := ws.conn.Write(frame)
+	return err
+}
+
+// KeepAlive pings the peer every wsPingInterval until the context is done or a ping fails, so that a stream with no
+// messages to send does not reach the idle timeout of the peer. the pongs are read by ReadMessage.
+func (ws *wsConn) KeepAlive(ctx context.Context) error {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := ws.writeFrame(wsPing, nil); err != nil {
+				return fmt.Errorf("failed to ping websocket: %w", err)
+			}
+		}
+	}
+}
+
+// Close sends a normal close frame and closes the connection
+func (ws *wsConn) Close() error {
+	if ws.stop != nil {
+		ws.stop()
+	}
+	ws.writeFrame(wsClose, []byte{0x03, 0xe8})
+	return ws.conn.Close()
+}
+
+// acceptWebsocket upgrades an HTTP request to a websocket connection. the connection is closed when the request
+// context is done.
+func acceptWebsocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "expected a websocket upgrade", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("not a websocket request")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket upgrade not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket upgrade not supported by the response writer")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade to a websocket: %w", err)
+	}
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to upgrade to a websocket: %w", err)
+	}
+
+	ws := &wsConn{conn: conn, r: rw.Reader, server: true}
+	ws.stop = context.AfterFunc(r.Context(), func() { conn.Close() })
+	return ws, nil
+}
+
+// wsURL joins a websocket URL with query parameters, keeping any already present
+func wsURL(base string, params url.Values) string {
+	if len(params) == 0 {
+		return base
+	}
+	if strings.Contains(base, "?") {
+		return base + "&" + params.Encode()
+	}
+	return base + "?" + params.Encode()
+}
@@ -0,0 +1,201 @@
+package bluegopher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newWebsocketServer serves a websocket handled by handle, and returns its ws:// URL
+func newWebsocketServer(t *testing.T, handle func(ws *wsConn)) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := acceptWebsocket(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		handle(ws)
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// writeRawFrame writes an unmasked frame as a server, with fin and opcode as given
+func writeRawFrame(t *testing.T, ws *wsConn, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+	header := opcode
+	if fin {
+		header |= 0x80
+	}
+	frame := append([]byte{header, byte(len(payload))}, payload...)
+	if _, err := ws.conn.Write(frame); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWebsocketEcho(t *testing.T) {
+	// the server echoes every message, so each size exercises the masked client and unmasked server framing
+	url := newWebsocketServer(t, func(ws *wsConn) {
+		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := ws.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	})
+
+	ws, err := dialWebsocket(context.Background(), url+"/echo?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	for _, size := range []int{0, 1, 125, 126, 65535, 65536, 1 << 20} {
+		message := bytes.Repeat([]byte{byte(size)}, size)
+		if err := ws.WriteMessage(message); err != nil {
+			t.Fatal(err)
+		}
+		opcode, got, err := ws.ReadMessage()
+		if err != nil || opcode != wsBinary || !bytes.Equal(got, message) {
+			t.Fatalf("echo of %d bytes = %d, %d bytes, %v", size, opcode, len(got), err)
+		}
+	}
+}
+
+func TestWebsocketFragmentsAndPings(t *testing.T) {
+	pong := make(chan []byte, 1)
+	url := newWebsocketServer(t, func(ws *wsConn) {
+		// a ping between the fragments of a message is answered while the message is read
+		writeRawFrame(t, ws, false, wsText, []byte("hello, "))
+		writeRawFrame(t, ws, true, wsPing, []byte("ping"))
+		writeRawFrame(t, ws, false, wsContinuation, []byte("web"))
+		writeRawFrame(t, ws, true, wsContinuation, []byte("socket"))
+		_, op, payload, err := ws.readFrame()
+		if err == nil && op == wsPong {
+			pong <- payload
+		}
+		close(pong)
+		writeRawFrame(t, ws, true, wsClose, append([]byte{0x03, 0xe9}, "going away"...))
+		ws.readFrame()
+	})
+
+	ws, err := dialWebsocket(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	opcode, message, err := ws.ReadMessage()
+	if err != nil || opcode != wsText || string(message) != "hello, websocket" {
+		t.Fatalf("ReadMessage = %d, %q, %v", opcode, message, err)
+	}
+	if got := <-pong; string(got) != "ping" {
+		t.Errorf("pong = %q, want ping", got)
+	}
+
+	var closeErr *wsCloseError
+	if _, _, err := ws.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != 1001 || closeErr.Reason != "going away" {
+		t.Errorf("ReadMessage after close = %v, want code 1001", err)
+	}
+}
+
+func TestWebsocketKeepAlive(t *testing.T) {
+	interval := wsPingInterval
+	wsPingInterval = 10 * time.Millisecond
+	defer func() { wsPingInterval = interval }()
+
+	pongs := make(chan int, 1)
+	url := newWebsocketServer(t, func(ws *wsConn) {
+		// the server has nothing to send, and reads the pongs answering its pings
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go ws.KeepAlive(ctx)
+		n := 0
+		for n < 3 {
+			_, op, _, err := ws.readFrame()
+			if err != nil {
+				break
+			}
+			if op == wsPong {
+				n++
+			}
+		}
+		pongs <- n
+		ws.WriteMessage([]byte("done"))
+	})
+
+	ws, err := dialWebsocket(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if _, message, err := ws.ReadMessage(); err != nil || string(message) != "done" {
+		t.Fatalf("ReadMessage = %q, %v", message, err)
+	}
+	if n := <-pongs; n != 3 {
+		t.Errorf("server read %d pongs, want 3", n)
+	}
+}
+
+func TestWebsocketProtocolErrors(t *testing.T) {
+	for name, write := range map[string]func(t *testing.T, ws *wsConn){
+		"fragmented ping": func(t *testing.T, ws *wsConn) { writeRawFrame(t, ws, false, wsPing, nil) },
+		"continuation":    func(t *testing.T, ws *wsConn) { writeRawFrame(t, ws, true, wsContinuation, []byte("x")) },
+		"unknown opcode":  func(t *testing.T, ws *wsConn) { writeRawFrame(t, ws, true, 0x3, nil) },
+		"masked frame": func(t *testing.T, ws *wsConn) {
+			ws.conn.Write([]byte{0x82, 0x81, 1, 2, 3, 4, 'x' ^ 1})
+		},
+		"new message before the last ended": func(t *testing.T, ws *wsConn) {
+			writeRawFrame(t, ws, false, wsText, []byte("a"))
+			writeRawFrame(t, ws, true, wsText, []byte("b"))
+		},
+	} {
+		url := newWebsocketServer(t, func(ws *wsConn) {
+			write(t, ws)
+			ws.readFrame()
+		})
+		ws, err := dialWebsocket(context.Background(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := ws.ReadMessage(); err == nil || !strings.Contains(err.Error(), "protocol error") {
+			t.Errorf("%s: ReadMessage = %v, want a protocol error", name, err)
+		}
+		ws.Close()
+	}
+}
+
+func TestWebsocketHandshake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reject" {
+			http.Error(w, "no", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Sec-WebSocket-Accept", "invalid")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	if _, err := dialWebsocket(context.Background(), url+"/reject"); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("dial rejected = %v", err)
+	}
+	if _, err := dialWebsocket(context.Background(), url); err == nil || !strings.Contains(err.Error(), "Sec-WebSocket-Accept") {
+		t.Errorf("dial with invalid accept = %v", err)
+	}
+	if _, err := dialWebsocket(context.Background(), "http://example.com"); err == nil {
+		t.Error("dial of an http URL succeeded")
+	}
+}
@@ -0,0 +1,162 @@
+package bluegopher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// forEachLine calls fn for each line read from r, skipping the first skip lines, with up to workers concurrent
+// calls. lines are started in input order. completed is called from the calling goroutine with the number of lines
+// finished without gaps, so it can be saved as a checkpoint. the first error cancels the remaining lines.
+func forEachLine(ctx context.Context, r io.Reader, skip, workers int, fn func(ctx context.Context, line int, text string) error, completed func(lines int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		line int
+		text string
+	}
+	type result struct {
+		line int
+		err  error
+	}
+	jobs := make(chan job)
+	results := make(chan result)
+	scanErr := make(chan error, 1)
+
+	go func() {
+		defer close(jobs)
+		scanner := bufio.NewScanner(r)
+		line := 0
+		for scanner.Scan() {
+			if line < skip {
+				line++
+				continue
+			}
+			select {
+			case jobs <- job{line: line, text: scanner.Text()}:
+			case <-ctx.Done():
+				scanErr <- nil
+				return
+			}
+			line++
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				// workers do not wait for the reader after a cancel, as reading stdin cannot be interrupted
+				select {
+				case j, ok := <-jobs:
+					if !ok {
+						return
+					}
+					results <- result{line: j.line, err: fn(ctx, j.line, j.text)}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	next := skip
+	finished := make(map[int]bool)
+	var firstErr error
+	for res := range results {
+		if firstErr != nil {
+			continue
+		}
+		if res.err != nil {
+			firstErr = res.err
+			cancel()
+			continue
+		}
+
+		finished[res.line] = true
+		if !finished[next] {
+			continue
+		}
+		for finished[next] {
+			delete(finished, next)
+			next++
+		}
+		if completed != nil {
+			if err := completed(next); err != nil {
+				firstErr = err
+				cancel()
+			}
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	if err := <-scanErr; err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+	return nil
+}
+
+// lineTurns orders the output of the lines of forEachLine: the line whose turn it is writes its output as it goes,
+// and later lines wait for their turn, so the output of lines processed concurrently does not interleave
+type lineTurns struct {
+	mu    sync.Mutex
+	next  int
+	turns map[int]chan struct{}
+}
+
+// newLineTurns returns the turns of the lines starting at first
+func newLineTurns(first int) *lineTurns {
+	return &lineTurns{next: first, turns: make(map[int]chan struct{})}
+}
+
+// turn returns a channel closed once it is the turn of line
+func (t *lineTurns) turn(line int) <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.channel(line)
+}
+
+// channel returns the channel of line, closed when created once its turn has come
+func (t *lineTurns) channel(line int) chan struct{} {
+	ch, ok := t.turns[line]
+	if !ok {
+		ch = make(chan struct{})
+		if line <= t.next {
+			close(ch)
+		}
+		t.turns[line] = ch
+	}
+	return ch
+}
+
+// done passes the turn of line to the next line
+func (t *lineTurns) done(line int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.turns, line)
+	t.next = line + 1
+	if ch, ok := t.turns[t.next]; ok {
+		close(ch)
+	}
+}
@@ -0,0 +1,245 @@
+package bluegopher
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultWriteBudget is the hourly write limit of an account on a PDS, in points: creates cost 3, updates 2, and
+	// deletes 1
+	defaultWriteBudget = 5000
+	// writeBudgetDays is the daily limit as a multiple of the hourly one, 35000 points on a PDS
+	writeBudgetDays = 7
+	// writeBudgetLockStale is the age at which the lock of a crashed process is taken over
+	writeBudgetLockStale = 30 * time.Second
+)
+
+// writePoints are the points of the repository write methods, and of the operations of applyWrites
+var writePoints = map[string]int{
+	"com.atproto.repo.createRecord":       3,
+	"com.atproto.repo.putRecord":          2,
+	"com.atproto.repo.deleteRecord":       1,
+	"com.atproto.repo.applyWrites#create": 3,
+	"com.atproto.repo.applyWrites#update": 2,
+	"com.atproto.repo.applyWrites#delete": 1,
+}
+
+// requestWritePoints returns the points of the write limit a request spends, or 0 when it is not a repository write
+func requestWritePoints(method, url string, body []byte) int {
+	if method != "POST" {
+		return 0
+	}
+	name := xrpcMethod(url)
+	if name != "com.atproto.repo.applyWrites" {
+		return writePoints[name]
+	}
+
+	var request struct {
+		Writes []struct {
+			Type string `json:"$type"`
+		} `json:"writes"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return 0
+	}
+	points := 0
+	for _, w := range request.Writes {
+		points += writePoints[w.Type]
+	}
+	return points
+}
+
+// writeBudgetState is the points spent by an account in the current hour and day, shared by every process writing
+// to it
+type writeBudgetState struct {
+	HourStart  time.Time `json:"hourStart"`
+	HourPoints int       `json:"hourPoints"`
+	DayStart   time.Time `json:"dayStart"`
+	DayPoints  int       `json:"dayPoints"`
+}
+
+// WriteBudget is the write limit of an account, kept in a file of the cache directory so concurrent commands
+// writing to the same account spend one budget rather than each assuming the whole limit is theirs
+type WriteBudget struct {
+	path   string
+	hourly int
+}
+
+// OpenWriteBudget returns the budget of an account with an hourly limit in points, and a daily limit of
+// writeBudgetDays times as much
+func OpenWriteBudget(did string, hourly int) (*WriteBudget, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "budget")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create write budget directory: %w", err)
+	}
+	return &WriteBudget{path: filepath.Join(dir, strings.ReplaceAll(did, ":", "_")+".json"), hourly: hourly}, nil
+}
+
+// Reserve spends points of the budget, waiting for the hour or day to reset when they do not fit. a write larger
+// than the whole hourly budget is let through once the hour is unspent.
+func (b *WriteBudget) Reserve(ctx context.Context, points int) error {
+	for {
+		wait, state, err := b.reserve(ctx, points)
+		if err != nil || wait <= 0 {
+			return err
+		}
+		log.Printf("write budget spent (%d/%d points this hour, %d/%d today): waiting %s\n",
+			state.HourPoints, b.hourly, state.DayPoints, b.hourly*writeBudgetDays, wait.Round(time.Second))
+		spanFromContext(ctx).AddEvent("write budget wait", map[string]interface{}{"wait_ms": wait.Milliseconds()})
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve spends points under the lock of the budget file, or returns how long to wait for them to fit
+func (b *WriteBudget) reserve(ctx context.Context, points int) (time.Duration, writeBudgetState, error) {
+	unlock, err := b.lock(ctx)
+	if err != nil {
+		return 0, writeBudgetState{}, err
+	}
+	defer unlock()
+
+	state, err := b.load()
+	if err != nil {
+		return 0, state, err
+	}
+
+	now := time.Now()
+	if now.Sub(state.HourStart) >= time.Hour {
+		state.HourStart, state.HourPoints = now, 0
+	}
+	if now.Sub(state.DayStart) >= 24*time.Hour {
+		state.DayStart, state.DayPoints = now, 0
+	}
+	if state.DayPoints > 0 && state.DayPoints+points > b.hourly*writeBudgetDays {
+		return time.Until(state.DayStart.Add(24 * time.Hour)), state, nil
+	}
+	if state.HourPoints > 0 && state.HourPoints+points > b.hourly {
+		return time.Until(state.HourStart.Add(time.Hour)), state, nil
+	}
+
+	state.HourPoints += points
+	state.DayPoints += points
+	return 0, state, b.save(state)
+}
+
+// Refund gives back the points of a write the PDS rejected. points are taken off the current hour and day, so a
+// refund after the hour reset may free less than was spent.
+func (b *WriteBudget) Refund(ctx context.Context, points int) error {
+	unlock, err := b.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := b.load()
+	if err != nil {
+		return err
+	}
+	state.HourPoints = max(state.HourPoints-points, 0)
+	state.DayPoints = max(state.DayPoints-points, 0)
+	return b.save(state)
+}
+
+// load reads the state of the budget file, which the caller holds the lock of
+func (b *WriteBudget) load() (writeBudgetState, error) {
+	var state writeBudgetState
+	if data, err := os.ReadFile(b.path); err == nil {
+		// a corrupt file starts a fresh budget rather than blocking every write
+		_ = json.Unmarshal(data, &state)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return state, fmt.Errorf("failed to read write budget: %w", err)
+	}
+	return state, nil
+}
+
+// save replaces the budget file, which the caller holds the lock of
+func (b *WriteBudget) save(state writeBudgetState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write write budget: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("failed to write write budget: %w", err)
+	}
+	return nil
+}
+
+// lock takes the lock file of the budget, which works across processes and platforms, and returns its release. the
+// file holds a token unique to its holder, so a process only ever removes the lock it took, or the stale lock it
+// found: two processes taking over the same stale lock cannot remove the lock the first of them took since.
+func (b *WriteBudget) lock(ctx context.Context) (func(), error) {
+	path := b.path + ".lock"
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to lock write budget: %w", err)
+	}
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.Write(token)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to lock write budget: %w", err)
+			}
+			return func() { removeLock(path, token) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock write budget: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > writeBudgetLockStale {
+			if held, err := os.ReadFile(path); err == nil {
+				removeLock(path, held)
+			}
+			continue
+		}
+		if err := sleepContext(ctx, 20*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// removeLock removes a lock file only when it still holds token
+func removeLock(path string, token []byte) {
+	if held, err := os.ReadFile(path); err == nil && bytes.Equal(held, token) {
+		os.Remove(path)
+	}
+}
+
+// openWriteBudget sets the write budget of the authenticated account. a negative limit leaves writes unbudgeted.
+func (c *Client) openWriteBudget(hourly int) error {
+	if hourly < 0 || c.Session.DID == "" {
+		return nil
+	}
+	if hourly == 0 {
+		hourly = defaultWriteBudget
+	}
+	budget, err := OpenWriteBudget(c.Session.DID, hourly)
+	if err != nil {
+		return err
+	}
+	c.WriteBudget = budget
+	return nil
+}
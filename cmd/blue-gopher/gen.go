@@ -0,0 +1,394 @@
+//go:build ignore
+// +build ignore
+
+// gen.go writes targets.go, the targets and flags of the blue-gopher command, from the source of the bluegopher
+// package: the exported methods of its mg.Namespace types, with the first sentence of their doc comments, and the
+// BG_* parameters the targets read, with their descriptions from the environment table of the README.
+//
+//	go generate ./cmd/blue-gopher
+//
+// with -check, it writes nothing and fails when targets.go is out of date.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	pkgDir = "../../bluegopher"
+	readme = "../../README.md"
+)
+
+// target is a method of a namespace
+type target struct {
+	namespace string
+	method    string
+	args      []string
+	synopsis  string
+	// params are the BG_* parameters the target reads
+	params []string
+	decl   *ast.FuncDecl
+}
+
+// envFlag is a BG_* parameter
+type envFlag struct {
+	env    string
+	isBool bool
+	usage  string
+}
+
+var (
+	envPattern = regexp.MustCompile(`^BG_[A-Z0-9_]+$`)
+	envRow     = regexp.MustCompile("^\\| (`BG_[A-Z0-9_]+`(?:, `BG_[A-Z0-9_]+`)*) \\| (.*) \\|$")
+
+	// boolArgs are the functions that read a boolean parameter, with the index of the argument naming it
+	boolArgs = map[string]int{"envBool": 0, "loopbackListenAddr": 1}
+)
+
+func main() {
+	check := flag.Bool("check", false, "fail when targets.go is out of date instead of writing it")
+	flag.Parse()
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, pkgDir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg, ok := pkgs["bluegopher"]
+	if !ok {
+		log.Fatalf("no bluegopher package in %s", pkgDir)
+	}
+
+	namespaces := make(map[string]bool)
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if sel, ok := ts.Type.(*ast.SelectorExpr); ok && sel.Sel.Name == "Namespace" {
+					namespaces[ts.Name.Name] = true
+				}
+			}
+		}
+	}
+
+	var targets []target
+	bools := make(map[string]bool)
+	envs := make(map[string]bool)
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && fn.Name.IsExported() {
+				if recv, ok := fn.Recv.List[0].Type.(*ast.Ident); ok && namespaces[recv.Name] {
+					targets = append(targets, newTarget(recv.Name, fn))
+				}
+			}
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BasicLit:
+				if s, err := strconv.Unquote(n.Value); err == nil && n.Kind == token.STRING && envPattern.MatchString(s) {
+					envs[s] = true
+				}
+			case *ast.CallExpr:
+				// parameters read with envBool, or passed as the public switch of loopbackListenAddr, are boolean flags
+				if ident, ok := n.Fun.(*ast.Ident); ok {
+					if i, ok := boolArgs[ident.Name]; ok && len(n.Args) > i {
+						if lit, ok := n.Args[i].(*ast.BasicLit); ok {
+							s, _ := strconv.Unquote(lit.Value)
+							bools[s] = true
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].name() < targets[j].name()
+	})
+	graph := newParamGraph(pkg, namespaces)
+	for i := range targets {
+		targets[i].params = graph.params(targets[i].decl)
+	}
+
+	usages, err := readEnvTable(readme)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var flags []envFlag
+	for env := range envs {
+		flags = append(flags, envFlag{env: env, isBool: bools[env], usage: usages[env]})
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].env < flags[j].env
+	})
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen.go from the bluegopher package; DO NOT EDIT.\n\npackage main\n\n")
+	fmt.Fprintf(&b, "import \"asw101-bluesky/bluegopher\"\n\n")
+	fmt.Fprintf(&b, "var targets = []target{\n")
+	for _, t := range targets {
+		fmt.Fprintf(&b, "\t{%q, bluegopher.%s{}, %q, %#v, %q, %#v},\n", t.name(), t.namespace, t.method, t.args, t.synopsis, t.params)
+	}
+	fmt.Fprintf(&b, "}\n\nvar flags = []flagSpec{\n")
+	for _, f := range flags {
+		fmt.Fprintf(&b, "\t{%q, %q, %t, %q},\n", flagName(f.env), f.env, f.isBool, f.usage)
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *check {
+		current, err := os.ReadFile("targets.go")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !bytes.Equal(current, src) {
+			log.Fatal("targets.go is out of date, run go generate ./cmd/blue-gopher")
+		}
+		return
+	}
+	if err := os.WriteFile("targets.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newTarget returns the target of a method, with its argument names after the optional context and the first
+// sentence of its doc comment without the name and arguments
+func newTarget(namespace string, fn *ast.FuncDecl) target {
+	t := target{namespace: namespace, method: fn.Name.Name, args: []string{}, decl: fn}
+	for i, field := range fn.Type.Params.List {
+		if sel, ok := field.Type.(*ast.SelectorExpr); ok && i == 0 && sel.Sel.Name == "Context" {
+			continue
+		}
+		for _, name := range field.Names {
+			t.args = append(t.args, name.Name)
+		}
+	}
+
+	doc := strings.Join(strings.Fields(fn.Doc.Text()), " ")
+	doc = strings.TrimPrefix(doc, fn.Name.Name+" ")
+	for strings.HasPrefix(doc, "<") {
+		if _, rest, ok := strings.Cut(doc, "> "); ok {
+			doc = rest
+		} else {
+			break
+		}
+	}
+	if i := strings.Index(doc, ". "); i >= 0 {
+		doc = doc[:i+1]
+	}
+	t.synopsis = doc
+	return t
+}
+
+// paramGraph finds the BG_* parameters a target reads by following the functions, methods, and package variables it
+// refers to by name. LoadParams reads every parameter, so it is not followed: the targets read the parameters of the
+// Params fields they use.
+type paramGraph struct {
+	namespaces map[string]bool
+	// funcs are the functions and package variables, and methods the methods of other types, by name
+	funcs, methods map[string][]ast.Node
+	// targets are the methods of the namespaces, followed only when called on a namespace value such as Bs{}
+	targets map[string]map[string]ast.Node
+	// fields are the parameters of the Params fields set by LoadParams
+	fields map[string][]string
+}
+
+// newParamGraph indexes the declarations of a package
+func newParamGraph(pkg *ast.Package, namespaces map[string]bool) *paramGraph {
+	g := &paramGraph{
+		namespaces: namespaces,
+		funcs:      make(map[string][]ast.Node),
+		methods:    make(map[string][]ast.Node),
+		targets:    make(map[string]map[string]ast.Node),
+		fields:     make(map[string][]string),
+	}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				switch {
+				case decl.Name.Name == "LoadParams" && decl.Recv == nil:
+					g.indexFields(decl.Body)
+				case decl.Recv == nil:
+					g.funcs[decl.Name.Name] = append(g.funcs[decl.Name.Name], decl)
+				case namespaces[recvName(decl)]:
+					if g.targets[recvName(decl)] == nil {
+						g.targets[recvName(decl)] = make(map[string]ast.Node)
+					}
+					g.targets[recvName(decl)][decl.Name.Name] = decl
+				default:
+					g.methods[decl.Name.Name] = append(g.methods[decl.Name.Name], decl)
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if vs, ok := spec.(*ast.ValueSpec); ok {
+						for _, name := range vs.Names {
+							g.funcs[name.Name] = append(g.funcs[name.Name], vs)
+						}
+					}
+				}
+			}
+		}
+	}
+	return g
+}
+
+// indexFields records the parameters LoadParams reads into each Params field, from the fields of its composite
+// literal and its assignments to p.<field>
+func (g *paramGraph) indexFields(body *ast.BlockStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.KeyValueExpr:
+			if key, ok := n.Key.(*ast.Ident); ok {
+				g.fields[key.Name] = append(g.fields[key.Name], envLiterals(n.Value)...)
+			}
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if sel, ok := lhs.(*ast.SelectorExpr); ok {
+					for _, rhs := range n.Rhs {
+						g.fields[sel.Sel.Name] = append(g.fields[sel.Sel.Name], envLiterals(rhs)...)
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+// params returns the sorted parameters read by a target
+func (g *paramGraph) params(decl *ast.FuncDecl) []string {
+	found := make(map[string]bool)
+	seen := map[ast.Node]bool{decl: true}
+	queue := []ast.Node{decl}
+	follow := func(nodes ...ast.Node) {
+		for _, n := range nodes {
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	var inspect func(n ast.Node) bool
+	inspect = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BasicLit:
+			for _, env := range envLiterals(n) {
+				found[env] = true
+			}
+		case *ast.Ident:
+			follow(g.funcs[n.Name]...)
+		case *ast.SelectorExpr:
+			// Params values are named p, as in p, err := LoadParams()
+			if x, ok := n.X.(*ast.Ident); ok && x.Name == "p" {
+				for _, env := range g.fields[n.Sel.Name] {
+					found[env] = true
+				}
+			}
+			follow(g.methods[n.Sel.Name]...)
+			if lit, ok := n.X.(*ast.CompositeLit); ok {
+				if ns, ok := lit.Type.(*ast.Ident); ok && g.targets[ns.Name][n.Sel.Name] != nil {
+					follow(g.targets[ns.Name][n.Sel.Name])
+				}
+			}
+			ast.Inspect(n.X, inspect)
+			return false
+		}
+		return true
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		ast.Inspect(n, inspect)
+	}
+
+	params := []string{}
+	for env := range found {
+		params = append(params, env)
+	}
+	sort.Strings(params)
+	return params
+}
+
+// envLiterals returns the BG_* string literals of an expression
+func envLiterals(n ast.Node) []string {
+	var envs []string
+	ast.Inspect(n, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil && envPattern.MatchString(s) {
+				envs = append(envs, s)
+			}
+		}
+		return true
+	})
+	return envs
+}
+
+// recvName returns the type name of the receiver of a method
+func recvName(fn *ast.FuncDecl) string {
+	typ := fn.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// name returns the <namespace>:<target> name of a target, as mage names it
+func (t target) name() string {
+	lower := func(s string) string {
+		return string(unicode.ToLower(rune(s[0]))) + s[1:]
+	}
+	return strings.ToLower(t.namespace) + ":" + lower(t.method)
+}
+
+// flagName returns the flag of a parameter, such as dry-run for BG_DRY_RUN
+func flagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(env, "BG_")), "_", "-")
+}
+
+// readEnvTable reads the descriptions of the parameters in the environment table of the README
+func readEnvTable(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	usages := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// a row can describe several parameters, such as | `BG_SINCE`, `BG_UNTIL` | ... |
+		if m := envRow.FindStringSubmatch(scanner.Text()); m != nil {
+			for _, env := range strings.Split(m[1], ", ") {
+				usages[strings.Trim(env, "`")] = strings.ReplaceAll(m[2], "`", "")
+			}
+		}
+	}
+	return usages, scanner.Err()
+}
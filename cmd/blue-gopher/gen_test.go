@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestTargetsUpToDate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go run gen.go")
+	}
+	out, err := exec.Command("go", "run", "gen.go", "-check").CombinedOutput()
+	if err != nil {
+		t.Fatalf("go generate ./cmd/blue-gopher changes targets.go: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,202 @@
+// Command blue-gopher runs the targets of the bluegopher package, with flags for the BG_* parameters each target reads:
+//
+//	go install ./cmd/blue-gopher
+//	blue-gopher bs:getAuthorFeeds -limit 50 -format csv alice.bsky.social
+//
+// the targets and flags are generated from the bluegopher package by go generate, so they stay in step with mage.
+package main
+
+//go:generate go run gen.go
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// target is a method of a bluegopher namespace
+type target struct {
+	name      string
+	namespace interface{}
+	method    string
+	args      []string
+	synopsis  string
+	// params are the BG_* parameters the target reads, which it accepts as flags
+	params []string
+}
+
+// flagSpec is a command-line flag that sets an environment variable read by the targets
+type flagSpec struct {
+	name   string
+	env    string
+	isBool bool
+	usage  string
+}
+
+// envFlag is the flag.Value of a flagSpec
+type envFlag struct {
+	env    string
+	isBool bool
+}
+
+func (f envFlag) String() string   { return "" }
+func (f envFlag) IsBoolFlag() bool { return f.isBool }
+func (f envFlag) Set(v string) error {
+	return os.Setenv(f.env, v)
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "help" {
+		usage()
+		return
+	}
+
+	t, err := findTarget(os.Args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fs := targetFlags(t)
+	fs.Parse(os.Args[2:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := runTarget(ctx, t, fs.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// targetFlags returns the flags of the parameters a target reads
+func targetFlags(t target) *flag.FlagSet {
+	fs := flag.NewFlagSet(t.name, flag.ExitOnError)
+	for _, f := range flags {
+		for _, env := range t.params {
+			if f.env == env {
+				fs.Var(envFlag{env: f.env, isBool: f.isBool}, f.name, f.usage+" ("+f.env+")")
+			}
+		}
+	}
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: blue-gopher %s [flags] %s\n", t.name, argNames(t.args))
+		if t.synopsis != "" {
+			fmt.Fprintf(w, "\n%s\n", t.synopsis)
+		}
+		if len(t.params) > 0 {
+			fmt.Fprintf(w, "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// runTarget calls a target with the positional arguments converted to its parameter types
+func runTarget(ctx context.Context, t target, args []string) error {
+	m := reflect.ValueOf(t.namespace).MethodByName(t.method)
+	if !m.IsValid() {
+		return fmt.Errorf("target %q is out of date, run go generate ./cmd/blue-gopher", t.name)
+	}
+
+	var in []reflect.Value
+	mt := m.Type()
+	if mt.NumIn() > 0 && mt.In(0) == contextType {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	if len(args) != len(t.args) {
+		return fmt.Errorf("usage: blue-gopher %s [flags] %s", t.name, argNames(t.args))
+	}
+
+	for i, arg := range args {
+		v, err := convertArg(arg, mt.In(len(in)))
+		if err != nil {
+			return fmt.Errorf("invalid argument <%s> %q: %w", t.args[i], arg, err)
+		}
+		in = append(in, v)
+	}
+
+	out := m.Call(in)
+	if len(out) > 0 {
+		if err, ok := out[len(out)-1].Interface().(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// findTarget returns a target, matching its name case-insensitively like mage
+func findTarget(name string) (target, error) {
+	if !strings.Contains(name, ":") {
+		return target{}, fmt.Errorf("unknown target %q: expected <namespace>:<target>, see blue-gopher help", name)
+	}
+	for _, t := range targets {
+		if strings.EqualFold(t.name, name) {
+			return t, nil
+		}
+	}
+	return target{}, fmt.Errorf("unknown target %q, see blue-gopher help", name)
+}
+
+// argNames formats argument names as <name> placeholders
+func argNames(args []string) string {
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = "<" + arg + ">"
+	}
+	return strings.Join(names, " ")
+}
+
+// convertArg converts a positional argument to one of the parameter types supported by mage
+func convertArg(arg string, t reflect.Type) (reflect.Value, error) {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(arg)
+		return reflect.ValueOf(d), err
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(arg), nil
+	case reflect.Int:
+		n, err := strconv.Atoi(arg)
+		return reflect.ValueOf(n), err
+	case reflect.Bool:
+		b, err := strconv.ParseBool(arg)
+		return reflect.ValueOf(b), err
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(arg, 64)
+		return reflect.ValueOf(f), err
+	}
+	return reflect.Value{}, errors.New("unsupported parameter type " + t.String())
+}
+
+// usage lists the targets and flags, each target accepting the flags of the parameters it reads
+func usage() {
+	fmt.Println("Usage: blue-gopher <namespace>:<target> [flags] [arguments]")
+	fmt.Println()
+	fmt.Println("Targets:")
+	for _, t := range targets {
+		fmt.Printf("  %s\n", strings.TrimSpace(t.name+" "+argNames(t.args)))
+		if t.synopsis != "" {
+			fmt.Printf("      %s\n", t.synopsis)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Flags:")
+	for _, f := range flags {
+		fmt.Printf("  -%-14s %s (%s)\n", f.name, f.usage, f.env)
+	}
+	fmt.Println()
+	fmt.Println("Run blue-gopher <namespace>:<target> -h for the flags of a target.")
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestTargetFlags(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []string
+		absent []string
+	}{
+		{"bs:tid", nil, []string{"limit", "profile"}},
+		{"bs:getAuthorFeeds", []string{"limit", "format", "resume", "profile"}, []string{"pg-table", "serve-token"}},
+		{"pg:createIndexes", []string{"pg-table", "pg-schema"}, []string{"limit", "format"}},
+	}
+	for _, tt := range tests {
+		target, err := findTarget(tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs := targetFlags(target)
+		for _, name := range tt.want {
+			if fs.Lookup(name) == nil {
+				t.Errorf("%s has no -%s flag", tt.target, name)
+			}
+		}
+		for _, name := range tt.absent {
+			if fs.Lookup(name) != nil {
+				t.Errorf("%s has a -%s flag for a parameter it does not read", tt.target, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Code generated by gen.go from the bluegopher package; DO NOT EDIT.
+
+package main
+
+import "asw101-bluesky/bluegopher"
+
+var targets = []target{
+	{"bs:altTextAudit", bluegopher.Bs{}, "AltTextAudit", []string{"actor"}, "walks the posts of an author with images and outputs each image without alt text, with a link to its post, then logs the share of images that have alt text.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_TEMPLATE"}},
+	{"bs:atUri", bluegopher.Bs{}, "AtUri", []string{"rawURL"}, "converts a bsky.app profile, post, list, feed, or starter pack URL to its AT URI.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PROFILE"}},
+	{"bs:authorStats", bluegopher.Bs{}, "AuthorStats", []string{"actor"}, "summarizes the engagement, posting times, and hashtags of an author's posts as JSON.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_FILTER", "BG_LIMIT", "BG_PROFILE", "BG_PROGRESS", "BG_TZ"}},
+	{"bs:autoReply", bluegopher.Bs{}, "AutoReply", []string{"name", "text"}, "runs a bot that replies with a fixed text to the mentions and replies of the account.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_INTERVAL", "BG_METRICS_ADDR", "BG_PROFILE", "BG_REASONS"}},
+	{"bs:backup", bluegopher.Bs{}, "Backup", []string{"dir"}, "backs up the authenticated account into a directory: the repository as repo.car, every blob under blobs/, the preferences, and a manifest of checksums.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_LIMIT", "BG_PROFILE", "BG_PROGRESS"}},
+	{"bs:backupBlobs", bluegopher.Bs{}, "BackupBlobs", []string{"actor", "dir"}, "downloads every blob for an account into dir, one file per CID.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:backupVerify", bluegopher.Bs{}, "BackupVerify", []string{"backup"}, "checks a backup directory or .tar.gz archive written by bs:backup: every file must match its checksum in the manifest, the repository must decode with every block matching its CID, and every blob must match its CID.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PROFILE"}},
+	{"bs:blockBulk", bluegopher.Bs{}, "BlockBulk", []string{}, "blocks the accounts read from standard input, such as a community blocklist exported as JSON lines.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FAILED", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE", "BG_WRITE_DELAY"}},
+	{"bs:communities", bluegopher.Bs{}, "Communities", []string{"input"}, "finds the clusters of a JSONL follow graph, - for standard input, such as the output of bs:crawlGraph, and outputs each with its size and its BG_LIMIT (default 10) most central accounts.", []string{"BG_CACHE_DIR", "BG_CLUSTERING", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DIRECTION", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_MIN_DEGREE", "BG_MIN_SIZE", "BG_MUTUAL", "BG_OUTPUT", "BG_PROFILE", "BG_SUBJECT", "BG_TEMPLATE"}},
+	{"bs:crawlGraph", bluegopher.Bs{}, "CrawlGraph", []string{"seedActor", "depth"}, "crawls the follow graph breadth-first from an account up to depth hops and outputs each follow as a {\"src\",\"dst\",\"type\":\"follows\"} edge.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DIRECTION", "BG_FIELDS", "BG_FORMAT", "BG_MAX_NODES", "BG_MAX_PER_NODE", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE"}},
+	{"bs:createRecord", bluegopher.Bs{}, "CreateRecord", []string{"text"}, "creates a new post", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:createRecordFromFile", bluegopher.Bs{}, "CreateRecordFromFile", []string{"file"}, "creates a new post with the text of a file, with facets for mentions and links", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:createRecordFromStdin", bluegopher.Bs{}, "CreateRecordFromStdin", []string{}, "creates a new post with the text read from standard input, with facets for mentions and links", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:createSession", bluegopher.Bs{}, "CreateSession", []string{}, "authenticates to the Bluesky API using the BLUESKY_HANDLE and BLUESKY_PASSWORD env vars", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PROFILE"}},
+	{"bs:didHistory", bluegopher.Bs{}, "DidHistory", []string{"did"}, "prints the audit log of a did:plc, or of the DID of a handle, from the PLC directory: one item per operation with its time, the handle and PDS it set, and what it changed, such as handle changes and account migrations between PDS hosts.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:digest", bluegopher.Bs{}, "Digest", []string{"source", "days"}, "renders the top posts of the last days from a list or feed URL, or a search query, ranked by likes, reposts, replies, and quotes, as a Markdown digest, or HTML ready to email with BG_FORMAT=html.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_DIGEST_TEMPLATE", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE"}},
+	{"bs:dmHistory", bluegopher.Bs{}, "DmHistory", []string{"convoID"}, "retrieves every message in a conversation, newest first", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:dmList", bluegopher.Bs{}, "DmList", []string{}, "lists the conversations of the authenticated user.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:dmSend", bluegopher.Bs{}, "DmSend", []string{"handle", "text"}, "sends a direct message to an actor", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:engagementByHour", bluegopher.Bs{}, "EngagementByHour", []string{"actor"}, "reports the average likes and reposts of an author's posts by weekday and hour of creation", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FILTER", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_TEMPLATE", "BG_TZ"}},
+	{"bs:enrich", bluegopher.Bs{}, "Enrich", []string{}, "reads JSON items from standard input, such as exported posts or stream events, runs them through the enrichment stages of BG_ENRICH in order, and outputs them with the added fields under enrichment.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_EMBEDDINGS_MODEL", "BG_EMBEDDINGS_PROVIDER", "BG_ENRICH", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_TEMPLATE"}},
+	{"bs:exportModeration", bluegopher.Bs{}, "ExportModeration", []string{}, "outputs the blocks and mutes of the account, as {\"did\",\"handle\",\"type\"} lines with type block or mute.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:extractRecords", bluegopher.Bs{}, "ExtractRecords", []string{"repo", "dir"}, "writes the records of a repository archive to one <collection>.jsonl file per collection in dir, without a PDS.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PROFILE"}},
+	{"bs:followBulk", bluegopher.Bs{}, "FollowBulk", []string{}, "follows the accounts read from standard input.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FAILED", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE", "BG_WRITE_DELAY"}},
+	{"bs:followList", bluegopher.Bs{}, "FollowList", []string{"listURL"}, "follows every member of a list or starter pack, given by its bsky.app URL or AT URI.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FAILED", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE", "BG_WRITE_DELAY"}},
+	{"bs:followerDiff", bluegopher.Bs{}, "FollowerDiff", []string{"actor", "previous"}, "compares the current followers of an actor with a previous JSONL export of them and outputs the gained and lost followers.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_SNAPSHOT_DIR", "BG_TEMPLATE"}},
+	{"bs:frequency", bluegopher.Bs{}, "Frequency", []string{}, "counts the hashtags, words, and mentions of posts read as JSONL from standard input", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:getAuthorFeed", bluegopher.Bs{}, "GetAuthorFeed", []string{"author"}, "retrieves a single page of an author feed", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FILTER", "BG_FORMAT", "BG_INCLUDE_PINS", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:getAuthorFeeds", bluegopher.Bs{}, "GetAuthorFeeds", []string{"author"}, "retrieves the author feed", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FILTER", "BG_FORMAT", "BG_INCLUDE_PINS", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_RESUME", "BG_TEMPLATE"}},
+	{"bs:getAuthorFeedsBulk", bluegopher.Bs{}, "GetAuthorFeedsBulk", []string{"pageLimit"}, "retrieves the author feed for a list of authors.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONCURRENCY", "BG_CONFIG_DIR", "BG_FAILED", "BG_FIELDS", "BG_FILTER", "BG_FORMAT", "BG_INCLUDE_PINS", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE"}},
+	{"bs:getFeedGenerator", bluegopher.Bs{}, "GetFeedGenerator", []string{"feed"}, "prints the did, creator, displayName, likeCount, and online status of a feed generator by AT URI or bsky.app URL", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:getFeedGenerators", bluegopher.Bs{}, "GetFeedGenerators", []string{"feeds"}, "retrieves the views of comma-separated feed generators by AT URI or bsky.app URL", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:getFollowers", bluegopher.Bs{}, "GetFollowers", []string{"actor"}, "retrieves the followers of a specified actor", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE"}},
+	{"bs:getFollows", bluegopher.Bs{}, "GetFollows", []string{"actor"}, "retrieves the followers of a specified actor", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_RESUME", "BG_TEMPLATE"}},
+	{"bs:getPopularFeedGenerators", bluegopher.Bs{}, "GetPopularFeedGenerators", []string{"pageLimit"}, "retrieves popular feed generators.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_QUERY", "BG_TEMPLATE"}},
+	{"bs:getProfile", bluegopher.Bs{}, "GetProfile", []string{"actor"}, "retrieves the profile for a given actor and prints the profile data", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:getProfiles", bluegopher.Bs{}, "GetProfiles", []string{"profiles"}, "retrieves the profiles of multiple actors", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:getProfilesBulk", bluegopher.Bs{}, "GetProfilesBulk", []string{}, "retrieves the profiles of multiple actors from standard input", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONCURRENCY", "BG_CONFIG_DIR", "BG_FAILED", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE"}},
+	{"bs:getServiceAuth", bluegopher.Bs{}, "GetServiceAuth", []string{"aud", "lxm"}, "mints a service auth token for a service DID, such as did:web:video.bsky.app, and a lexicon method.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:getTrendingTopics", bluegopher.Bs{}, "GetTrendingTopics", []string{}, "retrieves the current trending topics, then the suggested topics, one per line", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:graphExport", bluegopher.Bs{}, "GraphExport", []string{"input", "format", "path"}, "converts a JSONL graph to dot, graphml, or gephi files at path.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_DIRECTION", "BG_MIN_DEGREE", "BG_PROFILE", "BG_SUBJECT"}},
+	{"bs:listClone", bluegopher.Bs{}, "ListClone", []string{"sourceListURL", "newName"}, "creates a list with the purpose and description of any account's list and adds all of its members", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FIELDS", "BG_FORMAT", "BG_LIST_PURPOSE", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE", "BG_WRITE_DELAY"}},
+	{"bs:listCreate", bluegopher.Bs{}, "ListCreate", []string{"name", "description"}, "creates a new list", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_LIST_PURPOSE", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:listItem", bluegopher.Bs{}, "ListItem", []string{"listURL", "actor"}, "adds an actor to a list by its URL", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:listItemBulk", bluegopher.Bs{}, "ListItemBulk", []string{"listURL"}, "reads accounts from standard input and adds them to the list.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_FAILED", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME"}},
+	{"bs:listRepos", bluegopher.Bs{}, "ListRepos", []string{}, "streams every repository (did, head, rev, active) hosted on the PDS", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:listSync", bluegopher.Bs{}, "ListSync", []string{"listURL"}, "adds and removes list members so the list matches the accounts read from standard input.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE", "BG_WRITE_DELAY"}},
+	{"bs:migrate", bluegopher.Bs{}, "Migrate", []string{"pds", "handle"}, "moves the authenticated account to another PDS, as <handle> there: it creates the account with service auth, imports the repository, uploads the blobs and preferences, updates the PLC DID document, then activates the new account and deactivates the old one.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_INVITE_CODE", "BG_MIGRATE_EMAIL", "BG_MIGRATE_PASSWORD", "BG_PLC_TOKEN", "BG_PROFILE"}},
+	{"bs:mutuals", bluegopher.Bs{}, "Mutuals", []string{"actor"}, "outputs the accounts that follow the actor and that the actor follows", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:notFollowingBack", bluegopher.Bs{}, "NotFollowingBack", []string{"actor"}, "outputs the accounts the actor follows that do not follow the actor back", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:postThread", bluegopher.Bs{}, "PostThread", []string{"file"}, "posts the text of a file, or of standard input when file is -, as a numbered thread split at sentence boundaries, and prints the AT URI of each post.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_PROFILE"}},
+	{"bs:profiles", bluegopher.Bs{}, "Profiles", []string{}, "lists the account profiles in the config file.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:prunePosts", bluegopher.Bs{}, "PrunePosts", []string{"days"}, "deletes the account's posts created more than days ago.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FIELDS", "BG_FORMAT", "BG_KEEP", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE", "BG_WRITE_DELAY"}},
+	{"bs:queryLabels", bluegopher.Bs{}, "QueryLabels", []string{"uriPatterns"}, "retrieves the labels applied to comma-separated subject URIs or DIDs.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:retryFailed", bluegopher.Bs{}, "RetryFailed", []string{"file"}, "re-runs the inputs recorded in a .failed file by a bulk target.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONCURRENCY", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FAILED", "BG_FIELDS", "BG_FILTER", "BG_FORMAT", "BG_INCLUDE_PINS", "BG_LIMIT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE", "BG_WRITE_DELAY"}},
+	{"bs:rss", bluegopher.Bs{}, "Rss", []string{"source", "outFile"}, "writes the latest posts of an actor or a list URL as an RSS 2.0 document, or as Atom when outFile ends in .atom.", []string{"BG_ADDR", "BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_FILTER", "BG_INTERVAL", "BG_LIMIT", "BG_PROFILE"}},
+	{"bs:schedule", bluegopher.Bs{}, "Schedule", []string{"file"}, "runs targets on a schedule, such as daily follower snapshots into Postgres or hourly search syncs, until interrupted.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_INTERVAL", "BG_METRICS_ADDR", "BG_PROFILE", "BG_REASONS", "BG_TZ"}},
+	{"bs:scoreProfiles", bluegopher.Bs{}, "ScoreProfiles", []string{}, "reads accounts from standard input and outputs a heuristic spam score from 0 to 100 for each, with the reasons for it.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONCURRENCY", "BG_CONFIG_DIR", "BG_FAILED", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_TEMPLATE"}},
+	{"bs:searchPosts", bluegopher.Bs{}, "SearchPosts", []string{"query"}, "searches posts and outputs the first page", []string{"BG_AUTHOR", "BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_DOMAIN", "BG_FIELDS", "BG_FORMAT", "BG_LANG", "BG_LIMIT", "BG_MENTIONS", "BG_OUTPUT", "BG_PROFILE", "BG_SINCE", "BG_SORT", "BG_TAGS", "BG_TEMPLATE", "BG_UNTIL", "BG_URL"}},
+	{"bs:searchPostsBulk", bluegopher.Bs{}, "SearchPostsBulk", []string{"pageLimit", "query"}, "searches posts and outputs multiple pages, filtered with the BG_RULES filter rules", []string{"BG_AUTHOR", "BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_DOMAIN", "BG_FIELDS", "BG_FORMAT", "BG_KEYWORDS", "BG_LANG", "BG_LIMIT", "BG_MENTIONS", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_RESUME", "BG_RULES", "BG_SINCE", "BG_SORT", "BG_TAGS", "BG_TEMPLATE", "BG_UNTIL", "BG_URL"}},
+	{"bs:serve", bluegopher.Bs{}, "Serve", []string{"addr"}, "serves a local JSON API backed by the authenticated client until interrupted: GET /feed, GET /search, POST /post, and POST /follow.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PROFILE", "BG_SERVE_PUBLIC", "BG_SERVE_TOKEN"}},
+	{"bs:sessionBroker", bluegopher.Bs{}, "SessionBroker", []string{"addr"}, "owns the sessions of the account profiles and hands their access tokens to commands run with BLUESKY_SESSION_BROKER set to its URL, until interrupted.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PROFILE"}},
+	{"bs:syncModeration", bluegopher.Bs{}, "SyncModeration", []string{"fromProfile", "toProfile"}, "copies the blocks and mutes of one account profile of the config file to another, and outputs each change.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_SYNC_MODE", "BG_TEMPLATE"}},
+	{"bs:tid", bluegopher.Bs{}, "Tid", []string{"value"}, "prints a new TID when value is now, the time and clock ID of a TID, or the TID of an RFC 3339 time with clock ID 0", []string{}},
+	{"bs:tui", bluegopher.Bs{}, "Tui", []string{}, "pages through the home timeline, author feeds, and notifications in a full-screen terminal UI, opens threads, and likes, reposts, and replies to posts", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_INTERVAL", "BG_LIMIT", "BG_METRICS_ADDR", "BG_PROFILE", "BG_REASONS"}},
+	{"bs:unroll", bluegopher.Bs{}, "Unroll", []string{"postURL"}, "fetches the thread an author wrote by replying to themselves, from a bsky.app URL or AT URI of any of its posts, and writes it as a single Markdown document, or HTML with BG_FORMAT=html.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_FORMAT", "BG_IMAGES_DIR", "BG_OUTPUT", "BG_PROFILE"}},
+	{"bs:updateHandle", bluegopher.Bs{}, "UpdateHandle", []string{"handle"}, "changes the account handle, waiting for custom-domain DNS or well-known verification to propagate", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"bs:url", bluegopher.Bs{}, "Url", []string{"atURI"}, "converts the AT URI of a profile, post, list, feed, or starter pack to its bsky.app URL", []string{}},
+	{"bs:verifyHandle", bluegopher.Bs{}, "VerifyHandle", []string{"handle"}, "checks that a handle is verified in both directions: the DNS TXT record _atproto.<handle> and https://<handle>/.well-known/atproto-did are checked, and must not disagree, then the DID document they resolve to must claim the handle.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PROFILE"}},
+	{"bs:watchNotifications", bluegopher.Bs{}, "WatchNotifications", []string{}, "polls the notifications of the account every BG_INTERVAL and outputs each new one with a BG_REASONS reason as a JSON line, also posting it to BG_WEBHOOK when set.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_INTERVAL", "BG_METRICS_ADDR", "BG_OUTPUT", "BG_PROFILE", "BG_REASONS", "BG_TEMPLATE", "BG_WEBHOOK"}},
+	{"duck:exportParquet", bluegopher.Duck{}, "ExportParquet", []string{"file", "parquetFile"}, "converts a JSONL export to a Parquet file readable by DuckDB and other engines", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_DUCKDB", "BG_INTERVAL", "BG_METRICS_ADDR", "BG_PROFILE", "BG_REASONS"}},
+	{"duck:import", bluegopher.Duck{}, "Import", []string{"file", "database", "table"}, "loads a JSONL export into a table of a DuckDB database file, replacing the table", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_DUCKDB", "BG_INTERVAL", "BG_METRICS_ADDR", "BG_PROFILE", "BG_REASONS"}},
+	{"duck:query", bluegopher.Duck{}, "Query", []string{"database", "query"}, "runs a query against a DuckDB database file and outputs the rows in the BG_FORMAT format", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_DUCKDB", "BG_FORMAT", "BG_INTERVAL", "BG_METRICS_ADDR", "BG_PROFILE", "BG_REASONS"}},
+	{"hello:hello", bluegopher.Hello{}, "Hello", []string{}, "says hello", []string{}},
+	{"js:firehose", bluegopher.Js{}, "Firehose", []string{}, "streams the record operations, identity, and account events of the relay firehose as JSON lines, in the form of js:subscribe with a seq field.", []string{"BG_CACHE_DIR", "BG_COLLECTIONS", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_DIDS", "BG_FIELDS", "BG_FIREHOSE_URL", "BG_FORMAT", "BG_INTERVAL", "BG_KEYWORDS", "BG_LIMIT", "BG_METRICS_ADDR", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_REASONS", "BG_RESUME", "BG_RULES", "BG_TEMPLATE"}},
+	{"js:subscribe", bluegopher.Js{}, "Subscribe", []string{}, "streams the events of a Jetstream endpoint as JSON lines, filtered with BG_COLLECTIONS, BG_DIDS, and the BG_RULES filter rules, and stops after BG_LIMIT events.", []string{"BG_CACHE_DIR", "BG_COLLECTIONS", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_DIDS", "BG_FIELDS", "BG_FORMAT", "BG_INTERVAL", "BG_JETSTREAM_URL", "BG_KEYWORDS", "BG_LIMIT", "BG_METRICS_ADDR", "BG_OUTPUT", "BG_PROFILE", "BG_PROGRESS", "BG_REASONS", "BG_RULES", "BG_TEMPLATE"}},
+	{"lb:emit", bluegopher.Lb{}, "Emit", []string{"subject", "val"}, "applies a label value to an account DID or record AT URI, signed by the BG_LABELER_DID labeler", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_LABELER_DID", "BG_LABELER_KEY", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"lb:generateKey", bluegopher.Lb{}, "GenerateKey", []string{}, "prints a new P-256 signing key for BG_LABELER_KEY and the publicKeyMultibase of its #atproto_label verification method, which the DID document of the labeler account must list", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PROFILE"}},
+	{"lb:negate", bluegopher.Lb{}, "Negate", []string{"subject", "val"}, "removes a label value from an account DID or record AT URI by issuing a negation label", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_LABELER_DID", "BG_LABELER_KEY", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"lb:serve", bluegopher.Lb{}, "Serve", []string{"addr"}, "serves the issued labels with com.atproto.label.queryLabels and subscribeLabels until interrupted", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"mcp:serve", bluegopher.Mcp{}, "Serve", []string{}, "runs a Model Context Protocol server over stdio, exposing search, profiles, author feeds, posting, and list management as tools for LLM agents.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_MCP_WRITE", "BG_PROFILE"}},
+	{"pg:buildList", bluegopher.Pg{}, "BuildList", []string{"listURL", "query"}, "adds the accounts returned by a query, from its did or handle column or else its first column, to a list or to the list of a starter pack.", []string{"BG_ARGS", "BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DRY_RUN", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PG_SCHEMA", "BG_PROFILE", "BG_TEMPLATE", "BG_WRITE_DELAY"}},
+	{"pg:createAnalyticsViews", bluegopher.Pg{}, "CreateAnalyticsViews", []string{}, "creates the top_posters, daily_post_volume, follower_counts, and engagement_leaders materialized views, applying any pending migrations, and populates them", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:createBlueskyTable", bluegopher.Pg{}, "CreateBlueskyTable", []string{}, "creates a table for storing JSON objects, applying any pending migrations", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:createIndexes", bluegopher.Pg{}, "CreateIndexes", []string{}, "creates GIN indexes on the JSONB data and expression indexes on the handle and author DID of the BG_PG_TABLE table (default bluesky), and GIN indexes on the typed tables", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE"}},
+	{"pg:createSchema", bluegopher.Pg{}, "CreateSchema", []string{}, "creates the typed posts, profiles, followers, follows, and listitems tables, applying any pending migrations", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:dedupe", bluegopher.Pg{}, "Dedupe", []string{"key"}, "deletes the rows of the bluesky table that repeat the value of a JSON key within their name, keeping the newest.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE"}},
+	{"pg:dropBlueskyTable", bluegopher.Pg{}, "DropBlueskyTable", []string{}, "drops the bluesky table", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:dropSchema", bluegopher.Pg{}, "DropSchema", []string{}, "drops the typed tables created by pg:createSchema.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:embedPosts", bluegopher.Pg{}, "EmbedPosts", []string{}, "computes embeddings for the posts table that have none yet, with the BG_EMBEDDINGS_PROVIDER provider", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_EMBEDDINGS_MODEL", "BG_EMBEDDINGS_PROVIDER", "BG_LIMIT", "BG_PG_SCHEMA", "BG_PROFILE", "BG_PROGRESS"}},
+	{"pg:export", bluegopher.Pg{}, "Export", []string{"source", "outFile"}, "writes the rows of a table or query, with the bind parameters of BG_ARGS, to a JSONL, CSV, TSV, or Parquet file chosen by the extension.", []string{"BG_ARGS", "BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_DUCKDB", "BG_FIELDS", "BG_INTERVAL", "BG_METRICS_ADDR", "BG_PG_SCHEMA", "BG_PROFILE", "BG_REASONS", "BG_TEMPLATE"}},
+	{"pg:followerGrowth", bluegopher.Pg{}, "FollowerGrowth", []string{"actor"}, "outputs the follower snapshots of an actor, oldest first, with the change since the previous snapshot and a bar chart of the follower count.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PG_SCHEMA", "BG_PROFILE", "BG_TEMPLATE", "BG_TZ"}},
+	{"pg:graphExport", bluegopher.Pg{}, "GraphExport", []string{"format", "path"}, "converts the graph of the followers and follows tables to dot, graphml, or gephi files at path.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_MIN_DEGREE", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:importJsonFile", bluegopher.Pg{}, "ImportJsonFile", []string{"filePath", "name"}, "imports JSON lines from a file into the bluesky table, in transactions of BG_BATCH_SIZE lines", []string{"BG_BATCH_SIZE", "BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE", "BG_PROGRESS"}},
+	{"pg:importJsonFileFast", bluegopher.Pg{}, "ImportJsonFileFast", []string{"filePath", "name"}, "imports JSON lines from a file into the bluesky table with COPY, in transactions of BG_BATCH_SIZE lines", []string{"BG_BATCH_SIZE", "BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE", "BG_PROGRESS"}},
+	{"pg:importStdin", bluegopher.Pg{}, "ImportStdin", []string{"name"}, "imports JSON lines from standard input into the bluesky table, so the output of bs targets can be piped in directly.", []string{"BG_BATCH_SIZE", "BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE", "BG_PROGRESS"}},
+	{"pg:importTable", bluegopher.Pg{}, "ImportTable", []string{"table", "filePath", "key"}, "upserts JSON lines from a file into a typed table.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE", "BG_PROGRESS"}},
+	{"pg:ingest", bluegopher.Pg{}, "Ingest", []string{"source"}, "writes the events of jetstream or firehose into the typed tables until interrupted, in transactions of BG_BATCH_SIZE events.", []string{"BG_BATCH_SIZE", "BG_CACHE_DIR", "BG_COLLECTIONS", "BG_CONFIG_DIR", "BG_CURSOR", "BG_DIDS", "BG_FIREHOSE_URL", "BG_INTERVAL", "BG_JETSTREAM_URL", "BG_KEYWORDS", "BG_LIMIT", "BG_METRICS_ADDR", "BG_PG_SCHEMA", "BG_PROFILE", "BG_PROGRESS", "BG_REASONS", "BG_RULES"}},
+	{"pg:listTables", bluegopher.Pg{}, "ListTables", []string{}, "lists the tables of the current schema, BG_PG_SCHEMA or public", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:migrate", bluegopher.Pg{}, "Migrate", []string{}, "applies the pending schema migrations", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:migrateStatus", bluegopher.Pg{}, "MigrateStatus", []string{}, "lists the schema migrations and when they were applied", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:postsByAuthor", bluegopher.Pg{}, "PostsByAuthor", []string{"actor"}, "outputs the posts and feed items of an author in the bluesky table, newest import first", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"pg:postsContaining", bluegopher.Pg{}, "PostsContaining", []string{"term"}, "outputs the posts and feed items in the bluesky table whose text contains a term, ignoring case", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"pg:prune", bluegopher.Pg{}, "Prune", []string{"name", "olderThan"}, "deletes the rows of the bluesky table imported under a name longer ago than a duration, such as 720h for 30 days", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE"}},
+	{"pg:query", bluegopher.Pg{}, "Query", []string{"query"}, "runs an arbitrary query, with the bind parameters of BG_ARGS, and outputs the rows in the BG_FORMAT format.", []string{"BG_ARGS", "BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_OUTPUT", "BG_PG_SCHEMA", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"pg:queryHandles", bluegopher.Pg{}, "QueryHandles", []string{"name"}, "queries the bluesky table and selects the \"handle\" from the JSON column, filtered by name", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE"}},
+	{"pg:refreshViews", bluegopher.Pg{}, "RefreshViews", []string{}, "refreshes the analytics views with the current data of the typed tables", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:rollback", bluegopher.Pg{}, "Rollback", []string{"steps"}, "reverts the last steps applied schema migrations", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:searchLocal", bluegopher.Pg{}, "SearchLocal", []string{"query"}, "searches the text of the imported posts and feed items, best matches first.", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"pg:semanticSearch", bluegopher.Pg{}, "SemanticSearch", []string{"query"}, "outputs the posts whose embeddings are nearest to the query, by cosine distance", []string{"BG_CACHE_DIR", "BG_COLUMNS", "BG_CONFIG_DIR", "BG_EMBEDDINGS_MODEL", "BG_EMBEDDINGS_PROVIDER", "BG_FIELDS", "BG_FORMAT", "BG_LIMIT", "BG_OUTPUT", "BG_PG_SCHEMA", "BG_PROFILE", "BG_TEMPLATE"}},
+	{"pg:serveFeeds", bluegopher.Pg{}, "ServeFeeds", []string{"addr"}, "runs a feed generator serving the SQL feeds of the BG_FEEDS directory, one <name>.sql query per feed, until interrupted.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_FEEDGEN_HOSTNAME", "BG_FEEDGEN_PUBLISHER", "BG_FEEDS", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:snapshotFollowers", bluegopher.Pg{}, "SnapshotFollowers", []string{"actor"}, "records the current follower, follows, and posts counts of an actor in follower_snapshots.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PROFILE"}},
+	{"pg:stats", bluegopher.Pg{}, "Stats", []string{}, "outputs the size of each table, and the rows, import times, and distinct handles and DIDs of each name in the bluesky table", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_PG_SCHEMA", "BG_PG_TABLE", "BG_PROFILE"}},
+	{"pg:syncAuthorFeed", bluegopher.Pg{}, "SyncAuthorFeed", []string{"actor"}, "fetches the new posts of an author feed into the posts table, with the name authorFeed:<actor>.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_FILTER", "BG_FULL", "BG_LIMIT", "BG_PG_SCHEMA", "BG_PROFILE", "BG_PROGRESS"}},
+	{"pg:syncFollowers", bluegopher.Pg{}, "SyncFollowers", []string{"actor"}, "fetches the new followers of an actor into the followers table, with the actor's DID as subject.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_FULL", "BG_LIMIT", "BG_PG_SCHEMA", "BG_PROFILE", "BG_PROGRESS"}},
+	{"pg:syncSearch", bluegopher.Pg{}, "SyncSearch", []string{"query"}, "fetches the new posts matching a search query into the posts table, with the name search:<query>.", []string{"BG_CACHE_DIR", "BG_CONFIG_DIR", "BG_FULL", "BG_KEYWORDS", "BG_LIMIT", "BG_PG_SCHEMA", "BG_PROFILE", "BG_PROGRESS", "BG_RULES", "BG_SORT"}},
+}
+
+var flags = []flagSpec{
+	{"addr", "BG_ADDR", false, "listen address bs:rss serves its feed on, such as :8080, instead of exiting after writing it"},
+	{"args", "BG_ARGS", false, "JSON array of the $1, $2, ... bind parameters of pg:query and pg:export, e.g. [\"alice.bsky.social\", 10]"},
+	{"author", "BG_AUTHOR", false, "search filters"},
+	{"batch-size", "BG_BATCH_SIZE", false, "rows pg:importJsonFile, pg:importJsonFileFast, and pg:importStdin, or events pg:ingest, commit per transaction, default 10000"},
+	{"cache-dir", "BG_CACHE_DIR", false, "directory of cached sessions, defaults to ~/.cache/blue-gopher"},
+	{"clustering", "BG_CLUSTERING", false, "community detection of bs:communities: labels (label propagation, default) or components (connected components)"},
+	{"collections", "BG_COLLECTIONS", false, "comma-separated record collections js:subscribe, js:firehose, and pg:ingest stream, such as app.bsky.feed.post or app.bsky.feed.*"},
+	{"columns", "BG_COLUMNS", false, "comma-separated csv/tsv columns as dotted paths, defaults to BG_FIELDS, e.g. did,handle,displayName,createdAt or uri,author.handle,record.text"},
+	{"concurrency", "BG_CONCURRENCY", false, "number of inputs bulk targets process in parallel (default 4)"},
+	{"config-dir", "BG_CONFIG_DIR", false, "directory of config.json, defaults to ~/.config/blue-gopher"},
+	{"cursor", "BG_CURSOR", false, "cursor of the first page, the Jetstream time_us js:subscribe replays from, or the sequence number js:firehose replays from"},
+	{"dids", "BG_DIDS", false, "comma-separated repository DIDs js:subscribe, js:firehose, and pg:ingest stream"},
+	{"digest-template", "BG_DIGEST_TEMPLATE", false, "path of a Go template for bs:digest, executed with the Digest type of bluegopher/digest.go"},
+	{"direction", "BG_DIRECTION", false, "edges bs:crawlGraph follows from each account: follows (default), followers, or both"},
+	{"domain", "BG_DOMAIN", false, "search filters"},
+	{"dry-run", "BG_DRY_RUN", true, "report the records bulk write targets such as bs:followBulk would create without creating them"},
+	{"duckdb", "BG_DUCKDB", false, "duckdb CLI run by the duck: targets and Parquet exports of pg:export, defaults to duckdb on the PATH"},
+	{"embeddings-model", "BG_EMBEDDINGS_MODEL", false, "embeddings model, or the deployment for azure, defaults to text-embedding-3-small or nomic-embed-text for ollama"},
+	{"embeddings-provider", "BG_EMBEDDINGS_PROVIDER", false, "embeddings provider of pg:embedPosts and pg:semanticSearch: openai (default, OPENAI_API_KEY, OPENAI_BASE_URL), azure (AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY), or ollama (OLLAMA_HOST)"},
+	{"enrich", "BG_ENRICH", false, "stages of bs:enrich, in order: lang, sentiment, links, embedding, or stages registered with RegisterEnricher"},
+	{"failed", "BG_FAILED", false, "file the failed inputs of bulk targets are written to (default <target>.failed, e.g. bs-listItemBulk.failed)"},
+	{"feedgen-hostname", "BG_FEEDGEN_HOSTNAME", false, "public hostname of pg:serveFeeds, whose service DID is did:web:<hostname>"},
+	{"feedgen-publisher", "BG_FEEDGEN_PUBLISHER", false, "DID of the account publishing the app.bsky.feed.generator records, defaults to the service DID"},
+	{"feeds", "BG_FEEDS", false, "directory of the <name>.sql feed queries served by pg:serveFeeds, defaults to feeds"},
+	{"fields", "BG_FIELDS", false, "comma-separated fields to keep in each item as dotted paths, e.g. did,handle,followersCount"},
+	{"filter", "BG_FILTER", false, "author feed filter: posts_with_replies (default), posts_no_replies, posts_with_media, posts_and_author_threads"},
+	{"firehose-url", "BG_FIREHOSE_URL", false, "relay subscribeRepos endpoint of js:firehose, defaults to wss://bsky.network/xrpc/com.atproto.sync.subscribeRepos"},
+	{"format", "BG_FORMAT", false, "output format of the exporting targets: jsonl (default), csv, tsv, or table (aligned columns)"},
+	{"full", "BG_FULL", true, "true makes pg:syncAuthorFeed, pg:syncFollowers, and pg:syncSearch fetch everything instead of only what is newer than the last sync"},
+	{"images-dir", "BG_IMAGES_DIR", false, "directory bs:unroll downloads images into, by default images next to a local BG_OUTPUT"},
+	{"include-pins", "BG_INCLUDE_PINS", true, "include pinned posts in author feeds, defaults to true"},
+	{"interval", "BG_INTERVAL", false, "pause between the polls of bs:watchNotifications and the bots, and between the refreshes of bs:rss, defaults to 30s"},
+	{"invite-code", "BG_INVITE_CODE", false, "invite code of the new PDS, when it requires one"},
+	{"jetstream-url", "BG_JETSTREAM_URL", false, "Jetstream subscribe endpoint of the js: targets, defaults to wss://jetstream2.us-east.bsky.network/subscribe"},
+	{"keep", "BG_KEEP", false, "comma-separated posts bs:prunePosts keeps: pinned (the pinned post), liked (posts the account liked itself)"},
+	{"keywords", "BG_KEYWORDS", false, "comma-separated keywords, one of which the posts kept by the filter rules must contain, ignoring case"},
+	{"labeler-did", "BG_LABELER_DID", false, "DID of the labeler account issuing the labels of lb:emit and lb:negate"},
+	{"labeler-key", "BG_LABELER_KEY", false, "hex P-256 private key signing the labels, created with lb:generateKey"},
+	{"lang", "BG_LANG", false, "search filters"},
+	{"limit", "BG_LIMIT", false, "page size, defaults to the maximum of each endpoint"},
+	{"list-purpose", "BG_LIST_PURPOSE", false, "purpose of new lists: app.bsky.graph.defs#curatelist (default) or app.bsky.graph.defs#modlist"},
+	{"max-nodes", "BG_MAX_NODES", false, "accounts bs:crawlGraph expands before stopping, default unlimited"},
+	{"max-per-node", "BG_MAX_PER_NODE", false, "accounts bs:crawlGraph fetches per account and direction, default 1000"},
+	{"mcp-write", "BG_MCP_WRITE", true, "true adds the tools that post or change lists to mcp:serve, which is read-only by default"},
+	{"mentions", "BG_MENTIONS", false, "search filters"},
+	{"metrics-addr", "BG_METRICS_ADDR", false, "address such as :9090 on which the long-running targets serve Prometheus metrics at /metrics"},
+	{"migrate-email", "BG_MIGRATE_EMAIL", false, "email of the account on the new PDS, defaults to the email of the current account"},
+	{"migrate-password", "BG_MIGRATE_PASSWORD", false, "password of the account created on the new PDS by bs:migrate"},
+	{"min-degree", "BG_MIN_DEGREE", false, "minimum follows in and out of the accounts bs:graphExport, pg:graphExport, and bs:communities keep"},
+	{"min-size", "BG_MIN_SIZE", false, "smallest cluster bs:communities reports, default 3"},
+	{"mutual", "BG_MUTUAL", true, "true makes bs:communities link only accounts that follow each other"},
+	{"output", "BG_OUTPUT", false, "file written instead of standard output, or an s3://<bucket>/<key> or az://<container>/<blob> URL uploaded as it is written, see [Cloud storage](#cloud-storage)"},
+	{"pg-schema", "BG_PG_SCHEMA", false, "schema of every pg: table, including the migrations, created when missing, so projects can be isolated in one database"},
+	{"pg-table", "BG_PG_TABLE", false, "table of the JSON line import and query targets such as pg:importJsonFile, pg:postsByAuthor, and pg:stats, default bluesky; other tables are created with the columns and indexes of bluesky and their own id sequence"},
+	{"plc-token", "BG_PLC_TOKEN", false, "PLC operation token emailed during bs:migrate, which finishes the migration"},
+	{"profile", "BG_PROFILE", false, "account profile from the config file, see below"},
+	{"progress", "BG_PROGRESS", false, "interval of the progress reports written to stderr by bulk and import targets (default 10s, 0 disables)"},
+	{"query", "BG_QUERY", false, "search query of bs:getPopularFeedGenerators"},
+	{"reasons", "BG_REASONS", false, "comma-separated notification reasons bs:watchNotifications handles, defaults to mention,reply,follow"},
+	{"resume", "BG_RESUME", true, "resume an interrupted paginated or bulk run (same target and arguments) from its saved cursor and stdin line, or js:firehose from its saved sequence number"},
+	{"rules", "BG_RULES", false, "YAML or JSON file of the filter rules of js:subscribe, js:firehose, pg:ingest, pg:syncSearch, and bs:searchPostsBulk, see [Filter rules](#filter-rules)"},
+	{"serve-public", "BG_SERVE_PUBLIC", true, "set to true to let bs:serve listen on a non-loopback address"},
+	{"serve-token", "BG_SERVE_TOKEN", false, "bearer token required by bs:serve; a random token is generated and logged when unset"},
+	{"since", "BG_SINCE", false, "search date range, e.g. 2024-11-01T00:00:00Z"},
+	{"snapshot-dir", "BG_SNAPSHOT_DIR", false, "directory bs:followerDiff writes a timestamped snapshot of the current followers to, for the next comparison"},
+	{"sort", "BG_SORT", false, "search order: latest (default) or top"},
+	{"subject", "BG_SUBJECT", false, "DID whose followers or follows the accounts given to bs:graphExport are, with BG_DIRECTION followers or follows"},
+	{"sync-mode", "BG_SYNC_MODE", false, "bs:syncModeration mode: add (default) only adds blocks and mutes, full also removes those the source account lacks"},
+	{"tags", "BG_TAGS", false, "comma-separated search hashtags, without #"},
+	{"template", "BG_TEMPLATE", false, "Go text/template applied to each item, e.g. {{.handle}} {{.followersCount}} or {{.post.uri}} {{oneline .post.record.text}}"},
+	{"tz", "BG_TZ", false, "IANA time zone of the hour and weekday breakdowns of bs:authorStats and bs:engagementByHour, and of the snapshot times of pg:followerGrowth, default UTC"},
+	{"until", "BG_UNTIL", false, "search date range, e.g. 2024-11-01T00:00:00Z"},
+	{"url", "BG_URL", false, "search filters"},
+	{"webhook", "BG_WEBHOOK", false, "URL bs:watchNotifications posts each new notification to as JSON, retried with the BLUESKY_RETRY_* backoff"},
+	{"write-delay", "BG_WRITE_DELAY", false, "pause between the records created by bulk write targets (default 1s)"},
+}
@@ -0,0 +1,11 @@
+//go:build mage
+// +build mage
+
+package main
+
+// the targets live in the bluegopher package, so they can also be built into the blue-gopher command of
+// cmd/blue-gopher. mage imports its namespaces unprefixed, as bs:getProfile, pg:createSchema, and so on.
+import (
+	// mage:import
+	_ "asw101-bluesky/bluegopher"
+)